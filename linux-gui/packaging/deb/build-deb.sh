@@ -23,7 +23,7 @@ echo "Architecture: ${ARCH}"
 echo ""
 echo "[1/4] Building signaling server..."
 cd "${SIGNALING_SERVER}"
-go build -ldflags="-s -w" -o signaling-server ./cmd/server/
+go build -ldflags="-s -w -X main.Version=${VERSION}" -o signaling-server ./cmd/server/
 echo "      Done"
 
 # Create package structure
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/streamlinux/signaling-server/internal/signaling"
+	"go.uber.org/zap"
+)
+
+func TestHostsCORS(t *testing.T) {
+	hub := signaling.NewHubWithSecurity(zap.NewNop(), time.Minute, signaling.DefaultSecurityConfig())
+	handler := corsMiddleware(http.HandlerFunc(hub.HostsHandler), []string{"app.example"}, "GET", "Content-Type")
+
+	get := func(origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/hosts", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := get("https://evil.example"); w.Code != http.StatusForbidden {
+		t.Fatalf("disallowed origin: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	w := get("https://app.example")
+	if w.Code != http.StatusOK {
+		t.Fatalf("allowed origin: status %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Fatalf("Access-Control-Allow-Origin = %q, want the request origin", got)
+	}
+}
@@ -14,7 +14,13 @@
 package main
 
 import (
+	"compress/flate"
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
@@ -22,13 +28,18 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/streamlinux/signaling-server/internal/discovery"
+	"github.com/streamlinux/signaling-server/internal/federation"
+	"github.com/streamlinux/signaling-server/internal/jwtauth"
 	"github.com/streamlinux/signaling-server/internal/qr"
 	"github.com/streamlinux/signaling-server/internal/signaling"
+	"github.com/streamlinux/signaling-server/internal/turn"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -36,17 +47,116 @@ import (
 
 // Config holds server configuration
 type Config struct {
-	Host           string
-	Port           int
-	TLSCert        string
-	TLSKey         string
-	TokenTTL       time.Duration
-	AllowInsecure  bool
-	EnableQR       bool
-	EnableMDNS     bool
-	RoomTimeout    time.Duration
-	Debug          bool
-	AllowedOrigins []string
+	Host                 string
+	Port                 int
+	InsecurePort         int
+	UnixSocket           string
+	TLSCert              string
+	TLSKey               string
+	TLSAutocert          bool
+	TLSAutocertDir       string
+	TLSAutocertValidity  time.Duration
+	AdvertiseCertFP      bool
+	TokenTTL             time.Duration
+	HostTokenGrace       time.Duration
+	MaxConnLifetime      time.Duration
+	MaxPeers             int
+	MaxConnections       int
+	MaxClientsPerRoom    int
+	MaxRooms             int
+	MaxBroadcast         int
+	BroadcastOverflow    string
+	SendPolicy           string
+	MaxHostsPerRoom      int
+	TokenStore           string
+	DrainGrace           time.Duration
+	MaxGoroutines        int
+	MaxHeapMB            int
+	RequirePairing       bool
+	PairingWindow        time.Duration
+	RequirePIN           bool
+	PINExpiry            time.Duration
+	RateLimitWarnAt      int
+	RateLimitByDevice    bool
+	MaxConnAttemptsPerIP int
+	MaxAuthFailures      int
+	AllowCIDRs           []string
+	DenyCIDRs            []string
+	TrustedProxies       []string
+	MaxPendingAuth       int
+	PairingKeyFile       string
+	PairingBundleTTL     time.Duration
+	TokenChurn           int
+	RejectChurn          bool
+	ReconnectLimit       int
+	ReconnectWindow      time.Duration
+	FieldLimits          signaling.FieldLimits
+	ClientPeerIDs        bool
+	HandshakeRegister    bool
+	PeerIDConflict       string
+	AllowInsecure        bool
+	EnableQR             bool
+	NetworkPoll          time.Duration
+	QRTokenTTL           time.Duration
+	EnableMDNS           bool
+	MDNSInterface        string
+	ServerName           string
+	IPFamily             string
+	RoomTimeout          time.Duration
+	RoomInfoRefresh      time.Duration
+	StickyHistory        int
+	UniqueNames          bool
+	ResumeGrace          time.Duration
+	PeerTimeout          time.Duration
+	CandidateBatchWindow time.Duration
+	LegacyBroadcast      bool
+	HostLeavePolicy      string
+	HostReconnectGrace   time.Duration
+	EmptyRoomGrace       time.Duration
+	AllowedMedia         string
+	RequiredMedia        string
+	MediaFailClosed      bool
+	STUN                 string
+	TURN                 string
+	TURNUser             string
+	TURNCred             string
+	TURNSecret           string
+	TURNTTL              time.Duration
+	TURNRelay            bool
+	TURNRelayPort        int
+	TURNRelayPorts       string
+	TURNRelayIP          string
+	TURNRelayRealm       string
+	TURNRelayDenied      []string
+	JWTSecret            string
+	JWTPublicKey         string
+	JWTAudience          string
+	JWTIssuer            string
+	Debug                bool
+	LogMessages          bool
+	AllowedOrigins       []string
+	CORSMethods          string
+	CORSHeaders          string
+
+	Compression          bool
+	CompressionThreshold int
+	CompressionLevel     int
+	MaxMessageSize       int
+	PingInterval         time.Duration
+	PongTimeout          time.Duration
+	ReadBufferSize       int
+	WriteBufferSize      int
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	FederationPeers    []string
+	FederationURL      string
+	FederationSecret   string
+	FederationInterval time.Duration
+
+	AdminToken string
 }
 
 func main() {
@@ -57,33 +167,255 @@ func main() {
 	logger := initLogger(config.Debug)
 	defer logger.Sync()
 
+	build := buildInfo()
+	logger.Info("StreamLinux signaling server",
+		zap.String("version", build.Version),
+		zap.String("commit", build.Commit),
+		zap.String("commit_time", build.CommitTime),
+		zap.String("go_version", build.GoVersion))
+
 	// Create signaling hub
-	hub := signaling.NewHub(logger, config.RoomTimeout)
+	security := signaling.DefaultSecurityConfig()
+	security.HostTokenGrace = config.HostTokenGrace
+	security.MaxPeers = config.MaxPeers
+	security.MaxConnections = config.MaxConnections
+	security.MaxConnectionLifetime = config.MaxConnLifetime
+	security.MaxClientsPerRoom = config.MaxClientsPerRoom
+	security.MaxRooms = config.MaxRooms
+	security.RequireHandshakeRegistration = config.HandshakeRegister
+	security.MaxGoroutines = config.MaxGoroutines
+	security.MaxHeapBytes = uint64(config.MaxHeapMB) << 20
+	security.RequirePairingMode = config.RequirePairing
+	security.PairingWindow = config.PairingWindow
+	security.RequirePIN = config.RequirePIN
+	security.PINExpiry = config.PINExpiry
+	security.RateLimitWarnAt = config.RateLimitWarnAt
+	security.RateLimitByDevice = config.RateLimitByDevice
+	security.MaxConnAttemptsPerIP = config.MaxConnAttemptsPerIP
+	security.MaxAuthFailures = config.MaxAuthFailures
+	if config.CompressionLevel < flate.BestSpeed || config.CompressionLevel > flate.BestCompression {
+		logger.Fatal("Invalid -compression-level", zap.Int("level", config.CompressionLevel))
+	}
+	if err := signaling.ValidateKeepalive(config.PingInterval, config.PongTimeout, config.PeerTimeout); err != nil {
+		logger.Fatal("Invalid -ping-interval, -pong-timeout or -peer-timeout", zap.Error(err))
+	}
+	ipFilter, err := parseIPFilter(config)
+	if err != nil {
+		logger.Fatal("Invalid address filter", zap.Error(err))
+	}
+	security.IPFilter = ipFilter
+	if security.TrustedProxies, err = signaling.ParseCIDRs(config.TrustedProxies); err != nil {
+		logger.Fatal("Invalid -trusted-proxies", zap.Error(err))
+	}
+	security.MaxPendingAuth = config.MaxPendingAuth
+	security.AllowClientPeerIDs = config.ClientPeerIDs
+	security.PeerIDConflict = signaling.PeerIDConflictPolicy(config.PeerIDConflict)
+	security.TokenChurnThreshold = config.TokenChurn
+	security.RejectTokenChurn = config.RejectChurn
+	security.ReconnectLimit = config.ReconnectLimit
+	security.ReconnectWindow = config.ReconnectWindow
+	security.FieldLimits = config.FieldLimits
+	hubConfig := signaling.DefaultHubConfig()
+	hubConfig.StickyHistorySize = config.StickyHistory
+	hubConfig.CandidateBatchWindow = config.CandidateBatchWindow
+	hubConfig.UniqueRoomNames = config.UniqueNames
+	hubConfig.ResumeGrace = config.ResumeGrace
+	hubConfig.PeerTimeout = config.PeerTimeout
+	hubConfig.BroadcastUnknownTypes = config.LegacyBroadcast
+	hubConfig.HostLeavePolicy = signaling.HostLeavePolicy(config.HostLeavePolicy)
+	hubConfig.HostReconnectGrace = config.HostReconnectGrace
+	hubConfig.EmptyRoomGrace = config.EmptyRoomGrace
+	hubConfig.RoomInfoRefresh = config.RoomInfoRefresh
+	hubConfig.MaxBroadcastRecipients = config.MaxBroadcast
+	hubConfig.BroadcastOverflow = signaling.BroadcastOverflow(config.BroadcastOverflow)
+	hubConfig.SendPolicy = signaling.SendPolicy(config.SendPolicy)
+	hubConfig.MaxHostsPerRoom = config.MaxHostsPerRoom
+	hubConfig.LogMessages = config.LogMessages
+	if config.LogMessages && !config.Debug {
+		logger.Warn("-log-messages has no effect without -debug")
+	}
+	if config.TokenStore != "" {
+		hubConfig.TokenStore = signaling.NewFileTokenStore(config.TokenStore)
+	}
+	hubConfig.MediaPolicy = signaling.MediaPolicy{
+		Allowed:    parseList(config.AllowedMedia),
+		Required:   parseList(config.RequiredMedia),
+		FailClosed: config.MediaFailClosed,
+	}
+	hubConfig.ICE = signaling.ICEConfig{
+		STUN:           parseList(config.STUN),
+		TURN:           parseList(config.TURN),
+		TURNUser:       config.TURNUser,
+		TURNCredential: config.TURNCred,
+		TURNSecret:     config.TURNSecret,
+		TURNTTL:        config.TURNTTL,
+	}
+
+	// Built-in TURN relay for peers that can't connect directly
+	var relay *turn.Relay
+	if config.TURNRelay {
+		var url, secret string
+		relay, url, secret, err = newRelay(config, logger)
+		if err != nil {
+			logger.Fatal("Failed to start TURN relay", zap.Error(err))
+		}
+		hubConfig.ICE.Relay = []string{url}
+		hubConfig.ICE.RelaySecret = secret
+		relay.Start()
+	}
+	hub := signaling.NewHubWithConfig(logger, config.RoomTimeout, security, hubConfig)
 	signaling.SetAllowedOrigins(config.AllowedOrigins)
 
+	if config.PairingKeyFile != "" {
+		key, err := os.ReadFile(config.PairingKeyFile)
+		if err != nil || len(strings.TrimSpace(string(key))) == 0 {
+			logger.Fatal("Failed to read pairing key", zap.String("path", config.PairingKeyFile), zap.Error(err))
+		}
+		hub.SetPairingKey([]byte(strings.TrimSpace(string(key))))
+	}
+
+	jwtValidator, err := newJWTValidator(config)
+	if err != nil {
+		logger.Fatal("Invalid JWT configuration", zap.Error(err))
+	}
+	if jwtValidator != nil {
+		// Tokens registered with the hub, e.g. by hosts, keep working
+		hub.SetTokenValidator(signaling.TokenValidators(hub.DefaultTokenValidator(), jwtValidator))
+		logger.Info("Accepting JWT client tokens", zap.String("audience", config.JWTAudience))
+	}
+
+	family, err := discovery.ParseAddressFamily(config.IPFamily)
+	if err != nil {
+		logger.Fatal("Invalid -ip-family", zap.Error(err))
+	}
+
+	// First run without a certificate - generate a self-signed one and keep
+	// it, so clients that pinned its fingerprint still trust it after a
+	// restart
+	if config.TLSAutocert && config.TLSCert == "" && config.TLSKey == "" {
+		dir := config.TLSAutocertDir
+		if dir == "" {
+			if cfgDir, err := os.UserConfigDir(); err == nil {
+				dir = filepath.Join(cfgDir, "streamlinux")
+			} else {
+				dir = "."
+			}
+		}
+		names := []string{"localhost"}
+		if hostname, err := os.Hostname(); err == nil && hostname != "" {
+			names = append(names, hostname, hostname+".local")
+		}
+		certFile, keyFile, created, err := signaling.EnsureSelfSigned(dir, names,
+			discovery.LocalIPs(family, true), config.TLSAutocertValidity)
+		if err != nil {
+			logger.Fatal("Failed to set up self-signed certificate", zap.Error(err))
+		}
+		if created {
+			logger.Info("Generated self-signed certificate", zap.String("cert", certFile))
+		}
+		config.TLSCert, config.TLSKey = certFile, keyFile
+	}
+
+	// Load the certificate up front so the fingerprint we advertise is the
+	// one actually served. Renewed files are picked up on the next
+	// handshake, or immediately on SIGHUP.
+	tlsConfig := signaling.TLSConfig()
+	var certFingerprint string
+	var certReloader *signaling.CertReloader
+	if config.TLSCert != "" && config.TLSKey != "" {
+		var err error
+		certReloader, err = signaling.NewCertReloader(config.TLSCert, config.TLSKey, logger)
+		if err != nil {
+			logger.Fatal("Failed to load TLS certificate", zap.Error(err))
+		}
+		tlsConfig.GetCertificate = certReloader.GetCertificate
+		certFingerprint = certReloader.Fingerprint()
+		logger.Info("TLS certificate loaded", zap.String("sha256", certFingerprint))
+	}
+	advertisedFP := ""
+	if config.AdvertiseCertFP {
+		advertisedFP = certFingerprint
+	}
+
 	// Create HTTP server and routes
 	mux := http.NewServeMux()
 
 	// WebSocket signaling endpoint
 	wsHandler := func(w http.ResponseWriter, r *http.Request) {
-		if !config.AllowInsecure && r.TLS == nil {
+		// The dedicated -insecure-port listener is an explicit opt-in to ws,
+		// and a Unix socket is local by definition
+		local := r.Context().Value(unixListenerKey{}) != nil
+		allowInsecure := config.AllowInsecure || local || r.Context().Value(insecureListenerKey{}) != nil
+		if !allowInsecure && r.TLS == nil {
 			http.Error(w, "TLS required", http.StatusUpgradeRequired)
 			return
 		}
+		// HandleWebSocket lifts the server's Read/WriteTimeout for the session
 		signaling.HandleWebSocket(hub, w, r, logger, signaling.WebSocketSecurity{
-			RequireTLS:      !config.AllowInsecure,
-			DefaultTokenTTL: config.TokenTTL,
+			RequireTLS:           !allowInsecure,
+			DefaultTokenTTL:      config.TokenTTL,
+			EnableCompression:    config.Compression,
+			CompressionThreshold: config.CompressionThreshold,
+			CompressionLevel:     config.CompressionLevel,
+			MaxMessageSize:       config.MaxMessageSize,
+			PingInterval:         config.PingInterval,
+			PongTimeout:          config.PongTimeout,
+			ReadBufferSize:       config.ReadBufferSize,
+			WriteBufferSize:      config.WriteBufferSize,
+			Local:                local,
 		})
 	}
 	mux.HandleFunc("/ws", wsHandler)
 	mux.HandleFunc("/ws/signaling", wsHandler) // Alternative path for Android client
 
-	// Health check endpoint
+	// Health check endpoint - also identifies the server to LAN scanners.
+	// It is unauthenticated, so remote callers get -server-name, or the
+	// name mDNS already advertises, rather than the machine's hostname.
+	healthResp := discovery.HealthResponse{
+		Status:   "ok",
+		Service:  discovery.ServiceName,
+		Hostname: config.ServerName,
+		Port:     config.Port,
+	}
+	if healthResp.Hostname == "" && config.EnableMDNS && config.UnixSocket == "" {
+		healthResp.Hostname = discovery.InstanceHostname()
+	}
+	health, _ := json.Marshal(healthResp)
+	healthResp.Hostname, _ = os.Hostname()
+	localHealth, _ := json.Marshal(healthResp)
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		body := health
+		if r.Context().Value(unixListenerKey{}) != nil || hub.IsLocalRequest(r) {
+			body = localHealth
+		}
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok"}`))
+		w.Write(body)
+	})
+
+	// Orchestration probes - /healthz answers while the process is up,
+	// /readyz only while the hub takes new clients, so a draining instance
+	// drops out of the load balancer before it stops
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", hub.ReadyHandler)
+
+	// Server clock endpoint - unauthenticated and allocation-light so the
+	// response time is dominated by the network. Clients estimate their
+	// clock offset as server_time_ms - (t_send + t_recv)/2, using the
+	// smallest round trip of a few samples, and apply it to token and
+	// handshake timeouts.
+	mux.HandleFunc("/time", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprintf(w, `{"server_time_ms":%d}`, time.Now().UnixMilli())
 	})
 
+	// Build info endpoint - unauthenticated so users can report their build
+	mux.HandleFunc("/api/version", handleVersion)
+
 	// Room info endpoint
 	mux.HandleFunc("/rooms", func(w http.ResponseWriter, r *http.Request) {
 		if !requireToken(hub, w, r) {
@@ -92,6 +424,14 @@ func main() {
 		hub.HandleRoomInfo(w, r)
 	})
 
+	// Room members endpoint - /rooms plus each peer's ID, name and traffic
+	mux.HandleFunc("/admin/rooms", func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(config.AdminToken, w, r) {
+			return
+		}
+		hub.AdminRoomInfoHandler(w, r)
+	})
+
 	// Active hosts endpoint - allows clients to discover active streaming hosts
 	mux.HandleFunc("/hosts", func(w http.ResponseWriter, r *http.Request) {
 		if !requireToken(hub, w, r) {
@@ -106,35 +446,182 @@ func main() {
 		hub.HostsHandler(w, r)
 	}) // Alternative path
 
+	// Stats endpoint
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		if !requireToken(hub, w, r) {
+			return
+		}
+		hub.StatsHandler(w, r)
+	})
+
+	// Hub event stream - server-sent events for dashboards. Events name
+	// every peer in every room, so signaling tokens don't unlock it.
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(config.AdminToken, w, r) {
+			return
+		}
+		hub.EventsHandler(w, r)
+	})
+
+	// Prometheus metrics endpoint
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if !requireToken(hub, w, r) {
+			return
+		}
+		hub.MetricsHandler(w, r)
+	})
+
+	// Pairing mode endpoint - GET status, POST {"enabled":true,"duration_seconds":120}
+	mux.HandleFunc("/admin/pairing", func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(config.AdminToken, w, r) {
+			return
+		}
+		hub.PairingHandler(w, r)
+	})
+
+	// Client token endpoint - POST {"room":"...","ttl_seconds":300} with a
+	// host token mints a short-lived token for a join link
+	mux.HandleFunc("/api/tokens", func(w http.ResponseWriter, r *http.Request) {
+		if !requireToken(hub, w, r) {
+			return
+		}
+		hub.TokensHandler(w, r)
+	})
+
+	// Topology endpoint - node/edge graph of peers and rooms for diagnostics
+	mux.HandleFunc("/admin/topology", func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(config.AdminToken, w, r) {
+			return
+		}
+		hub.TopologyHandler(w, r)
+	})
+
+	// Token administration - GET lists token IDs, DELETE
+	// /admin/tokens/{id} revokes one and disconnects its peers
+	adminTokens := func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(config.AdminToken, w, r) {
+			return
+		}
+		hub.AdminTokensHandler(w, r)
+	}
+	mux.HandleFunc("/admin/tokens", adminTokens)
+	mux.HandleFunc("/admin/tokens/", adminTokens)
+
+	// Disconnect endpoint - POST {"peer_id":"..."} drops a stuck peer
+	mux.HandleFunc("/api/disconnect", func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(config.AdminToken, w, r) {
+			return
+		}
+		hub.DisconnectHandler(w, r)
+	})
+	if config.AdminToken == "" {
+		logger.Info("Admin endpoints disabled; set -admin-token to enable them")
+	}
+
+	// Federation - share active hosts with other signaling instances
+	var federator *federation.Federator
+	if len(config.FederationPeers) > 0 {
+		var err error
+		federator, err = federation.NewFederator(hub, federation.Config{
+			SelfURL:  config.FederationURL,
+			Peers:    config.FederationPeers,
+			Secret:   config.FederationSecret,
+			Interval: config.FederationInterval,
+		}, logger)
+		if err != nil {
+			logger.Fatal("Invalid federation configuration", zap.Error(err))
+		}
+		hub.SetRemoteHosts(federator)
+		mux.HandleFunc(federation.HostsPath, federator.HandleHosts)
+	}
+
 	// QR code endpoint
+	var qrHandler *qr.Handler
 	if config.EnableQR {
-		qrHandler := qr.NewHandler(config.Host, config.Port, config.TLSCert != "")
+		qrHandler = qr.NewHandler(config.Host, config.Port, config.TLSCert != "")
+		qrHandler.SetAddressFamily(family)
 		mux.HandleFunc("/qr", qrHandler.HandleQR)
 		mux.HandleFunc("/qr/image", qrHandler.HandleQRImage)
+		mux.HandleFunc("/qr/stream", qrHandler.HandleQRStream)
+		mux.HandleFunc("/qr/svg", qrHandler.HandleQRSVG)
+		mux.HandleFunc("/qr/datauri", qrHandler.HandleQRDataURI)
+		if config.TLSCert != "" && config.InsecurePort > 0 {
+			qrHandler.SetInsecurePort(config.InsecurePort)
+		}
+		if advertisedFP != "" {
+			qrHandler.SetCertFingerprint(advertisedFP)
+		}
+		if config.PairingKeyFile != "" {
+			qrHandler.SetTokenMinter(hub, config.PairingBundleTTL, hub.IsLocalRequest)
+			mux.HandleFunc("/qr/offline", qrHandler.HandleQROffline)
+		}
+		if security.RequireToken && config.QRTokenTTL > 0 {
+			qrHandler.SetTokenIssuer(hub, config.QRTokenTTL, hub.IsLocalRequest)
+		}
+		if config.NetworkPoll > 0 {
+			qrHandler.Watch(config.NetworkPoll, logger)
+		}
 	}
 
 	// Create HTTP server
 	addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
 	server := &http.Server{
 		Addr:         addr,
-		Handler:      corsMiddleware(mux, config.AllowedOrigins),
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
-		TLSConfig:    signaling.TLSConfig(),
+		Handler:      hub.FilterIPs(corsMiddleware(mux, config.AllowedOrigins, config.CORSMethods, config.CORSHeaders)),
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+		IdleTimeout:  config.IdleTimeout,
+		TLSConfig:    tlsConfig,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			if _, ok := c.(*net.UnixConn); ok {
+				return context.WithValue(ctx, unixListenerKey{}, true)
+			}
+			return ctx
+		},
+	}
+
+	// Optional plain ws listener next to wss, for clients that can't
+	// validate the certificate
+	var insecureServer *http.Server
+	if config.TLSCert != "" && config.InsecurePort > 0 && config.UnixSocket == "" {
+		insecureServer = &http.Server{
+			Addr: fmt.Sprintf("%s:%d", config.Host, config.InsecurePort),
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := context.WithValue(r.Context(), insecureListenerKey{}, true)
+				server.Handler.ServeHTTP(w, r.WithContext(ctx))
+			}),
+			ReadTimeout:  config.ReadTimeout,
+			WriteTimeout: config.WriteTimeout,
+			IdleTimeout:  config.IdleTimeout,
+		}
 	}
 
 	// Start hub
 	go hub.Run()
 
+	if federator != nil {
+		federator.Start()
+	}
+
 	// Start mDNS discovery if enabled
 	var mdnsServer *discovery.MDNSServer
-	if config.EnableMDNS {
+	if config.EnableMDNS && config.UnixSocket != "" {
+		logger.Info("mDNS discovery disabled, serving on a Unix socket only")
+	} else if config.EnableMDNS {
 		var err error
 		mdnsServer, err = discovery.NewMDNSServer(config.Port, logger)
 		if err != nil {
 			logger.Warn("Failed to start mDNS server", zap.Error(err))
 		} else {
+			if advertisedFP != "" {
+				mdnsServer.SetCertFingerprint(advertisedFP)
+			}
+			mdnsServer.SetAddressFamily(family)
+			if config.MDNSInterface != "" {
+				if err := mdnsServer.SetInterface(config.MDNSInterface); err != nil {
+					logger.Fatal("Invalid -mdns-interface", zap.Error(err))
+				}
+			}
 			go mdnsServer.Start()
 			logger.Info("mDNS discovery enabled", zap.String("service", "_streamlinux._tcp"))
 		}
@@ -142,19 +629,28 @@ func main() {
 
 	// Start server
 	go func() {
+		listenAddr := addr
+		if config.UnixSocket != "" {
+			listenAddr = "unix:" + config.UnixSocket
+		}
 		logger.Info("Starting signaling server",
-			zap.String("address", addr),
-			zap.Bool("tls", config.TLSCert != ""),
+			zap.String("address", listenAddr),
+			zap.Bool("tls", config.TLSCert != "" && config.UnixSocket == ""),
 			zap.Bool("qr", config.EnableQR),
 			zap.Bool("mdns", config.EnableMDNS))
 
 		var err error
-		if config.TLSCert != "" && config.TLSKey != "" {
-			err = server.ListenAndServeTLS(config.TLSCert, config.TLSKey)
+		if config.UnixSocket != "" {
+			var ln net.Listener
+			if ln, err = listenUnix(config.UnixSocket); err == nil {
+				err = server.Serve(ln) // Local, so plain HTTP even with a certificate
+			}
+		} else if config.TLSCert != "" && config.TLSKey != "" {
+			err = server.ListenAndServeTLS("", "") // Certificate already in TLSConfig
 		} else if config.AllowInsecure {
 			err = server.ListenAndServe()
 		} else {
-			err = fmt.Errorf("tls required: provide -tls-cert and -tls-key or set -allow-insecure true for local USB")
+			err = fmt.Errorf("tls required: provide -tls-cert and -tls-key, use -tls-autocert, or set -allow-insecure true for local USB")
 		}
 
 		if err != nil && err != http.ErrServerClosed {
@@ -162,8 +658,39 @@ func main() {
 		}
 	}()
 
+	if insecureServer != nil {
+		go func() {
+			logger.Info("Starting insecure ws listener", zap.String("address", insecureServer.Addr))
+			if err := insecureServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatal("Insecure listener failed", zap.Error(err))
+			}
+		}()
+	}
+
+	if certReloader != nil {
+		if advertisedFP != "" {
+			certReloader.OnReload(func(fingerprint string) {
+				if qrHandler != nil {
+					qrHandler.SetCertFingerprint(fingerprint)
+				}
+				if mdnsServer != nil {
+					mdnsServer.SetCertFingerprint(fingerprint)
+				}
+			})
+		}
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := certReloader.Reload(); err != nil {
+					logger.Error("TLS certificate reload failed", zap.Error(err))
+				}
+			}
+		}()
+	}
+
 	// Print connection info
-	printConnectionInfo(config, logger)
+	printConnectionInfo(config, family, logger)
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
@@ -172,12 +699,39 @@ func main() {
 
 	logger.Info("Shutting down server...")
 
+	if mdnsServer != nil {
+		mdnsServer.Stop()
+	}
+
+	if relay != nil {
+		relay.Stop()
+	}
+
+	if federator != nil {
+		federator.Stop()
+	}
+
+	// Drain - clients are told to reconnect elsewhere; a second signal
+	// skips the rest of the grace period
+	if config.DrainGrace > 0 {
+		drainCtx, stopDrain := context.WithTimeout(context.Background(), config.DrainGrace)
+		go func() {
+			select {
+			case <-quit:
+				stopDrain()
+			case <-drainCtx.Done():
+			}
+		}()
+		hub.Drain(drainCtx)
+		stopDrain()
+	}
+
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if mdnsServer != nil {
-		mdnsServer.Stop()
+	if qrHandler != nil {
+		qrHandler.Stop() // Also ends open QR streams so Shutdown doesn't wait on them
 	}
 
 	hub.Shutdown()
@@ -186,27 +740,174 @@ func main() {
 		logger.Error("Server shutdown failed", zap.Error(err))
 	}
 
+	if insecureServer != nil {
+		if err := insecureServer.Shutdown(ctx); err != nil {
+			logger.Error("Insecure listener shutdown failed", zap.Error(err))
+		}
+	}
+
 	logger.Info("Server stopped")
 }
 
+// insecureListenerKey marks requests received on the -insecure-port listener
+type insecureListenerKey struct{}
+
+// unixListenerKey marks requests received over the -unix socket
+type unixListenerKey struct{}
+
+// listenUnix listens on a Unix socket at path, replacing a stale socket
+// left by an earlier run. A socket that still accepts connections belongs
+// to a running instance and is left alone. Access is limited to the owner
+// and group, since connections on it skip token checks.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, err
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
 func parseFlags() Config {
 	config := Config{}
 
 	flag.StringVar(&config.Host, "host", "0.0.0.0", "Host to bind to")
 	flag.IntVar(&config.Port, "port", 8080, "Port to listen on")
+	flag.IntVar(&config.InsecurePort, "insecure-port", 0, "Also serve plain ws on this port when TLS is enabled (0 = disabled)")
+	flag.StringVar(&config.UnixSocket, "unix", "", "Serve on this Unix domain socket instead of TCP, e.g. for adb reverse; connections are trusted like localhost")
 	flag.StringVar(&config.TLSCert, "tls-cert", "", "Path to TLS certificate")
 	flag.StringVar(&config.TLSKey, "tls-key", "", "Path to TLS private key")
+	flag.BoolVar(&config.TLSAutocert, "tls-autocert", false, "Without -tls-cert/-tls-key, serve wss with a generated self-signed certificate that is kept on disk")
+	flag.StringVar(&config.TLSAutocertDir, "tls-autocert-dir", "", "Where -tls-autocert keeps its certificate (default: the user config dir's streamlinux folder)")
+	flag.DurationVar(&config.TLSAutocertValidity, "tls-autocert-validity", 90*24*time.Hour, "Validity of a certificate generated by -tls-autocert")
+	flag.BoolVar(&config.AdvertiseCertFP, "advertise-cert-fingerprint", true, "Include the certificate's SHA-256 fingerprint in QR codes and mDNS so clients can pin it")
 	flag.DurationVar(&config.TokenTTL, "token-ttl", 24*time.Hour, "Default token TTL for host registration")
+	flag.DurationVar(&config.HostTokenGrace, "host-token-grace", 30*time.Second, "How long a host's token stays valid after the host disconnects (0 = invalidate immediately)")
+	flag.DurationVar(&config.MaxConnLifetime, "max-connection-lifetime", 0, "Close connections older than this to force re-authentication, e.g. 8h (0 = unlimited)")
+	flag.IntVar(&config.MaxClientsPerRoom, "max-clients-per-room", 8, "Max clients in one room (0 = unlimited)")
+	flag.IntVar(&config.MaxRooms, "max-rooms", 0, "Max concurrent rooms (0 = unlimited)")
+	flag.IntVar(&config.MaxBroadcast, "max-broadcast-recipients", 0, "Max recipients of a single broadcast before -broadcast-overflow applies (0 = unlimited)")
+	flag.StringVar(&config.BroadcastOverflow, "broadcast-overflow", string(signaling.BroadcastChunk), "Handling of broadcasts over -max-broadcast-recipients: chunk or reject")
+	flag.StringVar(&config.SendPolicy, "send-policy", string(signaling.SendDropNewest), "When a peer's send buffer is full: drop-newest, drop-oldest or disconnect")
+	flag.IntVar(&config.MaxHostsPerRoom, "max-hosts-per-room", 1, "Hosts that can join one room, e.g. one per monitor; clients pick one by addressing it")
+	flag.StringVar(&config.TokenStore, "token-store", "", "File to persist registered tokens in across restarts (empty = in memory only)")
+	flag.DurationVar(&config.DrainGrace, "drain-grace", 30*time.Second, "On shutdown, refuse new connections and wait this long for rooms to empty (0 = close immediately)")
+	flag.IntVar(&config.MaxPeers, "max-peers", 0, "Reject new connections with 503 above this many peers (0 = unlimited)")
+	flag.IntVar(&config.MaxConnections, "max-connections", 0, "Reject new WebSocket connections with 503 above this many open sockets, registered or not (0 = unlimited)")
+	flag.IntVar(&config.MaxGoroutines, "max-goroutines", 0, "Reject new connections with 503 above this many goroutines (0 = unlimited)")
+	flag.IntVar(&config.MaxHeapMB, "max-heap-mb", 0, "Reject new connections with 503 above this much heap in MiB (0 = unlimited)")
+	flag.BoolVar(&config.RequirePairing, "require-pairing-mode", false, "Only accept new client devices while pairing mode is enabled by a host")
+	flag.DurationVar(&config.PairingWindow, "pairing-window", 2*time.Minute, "Maximum duration of a pairing mode window")
+	flag.BoolVar(&config.RequirePIN, "require-pin", true, "Hold remote clients until a host verifies the PIN shown on the client; -require-pin=false admits them on their token alone")
+	flag.DurationVar(&config.PINExpiry, "pin-expiry", 2*time.Minute, "How long a client may wait for its PIN to be verified")
+	flag.IntVar(&config.RateLimitWarnAt, "rate-limit-warn-at", 1, "Send X-RateLimit-Remaining once this many connection attempts remain (0 = never)")
+	flag.BoolVar(&config.RateLimitByDevice, "rate-limit-by-device", true, "Rate limit connection attempts per device_id or token instead of per address")
+	flag.IntVar(&config.MaxConnAttemptsPerIP, "max-conn-attempts-per-ip", 60, "Connection attempts per address per window when limiting by device (0 = no ceiling)")
+	flag.IntVar(&config.MaxAuthFailures, "max-auth-failures", 5, "Invalid token attempts per address per window before refusing (0 = unlimited)")
+	flag.IntVar(&config.MaxPendingAuth, "max-pending-auth", 64, "Max concurrent connections awaiting PIN verification (0 = unlimited)")
+	flag.IntVar(&config.TokenChurn, "token-churn-threshold", 5, "Flag devices that use more than this many distinct tokens within 10 minutes (0 = disabled)")
+	flag.BoolVar(&config.RejectChurn, "reject-token-churn", false, "Reject connections from devices flagged for token churn")
+	flag.IntVar(&config.ReconnectLimit, "reconnect-limit", 0, "Connections per device_id and token within -reconnect-window before escalating backoff, e.g. 10 (0 = disabled)")
+	flag.DurationVar(&config.ReconnectWindow, "reconnect-window", time.Minute, "Window for counting a device's reconnects")
+	limits := signaling.DefaultFieldLimits()
+	flag.IntVar(&config.FieldLimits.Name, "max-name-len", limits.Name, "Max length of a message name field in bytes")
+	flag.IntVar(&config.FieldLimits.Room, "max-room-len", limits.Room, "Max length of a message room field in bytes")
+	flag.IntVar(&config.FieldLimits.Candidate, "max-candidate-len", limits.Candidate, "Max length of an ICE candidate field in bytes")
+	flag.IntVar(&config.FieldLimits.SDPMid, "max-sdpmid-len", limits.SDPMid, "Max length of an sdpMid field in bytes")
+	flag.IntVar(&config.FieldLimits.PeerID, "max-peerid-len", limits.PeerID, "Max length of peer ID fields in bytes")
+	flag.IntVar(&config.FieldLimits.Password, "max-password-len", limits.Password, "Max length of room passwords in bytes")
+	flag.StringVar(&config.PairingKeyFile, "pairing-key-file", "", "File with the shared key for self-contained offline pairing codes, served at /qr/offline to local requests")
+	flag.DurationVar(&config.PairingBundleTTL, "pairing-bundle-ttl", 10*time.Minute, "Validity of offline pairing codes")
+	flag.DurationVar(&config.QRTokenTTL, "qr-token-ttl", 10*time.Minute, "Validity of the client token embedded in QR codes served to localhost (0 to disable)")
+	flag.BoolVar(&config.HandshakeRegister, "require-handshake-register", false, "Require role/name registration in the WebSocket handshake (?role=&name=&tags= or X-Peer-* headers)")
+	flag.BoolVar(&config.ClientPeerIDs, "client-peer-ids", false, "Allow clients to propose a sticky peer ID with ?peer_id=")
+	flag.StringVar(&config.PeerIDConflict, "peer-id-conflict", string(signaling.PeerIDReject), "Handling of a proposed peer ID already in use: reject or suffix")
 	flag.BoolVar(&config.AllowInsecure, "allow-insecure", false, "Allow ws (insecure) for USB/local-only")
 	flag.BoolVar(&config.EnableQR, "qr", true, "Enable QR code generation")
+	flag.DurationVar(&config.NetworkPoll, "network-poll", 10*time.Second, "Interval for detecting network address changes for the QR code (0 = disabled)")
 	flag.BoolVar(&config.EnableMDNS, "mdns", true, "Enable mDNS discovery")
+	flag.StringVar(&config.ServerName, "server-name", "", "Name /health reports to LAN scanners (default: the mDNS hostname, when -mdns is on)")
+	flag.StringVar(&config.MDNSInterface, "mdns-interface", "", "Network interface to serve mDNS on (default: reply on the interface each query arrived on)")
+	flag.StringVar(&config.IPFamily, "ip-family", "any", "Address family for discovery and advertised addresses: any, ipv4 or ipv6")
 	flag.DurationVar(&config.RoomTimeout, "room-timeout", 5*time.Minute, "Room inactivity timeout")
+	flag.DurationVar(&config.RoomInfoRefresh, "room-info-refresh", time.Second, "How often the /rooms listing is refreshed (0 = build it per request)")
+	flag.IntVar(&config.StickyHistory, "sticky-history", 16, "Max sticky host messages replayed to clients joining a room (0 = disabled)")
+	flag.StringVar(&config.STUN, "stun", "", "Comma-separated STUN URLs sent to clients, e.g. stun:stun.example.com:3478")
+	flag.StringVar(&config.TURN, "turn", "", "Comma-separated TURN URLs sent to clients")
+	flag.StringVar(&config.TURNUser, "turn-user", "", "Static TURN username")
+	flag.StringVar(&config.TURNCred, "turn-cred", "", "Static TURN credential")
+	flag.StringVar(&config.TURNSecret, "turn-secret", "", "Shared TURN REST secret; mints time-limited per-connection credentials instead of -turn-user/-turn-cred")
+	flag.DurationVar(&config.TURNTTL, "turn-ttl", 24*time.Hour, "Lifetime of minted TURN credentials")
+	flag.BoolVar(&config.TURNRelay, "turn-relay", false, "Run a built-in TURN relay over UDP and advertise it to clients")
+	flag.IntVar(&config.TURNRelayPort, "turn-relay-port", 3478, "UDP port of the built-in TURN relay")
+	flag.StringVar(&config.TURNRelayPorts, "turn-relay-ports", "49160-49200", "UDP port range for relayed connections, e.g. 49160-49200")
+	flag.StringVar(&config.TURNRelayIP, "turn-relay-ip", "", "Address advertised for the built-in relay, e.g. the public IP forwarded to this host (default: primary LAN address)")
+	flag.StringVar(&config.TURNRelayRealm, "turn-relay-realm", "streamlinux", "TURN realm of the built-in relay")
+	turnRelayDenied := flag.String("turn-relay-denied-peers", "", "Comma-separated CIDR ranges the built-in relay won't relay to, on top of loopback and this machine's own addresses")
+	flag.StringVar(&config.JWTSecret, "jwt-secret", "", "Accept HS256 JWTs signed with this secret as client tokens")
+	flag.StringVar(&config.JWTPublicKey, "jwt-public-key", "", "Accept RS256/ES256 JWTs verified with this PEM public key or certificate as client tokens")
+	flag.StringVar(&config.JWTAudience, "jwt-audience", "", "Required aud claim of JWT client tokens; must be set with -jwt-secret or -jwt-public-key")
+	flag.StringVar(&config.JWTIssuer, "jwt-issuer", "", "Required iss claim of JWT client tokens")
+	flag.StringVar(&config.AllowedMedia, "allowed-media", "", "Comma-separated SDP media types offers may use, e.g. video,audio (empty = no check)")
+	flag.StringVar(&config.RequiredMedia, "required-media", "", "Comma-separated SDP media types every offer must contain")
+	flag.BoolVar(&config.MediaFailClosed, "media-policy-fail-closed", false, "Reject offers whose SDP can't be parsed when a media policy is set")
+	flag.StringVar(&config.HostLeavePolicy, "host-leave-policy", string(signaling.HostLeaveKeepWaiting), "What happens to clients when the host leaves: keep-waiting, disconnect-clients or promote-client")
+	flag.DurationVar(&config.EmptyRoomGrace, "empty-room-grace", 2*time.Minute, "Keep a room this long after its last member leaves so quick reconnects rejoin it (0 = remove at next cleanup)")
+	flag.DurationVar(&config.HostReconnectGrace, "host-reconnect-grace", 0, "With keep-waiting, disconnect clients if the host hasn't rejoined within this long (0 = wait for room timeout)")
+	flag.BoolVar(&config.LegacyBroadcast, "broadcast-unknown-types", false, "Broadcast messages of unknown type to the room instead of rejecting them (legacy behavior)")
+	flag.DurationVar(&config.ResumeGrace, "resume-grace", 30*time.Second, "How long a dropped peer can reconnect with its resume token and keep its identity (0 = disabled)")
+	flag.DurationVar(&config.PeerTimeout, "peer-timeout", 90*time.Second, "Disconnect peers that haven't answered a ping for this long (0 = never); must exceed -ping-interval")
+	flag.DurationVar(&config.CandidateBatchWindow, "candidate-batch-window", 0, "Coalesce ICE candidates arriving within this window into one batch for peers that accept batches (0 = disabled)")
+	flag.BoolVar(&config.UniqueNames, "unique-room-names", false, "Suffix duplicate peer names within a room, e.g. \"TV (2)\"")
 	flag.BoolVar(&config.Debug, "debug", false, "Enable debug logging")
-	allowedOrigins := flag.String("allowed-origins", "localhost,127.0.0.1", "Comma-separated list of allowed Origin hosts (host or host:port)")
+	flag.BoolVar(&config.LogMessages, "log-messages", false, "Log routed message bodies, redacted and truncated, at debug level")
+	flag.BoolVar(&config.Compression, "ws-compression", false, "Negotiate permessage-deflate on WebSocket connections")
+	flag.IntVar(&config.CompressionThreshold, "compression-threshold", 512, "Messages smaller than this many bytes are sent uncompressed")
+	flag.IntVar(&config.CompressionLevel, "compression-level", flate.BestSpeed, "Flate level for compressed messages, 1 (least CPU) to 9 (smallest)")
+	flag.IntVar(&config.MaxMessageSize, "max-message-size", signaling.DefaultMaxMessageSize, "Largest WebSocket message accepted from a peer, in bytes")
+	flag.DurationVar(&config.PingInterval, "ping-interval", signaling.DefaultPingInterval, "How often to ping WebSocket peers")
+	flag.DurationVar(&config.PongTimeout, "pong-timeout", signaling.DefaultPongTimeout, "Drop a WebSocket peer after this long without a pong; must exceed -ping-interval")
+	flag.IntVar(&config.ReadBufferSize, "ws-read-buffer", 1024, "WebSocket read buffer size in bytes")
+	flag.IntVar(&config.WriteBufferSize, "ws-write-buffer", 1024, "WebSocket write buffer size in bytes")
+	flag.DurationVar(&config.ReadTimeout, "read-timeout", 15*time.Second, "HTTP read timeout (not applied to WebSocket sessions)")
+	flag.DurationVar(&config.WriteTimeout, "write-timeout", 15*time.Second, "HTTP write timeout (not applied to WebSocket sessions)")
+	flag.DurationVar(&config.IdleTimeout, "idle-timeout", 60*time.Second, "HTTP keep-alive idle timeout")
+	federationPeers := flag.String("federation-peers", "", "Comma-separated base URLs of other signaling instances to share hosts with")
+	flag.StringVar(&config.FederationURL, "federation-url", "", "Signaling URL advertised to federation peers for hosts on this instance")
+	flag.StringVar(&config.FederationSecret, "federation-secret", "", "Shared secret used to authenticate federation peers (required with -federation-peers)")
+	flag.DurationVar(&config.FederationInterval, "federation-interval", 15*time.Second, "How often to poll federation peers")
+	flag.StringVar(&config.AdminToken, "admin-token", "", "Credential for the /admin endpoints and /api/disconnect, as a bearer token or basic auth password (empty = admin endpoints disabled)")
+	allowCIDRs := flag.String("allow-cidr", "", "Comma-separated CIDR ranges allowed to connect; all others get 403 (empty = any)")
+	denyCIDRs := flag.String("deny-cidr", "", "Comma-separated CIDR ranges refused with 403, even if allowed")
+	trustedProxies := flag.String("trusted-proxies", "", "Comma-separated reverse proxy CIDRs whose X-Forwarded-For/X-Real-IP gives the client address")
+	allowedOrigins := flag.String("allowed-origins", "localhost,127.0.0.1", "Comma-separated list of allowed Origin hosts (host, host:port or *.domain for any subdomain)")
+	flag.StringVar(&config.CORSMethods, "cors-methods", "GET, POST, OPTIONS", "Access-Control-Allow-Methods sent to allowed origins")
+	flag.StringVar(&config.CORSHeaders, "cors-headers", "Content-Type, Authorization", "Access-Control-Allow-Headers sent to allowed origins")
 
 	flag.Parse()
 
 	config.AllowedOrigins = parseAllowedOrigins(*allowedOrigins)
+	config.FederationPeers = parseList(*federationPeers)
+	config.AllowCIDRs = parseList(*allowCIDRs)
+	config.DenyCIDRs = parseList(*denyCIDRs)
+	config.TrustedProxies = parseList(*trustedProxies)
+	config.TURNRelayDenied = parseList(*turnRelayDenied)
 	return config
 }
 
@@ -228,7 +929,9 @@ func initLogger(debug bool) *zap.Logger {
 	return logger
 }
 
-func corsMiddleware(next http.Handler, allowed []string) http.Handler {
+// corsMiddleware allows requests from the allowed origins, answering
+// preflights with the configured methods and headers
+func corsMiddleware(next http.Handler, allowed []string, methods, headers string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("Origin")
 		if origin != "" {
@@ -241,8 +944,8 @@ func corsMiddleware(next http.Handler, allowed []string) http.Handler {
 			}
 		}
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", methods)
+		w.Header().Set("Access-Control-Allow-Headers", headers)
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
@@ -253,25 +956,23 @@ func corsMiddleware(next http.Handler, allowed []string) http.Handler {
 	})
 }
 
-func printConnectionInfo(config Config, logger *zap.Logger) {
-	// Get local IP addresses
-	addrs, err := net.InterfaceAddrs()
-	if err != nil {
-		return
-	}
-
+func printConnectionInfo(config Config, family discovery.AddressFamily, logger *zap.Logger) {
 	protocol := "ws"
 	if config.TLSCert != "" {
 		protocol = "wss"
 	}
 
+	if config.UnixSocket != "" {
+		logger.Info(fmt.Sprintf("Server listening on Unix socket %s (ws, local only)", config.UnixSocket))
+		logger.Info(fmt.Sprintf("  USB: adb reverse tcp:%d localfilesystem:%s", config.Port, config.UnixSocket))
+		return
+	}
+
 	logger.Info("Server listening on:")
-	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				url := fmt.Sprintf("%s://%s:%d/ws", protocol, ipnet.IP.String(), config.Port)
-				logger.Info("  " + url)
-			}
+	for _, ip := range discovery.LocalIPs(family, false) {
+		logger.Info(fmt.Sprintf("  %s://%s/ws", protocol, net.JoinHostPort(ip, strconv.Itoa(config.Port))))
+		if protocol == "wss" && config.InsecurePort > 0 {
+			logger.Info(fmt.Sprintf("  ws://%s/ws", net.JoinHostPort(ip, strconv.Itoa(config.InsecurePort))))
 		}
 	}
 
@@ -302,6 +1003,113 @@ func parseAllowedOrigins(raw string) []string {
 	return hosts
 }
 
+// parseList splits a comma-separated flag value, dropping empty entries
+func parseList(raw string) []string {
+	parts := strings.Split(raw, ",")
+	items := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			items = append(items, p)
+		}
+	}
+	return items
+}
+
+// newJWTValidator creates a validator for externally issued JWTs, or nil
+// when neither -jwt-secret nor -jwt-public-key is set. -jwt-audience is
+// required with either, so tokens the identity service issues for other
+// services can't be replayed here.
+func newJWTValidator(config Config) (*jwtauth.Validator, error) {
+	jwtConfig := jwtauth.Config{
+		Secret:   []byte(config.JWTSecret),
+		Audience: config.JWTAudience,
+		Issuer:   config.JWTIssuer,
+	}
+	if config.JWTPublicKey != "" {
+		data, err := os.ReadFile(config.JWTPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if jwtConfig.PublicKey, err = jwtauth.ParsePublicKey(data); err != nil {
+			return nil, err
+		}
+	} else if config.JWTSecret == "" {
+		return nil, nil
+	}
+	if config.JWTAudience == "" {
+		return nil, fmt.Errorf("-jwt-audience is required with -jwt-secret or -jwt-public-key")
+	}
+	return jwtauth.New(jwtConfig)
+}
+
+// newRelay creates the built-in TURN relay and returns it with the URL and
+// TURN REST secret to advertise. Without -turn-secret a random secret is
+// used, since only this process mints and checks the credentials.
+func newRelay(config Config, logger *zap.Logger) (*turn.Relay, string, string, error) {
+	lo, hi, ok := strings.Cut(config.TURNRelayPorts, "-")
+	portMin, errMin := strconv.Atoi(strings.TrimSpace(lo))
+	portMax, errMax := strconv.Atoi(strings.TrimSpace(hi))
+	if !ok || errMin != nil || errMax != nil {
+		return nil, "", "", fmt.Errorf("invalid -turn-relay-ports %q", config.TURNRelayPorts)
+	}
+
+	var ip net.IP
+	if config.TURNRelayIP != "" {
+		if ip = net.ParseIP(config.TURNRelayIP); ip == nil {
+			return nil, "", "", fmt.Errorf("invalid -turn-relay-ip %q", config.TURNRelayIP)
+		}
+	} else if addrs := discovery.RankedIPs(discovery.FamilyIPv4, "", false); len(addrs) > 0 {
+		ip = net.ParseIP(addrs[0].IP)
+	} else {
+		return nil, "", "", fmt.Errorf("no address to advertise, set -turn-relay-ip")
+	}
+
+	denied, err := signaling.ParseCIDRs(config.TURNRelayDenied)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("-turn-relay-denied-peers: %w", err)
+	}
+
+	secret := config.TURNSecret
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", "", err
+		}
+		secret = hex.EncodeToString(b)
+	}
+
+	relay, err := turn.NewRelay(turn.Config{
+		ListenAddr:  net.JoinHostPort(config.Host, strconv.Itoa(config.TURNRelayPort)),
+		RelayIP:     ip,
+		PortMin:     portMin,
+		PortMax:     portMax,
+		Realm:       config.TURNRelayRealm,
+		Secret:      secret,
+		DeniedPeers: denied,
+	}, logger)
+	if err != nil {
+		return nil, "", "", err
+	}
+	url := "turn:" + net.JoinHostPort(ip.String(), strconv.Itoa(relay.Port())) + "?transport=udp"
+	return relay, url, secret, nil
+}
+
+// parseIPFilter builds the client address filter, nil if none is set
+func parseIPFilter(config Config) (*signaling.IPFilter, error) {
+	if len(config.AllowCIDRs) == 0 && len(config.DenyCIDRs) == 0 {
+		return nil, nil
+	}
+	var f signaling.IPFilter
+	var err error
+	if f.Allow, err = signaling.ParseCIDRs(config.AllowCIDRs); err != nil {
+		return nil, fmt.Errorf("-allow-cidr: %w", err)
+	}
+	if f.Deny, err = signaling.ParseCIDRs(config.DenyCIDRs); err != nil {
+		return nil, fmt.Errorf("-deny-cidr: %w", err)
+	}
+	return &f, nil
+}
+
 func hostAllowed(origin string, allowed []string) bool {
 	if origin == "" {
 		return true
@@ -311,11 +1119,9 @@ func hostAllowed(origin string, allowed []string) bool {
 		return false
 	}
 	host := strings.ToLower(u.Host)
-	// Remove port for comparison
 	hostWithoutPort := strings.Split(host, ":")[0]
 	for _, a := range allowed {
-		aWithoutPort := strings.Split(a, ":")[0]
-		if host == a || hostWithoutPort == aWithoutPort {
+		if signaling.MatchOrigin(host, a) {
 			return true
 		}
 	}
@@ -337,6 +1143,33 @@ func tokenFromRequest(r *http.Request) string {
 	return r.URL.Query().Get("token")
 }
 
+// requireAdmin checks the admin credential, which is separate from the
+// signaling tokens so that a leaked client or host token can't be used to
+// list or revoke the others. It is accepted as a bearer token or as the
+// password of HTTP basic auth, but never from the query string.
+func requireAdmin(credential string, w http.ResponseWriter, r *http.Request) bool {
+	if credential == "" {
+		http.Error(w, "Admin endpoints disabled", http.StatusForbidden)
+		return false
+	}
+	var got string
+	if _, password, ok := r.BasicAuth(); ok {
+		got = password
+	} else if authz := r.Header.Get("Authorization"); strings.HasPrefix(strings.ToLower(authz), "bearer ") {
+		got = strings.TrimSpace(authz[7:])
+	}
+	if got == "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+		http.Error(w, "Admin credential required", http.StatusUnauthorized)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(got), []byte(credential)) != 1 {
+		http.Error(w, "Invalid admin credential", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
 func requireToken(hub *signaling.Hub, w http.ResponseWriter, r *http.Request) bool {
 	token := tokenFromRequest(r)
 	if token == "" {
@@ -0,0 +1,21 @@
+//go:build linux
+
+package discovery
+
+import (
+	"net"
+	"syscall"
+)
+
+// bindToDevice pins the socket to iface so the kernel can't route the
+// packet out of another interface. Needs CAP_NET_RAW before Linux 5.7.
+func bindToDevice(c syscall.RawConn, iface *net.Interface) error {
+	var serr error
+	err := c.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface.Name)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
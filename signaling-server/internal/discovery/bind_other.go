@@ -0,0 +1,14 @@
+//go:build !linux
+
+package discovery
+
+import (
+	"net"
+	"syscall"
+)
+
+// bindToDevice is a no-op here; the source address chosen by sendTo is
+// what steers the packet
+func bindToDevice(c syscall.RawConn, iface *net.Interface) error {
+	return nil
+}
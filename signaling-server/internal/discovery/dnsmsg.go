@@ -0,0 +1,124 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// DNS constants used by the mDNS responder
+const (
+	dnsTypeA    = 1
+	dnsTypePTR  = 12
+	dnsTypeTXT  = 16
+	dnsTypeAAAA = 28
+	dnsTypeSRV  = 33
+	dnsTypeANY  = 255
+	dnsClassIN  = 1
+
+	dnsHeaderLen = 12
+
+	// Maximum compression pointers followed while decoding one name;
+	// more means a malicious loop
+	maxPointerHops = 16
+)
+
+var errMalformedDNS = errors.New("malformed DNS message")
+
+// dnsQuestion is one entry of a message's question section
+type dnsQuestion struct {
+	Name  string // Lowercased, with trailing dot
+	Type  uint16
+	Class uint16 // Unicast-response bit stripped
+}
+
+// parseQuestions returns the questions of a DNS query. Responses (QR bit
+// set) yield no questions.
+func parseQuestions(msg []byte) ([]dnsQuestion, error) {
+	if len(msg) < dnsHeaderLen {
+		return nil, errMalformedDNS
+	}
+	if msg[2]&0x80 != 0 {
+		return nil, nil // A response, not a query
+	}
+
+	// A question takes at least 5 bytes, so a forged count can't make us
+	// allocate more than the packet could hold
+	qdcount := int(binary.BigEndian.Uint16(msg[4:6]))
+	questions := make([]dnsQuestion, 0, min(qdcount, (len(msg)-dnsHeaderLen)/5))
+	off := dnsHeaderLen
+	for i := 0; i < qdcount; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(msg) {
+			return nil, errMalformedDNS
+		}
+		questions = append(questions, dnsQuestion{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(msg[next : next+2]),
+			Class: binary.BigEndian.Uint16(msg[next+2:next+4]) & 0x7fff,
+		})
+		off = next + 4
+	}
+	return questions, nil
+}
+
+// readName decodes the domain name at off, following compression
+// pointers, and returns it with the offset just past it
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1 // Offset after the name in the original position
+	hops := 0
+
+	for {
+		if off >= len(msg) {
+			return "", 0, errMalformedDNS
+		}
+		length := int(msg[off])
+
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.ToLower(strings.Join(labels, ".")) + ".", next, nil
+
+		case length&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return "", 0, errMalformedDNS
+			}
+			if hops++; hops > maxPointerHops {
+				return "", 0, errMalformedDNS
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:off+2]) & 0x3fff)
+
+		case length&0xc0 != 0:
+			return "", 0, errMalformedDNS // Reserved label types
+
+		default:
+			if off+1+length > len(msg) {
+				return "", 0, errMalformedDNS
+			}
+			labels = append(labels, string(msg[off+1:off+1+length]))
+			off += 1 + length
+		}
+	}
+}
+
+// asksForService reports whether any question asks for PTR (or ANY)
+// records of name
+func asksForService(questions []dnsQuestion, name string) bool {
+	name = strings.ToLower(name)
+	for _, q := range questions {
+		if q.Name == name && (q.Type == dnsTypePTR || q.Type == dnsTypeANY) &&
+			(q.Class == dnsClassIN || q.Class == dnsTypeANY) {
+			return true
+		}
+	}
+	return false
+}
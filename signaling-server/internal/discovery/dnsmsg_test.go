@@ -0,0 +1,100 @@
+package discovery
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// avahiQuery is an Avahi browse for _ssh._tcp and _streamlinux._tcp in one
+// packet, the second name compressed against the first
+var avahiQuery = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	// _ssh._tcp.local. PTR IN
+	0x04, '_', 's', 's', 'h',
+	0x04, '_', 't', 'c', 'p',
+	0x05, 'l', 'o', 'c', 'a', 'l',
+	0x00,
+	0x00, 0x0c, 0x00, 0x01,
+	// _streamlinux + pointer to _tcp.local. at offset 17, PTR IN with the
+	// unicast-response bit
+	0x0c, '_', 's', 't', 'r', 'e', 'a', 'm', 'l', 'i', 'n', 'u', 'x',
+	0xc0, 0x11,
+	0x00, 0x0c, 0x80, 0x01,
+}
+
+func TestParseAvahiQuery(t *testing.T) {
+	questions, err := parseQuestions(avahiQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) != 2 {
+		t.Fatalf("%d questions, want 2", len(questions))
+	}
+	if q := questions[1]; q.Name != "_streamlinux._tcp.local." || q.Type != dnsTypePTR || q.Class != dnsClassIN {
+		t.Fatalf("second question %+v", q)
+	}
+	if !asksForService(questions, serviceName) {
+		t.Fatal("Avahi query for the service not recognized")
+	}
+}
+
+func TestUnrelatedQuery(t *testing.T) {
+	query := append([]byte(nil), avahiQuery[:33]...)
+	query[5] = 1 // Only the _ssh question
+	questions, err := parseQuestions(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asksForService(questions, serviceName) {
+		t.Fatal("_ssh._tcp query taken for the service")
+	}
+
+	// A response mentioning the service isn't a query for it
+	response := append([]byte(nil), avahiQuery...)
+	response[2] = 0x84
+	if questions, _ := parseQuestions(response); asksForService(questions, serviceName) {
+		t.Fatal("response taken for a query")
+	}
+
+	// Nor is the service name occurring inside another label
+	other := []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0,
+		0x0f, 'x', '_', 's', 't', 'r', 'e', 'a', 'm', 'l', 'i', 'n', 'u', 'x', 'y', 'z',
+		0x05, 'l', 'o', 'c', 'a', 'l', 0x00, 0x00, 0x0c, 0x00, 0x01}
+	if questions, _ := parseQuestions(other); asksForService(questions, serviceName) {
+		t.Fatal("substring match on an unrelated name")
+	}
+}
+
+func TestParseQuestionsMalformed(t *testing.T) {
+	header := []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	tests := map[string][]byte{
+		"short header":      header[:6],
+		"missing question":  header,
+		"truncated label":   append(header, 0x05, 'l', 'o'),
+		"truncated type":    append(header, 0x00, 0x00),
+		"pointer loop":      append(header, 0xc0, 0x0c, 0x00, 0x0c, 0x00, 0x01),
+		"reserved label":    append(header, 0x40, 0x00, 0x00, 0x0c, 0x00, 0x01),
+		"dangling pointer":  append(header, 0xc0),
+		"pointer past end":  append(header, 0xc0, 0xff, 0x00, 0x0c, 0x00, 0x01),
+		"count past packet": append([]byte{0, 0, 0, 0, 0xff, 0xff, 0, 0, 0, 0, 0, 0}, 0x00, 0x00, 0x0c, 0x00, 0x01),
+	}
+	for name, msg := range tests {
+		if _, err := parseQuestions(msg); err == nil {
+			t.Errorf("%s: parsed", name)
+		}
+	}
+}
+
+func TestParseQuestionsCapsAllocation(t *testing.T) {
+	// 65535 questions claimed, room for 2
+	msg := []byte{0, 0, 0, 0, 0xff, 0xff, 0, 0, 0, 0, 0, 0}
+	msg = append(msg, strings.Repeat("\x00\x00\x0c\x00\x01", 2)...)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	parseQuestions(msg)
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 4096 {
+		t.Fatalf("%d bytes allocated parsing a forged count", n)
+	}
+}
@@ -0,0 +1,74 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// SetInterface serves mDNS on the named interface only: multicast is
+// joined there and every response goes out of it. Must be called before
+// Start.
+func (s *MDNSServer) SetInterface(name string) error {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return fmt.Errorf("mdns interface %q: %w", name, err)
+	}
+	s.iface = iface
+	return nil
+}
+
+// arrivalInterface works out which of our addresses a query from addr
+// came in on: the zone for IPv6 link-local sources, otherwise the
+// interface whose subnet contains the source
+func (s *MDNSServer) arrivalInterface(addr *net.UDPAddr) *ifaceAddr {
+	if addr.Zone != "" {
+		for _, a := range interfaceAddrs(FamilyIPv6) {
+			if a.iface.Name == addr.Zone && a.ip.IsLinkLocalUnicast() {
+				return &a
+			}
+		}
+	}
+	if a, ok := s.localNetFor(addr.IP); ok {
+		return &a
+	}
+	return nil
+}
+
+// sendTo sends data to addr out of via's interface, using its address as
+// the source. Without via the routing table decides.
+func (s *MDNSServer) sendTo(data []byte, addr *net.UDPAddr, via *ifaceAddr) {
+	var dialer net.Dialer
+	if via != nil {
+		dialer.LocalAddr = &net.UDPAddr{IP: via.ip, Zone: zoneFor(via)}
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			return bindToDevice(c, via.iface)
+		}
+	}
+
+	conn, err := dialer.DialContext(context.Background(), "udp", addr.String())
+	if err != nil && via != nil {
+		// Binding to the device needs privileges on older kernels; the
+		// source address alone still steers most stacks
+		s.logger.Debug("Failed to bind mDNS response to interface",
+			zap.String("interface", via.iface.Name), zap.Error(err))
+		dialer.Control = nil
+		conn, err = dialer.DialContext(context.Background(), "udp", addr.String())
+	}
+	if err != nil {
+		s.logger.Debug("Failed to send mDNS response", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+	conn.Write(data)
+}
+
+func zoneFor(a *ifaceAddr) string {
+	if a.ip.To4() == nil && a.ip.IsLinkLocalUnicast() {
+		return a.iface.Name
+	}
+	return ""
+}
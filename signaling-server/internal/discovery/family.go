@@ -0,0 +1,96 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// AddressFamily selects the IP versions used for discovery and advertised
+// addresses
+type AddressFamily string
+
+const (
+	FamilyAny  AddressFamily = "any"
+	FamilyIPv4 AddressFamily = "ipv4"
+	FamilyIPv6 AddressFamily = "ipv6"
+)
+
+// ParseAddressFamily parses a family name; the empty string means any
+func ParseAddressFamily(s string) (AddressFamily, error) {
+	switch f := AddressFamily(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return FamilyAny, nil
+	case FamilyAny, FamilyIPv4, FamilyIPv6:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown address family %q (want any, ipv4 or ipv6)", s)
+	}
+}
+
+func (f AddressFamily) v4() bool { return f != FamilyIPv6 }
+func (f AddressFamily) v6() bool { return f != FamilyIPv4 }
+
+// ifaceAddr is an address of an up interface
+type ifaceAddr struct {
+	ip    net.IP
+	ipnet *net.IPNet
+	iface *net.Interface
+}
+
+// String formats the address, qualifying IPv6 link-local addresses with
+// their zone since they are ambiguous without it
+func (a ifaceAddr) String() string {
+	if a.ip.To4() == nil && a.ip.IsLinkLocalUnicast() {
+		return a.ip.String() + "%" + a.iface.Name
+	}
+	return a.ip.String()
+}
+
+// interfaceAddrs returns the addresses of up interfaces in family f
+func interfaceAddrs(f AddressFamily) []ifaceAddr {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+
+	var out []ifaceAddr
+	for i := range ifaces {
+		iface := &ifaces[i]
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if ip4 := ipnet.IP.To4(); ip4 != nil {
+				if f.v4() {
+					out = append(out, ifaceAddr{ip: ip4, ipnet: ipnet, iface: iface})
+				}
+			} else if f.v6() {
+				out = append(out, ifaceAddr{ip: ipnet.IP, ipnet: ipnet, iface: iface})
+			}
+		}
+	}
+	return out
+}
+
+// LocalIPs returns the host's addresses in family f for advertising to
+// other devices. IPv6 link-local addresses are left out: their zone names
+// one of our interfaces, so a remote client can't use them as given.
+func LocalIPs(f AddressFamily, loopback bool) []string {
+	var ips []string
+	for _, a := range interfaceAddrs(f) {
+		if a.ip.IsLoopback() && !loopback {
+			continue
+		}
+		if a.ip.To4() == nil && a.ip.IsLinkLocalUnicast() {
+			continue
+		}
+		ips = append(ips, a.String())
+	}
+	return ips
+}
@@ -9,8 +9,11 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -18,62 +21,159 @@ import (
 const (
 	mdnsPort    = 5353
 	mdnsAddr    = "224.0.0.251"
+	mdnsAddr6   = "ff02::fb"
 	serviceName = "_streamlinux._tcp.local."
 	serviceType = "_streamlinux._tcp"
+
+	// Record TTLs in seconds; host records (SRV, A) use the shorter TTL
+	// RFC 6762 recommends since addresses change more often
+	serviceTTL = 3600
+	hostTTL    = 120
+
+	// Class bit telling caches to replace, not add to, records of this
+	// name and type. Set on records only we can answer for.
+	cacheFlush = 0x8000
+
+	// Per-source response limit, so a spoofed source can't turn us into
+	// an amplifier against a victim
+	maxResponsesPerSource = 5
+	responseWindow        = 10 * time.Second
+
+	// How long the list of local subnets is cached
+	localNetsTTL = 30 * time.Second
+
+	// LAN scanning defaults
+	defaultScanPort     = 8080
+	defaultScanWorkers  = 32
+	defaultProbeTimeout = 500 * time.Millisecond
+	maxScanAddresses    = 1 << 16 // A /16
+
+	// Network monitoring for socket recovery
+	networkCheckInterval = 10 * time.Second
+	maxReadErrors        = 10
 )
 
 // MDNSServer handles mDNS discovery
 type MDNSServer struct {
 	port     int
 	hostname string
-	conn     *net.UDPConn
+	certFP   atomic.Pointer[string] // Certificate fingerprint advertised in TXT, if any
+	family   AddressFamily          // IP versions to listen on and advertise
+	iface    *net.Interface         // Interface to serve on; nil means any
+	conns    []*net.UDPConn
+	connMu   sync.Mutex
 	logger   *zap.Logger
 	done     chan struct{}
 	wg       sync.WaitGroup
+
+	limiter     *responseLimiter
+	localNets   []ifaceAddr
+	localNetsAt time.Time
+	localNetsMu sync.Mutex
+
+	netState string        // Fingerprint of interfaces at last (re)init
+	stale    chan struct{} // Signalled by listen when the socket keeps failing
 }
 
-// NewMDNSServer creates a new mDNS server
-func NewMDNSServer(servicePort int, logger *zap.Logger) (*MDNSServer, error) {
+// responseLimiter is a small sliding-window limiter keyed by source IP
+type responseLimiter struct {
+	mu      sync.Mutex
+	history map[string][]time.Time
+	max     int
+	window  time.Duration
+}
+
+func newResponseLimiter(max int, window time.Duration) *responseLimiter {
+	return &responseLimiter{
+		history: make(map[string][]time.Time),
+		max:     max,
+		window:  window,
+	}
+}
+
+func (l *responseLimiter) Allow(source string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-l.window)
+
+	recent := l.history[source][:0]
+	for _, t := range l.history[source] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+
+	// Forget idle sources so the map doesn't grow with spoofed addresses
+	if len(recent) == 0 {
+		delete(l.history, source)
+		for src, times := range l.history {
+			if len(times) == 0 || times[len(times)-1].Before(cutoff) {
+				delete(l.history, src)
+			}
+		}
+	}
+
+	if len(recent) >= l.max {
+		l.history[source] = recent
+		return false
+	}
+	l.history[source] = append(recent, now)
+	return true
+}
+
+// InstanceHostname returns the name mDNS advertises this machine under
+func InstanceHostname() string {
 	hostname, _ := os.Hostname()
 	if hostname == "" {
 		hostname = "streamlinux-host"
 	}
 	// Clean hostname for mDNS
-	hostname = strings.ReplaceAll(hostname, " ", "-")
+	return strings.ReplaceAll(hostname, " ", "-")
+}
 
+// NewMDNSServer creates a new mDNS server
+func NewMDNSServer(servicePort int, logger *zap.Logger) (*MDNSServer, error) {
 	return &MDNSServer{
 		port:     servicePort,
-		hostname: hostname,
+		hostname: InstanceHostname(),
+		family:   FamilyAny,
 		logger:   logger,
 		done:     make(chan struct{}),
+		limiter:  newResponseLimiter(maxResponsesPerSource, responseWindow),
+		stale:    make(chan struct{}, 1),
 	}, nil
 }
 
+// SetCertFingerprint adds the server certificate's SHA-256 fingerprint to
+// the TXT record. It can be called while running, e.g. after the
+// certificate was renewed.
+func (s *MDNSServer) SetCertFingerprint(fingerprint string) {
+	s.certFP.Store(&fingerprint)
+}
+
+// SetAddressFamily restricts the server to IPv4 or IPv6, e.g. when the
+// network drops IPv6 multicast. Must be called before Start.
+func (s *MDNSServer) SetAddressFamily(f AddressFamily) {
+	s.family = f
+}
+
 // Start starts the mDNS server
 func (s *MDNSServer) Start() error {
-	// Join multicast group
-	addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", mdnsAddr, mdnsPort))
-	if err != nil {
+	s.netState = networkFingerprint()
+	if err := s.openSockets(); err != nil {
 		return err
 	}
 
-	conn, err := net.ListenMulticastUDP("udp4", nil, addr)
-	if err != nil {
-		return fmt.Errorf("failed to listen on mDNS multicast: %w", err)
-	}
-
-	s.conn = conn
-
-	// Start listening for queries
+	// Watch for network changes and stale sockets
 	s.wg.Add(1)
-	go s.listen()
-
-	// Announce service
-	s.announce()
+	go s.monitor()
 
 	s.logger.Info("mDNS server started",
 		zap.String("service", serviceName),
 		zap.String("hostname", s.hostname),
+		zap.String("family", string(s.family)),
 		zap.Int("port", s.port))
 
 	return nil
@@ -82,16 +182,152 @@ func (s *MDNSServer) Start() error {
 // Stop stops the mDNS server
 func (s *MDNSServer) Stop() {
 	close(s.done)
-	if s.conn != nil {
-		s.conn.Close()
-	}
+	s.closeSockets()
 	s.wg.Wait()
 	s.logger.Info("mDNS server stopped")
 }
 
-func (s *MDNSServer) listen() {
+// openSockets joins the multicast group of each enabled family, starts a
+// listener on every new socket and announces the service. It fails only
+// if no family could be joined.
+func (s *MDNSServer) openSockets() error {
+	var groups []*net.UDPAddr
+	if s.family.v4() {
+		groups = append(groups, &net.UDPAddr{IP: net.ParseIP(mdnsAddr), Port: mdnsPort})
+	}
+	if s.family.v6() {
+		groups = append(groups, &net.UDPAddr{IP: net.ParseIP(mdnsAddr6), Port: mdnsPort})
+	}
+
+	var conns []*net.UDPConn
+	var lastErr error
+	for _, group := range groups {
+		network := "udp4"
+		if group.IP.To4() == nil {
+			network = "udp6"
+		}
+		conn, err := net.ListenMulticastUDP(network, s.iface, group)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to listen on mDNS multicast %s: %w", group.IP, err)
+			s.logger.Debug("mDNS multicast join failed", zap.Error(lastErr))
+			continue
+		}
+		conns = append(conns, conn)
+	}
+	if len(conns) == 0 {
+		return lastErr
+	}
+
+	// Checked under connMu since Stop closes done before taking it: either
+	// Stop closes these sockets and waits for their listeners, or they are
+	// never used
+	s.connMu.Lock()
+	select {
+	case <-s.done:
+		s.connMu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		return nil
+	default:
+	}
+	s.conns = conns
+	s.wg.Add(len(conns))
+	s.connMu.Unlock()
+
+	// Start listening for queries
+	for _, conn := range conns {
+		go s.listen(conn)
+	}
+
+	// Announce service
+	s.announce()
+	return nil
+}
+
+func (s *MDNSServer) closeSockets() {
+	s.connMu.Lock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+	s.connMu.Unlock()
+}
+
+// reinit replaces the multicast socket. A socket joined before the
+// network went down stays bound to the old interface state and silently
+// stops receiving queries, so it has to be re-created.
+func (s *MDNSServer) reinit(reason string) {
+	s.closeSockets()
+
+	s.localNetsMu.Lock()
+	s.localNetsAt = time.Time{}
+	s.localNetsMu.Unlock()
+
+	if err := s.openSockets(); err != nil {
+		s.logger.Warn("mDNS reinitialization failed, will retry",
+			zap.String("reason", reason),
+			zap.Error(err))
+		return
+	}
+	s.logger.Info("mDNS socket reinitialized", zap.String("reason", reason))
+}
+
+// monitor periodically checks the interface addresses and the socket
+// health and re-creates the multicast listener when either changes
+func (s *MDNSServer) monitor() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(networkCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.stale:
+			s.reinit("socket errors")
+		case <-ticker.C:
+			state := networkFingerprint()
+			s.connMu.Lock()
+			missing := len(s.conns) == 0
+			s.connMu.Unlock()
+
+			switch {
+			case state != s.netState:
+				s.netState = state
+				s.reinit("network change")
+			case missing:
+				s.reinit("retry")
+			}
+		}
+	}
+}
+
+// networkFingerprint summarizes the up interfaces and their addresses
+func networkFingerprint() string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+
+	entries := make([]string, 0, len(ifaces))
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			entries = append(entries, iface.Name+"="+addr.String())
+		}
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+func (s *MDNSServer) listen(conn *net.UDPConn) {
 	defer s.wg.Done()
 	buf := make([]byte, 1500)
+	consecutiveErrors := 0
 
 	for {
 		select {
@@ -100,26 +336,76 @@ func (s *MDNSServer) listen() {
 		default:
 		}
 
-		n, remoteAddr, err := s.conn.ReadFromUDP(buf)
+		n, remoteAddr, err := conn.ReadFromUDP(buf)
 		if err != nil {
 			if strings.Contains(err.Error(), "closed") {
 				return
 			}
 			s.logger.Debug("mDNS read error", zap.Error(err))
+			if consecutiveErrors++; consecutiveErrors >= maxReadErrors {
+				select {
+				case s.stale <- struct{}{}:
+				default:
+				}
+				return
+			}
 			continue
 		}
+		consecutiveErrors = 0
 
 		// Check if this is a query for our service
-		if s.isServiceQuery(buf[:n]) {
-			s.respondTo(remoteAddr)
+		if !s.isServiceQuery(buf[:n]) {
+			continue
+		}
+		if !s.isLocalSource(remoteAddr.IP) {
+			s.logger.Debug("Ignoring mDNS query from off-link source", zap.String("source", remoteAddr.String()))
+			continue
+		}
+		if !s.limiter.Allow(remoteAddr.IP.String()) {
+			s.logger.Debug("Rate limited mDNS query", zap.String("source", remoteAddr.String()))
+			continue
+		}
+		s.respondTo(remoteAddr)
+	}
+}
+
+// isLocalSource reports whether ip is on one of our directly connected
+// subnets. Legitimate mDNS queriers are always on-link.
+func (s *MDNSServer) isLocalSource(ip net.IP) bool {
+	_, ok := s.localNetFor(ip)
+	return ok
+}
+
+// localNetFor returns our interface address whose subnet contains ip
+func (s *MDNSServer) localNetFor(ip net.IP) (ifaceAddr, bool) {
+	s.localNetsMu.Lock()
+	defer s.localNetsMu.Unlock()
+
+	if time.Since(s.localNetsAt) > localNetsTTL {
+		s.localNets = interfaceAddrs(FamilyAny)
+		s.localNetsAt = time.Now()
+	}
+
+	for _, a := range s.localNets {
+		if s.iface != nil && a.iface.Index != s.iface.Index {
+			continue
+		}
+		if a.ipnet.Contains(ip) {
+			return a, true
 		}
 	}
+	return ifaceAddr{}, false
 }
 
+// isServiceQuery reports whether data is a DNS query with a PTR question
+// for our service type
 func (s *MDNSServer) isServiceQuery(data []byte) bool {
-	// Simplified check - look for our service name in the query
-	return strings.Contains(string(data), "_streamlinux") ||
-		strings.Contains(string(data), serviceType)
+	questions, err := parseQuestions(data)
+	if err != nil {
+		s.logger.Debug("Ignoring malformed mDNS packet", zap.Error(err))
+		return false
+	}
+	return asksForService(questions, serviceName)
 }
 
 func (s *MDNSServer) announce() {
@@ -127,57 +413,91 @@ func (s *MDNSServer) announce() {
 	s.sendMulticast(response)
 }
 
+// respondTo answers a query out of the interface it arrived on, or the
+// routing table may pick another network on multi-homed hosts
 func (s *MDNSServer) respondTo(addr *net.UDPAddr) {
 	response := s.buildResponse()
-	s.sendTo(response, addr)
+	s.sendTo(response, addr, s.arrivalInterface(addr))
 }
 
+// buildResponse answers with the PTR record and puts everything needed to
+// connect (TXT, SRV and our addresses) in the Additional section, so
+// DNS-SD resolvers don't need follow-up queries
 func (s *MDNSServer) buildResponse() []byte {
-	txt := fmt.Sprintf("streamlinux=%s:%d", s.hostname, s.port)
+	instanceName := fmt.Sprintf("%s.%s", s.hostname, serviceName)
+	target := s.hostname + ".local."
+	ips := s.hostIPs()
 
 	response := make([]byte, 0, 512)
 
 	// Header (12 bytes)
+	additional := 2 + len(ips) // TXT, SRV, A/AAAA...
 	response = append(response,
 		0, 0, // Transaction ID
 		0x84, 0x00, // Flags: QR=1, AA=1
 		0, 0, // Questions
 		0, 1, // Answers
 		0, 0, // Authority
-		0, 0, // Additional
+		byte(additional>>8), byte(additional), // Additional
 	)
 
-	// Answer: PTR record pointing to our service
-	response = append(response, s.encodeName(serviceName)...)
-	response = append(response,
-		0, 12, // Type: PTR
-		0, 1, // Class: IN
-		0, 0, 0x0e, 0x10, // TTL: 3600
-	)
-	instanceName := fmt.Sprintf("%s.%s", s.hostname, serviceName)
-	nameData := s.encodeName(instanceName)
-	response = append(response,
-		byte(len(nameData)>>8), byte(len(nameData)),
-	)
-	response = append(response, nameData...)
+	// Answer: PTR record pointing to our service instance
+	response = s.appendRecord(response, serviceName, dnsTypePTR, dnsClassIN, serviceTTL,
+		s.encodeName(instanceName))
 
 	// TXT record with port info
-	response = append(response, s.encodeName(instanceName)...)
-	response = append(response,
-		0, 16, // Type: TXT
-		0, 1, // Class: IN
-		0, 0, 0x0e, 0x10, // TTL: 3600
-	)
+	txt := fmt.Sprintf("streamlinux=%s:%d", s.hostname, s.port)
 	txtData := []byte{byte(len(txt))}
 	txtData = append(txtData, []byte(txt)...)
-	response = append(response,
-		byte(len(txtData)>>8), byte(len(txtData)),
-	)
-	response = append(response, txtData...)
+	if certFP := s.certFP.Load(); certFP != nil && *certFP != "" {
+		fp := "certfp=sha256:" + *certFP
+		txtData = append(txtData, byte(len(fp)))
+		txtData = append(txtData, []byte(fp)...)
+	}
+	response = s.appendRecord(response, instanceName, dnsTypeTXT, dnsClassIN|cacheFlush, serviceTTL, txtData)
+
+	// SRV record: priority 0, weight 0, port, target host
+	srvData := []byte{0, 0, 0, 0, byte(s.port >> 8), byte(s.port)}
+	srvData = append(srvData, s.encodeName(target)...)
+	response = s.appendRecord(response, instanceName, dnsTypeSRV, dnsClassIN|cacheFlush, hostTTL, srvData)
+
+	// A and AAAA records for the target host
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			response = s.appendRecord(response, target, dnsTypeA, dnsClassIN|cacheFlush, hostTTL, ip4)
+		} else {
+			response = s.appendRecord(response, target, dnsTypeAAAA, dnsClassIN|cacheFlush, hostTTL, ip.To16())
+		}
+	}
 
 	return response
 }
 
+// appendRecord appends a resource record with an uncompressed owner name
+func (s *MDNSServer) appendRecord(buf []byte, name string, rtype, class uint16, ttl uint32, rdata []byte) []byte {
+	buf = append(buf, s.encodeName(name)...)
+	buf = append(buf,
+		byte(rtype>>8), byte(rtype),
+		byte(class>>8), byte(class),
+		byte(ttl>>24), byte(ttl>>16), byte(ttl>>8), byte(ttl),
+		byte(len(rdata)>>8), byte(len(rdata)),
+	)
+	return append(buf, rdata...)
+}
+
+// hostIPs returns our non-loopback addresses in the configured family.
+// IPv6 link-local addresses are included; the querier already knows which
+// link it heard them on.
+func (s *MDNSServer) hostIPs() []net.IP {
+	var ips []net.IP
+	for _, a := range interfaceAddrs(s.family) {
+		if !a.ip.IsLoopback() {
+			ips = append(ips, a.ip)
+		}
+	}
+	return ips
+}
+
 func (s *MDNSServer) encodeName(name string) []byte {
 	var result []byte
 	parts := strings.Split(name, ".")
@@ -192,90 +512,129 @@ func (s *MDNSServer) encodeName(name string) []byte {
 	return result
 }
 
+// sendMulticast announces on every multicast-capable interface (or just
+// the configured one), to the groups of each enabled family
 func (s *MDNSServer) sendMulticast(data []byte) {
-	addr, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", mdnsAddr, mdnsPort))
-	s.sendTo(data, addr)
-}
-
-func (s *MDNSServer) sendTo(data []byte, addr *net.UDPAddr) {
-	conn, err := net.DialUDP("udp4", nil, addr)
-	if err != nil {
-		s.logger.Debug("Failed to send mDNS response", zap.Error(err))
-		return
+	sent := make(map[string]bool)
+	for _, a := range interfaceAddrs(s.family) {
+		if a.iface.Flags&net.FlagMulticast == 0 || a.ip.IsLoopback() ||
+			(s.iface != nil && a.iface.Index != s.iface.Index) {
+			continue
+		}
+		group := &net.UDPAddr{IP: net.ParseIP(mdnsAddr), Port: mdnsPort}
+		if a.ip.To4() == nil {
+			group = &net.UDPAddr{IP: net.ParseIP(mdnsAddr6), Port: mdnsPort, Zone: a.iface.Name}
+		}
+		key := a.iface.Name + "/" + group.IP.String()
+		if sent[key] {
+			continue
+		}
+		sent[key] = true
+		s.sendTo(data, group, &a)
 	}
-	defer conn.Close()
-	conn.Write(data)
 }
 
 // LANScanner scans local network for StreamLinux hosts
 type LANScanner struct {
 	logger *zap.Logger
+	Family AddressFamily
 }
 
 // NewLANScanner creates a new LAN scanner
 func NewLANScanner(logger *zap.Logger) *LANScanner {
-	return &LANScanner{logger: logger}
+	return &LANScanner{logger: logger, Family: FamilyAny}
 }
 
 // DiscoveredHost represents a found StreamLinux host
 type DiscoveredHost struct {
 	IP       string `json:"ip"`
-	Port     int    `json:"port"`
+	Port     int    `json:"port"` // The scanned port that answered
 	Hostname string `json:"hostname"`
 }
 
-// Scan scans the local network for StreamLinux hosts
-func (s *LANScanner) Scan(timeout int) []DiscoveredHost {
-	var hosts []DiscoveredHost
-	var mu sync.Mutex
-	var wg sync.WaitGroup
+// ScanOptions tune a LAN scan. The zero value scans the /24 of the first
+// local IPv4 address on port 8080.
+type ScanOptions struct {
+	CIDR        string        // Range to scan, e.g. "10.0.0.0/22"; empty = local /24
+	Ports       []int         // Candidate ports tried in order per address; empty = 8080
+	Timeout     time.Duration // Per-probe timeout; 0 = 500ms
+	Concurrency int           // Addresses probed in parallel; 0 = 32
+}
 
-	localIP := s.getLocalIP()
-	if localIP == "" {
-		return hosts
+// Scan probes every address in the range and returns the ones that answer
+// /health as a StreamLinux server
+func (s *LANScanner) Scan(opts ScanOptions) ([]DiscoveredHost, error) {
+	cidr := opts.CIDR
+	if cidr == "" {
+		// Only IPv4 subnets are small enough to sweep
+		localIP := net.ParseIP(s.getLocalIP()).To4()
+		if localIP == nil {
+			return nil, fmt.Errorf("no local IPv4 address to derive a scan range from")
+		}
+		cidr = fmt.Sprintf("%s/24", localIP)
+	}
+	ips, err := scanAddresses(cidr)
+	if err != nil {
+		return nil, err
 	}
 
-	parts := strings.Split(localIP, ".")
-	if len(parts) != 4 {
-		return hosts
+	ports := opts.Ports
+	if len(ports) == 0 {
+		ports = []int{defaultScanPort}
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
 	}
-	subnet := strings.Join(parts[:3], ".")
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = defaultScanWorkers
+	}
+
+	var hosts []DiscoveredHost
+	var mu sync.Mutex
+	var wg sync.WaitGroup
 
-	for i := 1; i < 255; i++ {
+	queue := make(chan string)
+	for w := 0; w < workers; w++ {
 		wg.Add(1)
-		go func(ip string) {
+		go func() {
 			defer wg.Done()
-
-			addr := fmt.Sprintf("%s:8080", ip)
-			conn, err := net.DialTimeout("tcp", addr, 100*1e6)
-			if err == nil {
-				conn.Close()
-				mu.Lock()
-				hosts = append(hosts, DiscoveredHost{
-					IP:   ip,
-					Port: 8080,
-				})
-				mu.Unlock()
+			for ip := range queue {
+				for _, port := range ports {
+					if host, ok := probeHost(ip, port, timeout); ok {
+						mu.Lock()
+						hosts = append(hosts, host)
+						mu.Unlock()
+						break
+					}
+				}
 			}
-		}(fmt.Sprintf("%s.%d", subnet, i))
+		}()
+	}
+	for _, ip := range ips {
+		queue <- ip
 	}
+	close(queue)
 
 	wg.Wait()
-	return hosts
+	return hosts, nil
 }
 
+// getLocalIP returns the first non-loopback address in the scanner's
+// family, preferring IPv4. IPv6 link-local addresses carry their zone.
 func (s *LANScanner) getLocalIP() string {
-	addrs, err := net.InterfaceAddrs()
-	if err != nil {
-		return ""
-	}
-
-	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				return ipnet.IP.String()
-			}
+	var v6 string
+	for _, a := range interfaceAddrs(s.Family) {
+		if a.ip.IsLoopback() {
+			continue
+		}
+		if a.ip.To4() != nil {
+			return a.String()
+		}
+		if v6 == "" {
+			v6 = a.String()
 		}
 	}
-	return ""
+	return v6
 }
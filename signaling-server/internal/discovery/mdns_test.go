@@ -0,0 +1,102 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+type dnsRecord struct {
+	name  string
+	rtype uint16
+	rdata []byte
+	off   int // Offset of rdata in the message, for names compressed into it
+}
+
+// parseRecords decodes the resource records following a header with no
+// questions, as in the responses we build
+func parseRecords(t *testing.T, msg []byte) (answers, additional []dnsRecord) {
+	t.Helper()
+	counts := func(i int) int { return int(binary.BigEndian.Uint16(msg[i : i+2])) }
+	if counts(4) != 0 || counts(8) != 0 {
+		t.Fatalf("unexpected questions or authority records")
+	}
+	off := dnsHeaderLen
+	read := func(n int) []dnsRecord {
+		var records []dnsRecord
+		for i := 0; i < n; i++ {
+			name, next, err := readName(msg, off)
+			if err != nil || next+10 > len(msg) {
+				t.Fatalf("record %d at %d: malformed", i, off)
+			}
+			rdlen := int(binary.BigEndian.Uint16(msg[next+8 : next+10]))
+			start := next + 10
+			if start+rdlen > len(msg) {
+				t.Fatalf("record %d: rdata overruns the message", i)
+			}
+			records = append(records, dnsRecord{
+				name:  name,
+				rtype: binary.BigEndian.Uint16(msg[next : next+2]),
+				rdata: msg[start : start+rdlen],
+				off:   start,
+			})
+			off = start + rdlen
+		}
+		return records
+	}
+	answers = read(counts(6))
+	additional = read(counts(10))
+	if off != len(msg) {
+		t.Fatalf("%d bytes after the counted records", len(msg)-off)
+	}
+	return answers, additional
+}
+
+func TestBuildResponseRecords(t *testing.T) {
+	s, _ := NewMDNSServer(8443, zap.NewNop())
+	s.hostname = "box"
+	msg := s.buildResponse()
+	answers, additional := parseRecords(t, msg)
+
+	if len(answers) != 1 || answers[0].rtype != dnsTypePTR || answers[0].name != serviceName {
+		t.Fatalf("answers = %+v, want one PTR for %s", answers, serviceName)
+	}
+	instance := "box." + serviceName
+	if name, _, err := readName(msg, answers[0].off); err != nil || name != instance {
+		t.Fatalf("PTR target %q (%v), want %q", name, err, instance)
+	}
+
+	var srv *dnsRecord
+	addrs := 0
+	for i, r := range additional {
+		switch r.rtype {
+		case dnsTypeSRV:
+			srv = &additional[i]
+		case dnsTypeA, dnsTypeAAAA:
+			if r.name != "box.local." {
+				t.Errorf("address record owner %q, want box.local.", r.name)
+			}
+			if ip := net.IP(r.rdata); ip.IsLoopback() {
+				t.Errorf("loopback address %s advertised", ip)
+			}
+			addrs++
+		}
+	}
+	if srv == nil || srv.name != instance {
+		t.Fatalf("no SRV record for %s in %+v", instance, additional)
+	}
+	prio := binary.BigEndian.Uint16(srv.rdata)
+	weight := binary.BigEndian.Uint16(srv.rdata[2:])
+	port := binary.BigEndian.Uint16(srv.rdata[4:])
+	if prio != 0 || weight != 0 || port != 8443 {
+		t.Fatalf("SRV priority %d weight %d port %d, want 0 0 8443", prio, weight, port)
+	}
+	if target, _, err := readName(msg, srv.off+6); err != nil || target != "box.local." {
+		t.Fatalf("SRV target %q (%v), want box.local.", target, err)
+	}
+	if want := len(s.hostIPs()); addrs != want {
+		t.Fatalf("%d address records, want one per non-loopback address (%d)", addrs, want)
+	}
+}
@@ -0,0 +1,105 @@
+package discovery
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ServiceName identifies a StreamLinux signaling server in /health
+const ServiceName = "streamlinux-signaling"
+
+// HealthResponse is the body of the /health endpoint
+type HealthResponse struct {
+	Status   string `json:"status"`
+	Service  string `json:"service,omitempty"`
+	Hostname string `json:"hostname,omitempty"` // Display name; the real hostname only for local requests
+	Port     int    `json:"port,omitempty"`
+}
+
+// scanAddresses expands cidr to its host addresses, leaving out the
+// network and broadcast addresses of IPv4 ranges larger than a /31
+func scanAddresses(cidr string) ([]string, error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scan range: %w", err)
+	}
+	ones, bits := ipnet.Mask.Size()
+	if bits-ones > 16 {
+		return nil, fmt.Errorf("scan range %s has more than %d addresses", cidr, maxScanAddresses)
+	}
+
+	var ips []string
+	ip := append(net.IP(nil), ipnet.IP...)
+	for ; ipnet.Contains(ip); ip = nextIP(ip) {
+		ips = append(ips, ip.String())
+	}
+	if ipnet.IP.To4() != nil && bits-ones > 1 {
+		ips = ips[1 : len(ips)-1]
+	}
+	return ips, nil
+}
+
+// nextIP returns ip+1; it wraps to all zeros past the last address
+func nextIP(ip net.IP) net.IP {
+	next := append(net.IP(nil), ip...)
+	for i := len(next) - 1; i >= 0; i-- {
+		if next[i]++; next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// probeHost asks ip:port for /health over plain HTTP, then HTTPS, and
+// reports whether a StreamLinux server answered. The certificate isn't
+// verified: this only identifies the server, the client pins it later.
+func probeHost(ip string, port int, timeout time.Duration) (DiscoveredHost, bool) {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	hostport := net.JoinHostPort(ip, strconv.Itoa(port))
+	for _, scheme := range []string{"http", "https"} {
+		health, ok := fetchHealth(client, scheme+"://"+hostport+"/health")
+		if !ok {
+			continue
+		}
+		return DiscoveredHost{IP: ip, Port: port, Hostname: health.Hostname}, true
+	}
+	return DiscoveredHost{}, false
+}
+
+// fetchHealth GETs url and requires a {"status":"ok"} body. Servers that
+// name a service must name ours.
+func fetchHealth(client *http.Client, url string) (HealthResponse, bool) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return HealthResponse{}, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return HealthResponse{}, false
+	}
+
+	var health HealthResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&health); err != nil {
+		return HealthResponse{}, false
+	}
+	if health.Status != "ok" || (health.Service != "" && health.Service != ServiceName) {
+		return HealthResponse{}, false
+	}
+	return health, true
+}
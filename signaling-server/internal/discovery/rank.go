@@ -0,0 +1,125 @@
+package discovery
+
+import (
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// LocalAddr is an advertisable address and the interface it belongs to
+type LocalAddr struct {
+	IP        string
+	Interface string
+}
+
+// virtualPrefixes name interfaces of containers, VMs and bridges, which a
+// phone on the LAN can't reach
+var virtualPrefixes = []string{
+	"docker", "br-", "virbr", "veth", "vmnet", "vboxnet", "lxcbr", "lxdbr",
+	"cni", "flannel", "podman", "tun", "tap",
+}
+
+// RankedIPs returns the advertisable addresses in family f, most likely to
+// be reachable from another LAN device first: addresses on the default
+// route's subnet, then Wi-Fi, then other physical interfaces, with
+// virtual interfaces and loopback last. IPv4 link-local addresses are
+// dropped along with the ones LocalIPs leaves out. A non-empty iface
+// limits the result to that interface.
+func RankedIPs(f AddressFamily, iface string, loopback bool) []LocalAddr {
+	egress := egressIPs(f)
+
+	type ranked struct {
+		addr  LocalAddr
+		score int
+	}
+	var out []ranked
+	for _, a := range interfaceAddrs(f) {
+		if iface != "" && a.iface.Name != iface {
+			continue
+		}
+		if a.ip.IsLoopback() && !loopback {
+			continue
+		}
+		if a.ip.IsLinkLocalUnicast() {
+			continue
+		}
+		out = append(out, ranked{
+			addr:  LocalAddr{IP: a.String(), Interface: a.iface.Name},
+			score: addrScore(a, egress),
+		})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].score > out[j].score })
+	addrs := make([]LocalAddr, len(out))
+	for i, r := range out {
+		addrs[i] = r.addr
+	}
+	return addrs
+}
+
+func addrScore(a ifaceAddr, egress []net.IP) int {
+	if a.ip.IsLoopback() {
+		return -1000
+	}
+
+	score := 0
+	for _, ip := range egress {
+		if a.ipnet.Contains(ip) {
+			score += 100
+			break
+		}
+	}
+	if isVirtual(a.iface.Name) {
+		score -= 100
+	} else if isWireless(a.iface.Name) {
+		score += 20
+	}
+	if a.ip.To4() != nil {
+		score += 5 // Every client can do IPv4
+	}
+	return score
+}
+
+func isVirtual(name string) bool {
+	for _, prefix := range virtualPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWireless(name string) bool {
+	if strings.HasPrefix(name, "wl") {
+		return true
+	}
+	_, err := os.Stat("/sys/class/net/" + name + "/wireless")
+	return err == nil
+}
+
+// egressIPs returns the source addresses the routing table picks for the
+// default route. Connecting a UDP socket only consults the routes, no
+// packet is sent.
+func egressIPs(f AddressFamily) []net.IP {
+	var targets []string
+	if f.v4() {
+		targets = append(targets, "192.0.2.1:9") // TEST-NET-1
+	}
+	if f.v6() {
+		targets = append(targets, "[2001:db8::1]:9") // Documentation prefix
+	}
+
+	var ips []net.IP
+	for _, target := range targets {
+		conn, err := net.Dial("udp", target)
+		if err != nil {
+			continue
+		}
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+			ips = append(ips, addr.IP)
+		}
+		conn.Close()
+	}
+	return ips
+}
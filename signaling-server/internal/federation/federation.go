@@ -0,0 +1,212 @@
+/**
+ * Signaling Server Federation
+ *
+ * Shares active-host lists between signaling instances so a client
+ * connected to one instance can discover hosts on another. Only discovery
+ * is federated; clients connect directly to the instance owning the host.
+ */
+package federation
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streamlinux/signaling-server/internal/signaling"
+
+	"go.uber.org/zap"
+)
+
+// HostsPath is the HTTP path instances expose to their federation peers
+const HostsPath = "/federation/hosts"
+
+// maxSnapshotSize caps how much of a peer's response is read
+const maxSnapshotSize = 1 << 20
+
+// Snapshot is the payload exchanged between federated instances
+type Snapshot struct {
+	Server    string                 `json:"server"`
+	Hosts     []signaling.HostStatus `json:"hosts"`
+	Timestamp int64                  `json:"timestamp"`
+}
+
+// Config holds federation settings
+type Config struct {
+	SelfURL  string        // Signaling URL advertised to peers for our hosts
+	Peers    []string      // Base HTTP(S) URLs of the other instances
+	Secret   string        // Shared secret presented as a bearer token; required
+	Interval time.Duration // How often to poll each peer
+}
+
+type remoteSnapshot struct {
+	snapshot  Snapshot
+	fetchedAt time.Time
+}
+
+// Federator polls federation peers and serves the local host list to them
+type Federator struct {
+	config Config
+	hub    *signaling.Hub
+	client *http.Client
+	logger *zap.Logger
+
+	remote map[string]remoteSnapshot // peer URL -> last good snapshot
+	mu     sync.RWMutex
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewFederator creates a federator for the given hub
+func NewFederator(hub *signaling.Hub, config Config, logger *zap.Logger) (*Federator, error) {
+	if config.SelfURL == "" {
+		return nil, fmt.Errorf("federation requires a self URL to advertise")
+	}
+	if config.Secret == "" {
+		// The host list carries names and LAN addresses
+		return nil, fmt.Errorf("federation requires a shared secret")
+	}
+	if config.Interval <= 0 {
+		config.Interval = 15 * time.Second
+	}
+
+	peers := make([]string, 0, len(config.Peers))
+	for _, p := range config.Peers {
+		p = strings.TrimRight(strings.TrimSpace(p), "/")
+		if p != "" {
+			peers = append(peers, p)
+		}
+	}
+	config.Peers = peers
+
+	return &Federator{
+		config: config,
+		hub:    hub,
+		client: &http.Client{Timeout: 5 * time.Second},
+		logger: logger,
+		remote: make(map[string]remoteSnapshot),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start begins polling federation peers
+func (f *Federator) Start() {
+	f.wg.Add(1)
+	go f.run()
+
+	f.logger.Info("Federation started",
+		zap.String("self", f.config.SelfURL),
+		zap.Strings("peers", f.config.Peers))
+}
+
+// Stop stops polling
+func (f *Federator) Stop() {
+	close(f.done)
+	f.wg.Wait()
+	f.logger.Info("Federation stopped")
+}
+
+func (f *Federator) run() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(f.config.Interval)
+	defer ticker.Stop()
+
+	f.pollAll()
+	for {
+		select {
+		case <-ticker.C:
+			f.pollAll()
+		case <-f.done:
+			return
+		}
+	}
+}
+
+func (f *Federator) pollAll() {
+	var wg sync.WaitGroup
+	for _, peer := range f.config.Peers {
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+			snap, err := f.fetch(peer)
+			if err != nil {
+				f.logger.Debug("Federation poll failed", zap.String("peer", peer), zap.Error(err))
+				return
+			}
+			f.mu.Lock()
+			f.remote[peer] = remoteSnapshot{snapshot: snap, fetchedAt: time.Now()}
+			f.mu.Unlock()
+		}(peer)
+	}
+	wg.Wait()
+}
+
+func (f *Federator) fetch(peer string) (Snapshot, error) {
+	var snap Snapshot
+
+	req, err := http.NewRequest(http.MethodGet, peer+HostsPath, nil)
+	if err != nil {
+		return snap, err
+	}
+	req.Header.Set("Authorization", "Bearer "+f.config.Secret)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return snap, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return snap, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSnapshotSize)).Decode(&snap); err != nil {
+		return snap, fmt.Errorf("invalid snapshot: %w", err)
+	}
+	return snap, nil
+}
+
+// RemoteHosts returns the hosts reported by federation peers, tagged with
+// the signaling URL of the instance they are connected to. Snapshots from
+// peers that stopped answering are dropped after a few missed polls.
+func (f *Federator) RemoteHosts() []signaling.HostStatus {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	staleAfter := 3 * f.config.Interval
+	now := time.Now()
+
+	hosts := make([]signaling.HostStatus, 0)
+	for _, rs := range f.remote {
+		if now.Sub(rs.fetchedAt) > staleAfter {
+			continue
+		}
+		for _, host := range rs.snapshot.Hosts {
+			host.Server = rs.snapshot.Server
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// HandleHosts serves this instance's local hosts to federation peers.
+// Only local hosts are returned so that snapshots never loop between peers.
+func (f *Federator) HandleHosts(w http.ResponseWriter, r *http.Request) {
+	authz := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authz, "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(authz[7:]), []byte(f.config.Secret)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Snapshot{
+		Server:    f.config.SelfURL,
+		Hosts:     f.hub.GetActiveHosts(),
+		Timestamp: time.Now().Unix(),
+	})
+}
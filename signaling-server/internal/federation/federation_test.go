@@ -0,0 +1,112 @@
+package federation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streamlinux/signaling-server/internal/signaling"
+
+	"go.uber.org/zap"
+)
+
+func newTestFederator(t *testing.T, peers ...string) *Federator {
+	t.Helper()
+	f, err := NewFederator(signaling.NewHub(zap.NewNop(), 0), Config{
+		SelfURL:  "wss://self.example/ws",
+		Peers:    peers,
+		Secret:   "shared-secret",
+		Interval: time.Minute,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewFederator: %v", err)
+	}
+	return f
+}
+
+func TestNewFederatorRequiresSecret(t *testing.T) {
+	_, err := NewFederator(signaling.NewHub(zap.NewNop(), 0), Config{
+		SelfURL: "wss://self.example/ws",
+		Peers:   []string{"https://peer.example"},
+	}, zap.NewNop())
+	if err == nil {
+		t.Fatal("federator created without a shared secret")
+	}
+}
+
+func TestRemoteHosts(t *testing.T) {
+	f := newTestFederator(t)
+	now := time.Now()
+	f.remote["https://a.example"] = remoteSnapshot{
+		snapshot: Snapshot{
+			Server: "wss://a.example/ws",
+			Hosts:  []signaling.HostStatus{{PeerID: "host-a", Name: "Desk"}},
+		},
+		fetchedAt: now,
+	}
+	f.remote["https://b.example"] = remoteSnapshot{
+		snapshot: Snapshot{
+			Server: "wss://b.example/ws",
+			Hosts:  []signaling.HostStatus{{PeerID: "host-b", Name: "Laptop"}},
+		},
+		fetchedAt: now.Add(-4 * f.config.Interval),
+	}
+
+	hosts := f.RemoteHosts()
+	if len(hosts) != 1 {
+		t.Fatalf("got %d hosts, want 1 (stale snapshot dropped): %+v", len(hosts), hosts)
+	}
+	if hosts[0].PeerID != "host-a" || hosts[0].Server != "wss://a.example/ws" {
+		t.Fatalf("got %+v, want host-a tagged with its server", hosts[0])
+	}
+}
+
+func TestHandleHostsRequiresSecret(t *testing.T) {
+	f := newTestFederator(t)
+
+	for _, authz := range []string{"", "Bearer wrong", "shared-secret"} {
+		req := httptest.NewRequest(http.MethodGet, HostsPath, nil)
+		if authz != "" {
+			req.Header.Set("Authorization", authz)
+		}
+		rec := httptest.NewRecorder()
+		f.HandleHosts(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("Authorization %q: status %d, want %d", authz, rec.Code, http.StatusUnauthorized)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, HostsPath, nil)
+	req.Header.Set("Authorization", "Bearer shared-secret")
+	rec := httptest.NewRecorder()
+	f.HandleHosts(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusOK)
+	}
+	var snap Snapshot
+	if err := json.NewDecoder(rec.Body).Decode(&snap); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if snap.Server != f.config.SelfURL {
+		t.Fatalf("snapshot server %q, want %q", snap.Server, f.config.SelfURL)
+	}
+}
+
+func TestFetchLimitsResponse(t *testing.T) {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer shared-secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"server":"` + strings.Repeat("x", maxSnapshotSize) + `"}`))
+	}))
+	defer peer.Close()
+
+	f := newTestFederator(t, peer.URL)
+	if _, err := f.fetch(peer.URL); err == nil {
+		t.Fatal("oversized snapshot accepted")
+	}
+}
@@ -0,0 +1,209 @@
+/**
+ * JWT client tokens
+ *
+ * Validates JSON Web Tokens issued by an external identity service, so a
+ * deployment can admit clients without registering each token with the
+ * hub. HS256 with a shared secret, RS256 and ES256 with a public key are
+ * supported; the algorithm is fixed by the configured key, never taken
+ * from the token alone.
+ */
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/streamlinux/signaling-server/internal/signaling"
+)
+
+// DefaultLeeway is the clock skew allowed on exp and nbf
+const DefaultLeeway = 30 * time.Second
+
+// Config holds JWT validation settings. Exactly one of Secret and
+// PublicKey must be set.
+type Config struct {
+	Secret    []byte           // HS256 key
+	PublicKey crypto.PublicKey // *rsa.PublicKey for RS256, P-256 *ecdsa.PublicKey for ES256
+	Audience  string           // Required aud claim, if set
+	Issuer    string           // Required iss claim, if set
+	RoomClaim string           // Claim naming the room the token admits to; "room" if empty
+	Leeway    time.Duration    // Clock skew allowed on exp and nbf; DefaultLeeway if 0
+}
+
+// Validator checks JWTs against a Config. It implements
+// signaling.TokenValidator.
+type Validator struct {
+	config Config
+	alg    string
+}
+
+// New creates a Validator
+func New(config Config) (*Validator, error) {
+	v := &Validator{config: config}
+	switch key := config.PublicKey.(type) {
+	case nil:
+		if len(config.Secret) == 0 {
+			return nil, errors.New("jwt: a secret or public key is required")
+		}
+		v.alg = "HS256"
+	case *rsa.PublicKey:
+		v.alg = "RS256"
+	case *ecdsa.PublicKey:
+		if key.Curve.Params().BitSize != 256 {
+			return nil, errors.New("jwt: only P-256 ECDSA keys are supported")
+		}
+		v.alg = "ES256"
+	default:
+		return nil, fmt.Errorf("jwt: unsupported public key type %T", key)
+	}
+	if v.alg != "HS256" && len(config.Secret) > 0 {
+		return nil, errors.New("jwt: set either a secret or a public key, not both")
+	}
+	if v.config.RoomClaim == "" {
+		v.config.RoomClaim = "room"
+	}
+	if v.config.Leeway == 0 {
+		v.config.Leeway = DefaultLeeway
+	}
+	return v, nil
+}
+
+// ParsePublicKey reads an RSA or ECDSA public key from PEM, either as a
+// PKIX public key or a certificate
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: no PEM block found")
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+type header struct {
+	Alg string `json:"alg"`
+}
+
+// Validate verifies a token's signature and its exp, nbf, aud and iss
+// claims. exp is required.
+func (v *Validator) Validate(token string) (signaling.TokenClaims, bool) {
+	claims, err := v.parse(token, time.Now())
+	return claims, err == nil
+}
+
+func (v *Validator) parse(token string, now time.Time) (signaling.TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return signaling.TokenClaims{}, errors.New("not a JWT")
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return signaling.TokenClaims{}, err
+	}
+	if h.Alg != v.alg {
+		return signaling.TokenClaims{}, fmt.Errorf("unexpected alg %q", h.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return signaling.TokenClaims{}, err
+	}
+	if !v.verify(parts[0]+"."+parts[1], sig) {
+		return signaling.TokenClaims{}, errors.New("bad signature")
+	}
+
+	var payload map[string]interface{}
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return signaling.TokenClaims{}, err
+	}
+
+	exp, ok := numericDate(payload["exp"])
+	if !ok {
+		return signaling.TokenClaims{}, errors.New("missing exp")
+	}
+	if now.After(exp.Add(v.config.Leeway)) {
+		return signaling.TokenClaims{}, errors.New("expired")
+	}
+	if nbf, ok := numericDate(payload["nbf"]); ok && now.Add(v.config.Leeway).Before(nbf) {
+		return signaling.TokenClaims{}, errors.New("not yet valid")
+	}
+	if v.config.Issuer != "" && payload["iss"] != v.config.Issuer {
+		return signaling.TokenClaims{}, errors.New("wrong issuer")
+	}
+	if v.config.Audience != "" && !hasAudience(payload["aud"], v.config.Audience) {
+		return signaling.TokenClaims{}, errors.New("wrong audience")
+	}
+
+	room, _ := payload[v.config.RoomClaim].(string)
+	subject, _ := payload["sub"].(string)
+	return signaling.TokenClaims{Room: room, Subject: subject, ExpiresAt: exp}, nil
+}
+
+func (v *Validator) verify(signed string, sig []byte) bool {
+	digest := sha256.Sum256([]byte(signed))
+	switch key := v.config.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	case *ecdsa.PublicKey:
+		if len(sig) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(key, digest[:], r, s)
+	default:
+		mac := hmac.New(sha256.New, v.config.Secret)
+		mac.Write([]byte(signed))
+		return hmac.Equal(mac.Sum(nil), sig)
+	}
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// numericDate reads a JWT NumericDate, seconds since the epoch
+func numericDate(v interface{}) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+// hasAudience reports whether an aud claim, a string or an array of
+// strings, contains want
+func hasAudience(aud interface{}, want string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == want
+	case []interface{}:
+		for _, item := range a {
+			if item == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var now = time.Unix(1_700_000_000, 0)
+
+// sign builds a token with the given header alg and claims, signed by
+// key: a []byte HMAC secret, an *rsa.PrivateKey or an *ecdsa.PrivateKey
+func sign(t *testing.T, alg string, claims map[string]interface{}, key interface{}) string {
+	t.Helper()
+	h, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func claims(extra map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{"exp": now.Add(time.Hour).Unix(), "aud": "streamlinux", "sub": "alice"}
+	for k, v := range extra {
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+func mustNew(t *testing.T, config Config) *Validator {
+	t.Helper()
+	v, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pub, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	v := mustNew(t, Config{PublicKey: &rsaKey.PublicKey, Audience: "streamlinux"})
+
+	if _, err := v.parse(sign(t, "RS256", claims(nil), rsaKey), now); err != nil {
+		t.Fatalf("RS256 token rejected: %v", err)
+	}
+	// HS256 keyed with the public key, the classic confusion attack
+	if _, err := v.parse(sign(t, "HS256", claims(nil), pub), now); err == nil {
+		t.Error("HS256 token accepted by an RS256 validator")
+	}
+	none := sign(t, "none", claims(nil), []byte{})
+	if _, err := v.parse(none[:len(none)-43], now); err == nil {
+		t.Error("alg none token accepted")
+	}
+
+	hs := mustNew(t, Config{Secret: []byte("secret"), Audience: "streamlinux"})
+	if _, err := hs.parse(sign(t, "RS256", claims(nil), rsaKey), now); err == nil {
+		t.Error("RS256 token accepted by an HS256 validator")
+	}
+}
+
+func TestTimeClaims(t *testing.T) {
+	secret := []byte("secret")
+	v := mustNew(t, Config{Secret: secret, Audience: "streamlinux", Leeway: 30 * time.Second})
+	tests := []struct {
+		name  string
+		extra map[string]interface{}
+		ok    bool
+	}{
+		{"valid", nil, true},
+		{"no exp", map[string]interface{}{"exp": nil}, false},
+		{"expired", map[string]interface{}{"exp": now.Add(-time.Minute).Unix()}, false},
+		{"expired within leeway", map[string]interface{}{"exp": now.Add(-10 * time.Second).Unix()}, true},
+		{"not yet valid", map[string]interface{}{"nbf": now.Add(time.Minute).Unix()}, false},
+		{"nbf within leeway", map[string]interface{}{"nbf": now.Add(10 * time.Second).Unix()}, true},
+		{"exp not a number", map[string]interface{}{"exp": "tomorrow"}, false},
+	}
+	for _, tt := range tests {
+		_, err := v.parse(sign(t, "HS256", claims(tt.extra), secret), now)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestAudience(t *testing.T) {
+	secret := []byte("secret")
+	v := mustNew(t, Config{Secret: secret, Audience: "streamlinux", Issuer: "idp"})
+	tests := []struct {
+		aud interface{}
+		ok  bool
+	}{
+		{"streamlinux", true},
+		{[]string{"billing", "streamlinux"}, true},
+		{[]string{"billing"}, false},
+		{[]string{}, false},
+		{"billing", false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		c := claims(map[string]interface{}{"aud": tt.aud, "iss": "idp"})
+		if tt.aud == nil {
+			delete(c, "aud")
+		}
+		if _, err := v.parse(sign(t, "HS256", c, secret), now); (err == nil) != tt.ok {
+			t.Errorf("aud %v: err %v, want ok %v", tt.aud, err, tt.ok)
+		}
+	}
+	if _, err := v.parse(sign(t, "HS256", claims(map[string]interface{}{"iss": "other"}), secret), now); err == nil {
+		t.Error("wrong issuer accepted")
+	}
+}
+
+func TestES256(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	v := mustNew(t, Config{PublicKey: &key.PublicKey, Audience: "streamlinux", RoomClaim: "r"})
+
+	token := sign(t, "ES256", claims(map[string]interface{}{"r": "living-room"}), key)
+	got, err := v.parse(token, now)
+	if err != nil {
+		t.Fatalf("ES256 token rejected: %v", err)
+	}
+	if got.Room != "living-room" || got.Subject != "alice" {
+		t.Fatalf("claims %+v", got)
+	}
+
+	// A signature of the wrong length, e.g. ASN.1 DER instead of r||s
+	for _, n := range []int{0, 63, 65, 72} {
+		sig := base64.RawURLEncoding.EncodeToString(make([]byte, n))
+		bad := token[:len(token)-86] + sig
+		if _, err := v.parse(bad, now); err == nil {
+			t.Errorf("%d byte signature accepted", n)
+		}
+	}
+
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := v.parse(sign(t, "ES256", claims(nil), other), now); err == nil {
+		t.Error("token signed by another key accepted")
+	}
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	for name, config := range map[string]Config{
+		"no key":         {},
+		"P-384":          {PublicKey: &p384.PublicKey},
+		"secret and key": {PublicKey: &rsaKey.PublicKey, Secret: []byte("s")},
+		"private key":    {PublicKey: rsaKey},
+	} {
+		if _, err := New(config); err == nil {
+			t.Errorf("%s: New succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package qr
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+// renderedCode is the pairing code for one connection info
+type renderedCode struct {
+	info        ConnectionInfo
+	image       []byte
+	contentType string
+}
+
+// setCacheControl keeps codes carrying a token out of caches
+func (c renderedCode) setCacheControl(w http.ResponseWriter) {
+	if c.info.Token != "" {
+		w.Header().Set("Cache-Control", "no-store")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+}
+
+// renderPreferred renders the code for the preferred connection info of
+// r. On failure it writes the error response and returns false.
+func (h *Handler) renderPreferred(w http.ResponseWriter, r *http.Request, opts imageOptions) (renderedCode, bool) {
+	room := r.URL.Query().Get("room")
+	token, err := h.requestToken(r, room)
+	if err != nil {
+		http.Error(w, "Failed to issue token", http.StatusInternalServerError)
+		return renderedCode{}, false
+	}
+
+	infos := h.getConnectionInfos(room, token, r.URL.Query().Get("iface"))
+	if len(infos) == 0 {
+		http.Error(w, "No network interfaces found", http.StatusInternalServerError)
+		return renderedCode{}, false
+	}
+	info := preferredInfo(infos)
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		http.Error(w, "Failed to generate QR data", http.StatusInternalServerError)
+		return renderedCode{}, false
+	}
+	img, contentType, err := opts.render(string(data))
+	if err != nil {
+		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
+		return renderedCode{}, false
+	}
+	return renderedCode{info: info, image: img, contentType: contentType}, true
+}
+
+// HandleQRSVG returns the QR code as an SVG document for inline embedding.
+// Takes the same parameters as HandleQRImage.
+func (h *Handler) HandleQRSVG(w http.ResponseWriter, r *http.Request) {
+	opts := parseImageOptions(r.URL.Query())
+	opts.svg = true
+	code, ok := h.renderPreferred(w, r, opts)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", code.contentType)
+	code.setCacheControl(w)
+	w.Write(code.image)
+}
+
+// HandleQRDataURI returns the connection info and its QR code as a data:
+// URI ready for an <img> src, PNG unless ?format=svg
+func (h *Handler) HandleQRDataURI(w http.ResponseWriter, r *http.Request) {
+	code, ok := h.renderPreferred(w, r, parseImageOptions(r.URL.Query()))
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	code.setCacheControl(w)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"info":     code.info,
+		"data_uri": "data:" + code.contentType + ";base64," + base64.StdEncoding.EncodeToString(code.image),
+	})
+}
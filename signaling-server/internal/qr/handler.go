@@ -11,8 +11,17 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	neturl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streamlinux/signaling-server/internal/discovery"
 
 	"github.com/skip2/go-qrcode"
+	"go.uber.org/zap"
 )
 
 // ConnectionInfo contains information for client connection
@@ -22,99 +31,429 @@ type ConnectionInfo struct {
 	Port     int    `json:"port"`
 	Room     string `json:"room,omitempty"`
 	URL      string `json:"url"`
+	Token    string `json:"token,omitempty"`
+
+	// Interface the address belongs to
+	Interface string `json:"interface,omitempty"`
+
+	// SHA-256 of the server certificate, for clients to pin (wss only)
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// TokenMinter mints self-contained pairing tokens that the signaling
+// server accepts without having registered them first
+type TokenMinter interface {
+	MintPairingToken(room string, ttl time.Duration) (string, time.Time, error)
+}
+
+// TokenIssuer issues client tokens registered with the signaling server
+type TokenIssuer interface {
+	IssueClientToken(room string, ttl time.Duration) (string, time.Time, error)
+}
+
+// PairingBundle is an offline pairing code: everything a client needs to
+// reach the server plus a token it can present without a prior round trip
+type PairingBundle struct {
+	Version   int      `json:"v"`
+	Protocol  string   `json:"protocol"`
+	Hosts     []string `json:"hosts"`
+	Port      int      `json:"port"`
+	Room      string   `json:"room,omitempty"`
+	Token     string   `json:"token"`
+	ExpiresAt int64    `json:"expires_at"`
 }
 
 // Handler handles QR code generation requests
 type Handler struct {
-	host     string
-	port     int
-	useTLS   bool
-	localIPs []string
+	host   string
+	port   int
+	useTLS bool
+	addrs  []discovery.LocalAddr // Ranked, most reachable first
+	family discovery.AddressFamily
+	mu     sync.RWMutex
+
+	insecurePort int    // Optional plain ws port offered next to wss
+	fingerprint  string // Advertised certificate fingerprint, if any
+
+	minter      TokenMinter
+	bundleTTL   time.Duration
+	bundleLocal func(*http.Request) bool
+
+	issuer   TokenIssuer // Issues tokens embedded in codes served locally
+	tokenTTL time.Duration
+	isLocal  func(*http.Request) bool
+
+	// Network change watching
+	logger      *zap.Logger
+	subscribers map[chan struct{}]struct{}
+	done        chan struct{}
+	wg          sync.WaitGroup
 }
 
 // NewHandler creates a new QR handler
 func NewHandler(host string, port int, useTLS bool) *Handler {
 	h := &Handler{
-		host:   host,
-		port:   port,
-		useTLS: useTLS,
+		host:        host,
+		port:        port,
+		useTLS:      useTLS,
+		family:      discovery.FamilyAny,
+		subscribers: make(map[chan struct{}]struct{}),
+		done:        make(chan struct{}),
 	}
-	h.localIPs = h.getLocalIPs()
+	h.addrs = h.getLocalAddrs("")
 	return h
 }
 
-// HandleQR returns connection info as JSON
+// Watch polls the interface addresses every interval and refreshes the
+// advertised IPs when they change, e.g. after the host moves to another
+// Wi-Fi network. Subscribers of HandleQRStream are pushed the new code.
+func (h *Handler) Watch(interval time.Duration, logger *zap.Logger) {
+	h.logger = logger
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.refresh()
+			case <-h.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops watching for network changes
+func (h *Handler) Stop() {
+	close(h.done)
+	h.wg.Wait()
+}
+
+// refresh re-reads the local IPs and notifies subscribers on change
+func (h *Handler) refresh() {
+	addrs := h.getLocalAddrs("")
+	ips := addressIPs(addrs)
+
+	h.mu.Lock()
+	old := addressIPs(h.addrs)
+	changed := !sameAddresses(old, ips)
+	h.addrs = addrs // Keep the latest ranking even if the set is unchanged
+	if changed {
+		for ch := range h.subscribers {
+			select {
+			case ch <- struct{}{}:
+			default: // Already has a pending update
+			}
+		}
+	}
+	h.mu.Unlock()
+
+	if changed && h.logger != nil {
+		h.logger.Info("Advertised addresses changed",
+			zap.Strings("old", old),
+			zap.Strings("new", ips))
+	}
+}
+
+func addressIPs(addrs []discovery.LocalAddr) []string {
+	ips := make([]string, len(addrs))
+	for i, a := range addrs {
+		ips[i] = a.IP
+	}
+	return ips
+}
+
+func sameAddresses(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+func (h *Handler) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *Handler) unsubscribe(ch chan struct{}) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+// SetTokenIssuer embeds a token valid for ttl in codes requested from the
+// local machine without a ?token= of their own, so a client scanning the
+// code is admitted. Codes requested over the network only carry a token
+// the caller passed in. isLocal tells local requests apart, e.g. behind a
+// reverse proxy; nil checks for a loopback peer address.
+func (h *Handler) SetTokenIssuer(issuer TokenIssuer, ttl time.Duration, isLocal func(*http.Request) bool) {
+	if isLocal == nil {
+		isLocal = isLoopback
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.issuer = issuer
+	h.tokenTTL = ttl
+	h.isLocal = isLocal
+}
+
+// requestToken returns the token to embed in a code served for r
+func (h *Handler) requestToken(r *http.Request, room string) (string, error) {
+	token, _, err := h.issueRequestToken(r, room)
+	return token, err
+}
+
+// issueRequestToken is requestToken plus when the token expires; the
+// expiry is zero for a caller-supplied token or none at all
+func (h *Handler) issueRequestToken(r *http.Request, room string) (string, time.Time, error) {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token, time.Time{}, nil
+	}
+
+	h.mu.RLock()
+	issuer, ttl, isLocal := h.issuer, h.tokenTTL, h.isLocal
+	h.mu.RUnlock()
+	if issuer == nil || !isLocal(r) {
+		return "", time.Time{}, nil
+	}
+	return issuer.IssueClientToken(room, ttl)
+}
+
+// renewAt is when a token issued now and expiring at expires is replaced,
+// leaving a fifth of its lifetime for a client that scanned it just before
+func renewAt(now, expires time.Time) time.Time {
+	return now.Add(expires.Sub(now) * 4 / 5)
+}
+
+func isLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// HandleQR returns connection info as JSON, most reachable address first.
+// ?iface= limits it to one interface.
 func (h *Handler) HandleQR(w http.ResponseWriter, r *http.Request) {
 	room := r.URL.Query().Get("room")
+	token, err := h.requestToken(r, room)
+	if err != nil {
+		http.Error(w, "Failed to issue token", http.StatusInternalServerError)
+		return
+	}
 
-	infos := h.getConnectionInfos(room)
+	infos := h.getConnectionInfos(room, token, r.URL.Query().Get("iface"))
 
 	w.Header().Set("Content-Type", "application/json")
+	if token != "" {
+		w.Header().Set("Cache-Control", "no-store")
+	}
 	json.NewEncoder(w).Encode(infos)
 }
 
-// HandleQRImage returns QR code as PNG image
+// HandleQRImage returns the QR code as a PNG image. ?size= sets the width
+// in pixels, ?ecc=low|medium|high|highest the error correction level and
+// ?format=svg returns a scalable SVG instead.
 func (h *Handler) HandleQRImage(w http.ResponseWriter, r *http.Request) {
+	code, ok := h.renderPreferred(w, r, parseImageOptions(r.URL.Query()))
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", code.contentType)
+	code.setCacheControl(w)
+	w.Write(code.image)
+}
+
+// HandleQRStream streams the pairing QR code as server-sent events,
+// pushing a fresh code whenever the advertised addresses change and
+// before the embedded token expires
+func (h *Handler) HandleQRStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// The stream outlives the server's short write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
 	room := r.URL.Query().Get("room")
-	size := 256 // Default size
+	iface := r.URL.Query().Get("iface")
+	token, expires, err := h.issueRequestToken(r, room)
+	if err != nil {
+		http.Error(w, "Failed to issue token", http.StatusInternalServerError)
+		return
+	}
+	updates := h.subscribe()
+	defer h.unsubscribe(updates)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	var renew *time.Timer
+	var renewC <-chan time.Time
+	if !expires.IsZero() {
+		renew = time.NewTimer(time.Until(renewAt(time.Now(), expires)))
+		defer renew.Stop()
+		renewC = renew.C
+	}
+
+	for {
+		if event, err := h.qrEvent(room, token, iface); err == nil {
+			fmt.Fprintf(w, "event: qr\ndata: %s\n\n", event)
+			flusher.Flush()
+		}
+
+		select {
+		case <-updates:
+		case <-renewC:
+			if token, expires, err = h.issueRequestToken(r, room); err != nil {
+				return
+			}
+			renew.Reset(time.Until(renewAt(time.Now(), expires)))
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			return
+		}
+	}
+}
 
-	infos := h.getConnectionInfos(room)
+func (h *Handler) qrEvent(room, token, iface string) ([]byte, error) {
+	infos := h.getConnectionInfos(room, token, iface)
 	if len(infos) == 0 {
-		http.Error(w, "No network interfaces found", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("no network interfaces found")
 	}
 
-	// Use first non-loopback IP
-	var info ConnectionInfo
+	info := preferredInfo(infos)
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	png, err := qrcode.Encode(string(data), qrcode.Medium, 256)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"info":   info,
+		"qr_png": base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// preferredInfo returns the first non-loopback connection info
+func preferredInfo(infos []ConnectionInfo) ConnectionInfo {
 	for _, i := range infos {
 		if i.Host != "127.0.0.1" && i.Host != "localhost" {
-			info = i
-			break
+			return i
 		}
 	}
-	if info.Host == "" {
-		info = infos[0]
+	return infos[0]
+}
+
+// SetTokenMinter enables offline pairing bundles valid for ttl. A bundle
+// admits whoever holds it, so like embedded tokens they are only served
+// to requests isLocal accepts; nil checks for a loopback peer address.
+func (h *Handler) SetTokenMinter(minter TokenMinter, ttl time.Duration, isLocal func(*http.Request) bool) {
+	if isLocal == nil {
+		isLocal = isLoopback
 	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.minter = minter
+	h.bundleTTL = ttl
+	h.bundleLocal = isLocal
+}
 
-	// Generate QR code
-	data, err := json.Marshal(info)
+// HandleQROffline returns a self-contained pairing bundle and its QR code
+// to local requests. Add ?format=png for the image only.
+func (h *Handler) HandleQROffline(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	minter, ttl, isLocal := h.minter, h.bundleTTL, h.bundleLocal
+	localIPs := addressIPs(h.addrs)
+	primary := h.endpoints()[0]
+	h.mu.RUnlock()
+
+	if minter == nil {
+		http.Error(w, "Offline pairing not configured", http.StatusNotFound)
+		return
+	}
+	if !isLocal(r) {
+		http.Error(w, "Offline pairing codes are only served locally", http.StatusForbidden)
+		return
+	}
+
+	room := r.URL.Query().Get("room")
+	token, expires, err := minter.MintPairingToken(room, ttl)
 	if err != nil {
-		http.Error(w, "Failed to generate QR data", http.StatusInternalServerError)
+		http.Error(w, "Failed to mint pairing token", http.StatusInternalServerError)
 		return
 	}
 
-	png, err := qrcode.Encode(string(data), qrcode.Medium, size)
+	bundle := PairingBundle{
+		Version:   1,
+		Protocol:  primary.protocol,
+		Hosts:     localIPs,
+		Port:      primary.port,
+		Room:      room,
+		Token:     token,
+		ExpiresAt: expires.Unix(),
+	}
+
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		http.Error(w, "Failed to generate QR data", http.StatusInternalServerError)
+		return
+	}
+	png, err := qrcode.Encode(string(data), qrcode.Medium, 256)
 	if err != nil {
 		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/png")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Write(png)
+	w.Header().Set("Cache-Control", "no-store")
+	if r.URL.Query().Get("format") == "png" {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bundle": bundle,
+		"qr_png": base64.StdEncoding.EncodeToString(png),
+	})
 }
 
 // HandleQRBase64 returns QR code as base64 encoded string
 func (h *Handler) HandleQRBase64(w http.ResponseWriter, r *http.Request) {
 	room := r.URL.Query().Get("room")
+	token, err := h.requestToken(r, room)
+	if err != nil {
+		http.Error(w, "Failed to issue token", http.StatusInternalServerError)
+		return
+	}
 
-	infos := h.getConnectionInfos(room)
+	infos := h.getConnectionInfos(room, token, r.URL.Query().Get("iface"))
 	if len(infos) == 0 {
 		http.Error(w, "No network interfaces found", http.StatusInternalServerError)
 		return
 	}
 
-	// Use first non-loopback IP
-	var info ConnectionInfo
-	for _, i := range infos {
-		if i.Host != "127.0.0.1" {
-			info = i
-			break
-		}
-	}
-	if info.Host == "" {
-		info = infos[0]
-	}
+	info := preferredInfo(infos)
 
 	data, _ := json.Marshal(info)
 	png, err := qrcode.Encode(string(data), qrcode.Medium, 256)
@@ -132,46 +471,108 @@ func (h *Handler) HandleQRBase64(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func (h *Handler) getConnectionInfos(room string) []ConnectionInfo {
-	protocol := "ws"
-	if h.useTLS {
-		protocol = "wss"
-	}
+// SetInsecurePort advertises an additional plain ws listener alongside the
+// wss one, for clients that can't trust the server certificate
+func (h *Handler) SetInsecurePort(port int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.insecurePort = port
+}
 
-	infos := make([]ConnectionInfo, 0, len(h.localIPs))
-	for _, ip := range h.localIPs {
-		url := fmt.Sprintf("%s://%s:%d/ws", protocol, ip, h.port)
-		if room != "" {
-			url += "?room=" + room
-		}
+// SetAddressFamily limits the advertised addresses to IPv4 or IPv6
+func (h *Handler) SetAddressFamily(f discovery.AddressFamily) {
+	h.mu.Lock()
+	h.family = f
+	h.mu.Unlock()
+	h.refresh()
+}
 
-		infos = append(infos, ConnectionInfo{
-			Protocol: protocol,
-			Host:     ip,
-			Port:     h.port,
-			Room:     room,
-			URL:      url,
-		})
-	}
+// SetCertFingerprint advertises the server certificate's SHA-256
+// fingerprint in wss connection infos, so clients can pin a self-signed
+// certificate instead of trusting it blindly
+func (h *Handler) SetCertFingerprint(fingerprint string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fingerprint = fingerprint
+}
 
-	return infos
+// endpoint is a protocol/port pair the server accepts connections on
+type endpoint struct {
+	protocol string
+	port     int
 }
 
-func (h *Handler) getLocalIPs() []string {
-	var ips []string
+// endpoints returns the listeners to advertise, preferred protocol first
+func (h *Handler) endpoints() []endpoint {
+	if !h.useTLS {
+		return []endpoint{{"ws", h.port}}
+	}
+	eps := []endpoint{{"wss", h.port}}
+	if h.insecurePort > 0 {
+		eps = append(eps, endpoint{"ws", h.insecurePort})
+	}
+	return eps
+}
 
-	addrs, err := net.InterfaceAddrs()
-	if err != nil {
-		return ips
+func (h *Handler) getConnectionInfos(room, token, iface string) []ConnectionInfo {
+	h.mu.RLock()
+	addrs := h.addrs
+	eps := h.endpoints()
+	fingerprint := h.fingerprint
+	h.mu.RUnlock()
+	if iface != "" {
+		addrs = h.getLocalAddrs(iface)
 	}
 
-	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok {
-			if ipnet.IP.To4() != nil {
-				ips = append(ips, ipnet.IP.String())
+	infos := make([]ConnectionInfo, 0, len(addrs)*len(eps))
+	for _, ep := range eps {
+		for _, addr := range addrs {
+			url := fmt.Sprintf("%s://%s/ws", ep.protocol, urlHost(addr.IP, ep.port))
+			if query := connectQuery(room, token); query != "" {
+				url += "?" + query
+			}
+
+			info := ConnectionInfo{
+				Protocol:  ep.protocol,
+				Host:      addr.IP,
+				Port:      ep.port,
+				Room:      room,
+				URL:       url,
+				Token:     token,
+				Interface: addr.Interface,
 			}
+			if ep.protocol == "wss" {
+				info.Fingerprint = fingerprint
+			}
+			infos = append(infos, info)
 		}
 	}
 
-	return ips
+	return infos
+}
+
+// connectQuery encodes the room and token parameters of a connect URL
+func connectQuery(room, token string) string {
+	q := neturl.Values{}
+	if room != "" {
+		q.Set("room", room)
+	}
+	if token != "" {
+		q.Set("token", token)
+	}
+	return q.Encode()
+}
+
+// getLocalAddrs returns the ranked addresses to advertise, only those of
+// iface if it is set
+func (h *Handler) getLocalAddrs(iface string) []discovery.LocalAddr {
+	h.mu.RLock()
+	family := h.family
+	h.mu.RUnlock()
+	return discovery.RankedIPs(family, iface, true)
+}
+
+// urlHost formats host:port for a URL, bracketing IPv6 addresses
+func urlHost(ip string, port int) string {
+	return net.JoinHostPort(ip, strconv.Itoa(port))
 }
@@ -0,0 +1,100 @@
+package qr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeMinter struct{ minted int }
+
+func (m *fakeMinter) MintPairingToken(room string, ttl time.Duration) (string, time.Time, error) {
+	m.minted++
+	return "pairing-" + room, time.Now().Add(ttl), nil
+}
+
+func TestHandleQROfflineLocalOnly(t *testing.T) {
+	h := NewHandler("localhost", 8080, false)
+	minter := &fakeMinter{}
+	h.SetTokenMinter(minter, time.Minute, nil)
+
+	r := httptest.NewRequest("GET", "/qr/offline?room=r", nil)
+	r.RemoteAddr = "192.0.2.5:5000"
+	w := httptest.NewRecorder()
+	h.HandleQROffline(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("remote request: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if minter.minted != 0 {
+		t.Fatalf("remote request minted %d tokens", minter.minted)
+	}
+
+	r = httptest.NewRequest("GET", "/qr/offline?room=r", nil)
+	r.RemoteAddr = "127.0.0.1:5000"
+	w = httptest.NewRecorder()
+	h.HandleQROffline(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("local request: status %d, want %d", w.Code, http.StatusOK)
+	}
+	var resp struct {
+		Bundle PairingBundle `json:"bundle"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Bundle.Token != "pairing-r" || resp.Bundle.Room != "r" {
+		t.Fatalf("bundle = %+v", resp.Bundle)
+	}
+}
+
+type fakeIssuer struct {
+	mu     sync.Mutex
+	issued int
+}
+
+func (i *fakeIssuer) IssueClientToken(room string, ttl time.Duration) (string, time.Time, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.issued++
+	return fmt.Sprintf("client-%d", i.issued), time.Now().Add(ttl), nil
+}
+
+func TestHandleQRStreamRenewsToken(t *testing.T) {
+	h := NewHandler("localhost", 8080, false)
+	h.SetTokenIssuer(&fakeIssuer{}, 100*time.Millisecond, nil)
+
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleQRStream))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/qr/stream?room=r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	seen := map[string]bool{}
+	events := bufio.NewScanner(resp.Body)
+	deadline := time.AfterFunc(2*time.Second, func() { resp.Body.Close() })
+	defer deadline.Stop()
+	for len(seen) < 2 && events.Scan() {
+		data, ok := strings.CutPrefix(events.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event struct {
+			Info ConnectionInfo `json:"info"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatal(err)
+		}
+		seen[event.Info.Token] = true
+	}
+	if !seen["client-1"] || !seen["client-2"] {
+		t.Fatalf("tokens streamed: %v, want client-1 then a renewed client-2", seen)
+	}
+}
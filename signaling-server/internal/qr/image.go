@@ -0,0 +1,76 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	defaultImageSize = 256
+	minImageSize     = 64
+	maxImageSize     = 2048
+)
+
+// imageOptions are the rendering options of a QR image request
+type imageOptions struct {
+	size  int
+	level qrcode.RecoveryLevel
+	svg   bool
+}
+
+var recoveryLevels = map[string]qrcode.RecoveryLevel{
+	"low":     qrcode.Low,
+	"medium":  qrcode.Medium,
+	"high":    qrcode.High,
+	"highest": qrcode.Highest,
+}
+
+// parseImageOptions reads ?size=, ?ecc= and ?format=. Invalid values fall
+// back to the defaults and sizes are clamped to a scannable range.
+func parseImageOptions(q url.Values) imageOptions {
+	opts := imageOptions{size: defaultImageSize, level: qrcode.Medium}
+
+	if size, err := strconv.Atoi(q.Get("size")); err == nil {
+		opts.size = min(max(size, minImageSize), maxImageSize)
+	}
+	if level, ok := recoveryLevels[q.Get("ecc")]; ok {
+		opts.level = level
+	}
+	opts.svg = q.Get("format") == "svg"
+	return opts
+}
+
+// render encodes content as a PNG, or an SVG if requested. It returns the
+// image and its content type.
+func (o imageOptions) render(content string) ([]byte, string, error) {
+	code, err := qrcode.New(content, o.level)
+	if err != nil {
+		return nil, "", err
+	}
+	if o.svg {
+		return renderSVG(code.Bitmap(), o.size), "image/svg+xml", nil
+	}
+	png, err := code.PNG(o.size)
+	return png, "image/png", err
+}
+
+// renderSVG draws bitmap as one path of unit squares scaled to size
+func renderSVG(bitmap [][]bool, size int) []byte {
+	var b bytes.Buffer
+	n := len(bitmap)
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y, row := range bitmap {
+		for x, set := range row {
+			if set {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return b.Bytes()
+}
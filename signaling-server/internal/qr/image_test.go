@@ -0,0 +1,57 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+)
+
+func TestParseImageOptions(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		want  imageOptions
+	}{
+		{"", imageOptions{size: defaultImageSize, level: qrcode.Medium}},
+		{"size=512&ecc=highest", imageOptions{size: 512, level: qrcode.Highest}},
+		{"size=1", imageOptions{size: minImageSize, level: qrcode.Medium}},
+		{"size=100000", imageOptions{size: maxImageSize, level: qrcode.Medium}},
+		{"size=big&ecc=extreme&format=gif", imageOptions{size: defaultImageSize, level: qrcode.Medium}},
+		{"format=svg&ecc=low", imageOptions{size: defaultImageSize, level: qrcode.Low, svg: true}},
+	} {
+		q, _ := url.ParseQuery(tc.query)
+		if got := parseImageOptions(q); got != tc.want {
+			t.Errorf("%q: got %+v, want %+v", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestHandleQRImageSize(t *testing.T) {
+	h := NewHandler("localhost", 8080, false)
+	for _, size := range []int{128, 300, 1024} {
+		w := httptest.NewRecorder()
+		h.HandleQRImage(w, httptest.NewRequest("GET", "/qr/image?ecc=high&size="+strconv.Itoa(size), nil))
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("size %d: status %d, type %q", size, w.Code, w.Header().Get("Content-Type"))
+		}
+		config, err := png.DecodeConfig(bytes.NewReader(w.Body.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Width != size || config.Height != size {
+			t.Fatalf("size %d: image is %dx%d", size, config.Width, config.Height)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.HandleQRImage(w, httptest.NewRequest("GET", "/qr/image?format=svg&size=400", nil))
+	if w.Header().Get("Content-Type") != "image/svg+xml" || !strings.Contains(w.Body.String(), `width="400"`) {
+		t.Fatalf("svg: type %q, body %.80s", w.Header().Get("Content-Type"), w.Body)
+	}
+}
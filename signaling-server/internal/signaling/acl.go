@@ -0,0 +1,106 @@
+package signaling
+
+import "go.uber.org/zap"
+
+// messageACL lists the roles allowed to send each message type to the
+// hub. Types the hub itself sends to peers map to no roles, so peers can't
+// forge them to the room. Unlisted types, including app: messages, may be
+// sent by any peer; a peer that isn't acting as a host counts as a
+// client.
+var messageACL = map[MessageType][]PeerRole{
+	MsgTypeJoin:   {RoleHost, RoleClient},
+	MsgTypeLeave:  {RoleHost, RoleClient},
+	MsgTypeKick:   {RoleHost},
+	MsgTypeWhoAmI: {RoleHost, RoleClient},
+
+	MsgTypeRegister: {RoleHost, RoleClient},
+
+	MsgTypeOffer:        {RoleHost, RoleClient},
+	MsgTypeAnswer:       {RoleHost, RoleClient},
+	MsgTypeCandidate:    {RoleHost, RoleClient},
+	MsgTypeIceCandidate: {RoleHost, RoleClient},
+	MsgTypeCandidates:   {RoleHost, RoleClient},
+
+	MsgTypePing: {RoleHost, RoleClient},
+
+	MsgTypePairingMode: {RoleHost},
+	MsgTypePinVerify:   {RoleHost},
+
+	MsgTypeConnected: {RoleHost, RoleClient},
+
+	MsgTypeKeyBundle:    {RoleHost},
+	MsgTypeKeyBundleAck: {RoleClient},
+
+	// Sent by the hub only
+	MsgTypeRoomInfo:    nil,
+	MsgTypeHostChanged: nil,
+	MsgTypeRegistered:  nil,
+	MsgTypePeerJoined:  nil,
+	MsgTypePeerLeft:    nil,
+	MsgTypePong:        nil,
+	MsgTypeError:       nil,
+	MsgTypePinRequired: nil,
+	MsgTypePinAccepted: nil,
+}
+
+// messageAllowed reports whether a peer with role may send type t
+func messageAllowed(t MessageType, role PeerRole) bool {
+	roles, listed := messageACL[t]
+	if !listed {
+		return true
+	}
+	if role == "" {
+		role = RoleClient
+	}
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMessageACL enforces messageACL on a message from a peer, telling
+// the sender when it is dropped. Must be called with h.mu held.
+func (h *Hub) checkMessageACL(msg *Message) bool {
+	peer, ok := h.peers[msg.From]
+	if !ok {
+		return true
+	}
+	role := h.actingRole(peer)
+	if messageAllowed(msg.Type, role) {
+		return true
+	}
+
+	h.logger.Warn("Dropping message not allowed for role",
+		zap.String("from", msg.From),
+		zap.String("role", string(role)),
+		zap.String("type", string(msg.Type)))
+	if roles := messageACL[msg.Type]; len(roles) == 1 && roles[0] == RoleHost {
+		h.sendError(peer, CodeNotHost, "Only hosts may send "+string(msg.Type))
+	} else {
+		h.sendError(peer, CodeNotAllowed, "Peers may not send "+string(msg.Type))
+	}
+	return false
+}
+
+// actingRole is the role peer is authorized to act in. Peer.Role only
+// records what the peer asked for, so a host role also needs a host
+// connection or a host seat in the peer's room, e.g. after being promoted
+// when the host left. Must be called with h.mu held and room.mu not held.
+func (h *Hub) actingRole(peer *Peer) PeerRole {
+	if peer.Role != RoleHost {
+		return RoleClient
+	}
+	if peer.hostConn {
+		return RoleHost
+	}
+	if room, ok := h.rooms[peer.Room]; ok {
+		room.mu.RLock()
+		defer room.mu.RUnlock()
+		if room.isHost(peer.ID) {
+			return RoleHost
+		}
+	}
+	return RoleClient
+}
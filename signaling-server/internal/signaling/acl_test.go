@@ -0,0 +1,76 @@
+package signaling
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMessageAllowed(t *testing.T) {
+	tests := []struct {
+		typ   MessageType
+		role  PeerRole
+		allow bool
+	}{
+		{MsgTypeOffer, RoleClient, true},
+		{MsgTypePairingMode, RoleHost, true},
+		{MsgTypePairingMode, RoleClient, false},
+		{MsgTypeKeyBundle, "", false},
+		{MsgTypeKeyBundleAck, RoleHost, false},
+		{MsgTypeRoomInfo, RoleHost, false},
+		{"app:cursor", RoleClient, true},
+	}
+	for _, tt := range tests {
+		if got := messageAllowed(tt.typ, tt.role); got != tt.allow {
+			t.Errorf("messageAllowed(%s, %q) = %v, want %v", tt.typ, tt.role, got, tt.allow)
+		}
+	}
+}
+
+func TestClientCannotClaimHostRole(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	c := s.mustDial("")
+
+	c.send(Message{Type: MsgTypeRegister, Role: RoleHost})
+	if code := c.expectError(); code != CodeInvalidRegistration {
+		t.Fatalf("host registration on client connection: got %s", code)
+	}
+	c.register(RoleClient)
+
+	c.send(Message{Type: MsgTypeJoin, Room: "r", Role: RoleHost})
+	if code := c.expectError(); code != CodeNotHost {
+		t.Fatalf("host join on client connection: got %s", code)
+	}
+	for _, typ := range []MessageType{MsgTypePairingMode, MsgTypePinVerify, MsgTypeKeyBundle} {
+		c.send(Message{Type: typ, Role: RoleHost})
+		if code := c.expectError(); code != CodeNotHost {
+			t.Errorf("%s from client: got %s, want %s", typ, code, CodeNotHost)
+		}
+	}
+}
+
+func TestActingRole(t *testing.T) {
+	h := NewHubWithConfig(zap.NewNop(), 0, DefaultSecurityConfig(), DefaultHubConfig())
+	room := &Room{ID: "r", Clients: map[string]*Peer{}}
+	h.rooms["r"] = room
+
+	hostConn := &Peer{ID: "a", Role: RoleHost, hostConn: true}
+	forged := &Peer{ID: "b", Role: RoleHost, Room: "r"}
+	promoted := &Peer{ID: "c", Role: RoleHost, Room: "r"}
+	room.Host = promoted
+	hostAsClient := &Peer{ID: "d", Role: RoleClient, hostConn: true}
+
+	for _, tt := range []struct {
+		peer *Peer
+		want PeerRole
+	}{
+		{hostConn, RoleHost},
+		{forged, RoleClient},
+		{promoted, RoleHost},
+		{hostAsClient, RoleClient},
+	} {
+		if got := h.actingRole(tt.peer); got != tt.want {
+			t.Errorf("actingRole(%s) = %s, want %s", tt.peer.ID, got, tt.want)
+		}
+	}
+}
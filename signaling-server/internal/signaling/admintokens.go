@@ -0,0 +1,211 @@
+package signaling
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// The admin API names tokens by ID, the start of their SHA-256, rather than
+// by prefix, since tokens such as JWTs all begin alike. A revocation
+// accepts any unique ID prefix of at least minTokenIDPrefix characters.
+const (
+	adminTokenIDLen  = 16
+	minTokenIDPrefix = 8
+)
+
+// revokedTokenHold is how long a revoked token stays denied past its
+// revocation, or past its expiry if that is later. The hold outlives
+// host reconnects presenting the same token.
+const revokedTokenHold = 24 * time.Hour
+
+var (
+	errTokenNotFound  = errors.New("no token with this ID")
+	errTokenAmbiguous = errors.New("ID matches more than one token")
+	errTokenIDShort   = errors.New("token ID too short")
+)
+
+// tokenDigest is the hex SHA-256 of token, which the revocation list
+// holds instead of the token itself
+func tokenDigest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenID names token in the admin API without revealing it
+func tokenID(token string) string {
+	return tokenDigest(token)[:adminTokenIDLen]
+}
+
+// TokenInfo describes a valid token for GET /admin/tokens. The token
+// itself is never included, only its ID.
+type TokenInfo struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Room      string    `json:"room,omitempty"`
+	Hosts     []string  `json:"hosts,omitempty"` // Peer IDs of the connected hosts that registered it
+	Minted    bool      `json:"minted"`          // Client token minted from a host token
+	Orphaned  bool      `json:"orphaned"`        // Its hosts have all disconnected
+	Peers     int       `json:"peers"`           // Connected peers authorized by it
+}
+
+// RevokedToken is the response to DELETE /admin/tokens/{id}
+type RevokedToken struct {
+	TokenInfo
+	Disconnected int `json:"disconnected"`
+}
+
+func newTokenInfo(token string, entry *tokenEntry) TokenInfo {
+	return TokenInfo{
+		ID:        tokenID(token),
+		ExpiresAt: entry.ExpiresAt,
+		Room:      entry.Room,
+		Hosts:     entry.hostList(),
+		Minted:    entry.Minted,
+		Orphaned:  !entry.Orphaned.IsZero(),
+	}
+}
+
+// ListTokens returns the unexpired tokens, soonest to expire first
+func (h *Hub) ListTokens() []TokenInfo {
+	now := time.Now()
+	h.tokenMu.RLock()
+	infos := make([]TokenInfo, 0, len(h.validTokens))
+	tokens := make([]string, 0, len(h.validTokens))
+	for token, entry := range h.validTokens {
+		if now.After(entry.ExpiresAt) {
+			continue
+		}
+		infos = append(infos, newTokenInfo(token, entry))
+		tokens = append(tokens, token)
+	}
+	h.tokenMu.RUnlock()
+
+	h.mu.RLock()
+	for i, token := range tokens {
+		for _, peer := range h.peers {
+			if peer.token == token {
+				infos[i].Peers++
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ExpiresAt.Before(infos[j].ExpiresAt) })
+	return infos
+}
+
+// RevokeToken invalidates the one token whose ID starts with id and
+// disconnects every peer that connected with it, including ones waiting
+// to resume, so a leaked token stops working at once. The token stays
+// denied, so a host presenting it again can't register it anew.
+func (h *Hub) RevokeToken(id string) (RevokedToken, error) {
+	if len(id) < minTokenIDPrefix {
+		return RevokedToken{}, errTokenIDShort
+	}
+
+	h.tokenMu.RLock()
+	var token string
+	var revoked RevokedToken
+	matches := 0
+	for t, entry := range h.validTokens {
+		if strings.HasPrefix(tokenID(t), id) {
+			token = t
+			revoked.TokenInfo = newTokenInfo(t, entry)
+			matches++
+		}
+	}
+	h.tokenMu.RUnlock()
+	if matches != 1 {
+		if matches == 0 {
+			return RevokedToken{}, errTokenNotFound
+		}
+		return RevokedToken{}, errTokenAmbiguous
+	}
+
+	h.denyToken(token, revoked.ExpiresAt)
+	h.InvalidateToken(token)
+
+	h.mu.Lock()
+	for _, peer := range h.peers {
+		if peer.token != token {
+			continue
+		}
+		h.disconnectWithError(peer, CodeTokenRevoked, "Token revoked")
+		revoked.Disconnected++
+	}
+	h.mu.Unlock()
+	revoked.Peers = revoked.Disconnected
+
+	h.logger.Warn("Token revoked",
+		zap.String("token_id", revoked.ID),
+		zap.Int("disconnected", revoked.Disconnected))
+	return revoked, nil
+}
+
+// denyToken adds token to the revocation list until the later of expires
+// and revokedTokenHold from now
+func (h *Hub) denyToken(token string, expires time.Time) {
+	until := time.Now().Add(revokedTokenHold)
+	if expires.After(until) {
+		until = expires
+	}
+	h.tokenMu.Lock()
+	defer h.tokenMu.Unlock()
+	h.revokedTokens[tokenDigest(token)] = until
+}
+
+// tokenRevoked reports whether token is on the revocation list
+func (h *Hub) tokenRevoked(token string) bool {
+	h.tokenMu.RLock()
+	defer h.tokenMu.RUnlock()
+	return h.tokenRevokedLocked(token)
+}
+
+// tokenRevokedLocked is tokenRevoked with tokenMu already held
+func (h *Hub) tokenRevokedLocked(token string) bool {
+	if len(h.revokedTokens) == 0 {
+		return false
+	}
+	_, ok := h.revokedTokens[tokenDigest(token)]
+	return ok
+}
+
+// AdminTokensHandler serves GET /admin/tokens and
+// DELETE /admin/tokens/{id}. The caller authenticates the admin.
+func (h *Hub) AdminTokensHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/tokens"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]interface{}{"tokens": h.ListTokens()})
+
+	case r.Method == http.MethodDelete && id != "":
+		revoked, err := h.RevokeToken(id)
+		switch {
+		case errors.Is(err, errTokenNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, errTokenAmbiguous):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(revoked)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,77 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminTokenIDs(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	jwtA := "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiJhIn0.sig-a"
+	jwtB := "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiJiIn0.sig-b"
+	s.hub.RegisterToken(jwtA, time.Minute)
+	s.hub.RegisterToken(jwtB, time.Minute)
+
+	w := httptest.NewRecorder()
+	s.hub.AdminTokensHandler(w, httptest.NewRequest("GET", "/admin/tokens", nil))
+	if strings.Contains(w.Body.String(), "eyJ") {
+		t.Fatalf("listing leaks tokens: %s", w.Body)
+	}
+	var resp struct {
+		Tokens []TokenInfo `json:"tokens"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Tokens) != 2 || resp.Tokens[0].ID == resp.Tokens[1].ID {
+		t.Fatalf("tokens sharing a prefix aren't told apart: %+v", resp.Tokens)
+	}
+
+	if _, err := s.hub.RevokeToken(tokenID(jwtA)[:minTokenIDPrefix-1]); err != errTokenIDShort {
+		t.Fatalf("short ID: got %v, want %v", err, errTokenIDShort)
+	}
+	w = httptest.NewRecorder()
+	s.hub.AdminTokensHandler(w, httptest.NewRequest("DELETE", "/admin/tokens/"+tokenID(jwtA), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("revoke: status %d: %s", w.Code, w.Body)
+	}
+	if s.hub.ValidateToken(jwtA) || !s.hub.ValidateToken(jwtB) {
+		t.Fatalf("after revoking a: a valid %v, b valid %v", s.hub.ValidateToken(jwtA), s.hub.ValidateToken(jwtB))
+	}
+}
+
+func TestRevokedTokenStaysRevoked(t *testing.T) {
+	s := newTestServer(t, tokenOnlySecurity(), DefaultHubConfig())
+	host, _ := s.host("leaked-host-token")
+	host.join("r", RoleHost)
+
+	s.remote = "192.0.2.20:40000"
+	client, _ := s.client("token=leaked-host-token")
+	s.remote = ""
+
+	revoked, err := s.hub.RevokeToken(tokenID("leaked-host-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked.Disconnected != 2 {
+		t.Fatalf("disconnected %d peers, want 2", revoked.Disconnected)
+	}
+	if code := client.expectClose(); code != CloseTokenRevoked {
+		t.Fatalf("client close code %d, want %d", code, CloseTokenRevoked)
+	}
+
+	// Neither a host reconnecting with it nor a re-registration revives it
+	again := s.mustDial("is_host=true&token=leaked-host-token")
+	if code := again.expectClose(); code != CloseTokenRevoked {
+		t.Fatalf("host reconnect close code %d, want %d", code, CloseTokenRevoked)
+	}
+	s.hub.RegisterToken("leaked-host-token", time.Minute)
+	s.hub.RegisterTokenForRoom("leaked-host-token", "r", time.Minute)
+	if s.hub.ValidateToken("leaked-host-token") || s.hub.ValidateTokenForRoom("leaked-host-token", "r") {
+		t.Fatal("revoked token valid after re-registration")
+	}
+}
@@ -0,0 +1,64 @@
+package signaling
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrTooManyPendingAuth is returned when the pending-auth table is full
+var ErrTooManyPendingAuth = errors.New("too many pending authorizations")
+
+// addPendingAuth records a connection awaiting verification. Entries past
+// MaxPendingAuth are refused so that half-completed pairing attempts can't
+// grow the table without bound.
+func (h *Hub) addPendingAuth(auth *PendingAuth) error {
+	h.tokenMu.Lock()
+	defer h.tokenMu.Unlock()
+
+	if _, exists := h.pendingAuth[auth.ConnectionID]; !exists &&
+		h.security.MaxPendingAuth > 0 && len(h.pendingAuth) >= h.security.MaxPendingAuth {
+		h.logger.Warn("Pending auth limit reached",
+			zap.String("connection", auth.ConnectionID),
+			zap.Int("max", h.security.MaxPendingAuth))
+		return ErrTooManyPendingAuth
+	}
+
+	h.pendingAuth[auth.ConnectionID] = auth
+	return nil
+}
+
+// removePendingAuth drops a pending entry once it completes or fails
+func (h *Hub) removePendingAuth(connID string) {
+	h.tokenMu.Lock()
+	defer h.tokenMu.Unlock()
+	delete(h.pendingAuth, connID)
+}
+
+// cleanupPendingAuth removes entries past their ExpiresAt and disconnects
+// the connections that were still waiting on them
+func (h *Hub) cleanupPendingAuth() {
+	h.tokenMu.Lock()
+	var expired []*PendingAuth
+	now := time.Now()
+	for id, auth := range h.pendingAuth {
+		if now.After(auth.ExpiresAt) {
+			delete(h.pendingAuth, id)
+			expired = append(expired, auth)
+			h.logger.Info("Pending auth expired", zap.String("connection", id))
+		}
+	}
+	h.tokenMu.Unlock()
+
+	for _, auth := range expired {
+		h.rejectPendingAuth(auth, CodePINExpired, "PIN expired")
+	}
+}
+
+// PendingAuthCount returns the number of connections awaiting verification
+func (h *Hub) PendingAuthCount() int {
+	h.tokenMu.RLock()
+	defer h.tokenMu.RUnlock()
+	return len(h.pendingAuth)
+}
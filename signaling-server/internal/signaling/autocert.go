@@ -0,0 +1,111 @@
+package signaling
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Self-signed certificates are renewed once less than this much validity
+// is left, so a restart shortly before expiry doesn't serve a stale one
+const selfSignedRenewBefore = 7 * 24 * time.Hour
+
+// EnsureSelfSigned returns the paths of a self-signed key pair in dir,
+// generating one if none exists or the existing one is about to expire.
+// An existing pair is kept otherwise, even if the host's addresses
+// changed, so clients that pinned it keep working. It reports whether a
+// new pair was generated.
+func EnsureSelfSigned(dir string, names []string, ips []string, validity time.Duration) (certFile, keyFile string, created bool, err error) {
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+
+	if notAfter, err := certExpiry(certFile); err == nil && time.Until(notAfter) > selfSignedRenewBefore {
+		if _, err := os.Stat(keyFile); err == nil {
+			return certFile, keyFile, false, nil
+		}
+	}
+
+	certPEM, keyPEM, err := GenerateSelfSigned(names, ips, validity)
+	if err != nil {
+		return "", "", false, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", false, err
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return "", "", false, err
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return "", "", false, err
+	}
+	return certFile, keyFile, true, nil
+}
+
+// GenerateSelfSigned creates a PEM-encoded ECDSA P-256 certificate and key
+// for the given host names and IP addresses
+func GenerateSelfSigned(names []string, ips []string, validity time.Duration) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"StreamLinux"}, CommonName: "StreamLinux signaling server"},
+		NotBefore:             now.Add(-time.Hour), // Tolerate clients with a slightly slow clock
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              names,
+	}
+	for _, s := range ips {
+		host, _, _ := strings.Cut(s, "%") // Drop an IPv6 zone
+		if ip := net.ParseIP(host); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// certExpiry returns the NotAfter of the first certificate in a PEM file
+func certExpiry(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, os.ErrInvalid
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
@@ -0,0 +1,107 @@
+package signaling
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pairingTokenVersion prefixes self-contained pairing tokens
+const pairingTokenVersion = "slp1"
+
+// Self-contained pairing tokens let a display that shares the pairing key
+// with the server mint a QR code without asking the server for a token.
+// The token is its own proof: the server recomputes the HMAC over the
+// room, expiry and nonce with the shared key.
+//
+//	slp1.<base64url(room)>.<expiry unix>.<nonce hex>.<base64url(hmac-sha256)>
+//
+// The MAC input is "slp1|<room>|<expiry>|<nonce>".
+
+// SetPairingKey enables self-contained pairing tokens signed with key.
+// A nil or empty key disables them.
+func (h *Hub) SetPairingKey(key []byte) {
+	h.tokenMu.Lock()
+	defer h.tokenMu.Unlock()
+	h.pairingKey = append([]byte(nil), key...)
+}
+
+// MintPairingToken returns a self-contained pairing token for room valid for ttl
+func (h *Hub) MintPairingToken(room string, ttl time.Duration) (string, time.Time, error) {
+	h.tokenMu.RLock()
+	key := h.pairingKey
+	h.tokenMu.RUnlock()
+
+	if len(key) == 0 {
+		return "", time.Time{}, fmt.Errorf("pairing key not configured")
+	}
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", time.Time{}, err
+	}
+
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	expiry := strconv.FormatInt(expires.Unix(), 10)
+	nonceHex := hex.EncodeToString(nonce)
+	mac := pairingMAC(key, room, expiry, nonceHex)
+
+	token := strings.Join([]string{
+		pairingTokenVersion,
+		base64.RawURLEncoding.EncodeToString([]byte(room)),
+		expiry,
+		nonceHex,
+		base64.RawURLEncoding.EncodeToString(mac),
+	}, ".")
+	return token, expires, nil
+}
+
+// verifyPairingToken checks a self-contained pairing token and returns
+// the room it was minted for
+func (h *Hub) verifyPairingToken(token string) (string, bool) {
+	if !strings.HasPrefix(token, pairingTokenVersion+".") {
+		return "", false
+	}
+
+	h.tokenMu.RLock()
+	key := h.pairingKey
+	h.tokenMu.RUnlock()
+	if len(key) == 0 {
+		return "", false
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return "", false
+	}
+
+	room, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", false
+	}
+	expiry, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().After(time.Unix(expiry, 0)) {
+		return "", false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[4])
+	if err != nil {
+		return "", false
+	}
+
+	if !hmac.Equal(mac, pairingMAC(key, string(room), parts[2], parts[3])) {
+		return "", false
+	}
+	return string(room), true
+}
+
+func pairingMAC(key []byte, room, expiry, nonce string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(pairingTokenVersion + "|" + room + "|" + expiry + "|" + nonce))
+	return m.Sum(nil)
+}
@@ -0,0 +1,254 @@
+package signaling
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CapabilitiesVersion is the capability schema version this server understands
+const CapabilitiesVersion = 1
+
+// candidateGatherTimeout is how long candidates for a non-trickle peer are
+// held back waiting for end-of-candidates before being flushed anyway
+const candidateGatherTimeout = 2 * time.Second
+
+// Capabilities are declared by a peer in its register message and let
+// the hub adapt routing to WebRTC stacks with different feature sets.
+// Unknown fields in Extensions are kept so newer clients can declare
+// features older servers merely carry along.
+type Capabilities struct {
+	Version       int                        `json:"version"`
+	Trickle       *bool                      `json:"trickle,omitempty"`         // Accepts individual trickled candidates (default true)
+	MaxBundleSize int                        `json:"max_bundle_size,omitempty"` // Max candidates per bundled delivery (0 = unlimited)
+	Batches       bool                       `json:"batches,omitempty"`         // Also accepts MsgTypeCandidates batches while trickling
+	Renegotiation bool                       `json:"renegotiation,omitempty"`   // Supports offers after the initial exchange
+	Extensions    map[string]json.RawMessage `json:"extensions,omitempty"`
+}
+
+// trickle reports whether the peer wants candidates delivered one by one
+func (c *Capabilities) trickle() bool {
+	return c == nil || c.Trickle == nil || *c.Trickle
+}
+
+// negotiateCapabilities checks declared capabilities against the schema
+// version this server understands. A newer version is downgraded to ours:
+// fields are only ever added, so the ones we know keep their meaning and
+// the client learns our version from registered. A version below 1 isn't
+// one any client was built against and is refused.
+func negotiateCapabilities(c *Capabilities) (*Capabilities, bool) {
+	switch {
+	case c == nil || c.Version == CapabilitiesVersion:
+		return c, true
+	case c.Version < 1:
+		return nil, false
+	}
+	downgraded := *c
+	downgraded.Version = CapabilitiesVersion
+	return &downgraded, true
+}
+
+// serverCapabilities is what the hub announces in registered: Batches
+// means peers may send MsgTypeCandidates
+func serverCapabilities() *Capabilities {
+	return &Capabilities{Version: CapabilitiesVersion, Batches: true}
+}
+
+// acceptsBatches reports whether the peer can parse MsgTypeCandidates
+func (c *Capabilities) acceptsBatches() bool {
+	return !c.trickle() || (c != nil && c.Batches)
+}
+
+// candidateEntry is one candidate in a MsgTypeCandidates payload
+type candidateEntry struct {
+	Candidate     string `json:"candidate"`
+	SDPMid        string `json:"sdpMid,omitempty"`
+	SDPMLineIndex int    `json:"sdpMLineIndex"`
+}
+
+// candidateBuffer holds candidates from one sender for a non-trickle peer
+type candidateBuffer struct {
+	from    string
+	entries []json.RawMessage
+	timer   *time.Timer
+}
+
+func candidateKey(from, to string) string {
+	return from + "->" + to
+}
+
+// candidateJSON extracts a candidate in its wire form, the payload of a
+// normalized message or the flat fields of one built by the hub
+func candidateJSON(msg *Message) json.RawMessage {
+	if len(msg.Payload) > 0 {
+		return msg.Payload
+	}
+	data, _ := json.Marshal(candidateEntry{
+		Candidate:     msg.Candidate,
+		SDPMid:        msg.SDPMid,
+		SDPMLineIndex: msg.SDPMLineIndex,
+	})
+	return data
+}
+
+func isCandidate(t MessageType) bool {
+	return t == MsgTypeCandidate || t == MsgTypeIceCandidate
+}
+
+// deliverSignal sends an offer/answer/candidate to target, adapting to its
+// capabilities. Candidates for peers that can't trickle are buffered and
+// delivered together as one MsgTypeCandidates message, when the sender
+// signals end-of-candidates (an empty candidate), the target's
+// MaxBundleSize is reached, or candidateGatherTimeout elapses. With
+// HubConfig.CandidateBatchWindow set, candidates for trickling peers that
+// accept batches are coalesced the same way over that window.
+func (h *Hub) deliverSignal(target *Peer, msg *Message) {
+	h.trackNegotiation(msg, target)
+
+	switch {
+	case msg.Type == MsgTypeCandidates:
+		h.deliverCandidateBatch(target, msg)
+
+	case !isCandidate(msg.Type):
+		h.sendToPeer(target, msg)
+
+	case !target.Capabilities.trickle():
+		h.bufferCandidate(target, msg, candidateGatherTimeout)
+
+	case h.config.CandidateBatchWindow > 0 && target.Capabilities.acceptsBatches():
+		if h.bufferCandidate(target, msg, h.config.CandidateBatchWindow) {
+			h.sendToPeer(target, msg) // A trickling peer still wants the marker
+		}
+
+	default:
+		h.sendToPeer(target, msg)
+	}
+}
+
+// bufferCandidate adds msg to the batch from its sender to target, which
+// is flushed after timeout. It reports whether msg was end-of-candidates.
+func (h *Hub) bufferCandidate(target *Peer, msg *Message, timeout time.Duration) bool {
+	key := candidateKey(msg.From, target.ID)
+
+	h.candidateMu.Lock()
+	buf, ok := h.candidateBuffers[key]
+	if !ok {
+		buf = &candidateBuffer{from: msg.From}
+		buf.timer = time.AfterFunc(timeout, func() {
+			select {
+			case h.flushCandidates <- key:
+			case <-h.done:
+			}
+		})
+		h.candidateBuffers[key] = buf
+	}
+	endOfCandidates := msg.Candidate == ""
+	if !endOfCandidates {
+		buf.entries = append(buf.entries, candidateJSON(msg))
+	}
+	full := target.Capabilities.MaxBundleSize > 0 && len(buf.entries) >= target.Capabilities.MaxBundleSize
+	h.candidateMu.Unlock()
+
+	if endOfCandidates || full {
+		h.flushCandidateBuffer(key, target)
+	}
+	return endOfCandidates
+}
+
+// deliverCandidateBatch routes a MsgTypeCandidates batch from a peer.
+// Targets that accept batches get it as one message, merged with any
+// candidates already buffered for them; older clients get the candidates
+// one by one.
+func (h *Hub) deliverCandidateBatch(target *Peer, msg *Message) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(msg.Payload, &entries); err != nil {
+		return // Rejected by validation before routing
+	}
+
+	if !target.Capabilities.acceptsBatches() {
+		for _, raw := range entries {
+			var c candidateEntry
+			if json.Unmarshal(raw, &c) != nil {
+				continue
+			}
+			h.sendToPeer(target, &Message{
+				Type:          MsgTypeIceCandidate,
+				From:          msg.From,
+				To:            target.ID,
+				Candidate:     c.Candidate,
+				SDPMid:        c.SDPMid,
+				SDPMLineIndex: c.SDPMLineIndex,
+				Payload:       raw,
+			})
+		}
+		return
+	}
+
+	key := candidateKey(msg.From, target.ID)
+	h.candidateMu.Lock()
+	buf, pending := h.candidateBuffers[key]
+	if pending {
+		buf.entries = append(buf.entries, entries...)
+	}
+	h.candidateMu.Unlock()
+
+	if pending {
+		h.flushCandidateBuffer(key, target)
+		return
+	}
+	h.sendToPeer(target, &Message{
+		Type:      MsgTypeCandidates,
+		From:      msg.From,
+		To:        target.ID,
+		Payload:   msg.Payload,
+		MessageID: msg.MessageID,
+	})
+}
+
+// flushCandidateBuffer delivers and clears a buffered candidate batch.
+// Must be called with h.mu held (read or write) when target is nil.
+func (h *Hub) flushCandidateBuffer(key string, target *Peer) {
+	h.candidateMu.Lock()
+	buf, ok := h.candidateBuffers[key]
+	if ok {
+		delete(h.candidateBuffers, key)
+		buf.timer.Stop()
+	}
+	h.candidateMu.Unlock()
+	if !ok || len(buf.entries) == 0 {
+		return
+	}
+
+	if target == nil {
+		toID := key[len(buf.from)+len("->"):]
+		if target, ok = h.peers[toID]; !ok {
+			return
+		}
+	}
+
+	payload, err := json.Marshal(buf.entries)
+	if err != nil {
+		h.logger.Error("Failed to marshal candidate batch", zap.Error(err))
+		return
+	}
+	h.sendToPeer(target, &Message{
+		Type:    MsgTypeCandidates,
+		From:    buf.from,
+		To:      target.ID,
+		Payload: payload,
+	})
+}
+
+// dropCandidateBuffers discards batches to or from a departing peer
+func (h *Hub) dropCandidateBuffers(peerID string) {
+	h.candidateMu.Lock()
+	defer h.candidateMu.Unlock()
+
+	for key, buf := range h.candidateBuffers {
+		if buf.from == peerID || key[len(buf.from)+len("->"):] == peerID {
+			buf.timer.Stop()
+			delete(h.candidateBuffers, key)
+		}
+	}
+}
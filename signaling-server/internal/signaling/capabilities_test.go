@@ -0,0 +1,49 @@
+package signaling
+
+import "testing"
+
+func TestNegotiateCapabilities(t *testing.T) {
+	noTrickle := false
+	for _, tc := range []struct {
+		name    string
+		in      *Capabilities
+		version int
+		ok      bool
+	}{
+		{"none", nil, 0, true},
+		{"current", &Capabilities{Version: CapabilitiesVersion}, CapabilitiesVersion, true},
+		{"newer", &Capabilities{Version: CapabilitiesVersion + 1, Trickle: &noTrickle}, CapabilitiesVersion, true},
+		{"missing", &Capabilities{Trickle: &noTrickle}, 0, false},
+		{"negative", &Capabilities{Version: -1}, 0, false},
+	} {
+		got, ok := negotiateCapabilities(tc.in)
+		if ok != tc.ok {
+			t.Errorf("%s: ok = %v, want %v", tc.name, ok, tc.ok)
+			continue
+		}
+		if got != nil && got.Version != tc.version {
+			t.Errorf("%s: version %d, want %d", tc.name, got.Version, tc.version)
+		}
+	}
+
+	newer := &Capabilities{Version: CapabilitiesVersion + 1, Trickle: &noTrickle}
+	if got, _ := negotiateCapabilities(newer); got.trickle() || newer.Version != CapabilitiesVersion+1 {
+		t.Fatal("downgrade dropped known fields or modified the declaration")
+	}
+}
+
+func TestRegisterUnknownCapabilitiesVersion(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	c := s.mustDial("")
+
+	noTrickle := false
+	c.send(Message{Type: MsgTypeRegister, Role: RoleClient, Capabilities: &Capabilities{Trickle: &noTrickle}})
+	if code := c.expectError(); code != CodeInvalidRegistration {
+		t.Fatalf("unversioned capabilities: error %s, want %s", code, CodeInvalidRegistration)
+	}
+
+	c.send(Message{Type: MsgTypeRegister, Role: RoleClient, Capabilities: &Capabilities{Version: CapabilitiesVersion + 1}})
+	if reg := c.expect(MsgTypeRegistered); reg.Capabilities.Version != CapabilitiesVersion {
+		t.Fatalf("registered announces version %d, want %d", reg.Capabilities.Version, CapabilitiesVersion)
+	}
+}
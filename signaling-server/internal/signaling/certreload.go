@@ -0,0 +1,137 @@
+package signaling
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// certCheckInterval is how often handshakes check the key pair for changes
+const certCheckInterval = 5 * time.Second
+
+// CertReloader serves the key pair at certFile/keyFile, picking up a
+// renewed certificate on the next handshake instead of at restart. Use its
+// GetCertificate in the server's tls.Config.
+type CertReloader struct {
+	certFile, keyFile string
+	logger            *zap.Logger
+
+	mu          sync.RWMutex
+	cert        *tls.Certificate
+	fingerprint string
+	certStamp   fileStamp
+	keyStamp    fileStamp
+	checkedAt   time.Time
+	onReload    func(fingerprint string)
+}
+
+// fileStamp identifies a version of a file well enough to notice renewals
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stampFile(path string) (fileStamp, error) {
+	fi, err := os.Stat(path) // Follows certbot's live/ symlinks
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+// NewCertReloader loads the key pair, failing if it can't be used
+func NewCertReloader(certFile, keyFile string, logger *zap.Logger) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// OnReload registers fn to be called with the new fingerprint after a
+// different certificate was loaded
+func (r *CertReloader) OnReload(fn func(fingerprint string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onReload = fn
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the served certificate
+func (r *CertReloader) Fingerprint() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.fingerprint
+}
+
+// Reload re-reads the key pair now, e.g. on SIGHUP. On failure the
+// current certificate keeps being served.
+func (r *CertReloader) Reload() error {
+	certStamp, err := stampFile(r.certFile)
+	if err != nil {
+		return err
+	}
+	keyStamp, err := stampFile(r.keyFile)
+	if err != nil {
+		return err
+	}
+	cert, fingerprint, err := LoadCertificate(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	changed := fingerprint != r.fingerprint
+	first := r.cert == nil
+	r.cert = &cert
+	r.fingerprint = fingerprint
+	r.certStamp, r.keyStamp = certStamp, keyStamp
+	r.checkedAt = time.Now()
+	onReload := r.onReload
+	r.mu.Unlock()
+
+	if changed && !first {
+		r.logger.Info("TLS certificate reloaded", zap.String("sha256", fingerprint))
+		if onReload != nil {
+			onReload(fingerprint)
+		}
+	}
+	return nil
+}
+
+// GetCertificate serves the current certificate, first reloading it if
+// the files changed since the last check
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	cert := r.cert
+	due := time.Since(r.checkedAt) >= certCheckInterval
+	r.mu.RUnlock()
+
+	if due && r.filesChanged() {
+		if err := r.Reload(); err != nil {
+			// Typically mid-renewal with only one file replaced so far
+			r.logger.Warn("TLS certificate reload failed, serving previous certificate", zap.Error(err))
+		} else {
+			r.mu.RLock()
+			cert = r.cert
+			r.mu.RUnlock()
+		}
+	}
+	return cert, nil
+}
+
+// filesChanged stats the key pair and records the check time
+func (r *CertReloader) filesChanged() bool {
+	certStamp, certErr := stampFile(r.certFile)
+	keyStamp, keyErr := stampFile(r.keyFile)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkedAt = time.Now()
+	if certErr != nil || keyErr != nil {
+		return false
+	}
+	return certStamp != r.certStamp || keyStamp != r.keyStamp
+}
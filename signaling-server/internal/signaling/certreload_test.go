@@ -0,0 +1,103 @@
+package signaling
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// writeKeyPair writes a fresh self-signed pair to certFile/keyFile, dated
+// at so successive pairs are told apart by modification time
+func writeKeyPair(t *testing.T, certFile, keyFile string, at time.Time) {
+	t.Helper()
+	certPEM, keyPEM, err := GenerateSelfSigned([]string{"localhost"}, nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, data := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// servedFingerprint completes a TLS handshake with config and returns the
+// fingerprint of the leaf the server presented
+func servedFingerprint(t *testing.T, config *tls.Config) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	leaf := conn.ConnectionState().PeerCertificates[0]
+	return CertFingerprint(tls.Certificate{Certificate: [][]byte{leaf.Raw}})
+}
+
+func TestCertReloaderPicksUpRenewal(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	now := time.Now()
+	writeKeyPair(t, certFile, keyFile, now.Add(-time.Hour))
+
+	r, err := NewCertReloader(certFile, keyFile, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reloaded []string
+	r.OnReload(func(fp string) { reloaded = append(reloaded, fp) })
+	config := &tls.Config{GetCertificate: r.GetCertificate}
+
+	old := r.Fingerprint()
+	if got := servedFingerprint(t, config); got != old {
+		t.Fatalf("served %s, want the loaded %s", got, old)
+	}
+
+	writeKeyPair(t, certFile, keyFile, now)
+	r.mu.Lock()
+	r.checkedAt = time.Time{} // Don't wait out certCheckInterval
+	r.mu.Unlock()
+
+	renewed := servedFingerprint(t, config)
+	if renewed == old || renewed != r.Fingerprint() {
+		t.Fatalf("served %s after renewal, want a new leaf (old %s)", renewed, old)
+	}
+	if len(reloaded) != 1 || reloaded[0] != renewed {
+		t.Fatalf("OnReload calls %v, want one with %s", reloaded, renewed)
+	}
+
+	// Halfway through the next renewal only the certificate is replaced;
+	// the pair doesn't match and the current one stays in service
+	certPEM, _, err := GenerateSelfSigned([]string{"localhost"}, nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("mismatched pair loaded")
+	}
+	if got := servedFingerprint(t, config); got != renewed {
+		t.Fatalf("served %s mid-renewal, want %s", got, renewed)
+	}
+}
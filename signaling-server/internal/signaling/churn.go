@@ -0,0 +1,92 @@
+package signaling
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeviceChurn describes a device seen with many distinct tokens
+type DeviceChurn struct {
+	DeviceID string `json:"device_id"`
+	Tokens   int    `json:"tokens"`
+}
+
+// TokenChurnStats summarizes device/token churn tracking
+type TokenChurnStats struct {
+	TrackedDevices int           `json:"tracked_devices"`
+	Flagged        []DeviceChurn `json:"flagged"`
+}
+
+// churnTracker records which tokens each device_id connected with. Only
+// token digests are kept; the full credential is never retained here.
+// Prefixes wouldn't do, since tokens such as JWTs all begin alike.
+type churnTracker struct {
+	mu      sync.Mutex
+	devices map[string]map[string]time.Time // device -> tokenDigest -> last seen
+}
+
+// observe records a connection and returns the number of distinct tokens
+// the device used within window
+func (t *churnTracker) observe(deviceID, token string, window time.Duration) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.devices == nil {
+		t.devices = make(map[string]map[string]time.Time)
+	}
+	tokens, ok := t.devices[deviceID]
+	if !ok {
+		tokens = make(map[string]time.Time)
+		t.devices[deviceID] = tokens
+	}
+
+	now := time.Now()
+	tokens[tokenDigest(token)] = now
+	for digest, seen := range tokens {
+		if now.Sub(seen) > window {
+			delete(tokens, digest)
+		}
+	}
+	return len(tokens)
+}
+
+// prune forgets tokens and devices not seen within window
+func (t *churnTracker) prune(window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for device, tokens := range t.devices {
+		for digest, seen := range tokens {
+			if now.Sub(seen) > window {
+				delete(tokens, digest)
+			}
+		}
+		if len(tokens) == 0 {
+			delete(t.devices, device)
+		}
+	}
+}
+
+func (t *churnTracker) snapshot(threshold int) TokenChurnStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := TokenChurnStats{
+		TrackedDevices: len(t.devices),
+		Flagged:        make([]DeviceChurn, 0),
+	}
+	if threshold <= 0 {
+		return stats
+	}
+	for device, tokens := range t.devices {
+		if len(tokens) > threshold {
+			stats.Flagged = append(stats.Flagged, DeviceChurn{DeviceID: device, Tokens: len(tokens)})
+		}
+	}
+	sort.Slice(stats.Flagged, func(i, j int) bool {
+		return stats.Flagged[i].Tokens > stats.Flagged[j].Tokens
+	})
+	return stats
+}
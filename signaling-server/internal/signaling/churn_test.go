@@ -0,0 +1,37 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChurnTrackerCountsDistinctTokens(t *testing.T) {
+	var c churnTracker
+	// JWTs share their first characters; each must count separately
+	tokens := []string{
+		"eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.a",
+		"eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIyIn0.b",
+		"eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIzIn0.c",
+	}
+	for i, token := range tokens {
+		if n := c.observe("phone", token, time.Minute); n != i+1 {
+			t.Fatalf("after %d tokens observe = %d", i+1, n)
+		}
+	}
+	if n := c.observe("phone", tokens[0], time.Minute); n != len(tokens) {
+		t.Fatalf("repeated token counted again: %d", n)
+	}
+	if n := c.observe("tablet", tokens[0], time.Minute); n != 1 {
+		t.Fatalf("other device: %d", n)
+	}
+
+	stats := c.snapshot(2)
+	if stats.TrackedDevices != 2 || len(stats.Flagged) != 1 || stats.Flagged[0].DeviceID != "phone" {
+		t.Fatalf("snapshot = %+v", stats)
+	}
+
+	c.prune(-time.Second)
+	if stats := c.snapshot(2); stats.TrackedDevices != 0 {
+		t.Fatalf("after prune tracking %d devices", stats.TrackedDevices)
+	}
+}
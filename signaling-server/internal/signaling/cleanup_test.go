@@ -0,0 +1,51 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestCleanupRooms(t *testing.T) {
+	cfg := DefaultHubConfig()
+	cfg.EmptyRoomGrace = time.Minute
+	h := NewHubWithConfig(zap.NewNop(), 10*time.Minute, DefaultSecurityConfig(), cfg)
+
+	start := time.Unix(1_700_000_000, 0)
+	client := &Peer{ID: "c", Role: RoleClient}
+	h.rooms["emptied"] = &Room{ID: "emptied", Clients: map[string]*Peer{}, LastActive: start, emptySince: start}
+	h.rooms["never-joined"] = &Room{ID: "never-joined", Clients: map[string]*Peer{}, LastActive: start}
+	h.rooms["busy"] = &Room{ID: "busy", Clients: map[string]*Peer{"c": client}, LastActive: start}
+
+	rooms := func() map[string]bool {
+		ids := map[string]bool{}
+		for id := range h.rooms {
+			ids[id] = true
+		}
+		return ids
+	}
+
+	// Within the grace an empty room survives, so a phone waking up
+	// rejoins the same room
+	h.cleanupRooms(start.Add(59 * time.Second))
+	if got := rooms(); len(got) != 3 {
+		t.Fatalf("within grace: rooms %v", got)
+	}
+
+	// Past the grace the empty rooms go, the busy one stays
+	h.cleanupRooms(start.Add(time.Minute))
+	if got := rooms(); len(got) != 1 || !got["busy"] {
+		t.Fatalf("after grace: rooms %v, want only busy", got)
+	}
+
+	// A room with members goes once it has been idle past the timeout
+	h.cleanupRooms(start.Add(10 * time.Minute))
+	if !rooms()["busy"] {
+		t.Fatal("busy room removed at exactly the timeout")
+	}
+	h.cleanupRooms(start.Add(10*time.Minute + time.Second))
+	if got := rooms(); len(got) != 0 {
+		t.Fatalf("after timeout: rooms %v", got)
+	}
+}
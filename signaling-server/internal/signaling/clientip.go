@@ -0,0 +1,58 @@
+package signaling
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the address of the client behind r. When the direct
+// peer is one of SecurityConfig.TrustedProxies the address is taken from
+// X-Forwarded-For, walked from the right so a client can't prepend a
+// forged entry, or else X-Real-IP. known is false when a trusted proxy
+// didn't say whom it forwards for, ip then being the proxy's own address,
+// and when every forwarded hop is itself a trusted proxy, since the
+// leftmost of those may have been written by the client.
+func (h *Hub) ClientIP(r *http.Request) (ip net.IP, known bool) {
+	ip = net.ParseIP(remoteIP(r.RemoteAddr))
+	trusted := h.security.TrustedProxies
+	if ip == nil || !containsIP(trusted, ip) {
+		return ip, ip != nil
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				break
+			}
+			if !containsIP(trusted, hop) {
+				return hop, true
+			}
+			ip = hop
+		}
+		return ip, false
+	}
+	if real := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); real != nil {
+		return real, true
+	}
+	return ip, false
+}
+
+// IsLocalRequest reports whether r comes straight from this machine. Only
+// the socket peer counts: forwarding headers never make a request local,
+// and neither does a trusted proxy on localhost, which forwards for
+// clients elsewhere.
+func (h *Hub) IsLocalRequest(r *http.Request) bool {
+	ip := net.ParseIP(remoteIP(r.RemoteAddr))
+	return ip != nil && ip.IsLoopback() && !containsIP(h.security.TrustedProxies, ip)
+}
+
+// clientKey is the client address as a rate limit key
+func (h *Hub) clientKey(r *http.Request) string {
+	if ip, _ := h.ClientIP(r); ip != nil {
+		return ip.String()
+	}
+	return remoteIP(r.RemoteAddr)
+}
@@ -0,0 +1,56 @@
+package signaling
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestClientIP(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	var err error
+	if sec.TrustedProxies, err = ParseCIDRs([]string{"127.0.0.1", "10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHubWithSecurity(zap.NewNop(), 0, sec)
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		realIP    string
+		ip        string
+		known     bool
+		local     bool
+	}{
+		{name: "direct", remote: "192.0.2.1:1000", ip: "192.0.2.1", known: true},
+		{name: "direct loopback", remote: "127.0.0.2:1000", ip: "127.0.0.2", known: true, local: true},
+		{name: "untrusted proxy ignored", remote: "192.0.2.1:1000", forwarded: "203.0.113.9", ip: "192.0.2.1", known: true},
+		{name: "trusted proxy", remote: "127.0.0.1:1000", forwarded: "203.0.113.9", ip: "203.0.113.9", known: true},
+		{name: "forged leftmost", remote: "127.0.0.1:1000", forwarded: "198.51.100.1, 203.0.113.9, 10.1.1.1", ip: "203.0.113.9", known: true},
+		{name: "all hops trusted", remote: "127.0.0.1:1000", forwarded: "127.0.0.1", ip: "127.0.0.1", known: false},
+		{name: "all hops trusted chain", remote: "10.0.0.1:1000", forwarded: "127.0.0.1, 10.2.2.2", ip: "127.0.0.1", known: false},
+		{name: "real ip", remote: "127.0.0.1:1000", realIP: "203.0.113.7", ip: "203.0.113.7", known: true},
+		{name: "proxy names nobody", remote: "127.0.0.1:1000", ip: "127.0.0.1", known: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ws", nil)
+			r.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			ip, known := h.ClientIP(r)
+			if ip.String() != tt.ip || known != tt.known {
+				t.Errorf("ClientIP = %s, %v; want %s, %v", ip, known, tt.ip, tt.known)
+			}
+			if local := h.IsLocalRequest(r); local != tt.local {
+				t.Errorf("IsLocalRequest = %v, want %v", local, tt.local)
+			}
+		})
+	}
+}
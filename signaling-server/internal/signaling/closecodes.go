@@ -0,0 +1,95 @@
+package signaling
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket close codes sent when the server ends or refuses a session,
+// from the 4000-4999 range reserved for applications. The close reason
+// repeats the error message; clients should switch on the code. Other
+// handshake refusals, such as overload, stay plain HTTP errors with an
+// X-Error-Code header.
+const (
+	CloseRateLimited     = 4001 // Too many connection or authentication attempts
+	CloseAuthFailed      = 4002 // Token or room password rejected
+	CloseDraining        = 4003 // Server is shutting down; reconnect to another instance
+	CloseKicked          = 4004 // Removed from the room by its host
+	CloseSlowConsumer    = 4005 // Send buffer overflowed; reconnect
+	CloseTokenRevoked    = 4006 // Token was revoked by an administrator
+	CloseReplaced        = 4007 // A newer connection from the same device took over
+	CloseAdminDisconnect = 4008 // Disconnected by the server administrator
+	CloseHostLeft        = 4009 // Room host left and the room was closed
+	CloseMessageTooLarge = 4010 // Message exceeded the size limit
+)
+
+// maxCloseReasonLength is the control frame payload limit minus the code
+const maxCloseReasonLength = 123
+
+// closeCodes maps the error a peer is disconnected with to its close code
+var closeCodes = map[ErrorCode]int{
+	CodeRateLimited:      CloseRateLimited,
+	CodeBadToken:         CloseAuthFailed,
+	CodeRoomPassword:     CloseAuthFailed,
+	CodeServerDraining:   CloseDraining,
+	CodeKicked:           CloseKicked,
+	CodeTokenRevoked:     CloseTokenRevoked,
+	CodeReplaced:         CloseReplaced,
+	CodeServerDisconnect: CloseAdminDisconnect,
+	CodeHostLeft:         CloseHostLeft,
+	CodeTooLarge:         CloseMessageTooLarge,
+}
+
+// rejectWebSocket refuses a handshake with code. Browsers can't read the
+// status of a failed WebSocket handshake, so for codes with a close code
+// the upgrade is completed and closed straight away with it. The
+// X-Error-Code header is sent either way.
+func rejectWebSocket(w http.ResponseWriter, r *http.Request, code ErrorCode, message string, status int) {
+	closeCode, ok := closeCodes[code]
+	if !ok || !websocket.IsWebSocketUpgrade(r) {
+		httpError(w, code, message, status)
+		return
+	}
+	// Browsers drop a connection that was offered subprotocols and
+	// selected none before the close frame can be read
+	u := upgrader
+	u.Subprotocols = supportedSubprotocols
+	conn, err := u.Upgrade(w, r, http.Header{errorCodeHeader: {string(code)}})
+	if err != nil {
+		return // Upgrade already replied with an HTTP error
+	}
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(closeCode, closeReason(message)),
+		time.Now().Add(time.Second))
+	conn.Close()
+}
+
+func closeReason(reason string) string {
+	if len(reason) > maxCloseReasonLength {
+		return reason[:maxCloseReasonLength]
+	}
+	return reason
+}
+
+// setCloseReason records the close frame writePump sends when the peer's
+// Send channel is closed. The first reason set wins.
+func (p *Peer) setCloseReason(code int, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closeFrame == nil {
+		p.closeFrame = websocket.FormatCloseMessage(code, closeReason(reason))
+	}
+}
+
+// disconnectWithError tells peer why it is being dropped, then removes it.
+// The error is flushed before the connection closes with the matching
+// close code. Must be called with h.mu held for writing.
+func (h *Hub) disconnectWithError(peer *Peer, code ErrorCode, message string) {
+	if closeCode, ok := closeCodes[code]; ok {
+		peer.setCloseReason(closeCode, message)
+	}
+	h.sendError(peer, code, message)
+	h.removePeerLocked(peer)
+}
@@ -0,0 +1,106 @@
+package signaling
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func newCompressionServer(t testing.TB, enable bool) *testServer {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	s.ws.EnableCompression = enable
+	s.ws.CompressionThreshold = 256
+	s.dialer = &websocket.Dialer{EnableCompression: true}
+	return s
+}
+
+func TestCompressionNegotiation(t *testing.T) {
+	for _, enable := range []bool{true, false} {
+		s := newCompressionServer(t, enable)
+		_, resp, err := s.dial("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ext := resp.Header.Get("Sec-WebSocket-Extensions")
+		if got := strings.Contains(ext, "permessage-deflate"); got != enable {
+			t.Errorf("compression enabled %v: extensions %q", enable, ext)
+		}
+	}
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	s := newCompressionServer(t, true)
+	host, _ := s.host("token")
+	host.join("r", RoleHost)
+	host.EnableWriteCompression(true)
+	c, id := s.client("")
+	c.EnableWriteCompression(true)
+	c.join("r", RoleClient)
+	host.expect(MsgTypeJoin)
+
+	// Large enough to be compressed on the way out as well
+	sdp := "v=0\r\n" + strings.Repeat("a=candidate:1 1 udp 2122260223 192.168.1.20 50000 typ host\r\n", 100)
+	c.send(Message{Type: MsgTypeOffer, Room: "r", SDP: sdp})
+	offer := host.expect(MsgTypeOffer)
+	if offer.SDP != sdp || offer.From != id {
+		t.Fatalf("offer from %q with %d byte sdp, want %d bytes from %q", offer.From, len(offer.SDP), len(sdp), id)
+	}
+}
+
+func TestCompressionBombRejected(t *testing.T) {
+	s := newCompressionServer(t, true)
+	s.ws.MaxMessageSize = 16 * 1024
+	c, _ := s.client("")
+	c.EnableWriteCompression(true)
+
+	// A megabyte of padding deflates to about a kilobyte on the wire
+	bomb := `{"type":"app:x","name":"` + strings.Repeat("a", 1<<20) + `"}`
+	if err := c.WriteMessage(websocket.TextMessage, []byte(bomb)); err != nil {
+		t.Fatal(err)
+	}
+	if code := c.expectError(); code != CodeTooLarge {
+		t.Fatalf("got %s, want %s", code, CodeTooLarge)
+	}
+	if code := c.expectClose(); code != CloseMessageTooLarge {
+		t.Fatalf("close code %d, want %d", code, CloseMessageTooLarge)
+	}
+}
+
+// BenchmarkCompressionThreshold sends small signaling messages, such as
+// acks and candidates, through a peer's write pump to a client that
+// negotiated permessage-deflate, with every message compressed and with
+// the default threshold. The difference is the deflate CPU saved.
+func BenchmarkCompressionThreshold(b *testing.B) {
+	for _, bench := range []struct {
+		name      string
+		threshold int
+	}{
+		{"compress-all", 0},
+		{"threshold-512", 512},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			s := newCompressionServer(b, true)
+			s.ws.CompressionThreshold = bench.threshold
+			c, id := s.client("")
+			s.hub.mu.RLock()
+			peer := s.hub.peers[id]
+			s.hub.mu.RUnlock()
+
+			msg := &Message{Type: MsgTypeCandidate, From: "host", To: id,
+				Candidate: "candidate:1 1 udp 2122260223 192.168.1.20 50000 typ host", SDPMid: "0"}
+			// One message in flight at a time, so the send buffer never
+			// fills and each op is one write and read through deflate
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.hub.mu.RLock()
+				s.hub.sendToPeer(peer, msg)
+				s.hub.mu.RUnlock()
+				if _, _, err := c.ReadMessage(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package signaling
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// connIDHeader carries the connection ID on every handshake response, so
+// a client refused before the upgrade can still report it
+const connIDHeader = "X-Conn-ID"
+
+// newConnID returns a short random ID tying together the log lines of one
+// WebSocket connection
+func newConnID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// ConnID returns the ID of the peer's current connection
+func (p *Peer) ConnID() string {
+	id, _ := p.connID.Load().(string)
+	return id
+}
@@ -0,0 +1,51 @@
+package signaling
+
+import "sync/atomic"
+
+// connLimiter is a counting semaphore over open WebSocket connections.
+// Unlike MaxPeers it also counts sockets that haven't registered yet or
+// are waiting in a PIN prompt, so a flood of idle upgrades can't exhaust
+// goroutines and memory.
+type connLimiter struct {
+	slots chan struct{} // nil when unlimited
+	open  atomic.Int64
+}
+
+func newConnLimiter(max int) *connLimiter {
+	l := &connLimiter{}
+	if max > 0 {
+		l.slots = make(chan struct{}, max)
+	}
+	return l
+}
+
+// acquire takes a slot, reporting false without blocking when all are in use
+func (l *connLimiter) acquire() bool {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	l.open.Add(1)
+	return true
+}
+
+// full reports whether another connection would be refused
+func (l *connLimiter) full() bool {
+	return l.slots != nil && len(l.slots) == cap(l.slots)
+}
+
+func (l *connLimiter) release() {
+	l.open.Add(-1)
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// OpenConnections returns the number of WebSocket connections holding a
+// slot, upgraded or about to be
+func (h *Hub) OpenConnections() int {
+	return int(h.conns.open.Load())
+}
@@ -0,0 +1,31 @@
+package signaling
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestConnectionLimit(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	sec.MaxConnections = 2
+	s := newTestServer(t, sec, DefaultHubConfig())
+
+	s.mustDial("device_id=a")
+	second := s.mustDial("device_id=b")
+
+	_, resp, err := s.dial("device_id=c")
+	if err == nil {
+		t.Fatal("connection past the limit accepted")
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("connection past the limit: %v, want status %d", err, http.StatusServiceUnavailable)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Fatal("rejection has no Retry-After")
+	}
+
+	// Closing a connection frees its slot
+	second.Close()
+	waitFor(t, "slot release", func() bool { return s.hub.OpenConnections() == 1 })
+	s.mustDial("device_id=c")
+}
@@ -0,0 +1,63 @@
+package signaling
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// dedupCache remembers recently forwarded client message IDs so retried
+// messages are delivered once. It is an LRU bounded by size, and entries
+// older than ttl no longer count as duplicates.
+type dedupCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	entries map[string]*list.Element
+	order   *list.List // Front is most recently seen
+}
+
+type dedupEntry struct {
+	key  string
+	seen time.Time
+}
+
+func newDedupCache(max int, ttl time.Duration) *dedupCache {
+	return &dedupCache{
+		ttl:     ttl,
+		max:     max,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// duplicate reports whether messageID was already seen from one peer to
+// another within the TTL, and records it if not
+func (c *dedupCache) duplicate(from, to, messageID string) bool {
+	if c.max <= 0 {
+		return false
+	}
+	key := from + "\x00" + to + "\x00" + messageID
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*dedupEntry)
+		if now.Sub(entry.seen) <= c.ttl {
+			return true
+		}
+		entry.seen = now
+		c.order.MoveToFront(el)
+		return false
+	}
+
+	c.entries[key] = c.order.PushFront(&dedupEntry{key: key, seen: now})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*dedupEntry).key)
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupCache(t *testing.T) {
+	c := newDedupCache(2, 50*time.Millisecond)
+	if c.duplicate("a", "b", "m1") {
+		t.Fatal("first sighting reported as duplicate")
+	}
+	if !c.duplicate("a", "b", "m1") {
+		t.Fatal("retry within the TTL not reported")
+	}
+	if c.duplicate("b", "a", "m1") || c.duplicate("a", "c", "m1") {
+		t.Fatal("same ID between other peers reported as duplicate")
+	}
+
+	// a->c and b->a pushed a->b out of the two-entry cache
+	if c.duplicate("a", "b", "m1") {
+		t.Fatal("evicted ID still reported")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if c.duplicate("a", "b", "m1") {
+		t.Fatal("ID reported after its TTL")
+	}
+
+	if off := newDedupCache(0, time.Minute); off.duplicate("a", "b", "m") || off.duplicate("a", "b", "m") {
+		t.Fatal("disabled cache reported a duplicate")
+	}
+}
+
+func TestDuplicateDeliveredOnce(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	host, hostID := s.host("token")
+	host.join("r", RoleHost)
+	client, _ := s.client("")
+	client.join("r", RoleClient)
+
+	offer := Message{Type: MsgTypeOffer, To: hostID, SDP: "v=0", MessageID: "m1"}
+	client.send(offer)
+	client.send(offer)
+	offer.MessageID = "m2"
+	client.send(offer)
+
+	if got := host.expect(MsgTypeOffer).MessageID; got != "m1" {
+		t.Fatalf("first offer %q, want m1", got)
+	}
+	if got := host.expect(MsgTypeOffer).MessageID; got != "m2" {
+		t.Fatalf("second offer %q, want m2 with the retry of m1 dropped", got)
+	}
+}
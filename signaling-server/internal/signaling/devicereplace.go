@@ -0,0 +1,37 @@
+package signaling
+
+import (
+	"crypto/subtle"
+
+	"go.uber.org/zap"
+)
+
+// replaceDevicePeers removes peers left over from an earlier connection
+// of the device peer is connecting from, e.g. an app that restarted
+// before the server noticed its old socket was gone. Without this the
+// room briefly has two viewers for one device until the old connection
+// times out. Only peers of the same kind, host or client connection, that
+// presented the same non-empty token are replaced, so a device ID alone
+// can't be used to kick someone. Other peers see no peer-left for the
+// stale peer; the new one's peer-joined names it in Replaces. Must be
+// called with h.mu held for writing, before peer is added to h.peers.
+func (h *Hub) replaceDevicePeers(peer *Peer) {
+	if peer.deviceID == "" || peer.token == "" {
+		return
+	}
+	for _, stale := range h.peers {
+		if stale.deviceID != peer.deviceID || stale.hostConn != peer.hostConn ||
+			subtle.ConstantTimeCompare([]byte(stale.token), []byte(peer.token)) != 1 {
+			continue
+		}
+		h.logger.Info("Device reconnected, replacing its stale peer",
+			zap.String("device-id", peer.deviceID),
+			zap.String("stale", stale.ID),
+			zap.String("id", peer.ID))
+		if peer.replaces == "" {
+			peer.replaces = stale.ID
+			stale.replaced = true
+		}
+		h.disconnectWithError(stale, CodeReplaced, "Replaced by a newer connection from this device")
+	}
+}
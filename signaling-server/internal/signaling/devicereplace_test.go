@@ -0,0 +1,75 @@
+package signaling
+
+import "testing"
+
+// peerCount returns how many peers the hub holds
+func peerCount(h *Hub) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.peers)
+}
+
+func TestDeviceReconnectReplacesStalePeer(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	host, _ := s.host("token")
+
+	old, oldID := s.client("token=token&device_id=phone")
+	if joined := host.expect(MsgTypePeerJoined); joined.PeerID != oldID {
+		t.Fatalf("peer-joined for %q, want %q", joined.PeerID, oldID)
+	}
+
+	_, newID := s.client("token=token&device_id=phone")
+	if code := old.expectClose(); code != CloseReplaced {
+		t.Fatalf("stale connection closed with %d, want %d", code, CloseReplaced)
+	}
+
+	// A single peer-joined naming the stale peer, no peer-left for it
+	for {
+		msg := host.read()
+		if msg.Type == MsgTypePeerLeft {
+			t.Fatalf("peer-left for %q on a device reconnect", msg.PeerID)
+		}
+		if msg.Type == MsgTypePeerJoined {
+			if msg.PeerID != newID || msg.Replaces != oldID {
+				t.Fatalf("peer-joined %q replacing %q, want %q replacing %q", msg.PeerID, msg.Replaces, newID, oldID)
+			}
+			break
+		}
+	}
+	if n := peerCount(s.hub); n != 2 {
+		t.Fatalf("%d peers, want the host and one client", n)
+	}
+}
+
+func TestDeviceReconnectNotReplaced(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	s.host("token")
+
+	// Without a token the device ID alone doesn't replace anyone
+	s.client("device_id=phone")
+	s.client("device_id=phone")
+
+	// Nor does a client connection replace a host one
+	s.mustDial("is_host=true&token=other&device_id=laptop").register(RoleHost)
+	s.client("token=other&device_id=laptop")
+
+	waitFor(t, "all peers registered", func() bool { return peerCount(s.hub) == 5 })
+}
+
+func TestUnregisteredReplacementReportsStalePeerLeft(t *testing.T) {
+	cfg := DefaultHubConfig()
+	cfg.ResumeGrace = 0
+	s := newTestServer(t, DefaultSecurityConfig(), cfg)
+	host, _ := s.host("token")
+	_, oldID := s.client("token=token&device_id=phone")
+	host.expect(MsgTypePeerJoined)
+
+	// The replacement drops before it registers
+	c := s.mustDial("token=token&device_id=phone")
+	waitFor(t, "stale peer replaced", func() bool { return peerCount(s.hub) == 2 })
+	c.Close()
+
+	// Its own peer-left may come first; read fails if the stale one never does
+	for host.expect(MsgTypePeerLeft).PeerID != oldID {
+	}
+}
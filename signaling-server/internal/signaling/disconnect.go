@@ -0,0 +1,53 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// disconnectRequest is the body of POST /api/disconnect
+type disconnectRequest struct {
+	PeerID string `json:"peer_id"`
+}
+
+// DisconnectPeer tells the peer with id that the server dropped it and
+// removes it, closing its connection once the error is written. A peer
+// held for resume is removed too. It reports whether the peer existed.
+func (h *Hub) DisconnectPeer(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	peer, ok := h.peers[id]
+	if !ok {
+		return false
+	}
+	h.logger.Warn("Peer disconnected by administrator",
+		zap.String("peer", peer.ID),
+		zap.String("room", peer.Room))
+	h.disconnectWithError(peer, CodeServerDisconnect, "Disconnected by the server administrator")
+	return true
+}
+
+// DisconnectHandler serves POST /api/disconnect {"peer_id":"..."}. The
+// caller authenticates the admin.
+func (h *Hub) DisconnectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req disconnectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PeerID == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !h.DisconnectPeer(req.PeerID) {
+		http.Error(w, "Peer not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"disconnected": req.PeerID})
+}
@@ -0,0 +1,41 @@
+package signaling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDisconnectHandler(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	c, id := s.client("")
+	post := func(body string) int {
+		w := httptest.NewRecorder()
+		s.hub.DisconnectHandler(w, httptest.NewRequest(http.MethodPost, "/api/disconnect", strings.NewReader(body)))
+		return w.Code
+	}
+
+	if code := post(`{"peer_id":"` + id + `"}`); code != http.StatusOK {
+		t.Fatalf("disconnect: status %d", code)
+	}
+	if code := c.expectError(); code != CodeServerDisconnect {
+		t.Fatalf("peer told %s, want %s", code, CodeServerDisconnect)
+	}
+	if code := c.expectClose(); code != CloseAdminDisconnect {
+		t.Fatalf("close code %d, want %d", code, CloseAdminDisconnect)
+	}
+	waitFor(t, "peer removal", func() bool { return peerCount(s.hub) == 0 })
+
+	if code := post(`{"peer_id":"` + id + `"}`); code != http.StatusNotFound {
+		t.Fatalf("disconnecting a removed peer: status %d, want 404", code)
+	}
+	if code := post(`{}`); code != http.StatusBadRequest {
+		t.Fatalf("no peer_id: status %d, want 400", code)
+	}
+	w := httptest.NewRecorder()
+	s.hub.DisconnectHandler(w, httptest.NewRequest(http.MethodGet, "/api/disconnect", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET: status %d, want 405", w.Code)
+	}
+}
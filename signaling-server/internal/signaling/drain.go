@@ -0,0 +1,71 @@
+package signaling
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const drainPollInterval = 250 * time.Millisecond
+
+// Draining reports whether Drain has been called
+func (h *Hub) Draining() bool {
+	return h.draining.Load()
+}
+
+// Drain prepares for shutdown: new WebSocket connections are refused and
+// every connected peer gets a server_draining error, so clients can move
+// to a replacement instance. It waits until all rooms are empty or ctx is
+// done, and reports whether the rooms emptied. Call Shutdown afterwards.
+func (h *Hub) Drain(ctx context.Context) bool {
+	h.draining.Store(true)
+
+	h.mu.RLock()
+	for _, peer := range h.peers {
+		h.sendError(peer, CodeServerDraining, "Server is shutting down, reconnect to another instance")
+	}
+	peers := len(h.peers)
+	h.mu.RUnlock()
+	h.logger.Info("Draining connections", zap.Int("peers", peers))
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		members := h.roomMembers()
+		if members == 0 {
+			h.logger.Info("Drain complete")
+			return true
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			h.logger.Warn("Drain grace period ended with peers still in rooms", zap.Int("peers", members))
+			return false
+		}
+	}
+}
+
+// roomMembers counts the connected hosts and clients across all rooms.
+// Peers held for resume have already left and aren't counted.
+func (h *Hub) roomMembers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	n := 0
+	for _, room := range h.rooms {
+		room.mu.RLock()
+		for _, host := range room.hosts() {
+			if host.detachedAt.IsZero() {
+				n++
+			}
+		}
+		for _, client := range room.Clients {
+			if client.detachedAt.IsZero() {
+				n++
+			}
+		}
+		room.mu.RUnlock()
+	}
+	return n
+}
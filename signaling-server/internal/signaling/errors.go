@@ -0,0 +1,93 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorCode is a stable, machine-readable error identifier. Clients
+// should switch on the code; the accompanying message is for humans and
+// may change.
+type ErrorCode string
+
+// Error codes sent in MsgTypeError payloads
+const (
+	CodeInvalidMessage    ErrorCode = "err_invalid_message"     // Message failed validation or had a malformed payload
+	CodeUnknownType       ErrorCode = "err_unknown_type"        // Message type not supported by the server
+	CodeRoomRequired      ErrorCode = "err_room_required"       // Join without a room ID
+	CodeTooManyRooms      ErrorCode = "err_too_many_rooms"      // Server room limit reached; see max
+	CodeRoomFull          ErrorCode = "err_room_full"           // Room client limit reached; see max
+	CodeDuplicateHost     ErrorCode = "err_duplicate_host"      // Room already has a host
+	CodeTokenRoom         ErrorCode = "err_token_room"          // Token wasn't issued for the room being joined
+	CodeHostLeft          ErrorCode = "err_host_left"           // Room host left and the room was closed
+	CodeNotHost           ErrorCode = "err_not_host"            // Operation reserved for the room host
+	CodeNotAllowed        ErrorCode = "err_not_allowed"         // Message type can't be sent by peers with this role
+	CodeNotInRoom         ErrorCode = "err_not_in_room"         // Target peer isn't a member of the room
+	CodeRoomPassword      ErrorCode = "err_room_password"       // Room password missing or wrong
+	CodeKicked            ErrorCode = "kicked"                  // Removed from the room by its host
+	CodeTokenRevoked      ErrorCode = "err_token_revoked"       // Connection's token was revoked by an administrator
+	CodeReplaced          ErrorCode = "err_replaced"            // A newer connection from the same device took over
+	CodeServerDraining    ErrorCode = "server_draining"         // Server is shutting down; reconnect to another instance
+	CodeServerDisconnect  ErrorCode = "server_disconnect"       // Disconnected by the server administrator
+	CodeTooLarge          ErrorCode = "err_too_large"           // Payload exceeds the configured size limit
+	CodeUnknownBundle     ErrorCode = "err_unknown_bundle"      // Key bundle ID not found
+	CodeBroadcastTooLarge ErrorCode = "err_broadcast_too_large" // Broadcast exceeds the recipient limit; see max
+	CodeMediaPolicy       ErrorCode = "err_media_policy"        // Offer rejected by the media policy
+	CodePINFailed         ErrorCode = "err_pin_failed"          // Wrong PIN entered on the host
+	CodePINExpired        ErrorCode = "err_pin_expired"         // PIN window ran out before approval
+	CodePINAttempts       ErrorCode = "err_pin_attempts"        // Too many wrong PIN attempts
+)
+
+// Error codes sent in the X-Error-Code header of refused WebSocket
+// handshakes
+const (
+	CodeOverloaded          ErrorCode = "err_overloaded"
+	CodeRateLimited         ErrorCode = "err_rate_limited"
+	CodeTLSRequired         ErrorCode = "err_tls_required"
+	CodeBadToken            ErrorCode = "err_bad_token"
+	CodeProtocolVersion     ErrorCode = "err_protocol_version"
+	CodeTokenChurn          ErrorCode = "err_token_churn"
+	CodeReconnectLoop       ErrorCode = "err_reconnect_loop"
+	CodePairingInactive     ErrorCode = "err_pairing_inactive"
+	CodeInvalidRegistration ErrorCode = "err_invalid_registration"
+	CodePeerIDRejected      ErrorCode = "err_peer_id_rejected"
+	CodeIPDenied            ErrorCode = "err_ip_denied"
+)
+
+// errorCodeHeader carries the ErrorCode of a refused handshake
+const errorCodeHeader = "X-Error-Code"
+
+// errorPayload is the payload of a MsgTypeError message
+type errorPayload struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Max     int       `json:"max,omitempty"` // The limit that was hit, for limit errors
+	Error   string    `json:"error"`         // Same as Message, for clients predating codes
+	ConnID  string    `json:"conn_id,omitempty"`
+}
+
+// sendError sends an error with a stable code and a human-readable message
+func (h *Hub) sendError(peer *Peer, code ErrorCode, message string) {
+	h.sendErrorPayload(peer, errorPayload{Code: code, Message: message, Error: message})
+}
+
+// sendLimitError sends an error for a limit that was hit, including the
+// limit, e.g. {"code":"err_room_full","max":8}
+func (h *Hub) sendLimitError(peer *Peer, code ErrorCode, message string, max int) {
+	h.sendErrorPayload(peer, errorPayload{Code: code, Message: message, Max: max, Error: message})
+}
+
+func (h *Hub) sendErrorPayload(peer *Peer, e errorPayload) {
+	e.ConnID = peer.ConnID()
+	payload, _ := json.Marshal(e)
+	h.sendToPeer(peer, &Message{
+		Type:    MsgTypeError,
+		Payload: payload,
+	})
+}
+
+// httpError refuses a handshake with a status, message and error code
+func httpError(w http.ResponseWriter, code ErrorCode, message string, status int) {
+	w.Header().Set(errorCodeHeader, string(code))
+	http.Error(w, message, status)
+}
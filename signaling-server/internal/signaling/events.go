@@ -0,0 +1,125 @@
+package signaling
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// eventBufferSize is how many events a subscriber may fall behind by
+// before the oldest are dropped
+const eventBufferSize = 64
+
+// EventType identifies a hub lifecycle event
+type EventType string
+
+const (
+	EventPeerRegistered   EventType = "peer-registered"
+	EventPeerUnregistered EventType = "peer-unregistered"
+	EventRoomCreated      EventType = "room-created"
+	EventRoomDestroyed    EventType = "room-destroyed"
+	EventHostJoined       EventType = "host-joined"
+	EventHostLeft         EventType = "host-left"
+)
+
+// Event is a hub lifecycle event delivered to subscribers
+type Event struct {
+	Type EventType `json:"type"`
+	Peer string    `json:"peer,omitempty"`
+	Room string    `json:"room,omitempty"`
+	Role PeerRole  `json:"role,omitempty"`
+	Time int64     `json:"time_ms"`
+}
+
+// EventSubscription receives hub events on C until Close is called. A
+// subscriber that falls behind loses its oldest events rather than
+// holding up the hub.
+type EventSubscription struct {
+	C <-chan Event
+
+	ch  chan Event
+	hub *Hub
+}
+
+// Subscribe starts delivering hub events to a new subscription
+func (h *Hub) Subscribe() *EventSubscription {
+	ch := make(chan Event, eventBufferSize)
+	sub := &EventSubscription{C: ch, ch: ch, hub: h}
+
+	h.eventMu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.eventMu.Unlock()
+	return sub
+}
+
+// Close stops delivery to s. C is not closed.
+func (s *EventSubscription) Close() {
+	s.hub.eventMu.Lock()
+	delete(s.hub.subscribers, s)
+	s.hub.eventMu.Unlock()
+}
+
+// emit delivers an event to every subscriber without blocking. It may be
+// called with any hub or room locks held.
+func (h *Hub) emit(typ EventType, peer, room string, role PeerRole) {
+	h.eventMu.Lock()
+	defer h.eventMu.Unlock()
+	if len(h.subscribers) == 0 {
+		return
+	}
+
+	event := Event{Type: typ, Peer: peer, Room: room, Role: role, Time: time.Now().UnixMilli()}
+	for sub := range h.subscribers {
+		select {
+		case sub.ch <- event:
+			continue
+		default:
+		}
+		// Full: drop the oldest. Only emit sends, under eventMu, so there
+		// is room for this one afterwards.
+		select {
+		case <-sub.ch:
+		default:
+		}
+		sub.ch <- event
+	}
+}
+
+// EventsHandler streams hub events as server-sent events, one JSON object
+// per event, until the client goes away or the hub shuts down
+func (h *Hub) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// The stream outlives the server's short write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	sub := h.Subscribe()
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case event := <-sub.C:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package signaling
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// BroadcastOverflow selects what happens to a broadcast with more
+// recipients than MaxBroadcastRecipients
+type BroadcastOverflow string
+
+const (
+	// BroadcastChunk delivers to MaxBroadcastRecipients peers at a time,
+	// one chunk every broadcastChunkInterval
+	BroadcastChunk BroadcastOverflow = "chunk"
+	// BroadcastReject drops the broadcast and tells the sender
+	BroadcastReject BroadcastOverflow = "reject"
+)
+
+const broadcastChunkInterval = 10 * time.Millisecond
+
+// fanout is the undelivered rest of a chunked broadcast
+type fanout struct {
+	msg     *Message
+	room    string
+	targets []string // Peer IDs still to deliver to
+	signal  bool     // Deliver with deliverSignal instead of sendToPeer
+}
+
+// fanOut delivers msg to targets in room, enforcing the broadcast
+// recipient cap. Must be called with h.mu and room.mu held.
+func (h *Hub) fanOut(room *Room, msg *Message, targets []*Peer, signal bool) {
+	max := h.config.MaxBroadcastRecipients
+	if max <= 0 || len(targets) <= max {
+		for _, peer := range targets {
+			h.deliverFanOut(peer, msg, signal)
+		}
+		return
+	}
+
+	h.logger.Warn("Broadcast exceeds recipient limit",
+		zap.String("from", msg.From),
+		zap.String("room", room.ID),
+		zap.String("type", string(msg.Type)),
+		zap.Int("recipients", len(targets)),
+		zap.Int("max", max),
+		zap.String("policy", string(h.config.BroadcastOverflow)))
+
+	if h.config.BroadcastOverflow == BroadcastReject {
+		if sender, ok := h.peers[msg.From]; ok {
+			h.sendLimitError(sender, CodeBroadcastTooLarge, "Broadcast exceeds recipient limit", max)
+		}
+		return
+	}
+
+	for _, peer := range targets[:max] {
+		h.deliverFanOut(peer, msg, signal)
+	}
+	rest := &fanout{msg: msg, room: room.ID, signal: signal}
+	for _, peer := range targets[max:] {
+		rest.targets = append(rest.targets, peer.ID)
+	}
+	h.scheduleFanOut(rest)
+}
+
+func (h *Hub) scheduleFanOut(f *fanout) {
+	time.AfterFunc(broadcastChunkInterval, func() {
+		select {
+		case h.continueFanout <- f:
+		case <-h.done:
+		}
+	})
+}
+
+// continueFanOut delivers the next chunk of a broadcast, skipping peers
+// that left the room since it was sent
+func (h *Hub) continueFanOut(f *fanout) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	room, ok := h.rooms[f.room]
+	if !ok {
+		return
+	}
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+
+	n := min(h.config.MaxBroadcastRecipients, len(f.targets))
+	for _, id := range f.targets[:n] {
+		if peer, ok := h.peers[id]; ok && room.hasMember(id) {
+			h.deliverFanOut(peer, f.msg, f.signal)
+		}
+	}
+	if f.targets = f.targets[n:]; len(f.targets) > 0 {
+		h.scheduleFanOut(f)
+	}
+}
+
+func (h *Hub) deliverFanOut(peer *Peer, msg *Message, signal bool) {
+	if signal {
+		h.deliverSignal(peer, msg)
+	} else {
+		h.sendToPeer(peer, msg)
+	}
+}
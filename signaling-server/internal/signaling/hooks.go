@@ -0,0 +1,75 @@
+package signaling
+
+import "go.uber.org/zap"
+
+// hookQueueSize is how many hook calls can be pending before further ones
+// are dropped
+const hookQueueSize = 256
+
+// OnRegister adds a callback run for every peer the hub registers, i.e.
+// once per new connection that got past the handshake. OnUnregister adds
+// one run when a peer is finally removed; peers held for resume are only
+// removed once their grace runs out.
+//
+// Callbacks run one at a time, in order, on a goroutine of their own and
+// never under the hub's locks. They must not block: while they are
+// behind, calls beyond hookQueueSize pending ones are dropped and counted
+// in signaling_hook_drops_total. The peer
+// passed is a copy of its ID, Role, Name, Room and Tags at the time, with
+// no connection. Role and Name are only known at register time when the
+// peer registered in the handshake.
+func (h *Hub) OnRegister(fn func(*Peer)) {
+	h.eventMu.Lock()
+	defer h.eventMu.Unlock()
+	h.onRegister = append(h.onRegister, fn)
+}
+
+// OnUnregister adds a callback run when a peer is removed; see OnRegister
+func (h *Hub) OnUnregister(fn func(*Peer)) {
+	h.eventMu.Lock()
+	defer h.eventMu.Unlock()
+	h.onUnregister = append(h.onUnregister, fn)
+}
+
+// queueHooks schedules the callbacks registered in *hooks for peer, or
+// drops them if the queue is full; it never blocks. Must be called with
+// h.mu held, so the copy handed to them is consistent.
+func (h *Hub) queueHooks(hooks *[]func(*Peer), peer *Peer) {
+	h.eventMu.Lock()
+	fns := *hooks
+	h.eventMu.Unlock()
+	if len(fns) == 0 {
+		return
+	}
+
+	snapshot := &Peer{
+		ID:   peer.ID,
+		Role: peer.Role,
+		Name: peer.Name,
+		Room: peer.Room,
+		Tags: peer.Tags,
+	}
+
+	select {
+	case h.hookCalls <- func() {
+		for _, fn := range fns {
+			fn(snapshot)
+		}
+	}:
+	default:
+		h.metrics.HookDrops.Add(1)
+		h.logger.Warn("Hook queue full, dropped peer hook call", zap.String("peer", peer.ID))
+	}
+}
+
+// runHooks calls queued hooks until the hub shuts down
+func (h *Hub) runHooks() {
+	for {
+		select {
+		case call := <-h.hookCalls:
+			call()
+		case <-h.done:
+			return
+		}
+	}
+}
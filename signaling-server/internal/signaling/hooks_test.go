@@ -0,0 +1,65 @@
+package signaling
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHooksCountConnects(t *testing.T) {
+	cfg := DefaultHubConfig()
+	cfg.ResumeGrace = 0 // Remove peers as soon as they disconnect
+	s := newTestServer(t, DefaultSecurityConfig(), cfg)
+	var registered, unregistered atomic.Int32
+	s.hub.OnRegister(func(p *Peer) {
+		if p.ID == "" || p.Conn != nil {
+			t.Errorf("hook got %+v, want an ID and no connection", p)
+		}
+		registered.Add(1)
+	})
+	s.hub.OnUnregister(func(*Peer) { unregistered.Add(1) })
+
+	const n = 3
+	var conns []*testConn
+	for i := 0; i < n; i++ {
+		c, _ := s.client("")
+		conns = append(conns, c)
+	}
+	waitFor(t, "register hooks", func() bool { return registered.Load() == n })
+
+	for _, c := range conns {
+		c.Close()
+	}
+	waitFor(t, "unregister hooks", func() bool { return unregistered.Load() == n })
+	if got := registered.Load(); got != n {
+		t.Fatalf("%d register calls, want %d", got, n)
+	}
+}
+
+func TestHooksDropWhenQueueFull(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	release := make(chan struct{})
+	defer close(release)
+	s.hub.OnRegister(func(*Peer) { <-release })
+
+	const extra = 10
+	queued := make(chan struct{})
+	go func() {
+		s.hub.mu.Lock()
+		for i := 0; i < hookQueueSize+extra; i++ {
+			s.hub.queueHooks(&s.hub.onRegister, &Peer{ID: "p"})
+		}
+		s.hub.mu.Unlock()
+		close(queued)
+	}()
+
+	select {
+	case <-queued:
+	case <-time.After(2 * time.Second):
+		t.Fatal("queueHooks blocked under h.mu with a stuck hook")
+	}
+	// One call may already be running in the hook goroutine
+	if drops := s.hub.metrics.HookDrops.Load(); drops < extra-1 || drops > extra {
+		t.Fatalf("%d hook calls dropped, want %d or %d", drops, extra-1, extra)
+	}
+}
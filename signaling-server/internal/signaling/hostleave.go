@@ -0,0 +1,129 @@
+package signaling
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// HostLeavePolicy decides what happens to a room's clients when its host
+// disconnects
+type HostLeavePolicy string
+
+const (
+	// HostLeaveKeepWaiting leaves clients in the room for the host to come
+	// back; with HostReconnectGrace set they are disconnected once it runs out
+	HostLeaveKeepWaiting HostLeavePolicy = "keep-waiting"
+	// HostLeaveDisconnect disconnects the clients immediately
+	HostLeaveDisconnect HostLeavePolicy = "disconnect-clients"
+	// HostLeavePromote makes the longest-connected client the new host
+	HostLeavePromote HostLeavePolicy = "promote-client"
+)
+
+// handleHostLeft applies the host-leave policy after room lost its host.
+// Must be called with h.mu held for writing and room.mu not held.
+func (h *Hub) handleHostLeft(room *Room) {
+	switch h.config.HostLeavePolicy {
+	case HostLeaveDisconnect:
+		h.disconnectRoomClients(room)
+
+	case HostLeavePromote:
+		h.promoteClient(room)
+
+	default:
+		grace := h.config.HostReconnectGrace
+		if grace <= 0 {
+			return // Wait until the room times out
+		}
+		room.mu.Lock()
+		room.hostLeftAt = time.Now()
+		room.mu.Unlock()
+		roomID := room.ID
+		time.AfterFunc(grace, func() {
+			select {
+			case h.hostGraceExpired <- roomID:
+			case <-h.done:
+			}
+		})
+	}
+}
+
+// expireHostGrace disconnects the clients of a room whose host didn't
+// return within HostReconnectGrace
+func (h *Hub) expireHostGrace(roomID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room, ok := h.rooms[roomID]
+	if !ok {
+		return
+	}
+	room.mu.RLock()
+	expired := room.Host == nil && !room.hostLeftAt.IsZero() &&
+		time.Since(room.hostLeftAt) >= h.config.HostReconnectGrace
+	room.mu.RUnlock()
+
+	if expired {
+		h.logger.Info("Host did not return, disconnecting clients", zap.String("room", roomID))
+		h.disconnectRoomClients(room)
+	}
+}
+
+// disconnectRoomClients removes every client of room. Must be called with
+// h.mu held for writing and room.mu not held.
+func (h *Hub) disconnectRoomClients(room *Room) {
+	room.mu.RLock()
+	clients := make([]*Peer, 0, len(room.Clients))
+	for _, client := range room.Clients {
+		clients = append(clients, client)
+	}
+	room.mu.RUnlock()
+
+	for _, client := range clients {
+		h.disconnectWithError(client, CodeHostLeft, "Host left the room")
+	}
+}
+
+// promoteClient makes the longest-connected client of room its host and
+// tells the room. Must be called with h.mu held for writing and room.mu
+// not held.
+func (h *Hub) promoteClient(room *Room) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	var promoted *Peer
+	for _, client := range room.Clients {
+		if promoted == nil || client.connectedAt.Before(promoted.connectedAt) {
+			promoted = client
+		}
+	}
+	if promoted == nil {
+		return
+	}
+
+	delete(room.Clients, promoted.ID)
+	delete(room.keyDeliveries, promoted.ID)
+	room.Host = promoted
+	promoted.Role = RoleHost
+	h.emit(EventHostJoined, promoted.ID, room.ID, RoleHost)
+	h.logger.Info("Client promoted to host",
+		zap.String("room", room.ID),
+		zap.String("peer", promoted.ID))
+	h.announceHostChanged(room)
+}
+
+// announceHostChanged tells every member of room who its primary host now
+// is. Must be called with room.mu held.
+func (h *Hub) announceHostChanged(room *Room) {
+	notice := &Message{
+		Type:   MsgTypeHostChanged,
+		Room:   room.ID,
+		PeerID: room.Host.ID,
+	}
+	for _, host := range room.hosts() {
+		h.sendToPeer(host, notice)
+	}
+	for _, client := range room.Clients {
+		h.sendToPeer(client, notice)
+	}
+}
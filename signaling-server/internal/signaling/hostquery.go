@@ -0,0 +1,58 @@
+package signaling
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// Sort keys accepted by /hosts?sort=
+const (
+	hostSortActiveTime = "active_time" // Longest active first
+	hostSortClients    = "clients"     // Most clients first
+	hostSortName       = "name"        // Alphabetical
+)
+
+// queryHosts applies the ?sort= and ?has_clients= parameters of /hosts.
+// Without them hosts are returned as given.
+func queryHosts(hosts []HostStatus, query url.Values) ([]HostStatus, error) {
+	if raw := query.Get("has_clients"); raw != "" {
+		want, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid has_clients %q", raw)
+		}
+		filtered := hosts[:0]
+		for _, host := range hosts {
+			if host.HasClients == want {
+				filtered = append(filtered, host)
+			}
+		}
+		hosts = filtered
+	}
+
+	var less func(a, b HostStatus) bool
+	switch key := query.Get("sort"); key {
+	case "":
+		return hosts, nil
+	case hostSortActiveTime:
+		less = func(a, b HostStatus) bool { return a.ActiveTime > b.ActiveTime }
+	case hostSortClients:
+		less = func(a, b HostStatus) bool { return a.Clients > b.Clients }
+	case hostSortName:
+		less = func(a, b HostStatus) bool { return a.Name < b.Name }
+	default:
+		return nil, fmt.Errorf("invalid sort %q; use %s, %s or %s", key, hostSortActiveTime, hostSortClients, hostSortName)
+	}
+	// Ties in peer ID order, so repeated requests list hosts the same way
+	sort.SliceStable(hosts, func(i, j int) bool {
+		if less(hosts[i], hosts[j]) {
+			return true
+		}
+		if less(hosts[j], hosts[i]) {
+			return false
+		}
+		return hosts[i].PeerID < hosts[j].PeerID
+	})
+	return hosts, nil
+}
@@ -0,0 +1,54 @@
+package signaling
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func hostIDs(hosts []HostStatus) []string {
+	ids := make([]string, len(hosts))
+	for i, h := range hosts {
+		ids[i] = h.PeerID
+	}
+	return ids
+}
+
+func TestQueryHosts(t *testing.T) {
+	sample := func() []HostStatus {
+		return []HostStatus{
+			{PeerID: "b", Name: "Living room", ActiveTime: 60, Clients: 2, HasClients: true},
+			{PeerID: "a", Name: "Desk", ActiveTime: 600, Clients: 0},
+			{PeerID: "d", Name: "Attic", ActiveTime: 60, Clients: 2, HasClients: true},
+			{PeerID: "c", Name: "Basement", ActiveTime: 5, Clients: 1, HasClients: true},
+		}
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"b", "a", "d", "c"}},
+		{"sort=active_time", []string{"a", "b", "d", "c"}},
+		{"sort=clients", []string{"b", "d", "c", "a"}},
+		{"sort=name", []string{"d", "c", "a", "b"}},
+		{"has_clients=false", []string{"a"}},
+		{"has_clients=true&sort=active_time", []string{"b", "d", "c"}},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		got, err := queryHosts(sample(), query)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if ids := hostIDs(got); !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("%q: %v, want %v", tt.query, ids, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"sort=latency", "has_clients=maybe"} {
+		query, _ := url.ParseQuery(bad)
+		if _, err := queryHosts(sample(), query); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestHostStatusTimes(t *testing.T) {
+	h := NewHubWithConfig(zap.NewNop(), time.Minute, DefaultSecurityConfig(), DefaultHubConfig())
+	now := time.Now()
+	h.peers["h"] = &Peer{ID: "h", Role: RoleHost, connectedAt: now.Add(-time.Hour), LastPing: now.Add(-20 * time.Second)}
+	h.peers["c"] = &Peer{ID: "c", Role: RoleClient, connectedAt: now}
+
+	hosts := h.GetActiveHosts()
+	if len(hosts) != 1 {
+		t.Fatalf("%d hosts, want 1", len(hosts))
+	}
+	// Allow a second for the clock moving on between setup and the call
+	if got := hosts[0].ActiveTime; got < 3600 || got > 3601 {
+		t.Errorf("ActiveTime %d, want the hour since connecting", got)
+	}
+	if got := hosts[0].IdleSeconds; got < 20 || got > 21 {
+		t.Errorf("IdleSeconds %d, want the 20s since the last pong", got)
+	}
+}
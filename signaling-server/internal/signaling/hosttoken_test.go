@@ -0,0 +1,108 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHostTokenInvalidatedOnDisconnect(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	sec.HostTokenGrace = 0
+	cfg := DefaultHubConfig()
+	cfg.ResumeGrace = 0
+	s := newTestServer(t, sec, cfg)
+
+	host, _ := s.host("host-token")
+	if !s.hub.ValidateToken("host-token") {
+		t.Fatal("host token not valid while the host is connected")
+	}
+	host.Close()
+	waitFor(t, "token invalidation", func() bool { return !s.hub.ValidateToken("host-token") })
+
+	// Tokens not owned by a host peer aren't affected
+	s.hub.RegisterToken("api-token", time.Minute)
+	other, _ := s.host("other-token")
+	other.Close()
+	waitFor(t, "host removal", func() bool { return peerCount(s.hub) == 0 })
+	if !s.hub.ValidateToken("api-token") {
+		t.Fatal("unowned token invalidated by a host disconnect")
+	}
+}
+
+func TestHostTokenGrace(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	sec.HostTokenGrace = 300 * time.Millisecond
+	cfg := DefaultHubConfig()
+	cfg.ResumeGrace = 0
+	s := newTestServer(t, sec, cfg)
+
+	host, _ := s.host("host-token")
+	host.Close()
+	waitFor(t, "host removal", func() bool { return peerCount(s.hub) == 0 })
+	if !s.hub.ValidateToken("host-token") {
+		t.Fatal("token invalidated within the grace period")
+	}
+	time.Sleep(sec.HostTokenGrace)
+	if s.hub.ValidateToken("host-token") {
+		t.Fatal("token still valid after the grace period")
+	}
+
+	// A host back within the grace period reclaims its token for good
+	host, _ = s.host("reclaimed")
+	host.Close()
+	waitFor(t, "host removal", func() bool { return peerCount(s.hub) == 0 })
+	s.host("reclaimed")
+	time.Sleep(sec.HostTokenGrace + 100*time.Millisecond)
+	if !s.hub.ValidateToken("reclaimed") {
+		t.Fatal("reclaimed token expired with the old host's grace")
+	}
+}
+
+func TestMintedTokenRefusedForHost(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	s.host("host-token")
+	minted, err := s.hub.MintClientToken("host-token", "living-room", time.Minute)
+	if err != nil {
+		t.Fatalf("mint: %v", err)
+	}
+
+	c := s.mustDial("is_host=true&token=" + minted.Token)
+	if code := c.expectClose(); code != CloseAuthFailed {
+		t.Fatalf("host connection with a minted token: close code %d, want %d", code, CloseAuthFailed)
+	}
+
+	// Registering it directly doesn't extend its TTL or give it an owner
+	s.hub.registerHostToken(minted.Token, "intruder", time.Hour)
+	s.hub.tokenMu.RLock()
+	entry := *s.hub.validTokens[minted.Token]
+	s.hub.tokenMu.RUnlock()
+	if !entry.ExpiresAt.Equal(minted.ExpiresAt) || len(entry.HostPeers) != 0 {
+		t.Fatalf("minted token re-registered: expires %v (want %v), hosts %v",
+			entry.ExpiresAt, minted.ExpiresAt, entry.hostList())
+	}
+}
+
+func TestHostTokenSharedByTwoHosts(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	sec.HostTokenGrace = 0
+	cfg := DefaultHubConfig()
+	cfg.ResumeGrace = 0
+	cfg.MaxHostsPerRoom = 2
+	s := newTestServer(t, sec, cfg)
+
+	first, _ := s.host("shared-token")
+	first.join("desk", RoleHost)
+	second, _ := s.host("shared-token")
+	second.join("desk", RoleHost)
+
+	// The last host to register leaving doesn't orphan the token while
+	// the first is still connected
+	second.Close()
+	waitFor(t, "second host removal", func() bool { return peerCount(s.hub) == 1 })
+	if !s.hub.ValidateToken("shared-token") {
+		t.Fatal("token invalidated while another host is connected")
+	}
+
+	first.Close()
+	waitFor(t, "token invalidation", func() bool { return !s.hub.ValidateToken("shared-token") })
+}
@@ -7,11 +7,18 @@
 package signaling
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -27,24 +34,68 @@ const (
 	MsgTypeLeave    MessageType = "leave"
 	MsgTypeRoomInfo MessageType = "room_info"
 
+	// Sent to a room when its primary host changes, e.g. a client is
+	// promoted to host
+	MsgTypeHostChanged MessageType = "host-changed"
+
+	// Sent by a host to eject the client named in To (or PeerID)
+	MsgTypeKick MessageType = "kick"
+
 	// Simple peer management
 	MsgTypeRegister   MessageType = "register"
 	MsgTypeRegistered MessageType = "registered"
 	MsgTypePeerJoined MessageType = "peer-joined"
 	MsgTypePeerLeft   MessageType = "peer-left"
 
+	// Asks the hub for the sender's own identity; answered with the same
+	// type carrying peerId, role, name and room
+	MsgTypeWhoAmI MessageType = "whoami"
+
 	// WebRTC signaling
 	MsgTypeOffer        MessageType = "offer"
 	MsgTypeAnswer       MessageType = "answer"
 	MsgTypeCandidate    MessageType = "candidate"
 	MsgTypeIceCandidate MessageType = "ice-candidate"
+	MsgTypeCandidates   MessageType = "candidates" // Batch of candidates in Payload
 
 	// Control
 	MsgTypePing  MessageType = "ping"
 	MsgTypePong  MessageType = "pong"
 	MsgTypeError MessageType = "error"
+
+	// Pairing
+	MsgTypePairingMode MessageType = "pairing-mode"
+	MsgTypePinRequired MessageType = "pin-required"
+	MsgTypePinVerify   MessageType = "pin-verify"
+	MsgTypePinAccepted MessageType = "pin-accepted"
+
+	// Diagnostics
+	MsgTypeConnected MessageType = "connected"
+
+	// End-to-end encryption key coordination
+	MsgTypeKeyBundle    MessageType = "key-bundle"
+	MsgTypeKeyBundleAck MessageType = "key-bundle-ack"
 )
 
+// AppMessagePrefix marks application-defined message types, e.g.
+// "app:quality", which are broadcast to the sender's room like legacy
+// unknown types
+const AppMessagePrefix = "app:"
+
+// knownMessageType reports whether t is part of the signaling protocol
+func knownMessageType(t MessageType) bool {
+	switch t {
+	case MsgTypeJoin, MsgTypeLeave, MsgTypeRoomInfo, MsgTypeHostChanged, MsgTypeKick,
+		MsgTypeRegister, MsgTypeRegistered, MsgTypePeerJoined, MsgTypePeerLeft, MsgTypeWhoAmI,
+		MsgTypeOffer, MsgTypeAnswer, MsgTypeCandidate, MsgTypeIceCandidate, MsgTypeCandidates,
+		MsgTypePing, MsgTypePong, MsgTypeError,
+		MsgTypePairingMode, MsgTypePinRequired, MsgTypePinVerify, MsgTypePinAccepted,
+		MsgTypeConnected, MsgTypeKeyBundle, MsgTypeKeyBundleAck:
+		return true
+	}
+	return false
+}
+
 // PeerRole defines the role of a peer in a room
 type PeerRole string
 
@@ -70,6 +121,47 @@ type Message struct {
 	Candidate     string `json:"candidate,omitempty"`
 	SDPMid        string `json:"sdpMid,omitempty"`
 	SDPMLineIndex int    `json:"sdpMLineIndex,omitempty"`
+
+	// Sticky marks a host room broadcast as replayable to late joiners.
+	// The latest sticky message of each type is kept per room.
+	Sticky bool `json:"sticky,omitempty"`
+
+	// BundleID identifies an opaque E2E key bundle and its acknowledgement
+	BundleID string `json:"bundleId,omitempty"`
+
+	// Capabilities are declared in register and echoed in registered
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+
+	// PIN is shown to the client in pin-required and entered by the host
+	// in pin-verify
+	PIN string `json:"pin,omitempty"`
+
+	// MessageID lets clients mark retried offers/candidates so the hub
+	// forwards each one once
+	MessageID string `json:"messageId,omitempty"`
+
+	// Password protects a room when sent in a host's join and admits a
+	// client in its join. Never logged or echoed.
+	Password string `json:"password,omitempty"`
+
+	// Tags are free-form labels a peer registers with, e.g. device class
+	Tags []string `json:"tags,omitempty"`
+
+	// ProtocolVersion is the negotiated wire protocol, sent in registered
+	// to v2+ clients
+	ProtocolVersion int `json:"protocolVersion,omitempty"`
+
+	// ResumeToken is issued in registered and presented as ?resume_token=
+	// to get the same peer back after a dropped connection
+	ResumeToken string `json:"resumeToken,omitempty"`
+
+	// LANIP is the local network address a host registers with, listed
+	// in /hosts
+	LANIP string `json:"lanIp,omitempty"`
+
+	// Replaces is set in peer-joined when the peer took over from a stale
+	// connection of the same device; no peer-left is sent for that one
+	Replaces string `json:"replaces,omitempty"`
 }
 
 // Peer represents a connected WebSocket peer
@@ -84,9 +176,107 @@ type Peer struct {
 	Logger   *zap.Logger
 	LastPing time.Time
 	mu       sync.Mutex
+
+	// pingSentAt is when the last WebSocket ping was written, for RTT
+	pingSentAt time.Time
+
+	// connID is the ID of the current connection (a string), logged as
+	// conn_id and included in errors sent to the peer
+	connID atomic.Value
+
+	// Message bytes read from and written to the peer, across resumes
+	bytesSent     atomic.Uint64
+	bytesReceived atomic.Uint64
+
+	// sendClosed is set once Send is closed so late senders (e.g. readPump
+	// answering a ping while the hub removes the peer) drop the message
+	// instead of panicking. Guarded by mu.
+	sendClosed bool
+
+	// closeFrame is the close message writePump sends once Send is closed;
+	// nil for a plain close. Guarded by mu.
+	closeFrame []byte
+
+	// ProtocolVersion is the wire protocol negotiated via subprotocol
+	ProtocolVersion int
+
+	// Capabilities declared at registration; nil means defaults
+	Capabilities *Capabilities
+	Tags         []string
+
+	// lanIP is the address reported in the peer's registration
+	lanIP string
+
+	// preamble is the registration carried by the upgrade request, applied
+	// when the hub registers the peer
+	preamble *Message
+
+	// token is the credential the peer connected with, if any, and
+	// tokenChecked whether it was validated at connect and so is held to
+	// the room it was issued for
+	token        string
+	tokenChecked bool
+
+	// hostConn is set when the peer connected as a host, with a token.
+	// Only such peers may claim the host role; the role a message claims
+	// is never trusted on its own.
+	hostConn bool
+
+	// deviceID is the client-supplied device_id, if any, and connectedAt
+	// when the WebSocket was accepted, kept across resumes; used for
+	// reconnect-loop tracking, replacing stale peers of a reconnecting
+	// device and a host's active time
+	deviceID    string
+	connectedAt time.Time
+
+	// replaces is the stale peer of the same device this one took over,
+	// until the replacement is announced in its first peer-joined; a
+	// replaced peer is removed without a peer-left. See replaceDevicePeers.
+	replaces string
+	replaced bool
+
+	// requestedName is the name the peer registered with; Name holds the
+	// effective name, which differs when UniqueRoomNames had to suffix it
+	requestedName string
+
+	// awaitingPIN holds the peer's messages until a host verifies its
+	// PIN, guarded by Hub.mu
+	awaitingPIN bool
+
+	// passwordFailures counts wrong room passwords, guarded by Hub.mu
+	passwordFailures int
+
+	// session is the connection the peer is currently bound to. While the
+	// peer is detached (detachedAt set) it waits up to ResumeGrace for a
+	// reconnect presenting resumeToken. Guarded by Hub.mu.
+	session     *connSession
+	resumeToken string
+	detachedAt  time.Time
+	resumeTimer *time.Timer
+
+	// unsent is a message a failed write couldn't deliver, sent first by
+	// the next session's writePump. Guarded by mu.
+	unsent []byte
+
+	// compressionThreshold is the minimum message size in bytes that is
+	// written with permessage-deflate; smaller messages are sent as-is.
+	compressionThreshold int
+
+	// maxMessageSize is the largest message read from the peer, in bytes
+	maxMessageSize int
+
+	// pingInterval is how often writePump pings the peer, and pongTimeout
+	// how long readPump waits for a pong before giving up
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+
+	// slow is set once the peer was queued for disconnection by
+	// SendDisconnect. Guarded by mu.
+	slow bool
 }
 
-// Room represents a signaling room
+// Room represents a signaling room. Host, Clients and the fields below mu
+// are guarded by mu, which is taken after h.mu; ID is immutable.
 type Room struct {
 	ID         string
 	Host       *Peer
@@ -94,15 +284,104 @@ type Room struct {
 	CreatedAt  time.Time
 	LastActive time.Time
 	mu         sync.RWMutex
+
+	// sticky holds the latest sticky message per type, oldest first
+	sticky []*Message
+
+	// keyBundle is the host's latest room-wide key bundle, delivered to
+	// late joiners; keyDeliveries tracks delivery/ack per client
+	keyBundle     *Message
+	keyDeliveries map[string]*keyDelivery
+
+	// hostLeftAt is when the host disconnected, for HostReconnectGrace
+	hostLeftAt time.Time
+
+	// emptySince is when the last member left, for EmptyRoomGrace; zero
+	// while the room has members
+	emptySince time.Time
+
+	// extraHosts are hosts that joined after Host, by peer ID
+	extraHosts map[string]*Peer
+
+	// password, if set by a host, is required from joining clients
+	password *roomPassword
+}
+
+// hasMember reports whether peerID is one of the room's hosts or
+// clients. Must be called with room.mu held.
+func (r *Room) hasMember(peerID string) bool {
+	if r.isHost(peerID) {
+		return true
+	}
+	_, ok := r.Clients[peerID]
+	return ok
+}
+
+// hostID returns the ID of the room's host, or "" if it has none. Must be
+// called with room.mu held.
+func (r *Room) hostID() string {
+	if r.Host == nil {
+		return ""
+	}
+	return r.Host.ID
 }
 
 // SecurityConfig holds security-related settings
 type SecurityConfig struct {
 	RequireToken    bool          // Require token validation
-	RequirePIN      bool          // Require PIN authorization
+	RequirePIN      bool          // Require PIN authorization for remote clients
 	TokenExpiry     time.Duration // Token validity duration
 	MaxConnAttempts int           // Max connection attempts per window
 	RateLimitWindow time.Duration // Time window for rate limiting
+	RateLimitWarnAt int           // Warn clients once this many attempts remain (0 = never)
+	HostTokenGrace  time.Duration // How long a token outlives its disconnected host (0 = invalidate immediately)
+
+	// RateLimitByDevice keys MaxConnAttempts on the device_id and token when
+	// present, so clients sharing a NAT address get separate buckets.
+	// MaxConnAttemptsPerIP still caps each address, so rotating device IDs
+	// doesn't lift the limit.
+	RateLimitByDevice    bool
+	MaxConnAttemptsPerIP int // Per-address ceiling with RateLimitByDevice (0 = none)
+	MaxAuthFailures      int // Invalid tokens per address per window before refusing (0 = unlimited)
+
+	IPFilter *IPFilter // Client address allow/deny ranges, checked before anything else (nil = none)
+
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For or
+	// X-Real-IP names the client, for the localhost check, rate limits and
+	// IPFilter. Without any the connecting address is the client.
+	TrustedProxies []*net.IPNet
+
+	MaxConnectionLifetime time.Duration // Close connections older than this to force re-authentication (0 = unlimited)
+	MaxConnections        int           // Max open WebSocket connections, registered or not (0 = unlimited)
+
+	// Load shedding thresholds for new connections (0 = disabled)
+	MaxPeers      int    // Max connected peers
+	MaxGoroutines int    // Max runtime goroutines
+	MaxHeapBytes  uint64 // Max allocated heap in bytes
+
+	RequirePairingMode bool          // Only admit new clients while pairing mode is active
+	PairingWindow      time.Duration // Max duration of a pairing window
+
+	MaxPendingAuth int           // Max concurrent connections awaiting PIN verification (0 = unlimited)
+	PINExpiry      time.Duration // How long a connection may wait for its PIN to be verified
+
+	TokenChurnThreshold int           // Distinct tokens per device within the window before flagging (0 = disabled)
+	TokenChurnWindow    time.Duration // Window for counting a device's tokens
+	RejectTokenChurn    bool          // Reject flagged devices instead of only logging
+
+	FieldLimits FieldLimits // Per-field length limits for incoming messages
+
+	ReconnectLimit       int           // Connections per device_id and token within ReconnectWindow before backoff (0 = disabled)
+	ReconnectWindow      time.Duration // Window for counting a device's reconnects
+	ReconnectStableAfter time.Duration // Session length that clears a device's reconnect history
+
+	MaxClientsPerRoom int // Max clients in one room (0 = unlimited)
+	MaxRooms          int // Max concurrent rooms (0 = unlimited)
+
+	RequireHandshakeRegistration bool // Reject upgrades that don't carry role/name registration
+
+	AllowClientPeerIDs bool                 // Let clients propose a sticky peer ID via ?peer_id=
+	PeerIDConflict     PeerIDConflictPolicy // What to do when a proposed ID is taken
 }
 
 // DefaultSecurityConfig returns the default security configuration
@@ -113,9 +392,104 @@ func DefaultSecurityConfig() SecurityConfig {
 		TokenExpiry:     5 * time.Minute,
 		MaxConnAttempts: 10,
 		RateLimitWindow: 1 * time.Minute,
+		RateLimitWarnAt: 1,
+		HostTokenGrace:  30 * time.Second,
+
+		RateLimitByDevice:    true,
+		MaxConnAttemptsPerIP: 60,
+		MaxAuthFailures:      5,
+		PairingWindow:        2 * time.Minute,
+		MaxPendingAuth:       64,
+		PINExpiry:            2 * time.Minute,
+
+		TokenChurnThreshold: 5,
+		TokenChurnWindow:    10 * time.Minute,
+
+		ReconnectLimit:       0,
+		ReconnectWindow:      time.Minute,
+		ReconnectStableAfter: 30 * time.Second,
+
+		MaxClientsPerRoom: 8,
+
+		FieldLimits:    DefaultFieldLimits(),
+		PeerIDConflict: PeerIDReject,
+	}
+}
+
+// HubConfig holds tunable hub behavior that is not security related
+type HubConfig struct {
+	StickyHistorySize int  // Max sticky messages kept per room (0 = disabled)
+	MaxKeyBundleSize  int  // Max key bundle payload size in bytes (0 = unlimited)
+	UniqueRoomNames   bool // Suffix duplicate peer names within a room, e.g. "TV (2)"
+
+	ICE ICEConfig // STUN/TURN servers sent to peers in registered
+
+	MediaPolicy MediaPolicy // Optional allowlist for offer media sections
+
+	ResumeGrace time.Duration // How long a dropped peer can resume its session (0 = disabled)
+	PeerTimeout time.Duration // Evict peers that haven't answered a ping for this long (0 = never)
+
+	// CandidateBatchWindow coalesces single candidates sent within this
+	// window into one MsgTypeCandidates for peers declaring batch support
+	// (0 = forward each one as it arrives)
+	CandidateBatchWindow time.Duration
+
+	// BroadcastUnknownTypes restores the legacy behavior of broadcasting
+	// messages of unknown type to the room instead of rejecting them
+	BroadcastUnknownTypes bool
+
+	HostLeavePolicy    HostLeavePolicy // What happens to clients when the host leaves
+	HostReconnectGrace time.Duration   // With keep-waiting, disconnect clients if the host isn't back in time (0 = wait for room timeout)
+
+	// EmptyRoomGrace keeps a room whose last member left, so a peer that
+	// briefly drops, e.g. a phone turning its screen off, rejoins the same
+	// room instead of re-pairing. Rooms idle past the room timeout are
+	// removed regardless. 0 removes empty rooms at the next cleanup.
+	EmptyRoomGrace time.Duration
+
+	DedupCacheSize int           // Recent signaling message IDs remembered (0 = no deduplication)
+	DedupTTL       time.Duration // How long a message ID counts as a duplicate
+
+	RoomInfoRefresh time.Duration // How often the /rooms snapshot is rebuilt (0 = build per request)
+
+	MaxBroadcastRecipients int               // Recipients of one broadcast before BroadcastOverflow applies (0 = unlimited)
+	BroadcastOverflow      BroadcastOverflow // Chunk or reject oversized broadcasts
+
+	SendPolicy SendPolicy // What to do when a peer's send buffer is full
+
+	TokenStore TokenStore // Persists registered tokens across restarts (nil = in memory only)
+
+	MaxHostsPerRoom int // Hosts that can share a room, e.g. one per monitor
+
+	// LogMessages writes each routed message, redacted and truncated, to
+	// the debug log. Off by default since SDP reveals network details.
+	LogMessages bool
+}
+
+// DefaultHubConfig returns the default hub configuration
+func DefaultHubConfig() HubConfig {
+	return HubConfig{
+		StickyHistorySize: 16,
+		MaxKeyBundleSize:  16 * 1024,
+		ResumeGrace:       30 * time.Second,
+		PeerTimeout:       90 * time.Second,
+		HostLeavePolicy:   HostLeaveKeepWaiting,
+		DedupCacheSize:    4096,
+		DedupTTL:          30 * time.Second,
+		RoomInfoRefresh:   time.Second,
+		BroadcastOverflow: BroadcastChunk,
+		SendPolicy:        SendDropNewest,
+		MaxHostsPerRoom:   1,
+		EmptyRoomGrace:    2 * time.Minute,
+		ICE: ICEConfig{
+			TURNTTL: 24 * time.Hour,
+		},
 	}
 }
 
+// rateLimitRemainingHeader reports attempts left in the rate limit window
+const rateLimitRemainingHeader = "X-RateLimit-Remaining"
+
 // RateLimiter tracks connection attempts
 type RateLimiter struct {
 	attempts map[string][]time.Time
@@ -129,13 +503,67 @@ func NewRateLimiter() *RateLimiter {
 }
 
 func (r *RateLimiter) Allow(identifier string, maxAttempts int, window time.Duration) bool {
+	return r.AllowN(identifier, 1, maxAttempts, window)
+}
+
+// AllowN records n attempts for identifier if they fit within maxAttempts
+// for the window, and reports whether they did. Nothing is recorded
+// otherwise.
+func (r *RateLimiter) AllowN(identifier string, n, maxAttempts int, window time.Duration) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	now := time.Now()
-	cutoff := now.Add(-window)
+	r.prune(identifier, now.Add(-window))
+
+	// Check limit
+	if len(r.attempts[identifier])+n > maxAttempts {
+		return false
+	}
+
+	// Record the attempts
+	for i := 0; i < n; i++ {
+		r.attempts[identifier] = append(r.attempts[identifier], now)
+	}
+	return true
+}
 
-	// Clean old attempts
+// Reset forgets identifier's attempts, e.g. after a successful login
+func (r *RateLimiter) Reset(identifier string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.attempts, identifier)
+}
+
+// PruneAll drops attempts older than window and forgets idle identifiers
+func (r *RateLimiter) PruneAll(window time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := time.Now().Add(-window)
+	for identifier := range r.attempts {
+		r.prune(identifier, cutoff)
+		if len(r.attempts[identifier]) == 0 {
+			delete(r.attempts, identifier)
+		}
+	}
+}
+
+// Remaining returns how many more attempts identifier may make in the
+// current window
+func (r *RateLimiter) Remaining(identifier string, maxAttempts int, window time.Duration) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune(identifier, time.Now().Add(-window))
+	if n := maxAttempts - len(r.attempts[identifier]); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// prune drops attempts older than cutoff. Must be called with r.mu held.
+func (r *RateLimiter) prune(identifier string, cutoff time.Time) {
 	if attempts, ok := r.attempts[identifier]; ok {
 		filtered := make([]time.Time, 0)
 		for _, t := range attempts {
@@ -145,15 +573,34 @@ func (r *RateLimiter) Allow(identifier string, maxAttempts int, window time.Dura
 		}
 		r.attempts[identifier] = filtered
 	}
+}
 
-	// Check limit
-	if len(r.attempts[identifier]) >= maxAttempts {
-		return false
-	}
+// tokenEntry tracks a registered token and the host peers it belongs to
+type tokenEntry struct {
+	ExpiresAt time.Time
+	HostPeers map[string]struct{} // IDs of the connected hosts that registered the token
+	Room      string              // Room the token admits clients to; empty = any room
+	Orphaned  time.Time           // When the last host disconnected; zero while one is connected
+	Minted    bool                // Client token issued via MintClientToken; can't mint others
+}
 
-	// Record this attempt
-	r.attempts[identifier] = append(r.attempts[identifier], now)
-	return true
+// ownedBy reports whether hostPeer is one of the token's connected hosts
+func (e *tokenEntry) ownedBy(hostPeer string) bool {
+	_, ok := e.HostPeers[hostPeer]
+	return ok
+}
+
+// hostList returns the IDs of the token's connected hosts, sorted
+func (e *tokenEntry) hostList() []string {
+	if len(e.HostPeers) == 0 {
+		return nil
+	}
+	hosts := make([]string, 0, len(e.HostPeers))
+	for id := range e.HostPeers {
+		hosts = append(hosts, id)
+	}
+	sort.Strings(hosts)
+	return hosts
 }
 
 // PendingAuth represents a connection awaiting PIN verification
@@ -166,24 +613,75 @@ type PendingAuth struct {
 	ExpiresAt    time.Time
 	Attempts     int
 	Peer         *Peer
+
+	held []*Message // Messages queued until the PIN is verified
 }
 
-// Hub manages all peers and rooms
+// Hub manages all peers and rooms.
+//
+// Lock order: h.mu, then room.mu, then peer.mu; h.mu before tokenMu and
+// candidateMu. peer.mu is a leaf and only guards the peer's own fields
+// and the closing of its Send channel. eventMu is a leaf too.
 type Hub struct {
 	rooms       map[string]*Room
 	peers       map[string]*Peer
+	reservedIDs map[string]struct{} // IDs claimed by connections not yet registered
 	register    chan *Peer
-	unregister  chan *Peer
+	unregister  chan *connSession
 	broadcast   chan *Message
 	timeout     time.Duration
 	logger      *zap.Logger
 	mu          sync.RWMutex
 	done        chan struct{}
 	security    SecurityConfig
+	config      HubConfig
 	rateLimiter *RateLimiter
-	validTokens map[string]time.Time // token -> expiry time
+	authLimiter *RateLimiter           // Failed token validations per address
+	validTokens map[string]*tokenEntry // token -> expiry and owning host
 	pendingAuth map[string]*PendingAuth
+
+	revokedTokens map[string]time.Time // tokenDigest -> denied until, guarded by tokenMu
+
 	tokenMu     sync.RWMutex
+	remoteHosts RemoteHostsProvider
+	pairingKey  []byte         // Key for self-contained pairing tokens, guarded by tokenMu
+	validator   TokenValidator // Guarded by tokenMu
+	load        loadMonitor
+	conns       *connLimiter
+	ids         IDGenerator // Guarded by mu
+	ice         iceTracker
+	churn       churnTracker
+	reconnects  reconnectTracker
+	metrics     *Metrics
+	quality     *qualityTracker
+	dedup       *dedupCache
+	roomInfo    atomic.Pointer[roomInfoSnapshot] // Latest /rooms response
+
+	pairingUntil time.Time // Pairing mode is active until this time
+
+	// Candidates held back for peers that don't support trickle ICE
+	candidateBuffers map[string]*candidateBuffer
+	candidateMu      sync.Mutex
+	flushCandidates  chan string
+
+	// Peers that can be resumed, by resume token
+	resumable     map[string]*Peer
+	resumeExpired chan *Peer
+
+	hostGraceExpired chan string // Room IDs whose HostReconnectGrace ran out
+	continueFanout   chan *fanout
+	slowPeers        chan *Peer // Peers to disconnect under SendDisconnect
+	tokenSave        chan struct{}
+
+	// Event subscribers and hooks, see Subscribe and OnRegister
+	subscribers  map[*EventSubscription]struct{}
+	onRegister   []func(*Peer)
+	onUnregister []func(*Peer)
+	hookCalls    chan func()
+	eventMu      sync.Mutex
+
+	draining atomic.Bool // Set by Drain; new connections are refused
+	running  atomic.Bool // Set while Run is looping
 }
 
 var allowedOrigins []string
@@ -193,6 +691,28 @@ func SetAllowedOrigins(origins []string) {
 	allowedOrigins = origins
 }
 
+// MatchOrigin reports whether an Origin host matches one allowlist
+// entry. Ports are not compared. "*.example.com" matches any subdomain,
+// at any depth, but not example.com itself or names with empty labels
+// such as ".example.com".
+func MatchOrigin(host, allowed string) bool {
+	host = strings.Split(strings.ToLower(host), ":")[0]
+	allowed = strings.Split(strings.ToLower(allowed), ":")[0]
+	if suffix, ok := strings.CutPrefix(allowed, "*"); ok && strings.HasPrefix(suffix, ".") {
+		sub, ok := strings.CutSuffix(host, suffix)
+		if !ok || sub == "" {
+			return false
+		}
+		for _, label := range strings.Split(sub, ".") {
+			if label == "" {
+				return false
+			}
+		}
+		return true
+	}
+	return host == allowed
+}
+
 func originAllowed(origin string) bool {
 	if origin == "" {
 		return true // Native apps without Origin
@@ -205,8 +725,7 @@ func originAllowed(origin string) bool {
 	hostWithoutPort := strings.Split(host, ":")[0]
 
 	for _, allowed := range allowedOrigins {
-		allowedWithoutPort := strings.Split(strings.ToLower(allowed), ":")[0]
-		if host == allowed || hostWithoutPort == allowedWithoutPort {
+		if MatchOrigin(host, allowed) {
 			return true
 		}
 	}
@@ -232,50 +751,157 @@ var upgrader = websocket.Upgrader{
 
 // NewHub creates a new signaling hub with security
 func NewHub(logger *zap.Logger, timeout time.Duration) *Hub {
-	return &Hub{
+	h := &Hub{
 		rooms:       make(map[string]*Room),
 		peers:       make(map[string]*Peer),
+		reservedIDs: make(map[string]struct{}),
 		register:    make(chan *Peer),
-		unregister:  make(chan *Peer),
+		unregister:  make(chan *connSession),
 		broadcast:   make(chan *Message, 256),
 		timeout:     timeout,
 		logger:      logger,
 		done:        make(chan struct{}),
 		security:    DefaultSecurityConfig(),
+		config:      DefaultHubConfig(),
 		rateLimiter: NewRateLimiter(),
-		validTokens: make(map[string]time.Time),
+		authLimiter: NewRateLimiter(),
+		validTokens: make(map[string]*tokenEntry),
 		pendingAuth: make(map[string]*PendingAuth),
+
+		revokedTokens: make(map[string]time.Time),
+
+		conns: newConnLimiter(0),
+		ids:   randomIDs{},
+
+		metrics:          newMetrics(),
+		quality:          newQualityTracker(),
+		dedup:            newDedupCache(DefaultHubConfig().DedupCacheSize, DefaultHubConfig().DedupTTL),
+		candidateBuffers: make(map[string]*candidateBuffer),
+		flushCandidates:  make(chan string, 64),
+
+		resumable:     make(map[string]*Peer),
+		resumeExpired: make(chan *Peer),
+
+		hostGraceExpired: make(chan string),
+		continueFanout:   make(chan *fanout),
+		slowPeers:        make(chan *Peer, 64),
+		tokenSave:        make(chan struct{}, 1),
+
+		subscribers: make(map[*EventSubscription]struct{}),
+		hookCalls:   make(chan func(), hookQueueSize),
 	}
+	h.validator = hubTokens{h}
+	return h
 }
 
 // NewHubWithSecurity creates a new signaling hub with custom security config
 func NewHubWithSecurity(logger *zap.Logger, timeout time.Duration, security SecurityConfig) *Hub {
 	hub := NewHub(logger, timeout)
 	hub.security = security
+	hub.conns = newConnLimiter(security.MaxConnections)
+	return hub
+}
+
+// NewHubWithConfig creates a new signaling hub with custom security and hub config
+func NewHubWithConfig(logger *zap.Logger, timeout time.Duration, security SecurityConfig, config HubConfig) *Hub {
+	hub := NewHubWithSecurity(logger, timeout, security)
+	hub.config = config
+	hub.dedup = newDedupCache(config.DedupCacheSize, config.DedupTTL)
+	if config.TokenStore != nil {
+		hub.restoreTokens()
+	}
 	return hub
 }
 
 // RegisterToken registers a valid session token from the host
 func (h *Hub) RegisterToken(token string, expiry time.Duration) {
+	h.registerHostToken(token, "", expiry)
+}
+
+// registerHostToken registers a token owned by a connected host peer. When
+// that host disconnects the token is invalidated after HostTokenGrace. A
+// host reconnecting with the same token within the grace period reclaims it.
+// Revoked tokens are refused.
+func (h *Hub) registerHostToken(token, hostPeer string, expiry time.Duration) {
 	h.tokenMu.Lock()
 	defer h.tokenMu.Unlock()
-	h.validTokens[token] = time.Now().Add(expiry)
-	h.logger.Info("Token registered", zap.String("token", token[:8]+"..."))
+	if h.tokenRevokedLocked(token) {
+		h.logger.Warn("Refusing to register revoked token", zap.String("token_id", tokenID(token)))
+		return
+	}
+	entry := &tokenEntry{ExpiresAt: time.Now().Add(expiry)}
+	if hostPeer != "" {
+		entry.HostPeers = map[string]struct{}{hostPeer: {}}
+	}
+	if old, ok := h.validTokens[token]; ok {
+		if old.Minted {
+			// A client token keeps its short TTL and never gains an owner
+			h.logger.Warn("Refusing to register minted token for a host", zap.String("token_id", tokenID(token)))
+			return
+		}
+		entry.Room = old.Room // A reclaiming host keeps its room scope
+		if hostPeer != "" {
+			// Hosts sharing the token all own it until the last one leaves
+			for other := range old.HostPeers {
+				entry.HostPeers[other] = struct{}{}
+			}
+		}
+	}
+	h.validTokens[token] = entry
+	h.tokensChanged()
+	h.logger.Info("Token registered",
+		zap.String("token", tokenPrefix(token)),
+		zap.String("host", hostPeer))
 }
 
-// ValidateToken checks if a token is valid
+// ValidateToken checks if a token is valid with the hub's TokenValidator
+// and hasn't been revoked
 func (h *Hub) ValidateToken(token string) bool {
-	h.tokenMu.RLock()
-	defer h.tokenMu.RUnlock()
-
-	expiry, ok := h.validTokens[token]
-	if !ok {
+	if h.tokenRevoked(token) {
 		return false
 	}
-	if time.Now().After(expiry) {
-		return false
+	_, ok := h.tokenValidator().Validate(token)
+	return ok
+}
+
+// releaseHostTokens drops a host that just disconnected from the tokens it
+// owns and applies the HostTokenGrace policy to those it was the last
+// connected host of
+func (h *Hub) releaseHostTokens(hostPeer string) {
+	h.tokenMu.Lock()
+	defer h.tokenMu.Unlock()
+
+	now := time.Now()
+	for token, entry := range h.validTokens {
+		if !entry.ownedBy(hostPeer) {
+			continue
+		}
+		delete(entry.HostPeers, hostPeer)
+		if len(entry.HostPeers) > 0 {
+			continue
+		}
+		h.tokensChanged()
+		if h.security.HostTokenGrace <= 0 {
+			delete(h.validTokens, token)
+			h.logger.Info("Token invalidated after host disconnect",
+				zap.String("token", tokenPrefix(token)),
+				zap.String("host", hostPeer))
+			continue
+		}
+		entry.Orphaned = now
+		if graceEnd := now.Add(h.security.HostTokenGrace); graceEnd.Before(entry.ExpiresAt) {
+			entry.ExpiresAt = graceEnd
+		}
+		h.logger.Info("Host disconnected, token kept for grace period",
+			zap.String("token", tokenPrefix(token)),
+			zap.String("host", hostPeer),
+			zap.Duration("grace", h.security.HostTokenGrace))
 	}
-	return true
+}
+
+// tokenPrefix returns a loggable prefix of a token
+func tokenPrefix(token string) string {
+	return token[:min(8, len(token))] + "..."
 }
 
 // InvalidateToken removes a token
@@ -283,6 +909,7 @@ func (h *Hub) InvalidateToken(token string) {
 	h.tokenMu.Lock()
 	defer h.tokenMu.Unlock()
 	delete(h.validTokens, token)
+	h.tokensChanged()
 }
 
 // CleanupExpiredTokens removes expired tokens
@@ -291,9 +918,15 @@ func (h *Hub) CleanupExpiredTokens() {
 	defer h.tokenMu.Unlock()
 
 	now := time.Now()
-	for token, expiry := range h.validTokens {
-		if now.After(expiry) {
+	for token, entry := range h.validTokens {
+		if now.After(entry.ExpiresAt) {
 			delete(h.validTokens, token)
+			h.tokensChanged()
+		}
+	}
+	for digest, until := range h.revokedTokens {
+		if now.After(until) {
+			delete(h.revokedTokens, digest)
 		}
 	}
 }
@@ -302,21 +935,54 @@ func (h *Hub) CleanupExpiredTokens() {
 func (h *Hub) Run() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
+	h.running.Store(true)
+	defer h.running.Store(false)
+
+	if h.config.RoomInfoRefresh > 0 {
+		go h.refreshRoomInfo()
+	}
+	if h.config.TokenStore != nil {
+		go h.persistTokens()
+	}
+	go h.runHooks()
 
 	for {
 		select {
 		case peer := <-h.register:
 			h.registerPeer(peer)
 
-		case peer := <-h.unregister:
-			h.unregisterPeer(peer)
+		case s := <-h.unregister:
+			h.endSession(s)
+
+		case peer := <-h.resumeExpired:
+			h.expireResume(peer)
+
+		case roomID := <-h.hostGraceExpired:
+			h.expireHostGrace(roomID)
 
 		case msg := <-h.broadcast:
 			h.routeMessage(msg)
 
+		case f := <-h.continueFanout:
+			h.continueFanOut(f)
+
+		case peer := <-h.slowPeers:
+			h.disconnectSlowPeer(peer)
+
+		case key := <-h.flushCandidates:
+			h.mu.RLock()
+			h.flushCandidateBuffer(key, nil)
+			h.mu.RUnlock()
+
 		case <-ticker.C:
-			h.cleanupRooms()
+			h.cleanupRooms(time.Now())
 			h.CleanupExpiredTokens()
+			h.closeExpiredConnections(time.Now())
+			h.evictDeadPeers(time.Now())
+			h.churn.prune(h.security.TokenChurnWindow)
+			h.reconnects.prune(h.security.ReconnectWindow)
+			h.rateLimiter.PruneAll(h.security.RateLimitWindow)
+			h.authLimiter.PruneAll(h.security.RateLimitWindow)
 
 		case <-h.done:
 			h.closeAllPeers()
@@ -328,118 +994,248 @@ func (h *Hub) Run() {
 // Shutdown gracefully shuts down the hub
 func (h *Hub) Shutdown() {
 	close(h.done)
+	if h.config.TokenStore != nil {
+		h.saveTokens()
+	}
 }
 
 func (h *Hub) registerPeer(peer *Peer) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	delete(h.reservedIDs, peer.ID)
+	if peer.awaitingPIN && !h.announcePINRequired(peer) {
+		h.logger.Warn("Connection awaiting PIN has no pending entry", zap.String("id", peer.ID))
+		peer.closeSend()
+		return
+	}
+	h.replaceDevicePeers(peer)
 	h.peers[peer.ID] = peer
-	h.logger.Info("Peer registered", zap.String("id", peer.ID), zap.String("role", string(peer.Role)))
+	h.emit(EventPeerRegistered, peer.ID, "", peer.Role)
+	h.logger.Info("Peer registered",
+		zap.String("id", peer.ID),
+		zap.String("role", string(peer.Role)),
+		zap.String("conn_id", peer.ConnID()))
+
+	if pre := peer.preamble; pre != nil {
+		peer.preamble = nil
+		if !h.holdIfAwaitingPIN(pre) {
+			h.registerLocked(peer, pre)
+		}
+	}
+	h.queueHooks(&h.onRegister, peer)
 }
 
 func (h *Hub) unregisterPeer(peer *Peer) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	h.removePeerLocked(peer)
+}
 
+// removePeerLocked must be called with h.mu held for writing
+func (h *Hub) removePeerLocked(peer *Peer) {
 	if _, ok := h.peers[peer.ID]; ok {
 		delete(h.peers, peer.ID)
+		if peer.resumeToken != "" {
+			delete(h.resumable, peer.resumeToken)
+		}
+		if peer.resumeTimer != nil {
+			peer.resumeTimer.Stop()
+		}
 
-		// Notify other peers that this peer left
+		// Notify other peers that this peer left. A replaced one is
+		// announced by its replacement's peer-joined instead, and if that
+		// never registered its own peer-left covers the one it replaced.
 		for _, otherPeer := range h.peers {
-			h.sendToPeer(otherPeer, &Message{
-				Type:   MsgTypePeerLeft,
-				PeerID: peer.ID,
-			})
+			if !peer.replaced {
+				h.sendToPeer(otherPeer, &Message{
+					Type:   MsgTypePeerLeft,
+					PeerID: peer.ID,
+				})
+			}
+			if peer.replaces != "" {
+				h.sendToPeer(otherPeer, &Message{
+					Type:   MsgTypePeerLeft,
+					PeerID: peer.replaces,
+				})
+			}
 		}
 
-		// Remove from room
-		if peer.Room != "" {
-			if room, ok := h.rooms[peer.Room]; ok {
-				room.mu.Lock()
-				if peer.Role == RoleHost {
-					room.Host = nil
-					// Notify clients that host left
-					for _, client := range room.Clients {
-						h.sendToPeer(client, &Message{
-							Type: MsgTypeLeave,
-							From: peer.ID,
-							Room: peer.Room,
-						})
-					}
-				} else {
-					delete(room.Clients, peer.ID)
-					// Notify host that client left
-					if room.Host != nil {
-						h.sendToPeer(room.Host, &Message{
-							Type: MsgTypeLeave,
-							From: peer.ID,
-							Room: peer.Room,
-						})
-					}
-				}
-				room.mu.Unlock()
-			}
+		h.leaveRoomLocked(peer)
+
+		if peer.token != "" {
+			h.releaseHostTokens(peer.ID)
+		}
+		h.dropCandidateBuffers(peer.ID)
+		h.quality.forget(peer.ID)
+		if peer.deviceID != "" && time.Since(peer.connectedAt) >= h.security.ReconnectStableAfter {
+			h.reconnects.reset(newReconnectKey(peer.deviceID, peer.token))
+		}
+		if peer.awaitingPIN {
+			h.removePendingAuth(peer.ID)
 		}
 
-		close(peer.Send)
-		h.logger.Info("Peer unregistered", zap.String("id", peer.ID))
+		peer.closeSend()
+		h.emit(EventPeerUnregistered, peer.ID, "", peer.Role)
+		h.queueHooks(&h.onUnregister, peer)
+		h.logger.Info("Peer unregistered", zap.String("id", peer.ID), zap.String("conn_id", peer.ConnID()))
 	}
 }
 
 func (h *Hub) routeMessage(msg *Message) {
+	h.mu.RLock()
+	held := h.holdIfAwaitingPIN(msg)
+	allowed := held || h.checkMessageACL(msg)
+	h.mu.RUnlock()
+	if held || !allowed {
+		return
+	}
+	h.metrics.Routed(msg.Type)
+
 	switch msg.Type {
 	case MsgTypeRegister:
 		h.handleRegister(msg)
 
-	case MsgTypeJoin:
-		h.mu.RLock()
-		h.handleJoin(msg)
-		h.mu.RUnlock()
+	case MsgTypePairingMode:
+		h.handlePairingMode(msg)
+
+	case MsgTypePinVerify:
+		h.handlePinVerify(msg)
 
-	case MsgTypeOffer, MsgTypeAnswer, MsgTypeCandidate, MsgTypeIceCandidate:
+	case MsgTypeConnected:
+		h.handleConnected(msg)
+
+	case MsgTypeKeyBundle:
+		h.handleKeyBundle(msg)
+
+	case MsgTypeKeyBundleAck:
+		h.handleKeyBundleAck(msg)
+
+	case MsgTypeKick:
+		h.handleKick(msg)
+
+	case MsgTypeWhoAmI:
+		h.handleWhoAmI(msg)
+
+	case MsgTypeLeave:
+		h.handleLeave(msg)
+
+	case MsgTypeJoin:
+		// Passwords are hashed before taking the lock, so hosts setting
+		// them and clients guessing them don't stall the hub. handleJoin
+		// may create a room, so it needs the write lock.
+		check := h.verifyJoinPassword(msg)
+		h.mu.Lock()
+		h.handleJoin(msg, check)
+		h.mu.Unlock()
+
+	case MsgTypeOffer, MsgTypeAnswer, MsgTypeCandidate, MsgTypeIceCandidate, MsgTypeCandidates:
 		h.mu.RLock()
 		defer h.mu.RUnlock()
+		if !h.checkMediaPolicy(msg) {
+			return
+		}
+		fromPeer, ok := h.peers[msg.From]
+		if !ok {
+			return
+		}
+		// Signaling only flows between members of the same room
+		room, ok := h.rooms[fromPeer.Room]
+		if !ok {
+			h.logger.Warn("Dropping signaling from peer outside any room",
+				zap.String("from", msg.From),
+				zap.String("type", string(msg.Type)))
+			return
+		}
+		room.mu.RLock()
+		defer room.mu.RUnlock()
+		if !room.hasMember(fromPeer.ID) {
+			h.logger.Warn("Dropping signaling from peer not in its room",
+				zap.String("from", msg.From),
+				zap.String("room", room.ID))
+			return
+		}
+
+		if msg.MessageID != "" && h.dedup.duplicate(msg.From, msg.To, msg.MessageID) {
+			h.logger.Debug("Dropping duplicate signaling message",
+				zap.String("from", msg.From),
+				zap.String("message-id", msg.MessageID))
+			return
+		}
+
 		// Route to specific peer
 		targetID := msg.To
 		if targetID != "" {
-			if peer, ok := h.peers[targetID]; ok {
-				h.sendToPeer(peer, msg)
-			} else {
+			peer, ok := h.peers[targetID]
+			if !ok {
 				h.logger.Warn("Target peer not found", zap.String("to", targetID))
+				return
 			}
-		} else {
-			// If no specific target, broadcast to all peers of opposite role
-			fromPeer, ok := h.peers[msg.From]
-			if !ok {
+			if !room.hasMember(targetID) {
+				h.logger.Warn("Dropping signaling to peer in another room",
+					zap.String("from", msg.From),
+					zap.String("to", targetID),
+					zap.String("type", string(msg.Type)))
 				return
 			}
-			for _, peer := range h.peers {
-				if peer.ID != msg.From {
-					// Host sends to viewers, viewers send to host
-					if (fromPeer.Role == RoleHost && peer.Role == RoleClient) ||
-						(fromPeer.Role == RoleClient && peer.Role == RoleHost) {
-						h.sendToPeer(peer, msg)
-					}
-				}
+			h.deliverSignal(peer, msg)
+		} else if room.isHost(fromPeer.ID) {
+			// If no specific target, host sends to the room's viewers...
+			targets := make([]*Peer, 0, len(room.Clients))
+			for _, peer := range room.Clients {
+				targets = append(targets, peer)
 			}
+			h.fanOut(room, msg, targets, true)
+		} else if room.Host != nil {
+			// ...and viewers send to the room's host
+			h.deliverSignal(room.Host, msg)
 		}
 
 	default:
 		h.mu.RLock()
 		defer h.mu.RUnlock()
-		// Broadcast to room
+		if !knownMessageType(msg.Type) && !strings.HasPrefix(string(msg.Type), AppMessagePrefix) &&
+			!h.config.BroadcastUnknownTypes {
+			h.logger.Warn("Rejected unknown message type",
+				zap.String("from", msg.From),
+				zap.String("type", string(msg.Type)))
+			if peer, ok := h.peers[msg.From]; ok {
+				h.sendError(peer, CodeUnknownType, "unknown message type")
+			}
+			return
+		}
+		// Broadcast to room, if the sender is in it
 		if msg.Room != "" {
 			if room, ok := h.rooms[msg.Room]; ok {
 				room.mu.RLock()
+				member := room.hasMember(msg.From)
+				room.mu.RUnlock()
+				if !member {
+					h.logger.Warn("Rejected broadcast to a room the sender isn't in",
+						zap.String("from", msg.From),
+						zap.String("room", msg.Room),
+						zap.String("type", string(msg.Type)))
+					if peer, ok := h.peers[msg.From]; ok {
+						h.sendError(peer, CodeNotInRoom, "Not a member of this room")
+					}
+					return
+				}
+				if msg.Sticky {
+					h.storeSticky(room, msg)
+				}
+				room.mu.RLock()
+				targets := make([]*Peer, 0, len(room.Clients)+1)
 				for _, peer := range room.Clients {
 					if peer.ID != msg.From {
-						h.sendToPeer(peer, msg)
+						targets = append(targets, peer)
 					}
 				}
-				if room.Host != nil && room.Host.ID != msg.From {
-					h.sendToPeer(room.Host, msg)
+				for _, host := range room.hosts() {
+					if host.ID != msg.From {
+						targets = append(targets, host)
+					}
 				}
+				h.fanOut(room, msg, targets, false)
 				room.mu.RUnlock()
 			}
 		}
@@ -454,6 +1250,25 @@ func (h *Hub) handleRegister(msg *Message) {
 	if !ok {
 		return
 	}
+	h.registerLocked(peer, msg)
+}
+
+// registerLocked applies a registration to peer, from a register message
+// or the handshake. Must be called with h.mu held for writing.
+func (h *Hub) registerLocked(peer *Peer, msg *Message) {
+	if msg.Role == RoleHost && !peer.hostConn {
+		h.logger.Warn("Registration claims host role on a client connection", zap.String("peer", peer.ID))
+		h.sendError(peer, CodeInvalidRegistration, "Host role requires a host connection")
+		return
+	}
+	caps, ok := negotiateCapabilities(msg.Capabilities)
+	if !ok {
+		h.logger.Warn("Registration declares an unknown capabilities version",
+			zap.String("peer", peer.ID),
+			zap.Int("version", msg.Capabilities.Version))
+		h.sendError(peer, CodeInvalidRegistration, "Unsupported capabilities version")
+		return
+	}
 
 	// Set peer info
 	if msg.Role != "" {
@@ -463,28 +1278,55 @@ func (h *Hub) handleRegister(msg *Message) {
 		peer.Role = RoleClient
 	}
 	peer.Name = msg.Name
+	peer.requestedName = msg.Name
+	peer.mu.Lock()
 	peer.LastPing = time.Now() // Update last ping time
+	peer.mu.Unlock()
+	peer.Capabilities = caps
+	peer.Tags = msg.Tags
+	peer.lanIP = msg.LANIP
+	if peer.resumeToken == "" && h.config.ResumeGrace > 0 {
+		if peer.resumeToken = generateResumeToken(); peer.resumeToken != "" {
+			h.resumable[peer.resumeToken] = peer
+		}
+	}
 
 	h.logger.Info("Peer registered",
 		zap.String("id", peer.ID),
 		zap.String("role", string(peer.Role)),
-		zap.String("name", peer.Name))
-
-	// Send confirmation
-	h.sendToPeer(peer, &Message{
-		Type:   MsgTypeRegistered,
-		PeerID: peer.ID,
-	})
+		zap.String("name", peer.Name),
+		zap.Bool("trickle", peer.Capabilities.trickle()),
+		zap.String("conn_id", peer.ConnID()))
+
+	// Send confirmation, advertising the capability schema we understand
+	// and the ICE servers the peer should use
+	registered := &Message{
+		Type:         MsgTypeRegistered,
+		PeerID:       peer.ID,
+		ResumeToken:  peer.resumeToken,
+		Capabilities: serverCapabilities(),
+	}
+	if peer.ProtocolVersion >= ProtocolV2 {
+		registered.ProtocolVersion = peer.ProtocolVersion
+	}
+	if servers := h.config.ICE.iceServers(peer.ID); len(servers) > 0 {
+		registered.Payload, _ = json.Marshal(registeredPayload{ICEServers: servers})
+	}
+	h.sendToPeer(peer, registered)
 
 	// Notify other peers
+	replaces := peer.replaces
+	peer.replaces = ""
 	for _, otherPeer := range h.peers {
 		if otherPeer.ID != peer.ID {
 			// Notify existing peers about new peer
 			h.sendToPeer(otherPeer, &Message{
-				Type:   MsgTypePeerJoined,
-				PeerID: peer.ID,
-				Name:   peer.Name,
-				Role:   peer.Role,
+				Type:     MsgTypePeerJoined,
+				PeerID:   peer.ID,
+				Name:     peer.Name,
+				Role:     peer.Role,
+				Tags:     peer.Tags,
+				Replaces: replaces,
 			})
 
 			// Notify new peer about existing peers
@@ -493,12 +1335,13 @@ func (h *Hub) handleRegister(msg *Message) {
 				PeerID: otherPeer.ID,
 				Name:   otherPeer.Name,
 				Role:   otherPeer.Role,
+				Tags:   otherPeer.Tags,
 			})
 		}
 	}
 }
 
-func (h *Hub) handleJoin(msg *Message) {
+func (h *Hub) handleJoin(msg *Message, check passwordCheck) {
 	peer, ok := h.peers[msg.From]
 	if !ok {
 		return
@@ -506,45 +1349,103 @@ func (h *Hub) handleJoin(msg *Message) {
 
 	roomID := msg.Room
 	if roomID == "" {
-		h.sendError(peer, "Room ID required")
+		h.sendError(peer, CodeRoomRequired, "Room ID required")
+		return
+	}
+	if msg.Role == RoleHost && !peer.hostConn {
+		h.logger.Warn("Join claims host role on a client connection", zap.String("peer", peer.ID))
+		h.sendError(peer, CodeNotHost, "Host role requires a host connection")
+		return
+	}
+	if msg.Role != RoleHost && !h.tokenAdmits(peer, roomID) {
+		h.sendError(peer, CodeTokenRoom, "Token not valid for this room")
 		return
 	}
 
 	// Create or get room
 	room, ok := h.rooms[roomID]
 	if !ok {
+		if max := h.security.MaxRooms; max > 0 && len(h.rooms) >= max {
+			h.logger.Warn("Room limit reached", zap.String("room", roomID), zap.Int("max", max))
+			h.sendLimitError(peer, CodeTooManyRooms, "Room limit reached", max)
+			return
+		}
 		room = &Room{
 			ID:         roomID,
 			Clients:    make(map[string]*Peer),
 			CreatedAt:  time.Now(),
 			LastActive: time.Now(),
+
+			keyDeliveries: make(map[string]*keyDelivery),
 		}
 		h.rooms[roomID] = room
+		h.emit(EventRoomCreated, "", roomID, "")
 		h.logger.Info("Room created", zap.String("room", roomID))
 	}
 
+	password := msg.Password
+	msg.Password = ""
+	if msg.Role != RoleHost && !h.checkRoomPassword(room, peer, password, check) {
+		return
+	}
+
 	room.mu.Lock()
 	defer room.mu.Unlock()
 
+	if msg.Role != RoleHost {
+		_, rejoin := room.Clients[peer.ID]
+		if max := h.security.MaxClientsPerRoom; max > 0 && !rejoin && len(room.Clients) >= max {
+			h.logger.Warn("Room full", zap.String("room", roomID), zap.String("peer", peer.ID), zap.Int("max", max))
+			h.sendLimitError(peer, CodeRoomFull, "Room is full", max)
+			return
+		}
+	}
+
 	peer.Room = roomID
 	room.LastActive = time.Now()
+	room.emptySince = time.Time{}
+
+	if h.config.UniqueRoomNames {
+		peer.Name = uniqueRoomName(room, peer, peer.requestedName)
+		if peer.Name != peer.requestedName {
+			h.logger.Info("Peer name taken in room, using suffixed name",
+				zap.String("room", roomID),
+				zap.String("peer", peer.ID),
+				zap.String("name", peer.Name))
+		}
+	}
 
 	if msg.Role == RoleHost {
-		if room.Host != nil && room.Host.ID != peer.ID {
-			h.sendError(peer, "Room already has a host")
+		additional := room.Host != nil && !room.isHost(peer.ID)
+		if !room.addHost(peer, h.config.MaxHostsPerRoom) {
+			h.sendError(peer, CodeDuplicateHost, "Room already has a host")
 			return
 		}
-		room.Host = peer
+		room.hostLeftAt = time.Time{}
 		peer.Role = RoleHost
+		if password != "" {
+			h.setRoomPassword(room, peer, check.set)
+		}
+		if peer.token != "" {
+			h.scopeHostTokens(peer.ID, roomID)
+		}
+		h.emit(EventHostJoined, peer.ID, roomID, RoleHost)
 		h.logger.Info("Host joined room", zap.String("room", roomID), zap.String("peer", peer.ID))
+
+		// Let clients see the new stream to pick from
+		if additional {
+			for _, client := range room.Clients {
+				h.sendRoomInfo(client, room)
+			}
+		}
 	} else {
 		room.Clients[peer.ID] = peer
 		peer.Role = RoleClient
 		h.logger.Info("Client joined room", zap.String("room", roomID), zap.String("peer", peer.ID))
 
-		// Notify host of new client
-		if room.Host != nil {
-			h.sendToPeer(room.Host, &Message{
+		// Notify hosts of new client
+		for _, host := range room.hosts() {
+			h.sendToPeer(host, &Message{
 				Type: MsgTypeJoin,
 				From: peer.ID,
 				Room: roomID,
@@ -555,6 +1456,43 @@ func (h *Hub) handleJoin(msg *Message) {
 
 	// Send room info to peer
 	h.sendRoomInfo(peer, room)
+
+	// Bring late joiners up to date with the host's current state
+	if peer.Role == RoleClient {
+		for _, sticky := range room.sticky {
+			h.sendToPeer(peer, sticky)
+		}
+		if room.keyBundle != nil {
+			h.deliverKeyBundle(room, peer, room.keyBundle)
+		}
+	}
+}
+
+// storeSticky records a host's sticky broadcast on the room, replacing
+// any earlier sticky message of the same type
+func (h *Hub) storeSticky(room *Room, msg *Message) {
+	if h.config.StickyHistorySize <= 0 {
+		return
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if !room.isHost(msg.From) {
+		h.logger.Warn("Ignoring sticky flag from non-host", zap.String("peer", msg.From))
+		return
+	}
+
+	for i, existing := range room.sticky {
+		if existing.Type == msg.Type {
+			room.sticky = append(room.sticky[:i], room.sticky[i+1:]...)
+			break
+		}
+	}
+	if len(room.sticky) >= h.config.StickyHistorySize {
+		room.sticky = room.sticky[1:]
+	}
+	room.sticky = append(room.sticky, msg)
 }
 
 func (h *Hub) sendRoomInfo(peer *Peer, room *Room) {
@@ -562,6 +1500,7 @@ func (h *Hub) sendRoomInfo(peer *Peer, room *Room) {
 		RoomID    string   `json:"room_id"`
 		HasHost   bool     `json:"has_host"`
 		HostID    string   `json:"host_id,omitempty"`
+		HostIDs   []string `json:"host_ids,omitempty"` // All hosts, HostID first
 		ClientIDs []string `json:"client_ids"`
 	}
 
@@ -574,6 +1513,9 @@ func (h *Hub) sendRoomInfo(peer *Peer, room *Room) {
 	if room.Host != nil {
 		payload.HostID = room.Host.ID
 	}
+	for _, host := range room.hosts() {
+		payload.HostIDs = append(payload.HostIDs, host.ID)
+	}
 
 	for id := range room.Clients {
 		payload.ClientIDs = append(payload.ClientIDs, id)
@@ -583,6 +1525,7 @@ func (h *Hub) sendRoomInfo(peer *Peer, room *Room) {
 	h.sendToPeer(peer, &Message{
 		Type:    MsgTypeRoomInfo,
 		Room:    room.ID,
+		Name:    peer.Name, // Effective name within the room
 		Payload: payloadBytes,
 	})
 }
@@ -595,34 +1538,52 @@ func (h *Hub) sendToPeer(peer *Peer, msg *Message) {
 		return
 	}
 
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	if peer.sendClosed {
+		return
+	}
+
 	select {
 	case peer.Send <- data:
 	default:
-		h.logger.Warn("Peer send buffer full", zap.String("peer", peer.ID))
+		h.sendBufferFull(peer, data)
 	}
 }
 
-func (h *Hub) sendError(peer *Peer, errMsg string) {
-	payload, _ := json.Marshal(map[string]string{"error": errMsg})
-	h.sendToPeer(peer, &Message{
-		Type:    MsgTypeError,
-		Payload: payload,
-	})
+// closeSend closes the peer's Send channel once, telling writePump to
+// close the connection
+func (p *Peer) closeSend() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.sendClosed {
+		p.sendClosed = true
+		close(p.Send)
+	}
 }
 
-func (h *Hub) cleanupRooms() {
+// cleanupRooms removes rooms idle for longer than the room timeout and
+// rooms that have been empty for EmptyRoomGrace, as of now
+func (h *Hub) cleanupRooms(now time.Time) {
+	h.cleanupPendingAuth()
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	now := time.Now()
 	for id, room := range h.rooms {
 		room.mu.RLock()
 		isEmpty := room.Host == nil && len(room.Clients) == 0
+		emptySince := room.emptySince
+		if emptySince.IsZero() {
+			emptySince = room.LastActive // Created but never joined
+		}
+		graceOver := now.Sub(emptySince) >= h.config.EmptyRoomGrace
 		isStale := now.Sub(room.LastActive) > h.timeout
 		room.mu.RUnlock()
 
-		if isEmpty || isStale {
+		if (isEmpty && graceOver) || isStale {
 			delete(h.rooms, id)
+			h.emit(EventRoomDestroyed, "", id, "")
 			h.logger.Info("Room cleaned up", zap.String("room", id))
 		}
 	}
@@ -632,45 +1593,47 @@ func (h *Hub) closeAllPeers() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	for _, peer := range h.peers {
-		close(peer.Send)
-	}
-}
-
-// HandleRoomInfo handles HTTP requests for room information
-func (h *Hub) HandleRoomInfo(w http.ResponseWriter, r *http.Request) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-
-	type RoomSummary struct {
-		ID         string    `json:"id"`
-		HasHost    bool      `json:"has_host"`
-		NumClients int       `json:"num_clients"`
-		CreatedAt  time.Time `json:"created_at"`
-		LastActive time.Time `json:"last_active"`
+	code, reason := websocket.CloseGoingAway, "server shutting down"
+	if h.Draining() {
+		code, reason = CloseDraining, "server shutting down, reconnect to another instance"
 	}
-
-	rooms := make([]RoomSummary, 0, len(h.rooms))
-	for _, room := range h.rooms {
-		room.mu.RLock()
-		rooms = append(rooms, RoomSummary{
-			ID:         room.ID,
-			HasHost:    room.Host != nil,
-			NumClients: len(room.Clients),
-			CreatedAt:  room.CreatedAt,
-			LastActive: room.LastActive,
-		})
-		room.mu.RUnlock()
+	for _, peer := range h.peers {
+		peer.setCloseReason(code, reason)
+		peer.closeSend()
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(rooms)
 }
 
 // HandleWebSocket handles WebSocket upgrade and connection with security
 type WebSocketSecurity struct {
 	RequireTLS      bool
 	DefaultTokenTTL time.Duration
+
+	// EnableCompression negotiates permessage-deflate with clients that offer it
+	EnableCompression bool
+	// CompressionThreshold is the message size below which frames are sent
+	// uncompressed even when compression was negotiated
+	CompressionThreshold int
+	// CompressionLevel is the flate level for compressed frames, from 1
+	// (fastest) to 9 (smallest); 0 keeps the library default
+	CompressionLevel int
+
+	// MaxMessageSize is the largest message accepted from a peer, and
+	// Read/WriteBufferSize the upgrader's I/O buffers; all in bytes, 0 for
+	// the defaults
+	MaxMessageSize  int
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// PingInterval is how often peers are pinged and PongTimeout how long
+	// a peer that doesn't pong is kept before it is dropped; 0 for the
+	// defaults. The timeout must be longer than the interval, see
+	// ValidateKeepalive.
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+
+	// Local marks a connection that arrived on a local-only listener, such
+	// as a Unix socket, and is trusted like one from localhost
+	Local bool
 }
 
 func HandleWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request, logger *zap.Logger, sec WebSocketSecurity) {
@@ -679,6 +1642,25 @@ func HandleWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request, logger *z
 	token := extractToken(r)
 	deviceID := r.URL.Query().Get("device_id")
 
+	hub.mu.RLock()
+	connID := hub.ids.ConnID()
+	hub.mu.RUnlock()
+	logger = logger.With(zap.String("conn_id", connID))
+	w.Header().Set(connIDHeader, connID)
+
+	// Address filtering comes before any rate-limit bookkeeping, so denied
+	// ranges can't use up the buckets of allowed clients
+	if filter := hub.security.IPFilter; filter.Active() {
+		if ip, _ := hub.ClientIP(r); !filter.Allowed(ip) {
+			logger.Warn("Rejecting connection from disallowed address",
+				zap.String("remote", remoteAddr),
+				zap.Stringer("client", ip))
+			hub.metrics.Reject(RejectIPDenied)
+			httpError(w, CodeIPDenied, "Address not allowed", http.StatusForbidden)
+			return
+		}
+	}
+
 	logger.Info("WebSocket connection attempt",
 		zap.String("remote", remoteAddr),
 		zap.String("path", r.URL.Path),
@@ -686,16 +1668,75 @@ func HandleWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request, logger *z
 		zap.String("client-type", clientType),
 		zap.Bool("has-token", token != ""))
 
+	// A draining server sends new sessions to its replacement
+	if hub.Draining() {
+		logger.Info("Rejecting connection, server draining", zap.String("remote", remoteAddr))
+		hub.metrics.Reject(RejectDraining)
+		rejectWebSocket(w, r, CodeServerDraining, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Load shedding - refuse new sessions rather than degrade existing ones
+	if load := hub.Load(); load.Overloaded {
+		logger.Warn("Rejecting connection, server overloaded",
+			zap.String("remote", remoteAddr),
+			zap.String("reason", load.Reason))
+		w.Header().Set("Retry-After", overloadRetryAfter)
+		hub.metrics.Reject(RejectOverloaded)
+		httpError(w, CodeOverloaded, "Server overloaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	// The slot is handed to the session once its pumps start and released
+	// when the connection closes; every earlier exit gives it back here
+	if !hub.conns.acquire() {
+		logger.Warn("Rejecting connection, too many open connections",
+			zap.String("remote", remoteAddr),
+			zap.Int("max", hub.security.MaxConnections))
+		w.Header().Set("Retry-After", overloadRetryAfter)
+		hub.metrics.Reject(RejectConnLimit)
+		httpError(w, CodeOverloaded, "Too many connections", http.StatusServiceUnavailable)
+		return
+	}
+	slotHandedOff := false
+	defer func() {
+		if !slotHandedOff {
+			hub.conns.release()
+		}
+	}()
+
 	// Rate limiting check
-	if !hub.rateLimiter.Allow(remoteAddr, hub.security.MaxConnAttempts, hub.security.RateLimitWindow) {
-		logger.Warn("Rate limited connection attempt", zap.String("remote", remoteAddr))
-		http.Error(w, "Too many connection attempts", http.StatusTooManyRequests)
+	clientIP := hub.clientKey(r)
+	limitKey := hub.rateLimitKey(clientIP, deviceID, token)
+	if !hub.allowConnAttempt(clientIP, limitKey) {
+		logger.Warn("Rate limited connection attempt",
+			zap.String("remote", remoteAddr),
+			zap.String("key", limitKey))
+		w.Header().Set(rateLimitRemainingHeader, "0")
+		w.Header().Set("Retry-After", strconv.Itoa(int(hub.security.RateLimitWindow.Seconds())))
+		hub.metrics.Reject(RejectRateLimit)
+		rejectWebSocket(w, r, CodeRateLimited, "Too many connection attempts", http.StatusTooManyRequests)
 		return
 	}
 
+	// Tell cooperative clients they are close to the limit so they can
+	// back off before being locked out. The upgrade response is written
+	// by the upgrader, so the headers are collected separately.
+	responseHeader := http.Header{}
+	responseHeader.Set(connIDHeader, connID)
+	remaining := hub.rateLimiter.Remaining(limitKey, hub.security.MaxConnAttempts, hub.security.RateLimitWindow)
+	if hub.security.RateLimitWarnAt > 0 && remaining <= hub.security.RateLimitWarnAt {
+		logger.Info("Client close to rate limit",
+			zap.String("remote", remoteAddr),
+			zap.Int("remaining", remaining))
+		responseHeader.Set(rateLimitRemainingHeader, strconv.Itoa(remaining))
+		w.Header().Set(rateLimitRemainingHeader, strconv.Itoa(remaining))
+	}
+
 	if sec.RequireTLS && r.TLS == nil {
 		logger.Warn("Rejected non-TLS WebSocket")
-		http.Error(w, "TLS required", http.StatusUpgradeRequired)
+		hub.metrics.Reject(RejectNoTLS)
+		httpError(w, CodeTLSRequired, "TLS required", http.StatusUpgradeRequired)
 		return
 	}
 
@@ -704,7 +1745,7 @@ func HandleWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request, logger *z
 	// Exception: localhost connections (USB via ADB reverse) don't require tokens
 	// Check both header and query param for host identification
 	isHost := clientType == "host" || r.URL.Query().Get("is_host") == "true"
-	isLocalhost := strings.HasPrefix(remoteAddr, "127.0.0.1:") || strings.HasPrefix(remoteAddr, "[::1]:")
+	isLocalhost := sec.Local || hub.IsLocalRequest(r)
 
 	logger.Info("Connection type detection",
 		zap.Bool("is-host", isHost),
@@ -712,49 +1753,236 @@ func HandleWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request, logger *z
 		zap.String("client-type-header", clientType),
 		zap.String("is_host-param", r.URL.Query().Get("is_host")))
 
+	tokenChecked := false
 	if isHost {
 		if token == "" {
 			logger.Warn("Host connection without token rejected")
-			http.Error(w, "Token required for host", http.StatusUnauthorized)
+			hub.metrics.Reject(RejectBadToken)
+			rejectWebSocket(w, r, CodeBadToken, "Token required for host", http.StatusUnauthorized)
+			return
+		}
+		if hub.tokenRevoked(token) {
+			logger.Warn("Host connection with revoked token rejected", zap.String("remote", remoteAddr))
+			hub.metrics.Reject(RejectBadToken)
+			rejectWebSocket(w, r, CodeTokenRevoked, "Token revoked", http.StatusUnauthorized)
+			return
+		}
+		if hub.tokenMinted(token) {
+			logger.Warn("Host connection with minted client token rejected", zap.String("remote", remoteAddr))
+			hub.metrics.Reject(RejectBadToken)
+			rejectWebSocket(w, r, CodeBadToken, "Client token can't be used by a host", http.StatusForbidden)
 			return
 		}
-		hub.RegisterToken(token, sec.DefaultTokenTTL)
-		logger.Info("Host token registered", zap.String("remote", remoteAddr))
 	} else if hub.security.RequireToken && !isLocalhost {
 		// Non-localhost clients require valid token
 		if token == "" {
 			logger.Warn("Client without token rejected", zap.String("remote", remoteAddr))
-			http.Error(w, "Token required", http.StatusUnauthorized)
+			hub.metrics.Reject(RejectBadToken)
+			rejectWebSocket(w, r, CodeBadToken, "Token required", http.StatusUnauthorized)
+			return
+		}
+		if hub.authFailuresExhausted(clientIP) {
+			logger.Warn("Too many failed token attempts", zap.String("remote", remoteAddr))
+			w.Header().Set("Retry-After", strconv.Itoa(int(hub.security.RateLimitWindow.Seconds())))
+			hub.metrics.Reject(RejectRateLimit)
+			rejectWebSocket(w, r, CodeRateLimited, "Too many failed authentication attempts", http.StatusTooManyRequests)
 			return
 		}
 		if !hub.ValidateToken(token) {
 			logger.Warn("Invalid token rejected",
 				zap.String("remote", remoteAddr),
-				zap.String("token", token[:min(8, len(token))]+"..."))
-			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+				zap.String("token", tokenPrefix(token)))
+			hub.recordAuthFailure(clientIP)
+			hub.metrics.Reject(RejectBadToken)
+			rejectWebSocket(w, r, CodeBadToken, "Invalid or expired token", http.StatusUnauthorized)
 			return
 		}
+		// Failures expire with the window rather than being cleared here,
+		// or one valid token behind the same NAT would reset the budget of
+		// every guesser sharing the address
+		tokenChecked = true
 		logger.Info("Token validated successfully", zap.String("remote", remoteAddr))
 	} else if isLocalhost {
 		// Localhost (USB) connections are trusted
 		logger.Info("Localhost connection allowed (USB)", zap.String("remote", remoteAddr))
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	// Protocol versioning - clients offering only versions we don't speak
+	// are told to upgrade rather than silently speaking v1
+	if offered := websocket.Subprotocols(r); !offersSupportedProtocol(offered) {
+		logger.Warn("Rejected unsupported protocol versions",
+			zap.String("remote", remoteAddr),
+			zap.Strings("offered", offered))
+		hub.metrics.Reject(RejectProtocol)
+		httpError(w, CodeProtocolVersion, "Unsupported protocol version; supported: "+strings.Join(supportedSubprotocols, ", "), http.StatusUpgradeRequired)
+		return
+	}
+
+	wsUpgrader := upgrader
+	if sec.ReadBufferSize > 0 {
+		wsUpgrader.ReadBufferSize = sec.ReadBufferSize
+	}
+	if sec.WriteBufferSize > 0 {
+		wsUpgrader.WriteBufferSize = sec.WriteBufferSize
+	}
+	wsUpgrader.EnableCompression = sec.EnableCompression
+	wsUpgrader.Subprotocols = supportedSubprotocols
+	wsUpgrader.CheckOrigin = func(r *http.Request) bool {
+		if !upgrader.CheckOrigin(r) {
+			hub.metrics.Reject(RejectBadOrigin)
+			return false
+		}
+		return true
+	}
+
+	// Token churn - a device cycling through tokens is a buggy client
+	// minting one per connection, or a shared/leaked credential
+	if deviceID != "" && token != "" && hub.security.TokenChurnThreshold > 0 {
+		if n := hub.churn.observe(deviceID, token, hub.security.TokenChurnWindow); n > hub.security.TokenChurnThreshold {
+			logger.Warn("Device is cycling through tokens",
+				zap.String("device-id", deviceID),
+				zap.Int("tokens", n),
+				zap.Duration("window", hub.security.TokenChurnWindow))
+			if hub.security.RejectTokenChurn {
+				hub.metrics.Reject(RejectChurn)
+				httpError(w, CodeTokenChurn, "Too many tokens for device", http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	// Reconnect loops - a device repeatedly connecting and dropping is
+	// almost always a client failing negotiation and retrying immediately
+	if deviceID != "" && hub.security.ReconnectLimit > 0 {
+		key := newReconnectKey(deviceID, token)
+		wait, flagged := hub.reconnects.admit(key, hub.security.ReconnectLimit, hub.security.ReconnectWindow)
+		if flagged {
+			logger.Warn("Device is in a reconnect loop, probable client bug",
+				zap.String("device-id", deviceID),
+				zap.Int("limit", hub.security.ReconnectLimit),
+				zap.Duration("window", hub.security.ReconnectWindow),
+				zap.Duration("backoff", wait))
+		}
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+			hub.metrics.Reject(RejectReconnect)
+			httpError(w, CodeReconnectLoop, "Reconnecting too often", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	// Outside a pairing window only already-paired flows (hosts, USB) connect
+	if !isHost && !isLocalhost && !hub.acceptsNewClients() {
+		logger.Warn("Client rejected, pairing mode not active", zap.String("remote", remoteAddr))
+		hub.metrics.Reject(RejectPairing)
+		httpError(w, CodePairingInactive, "Pairing mode not active", http.StatusForbidden)
+		return
+	}
+
+	// Registration carried in the handshake
+	preamble, hasPreamble := handshakeRegistration(r)
+	if hasPreamble {
+		if err := preamble.validate(hub.security.FieldLimits); err != nil {
+			httpError(w, CodeInvalidRegistration, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch preamble.Role {
+		case "", RoleClient:
+		case RoleHost:
+			if !isHost {
+				logger.Warn("Handshake claims host role on a client connection", zap.String("remote", remoteAddr))
+				httpError(w, CodeInvalidRegistration, "Host role requires a host connection", http.StatusForbidden)
+				return
+			}
+		default:
+			httpError(w, CodeInvalidRegistration, "Invalid role", http.StatusBadRequest)
+			return
+		}
+		if preamble.Role == "" && isHost {
+			preamble.Role = RoleHost
+		}
+	} else if hub.security.RequireHandshakeRegistration && r.URL.Query().Get("resume_token") == "" {
+		httpError(w, CodeInvalidRegistration, "Registration required in handshake", http.StatusBadRequest)
+		return
+	}
+
+	// The server-wide Read/WriteTimeout are meant for short HTTP requests.
+	// A WebSocket lives for the whole session and manages its own
+	// deadlines in readPump/writePump, so lift the server deadlines here
+	// before upgrading or long-lived connections get cut off.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	// Resume a session dropped within the grace window
+	if resumeToken := r.URL.Query().Get("resume_token"); resumeToken != "" && hub.canResume(resumeToken) {
+		conn, err := wsUpgrader.Upgrade(w, r, responseHeader)
+		if err != nil {
+			logger.Error("WebSocket upgrade failed",
+				zap.Error(err),
+				zap.String("remote", remoteAddr))
+			return
+		}
+		setCompressionLevel(conn, sec.CompressionLevel)
+		_, ok := hub.resumePeer(resumeToken, conn, connID, logger)
+		slotHandedOff = ok
+		if !ok {
+			// Expired between the check and the upgrade
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "resume token expired"),
+				time.Now().Add(time.Second))
+			conn.Close()
+			return
+		}
+		hub.metrics.ConnectionsAccepted.Add(1)
+		return
+	}
+
+	// Reserve the peer ID before upgrading so conflicts are reported as
+	// plain HTTP errors and two racing clients can't both get the same ID
+	proposedID := ""
+	if hub.security.AllowClientPeerIDs {
+		proposedID = r.URL.Query().Get("peer_id")
+	}
+	peerID, err := hub.reservePeerID(proposedID)
+	if err != nil {
+		logger.Warn("Peer ID rejected",
+			zap.String("remote", remoteAddr),
+			zap.String("peer-id", proposedID),
+			zap.Error(err))
+		status := http.StatusConflict
+		switch err {
+		case ErrInvalidPeerID:
+			status = http.StatusBadRequest
+		case ErrPeerIDUnavailable:
+			status = http.StatusServiceUnavailable
+		}
+		hub.metrics.Reject(RejectPeerID)
+		httpError(w, CodePeerIDRejected, err.Error(), status)
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
 		logger.Error("WebSocket upgrade failed",
 			zap.Error(err),
 			zap.String("remote", remoteAddr))
+		hub.releasePeerID(peerID)
 		return
 	}
+	setCompressionLevel(conn, sec.CompressionLevel)
 
+	hub.metrics.ConnectionsAccepted.Add(1)
 	logger.Info("WebSocket connected",
 		zap.String("remote", remoteAddr),
 		zap.Bool("is-host", isHost),
 		zap.String("device-id", deviceID))
 
-	// Generate peer ID
-	peerID := generatePeerID()
+	if proposedID != "" && peerID != proposedID {
+		logger.Info("Proposed peer ID taken, assigned alternative",
+			zap.String("proposed", proposedID),
+			zap.String("assigned", peerID))
+	}
 
 	peer := &Peer{
 		ID:       peerID,
@@ -763,13 +1991,61 @@ func HandleWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request, logger *z
 		Hub:      hub,
 		Logger:   logger,
 		LastPing: time.Now(),
+
+		ProtocolVersion: negotiatedVersion(conn),
+
+		token:                token,
+		tokenChecked:         tokenChecked,
+		hostConn:             isHost,
+		deviceID:             deviceID,
+		connectedAt:          time.Now(),
+		compressionThreshold: sec.CompressionThreshold,
+		maxMessageSize:       sec.MaxMessageSize,
+	}
+	peer.connID.Store(connID)
+	if peer.maxMessageSize <= 0 {
+		peer.maxMessageSize = DefaultMaxMessageSize
+	}
+	peer.pingInterval, peer.pongTimeout = keepaliveOrDefault(sec.PingInterval, sec.PongTimeout)
+
+	if isHost {
+		hub.registerHostToken(token, peerID, sec.DefaultTokenTTL)
+		logger.Info("Host token registered", zap.String("remote", remoteAddr))
+	} else if hub.security.RequirePIN && !isLocalhost {
+		peer.awaitingPIN = true
+		err := hub.SetPendingAuth(&PendingAuth{
+			ConnectionID: peerID,
+			DeviceID:     deviceID,
+			DeviceName:   r.URL.Query().Get("device_name"),
+			Peer:         peer,
+		})
+		if err != nil {
+			logger.Warn("Client rejected, cannot start PIN authorization",
+				zap.String("remote", remoteAddr),
+				zap.Error(err))
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error()),
+				time.Now().Add(time.Second))
+			conn.Close()
+			hub.releasePeerID(peerID)
+			hub.metrics.Reject(RejectPIN)
+			return
+		}
 	}
 
+	if hasPreamble {
+		preamble.From = peerID
+		peer.preamble = preamble
+	}
+
+	sess := newConnSession(peer, conn, connID, logger)
+	peer.session = sess
 	hub.register <- peer
+	slotHandedOff = true
 
 	// Start read/write pumps
-	go peer.writePump()
-	go peer.readPump()
+	go peer.writePump(sess)
+	go peer.readPump(sess)
 }
 
 func extractToken(r *http.Request) string {
@@ -788,104 +2064,218 @@ func min(a, b int) int {
 	return b
 }
 
-func (p *Peer) readPump() {
+// requestUnregister asks the hub to end the peer's session s. Both pumps
+// call it when their side of the connection fails so that the peer stops
+// receiving routed messages as soon as either direction breaks; only the
+// first call per session has any effect. Closing the connection afterwards
+// unblocks the other pump.
+func (p *Peer) requestUnregister(s *connSession) {
+	s.unregisterOnce.Do(func() {
+		select {
+		case p.Hub.unregister <- s:
+		case <-p.Hub.done:
+		}
+	})
+}
+
+func (p *Peer) readPump(s *connSession) {
+	conn := s.conn
+	tooLarge := false
 	defer func() {
-		p.Hub.unregister <- p
-		p.Conn.Close()
+		p.requestUnregister(s)
+		p.Hub.conns.release()
+		if !tooLarge {
+			conn.Close()
+		}
+		// Otherwise writePump closes the connection once the error is out
 	}()
 
-	p.Conn.SetReadLimit(64 * 1024) // 64KB max message size
-	p.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	p.Conn.SetPongHandler(func(string) error {
-		p.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetReadDeadline(time.Now().Add(p.pongTimeout))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(p.pongTimeout))
 		p.mu.Lock()
 		p.LastPing = time.Now()
+		sent := p.pingSentAt
+		p.pingSentAt = time.Time{}
 		p.mu.Unlock()
+		if !sent.IsZero() {
+			p.Hub.quality.rtt.observe(msSince(sent, time.Now()))
+		}
 		return nil
 	})
 
 	for {
-		_, data, err := p.Conn.ReadMessage()
+		data, err := p.readMessage(conn)
+		if err == errMessageTooLarge {
+			s.logger.Warn("Message exceeds size limit",
+				zap.String("peer", p.ID),
+				zap.Int("max", p.maxMessageSize))
+			p.setCloseReason(CloseMessageTooLarge, "message too large")
+			p.Hub.sendLimitError(p, CodeTooLarge, "Message too large", p.maxMessageSize)
+			p.Hub.dropSession(s)
+			tooLarge = true
+			break
+		}
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				p.Logger.Error("WebSocket read error", zap.Error(err))
+				s.logger.Error("WebSocket read error", zap.Error(err))
 			}
 			break
 		}
+		p.bytesReceived.Add(uint64(len(data)))
 
 		var msg Message
 		if err := json.Unmarshal(data, &msg); err != nil {
-			p.Logger.Error("Failed to parse message", zap.Error(err))
+			s.logger.Error("Failed to parse message", zap.Error(err))
 			continue
 		}
 
+		if err := msg.validate(p.Hub.security.FieldLimits); err != nil {
+			s.logger.Warn("Rejected invalid message",
+				zap.String("peer", p.ID),
+				zap.String("type", string(msg.Type)),
+				zap.Error(err))
+			code := CodeInvalidMessage
+			if errors.Is(err, errRoomRequired) {
+				code = CodeRoomRequired
+			}
+			p.Hub.sendError(p, code, err.Error())
+			continue
+		}
+		if msg.normalizeSignal() {
+			s.logger.Debug("Flat fields and payload disagree, using flat fields",
+				zap.String("peer", p.ID),
+				zap.String("type", string(msg.Type)))
+		}
+
 		// Handle ping/pong
 		if msg.Type == MsgTypePing {
 			p.Hub.sendToPeer(p, &Message{Type: MsgTypePong})
 			continue
 		}
 
+		s.logger.Debug("Message received",
+			zap.String("peer", p.ID),
+			zap.String("type", string(msg.Type)))
 		msg.From = p.ID
+		p.logMessage(s.logger, &msg)
 		p.Hub.broadcast <- &msg
 	}
 }
 
-func (p *Peer) writePump() {
-	ticker := time.NewTicker(30 * time.Second)
+func (p *Peer) writePump(s *connSession) {
+	conn := s.conn
+	ticker := time.NewTicker(p.pingInterval)
 	defer func() {
 		ticker.Stop()
-		p.Conn.Close()
+		conn.Close()
+		close(s.writerDone)
 	}()
 
+	// write sends one message; a message that fails to send is kept for
+	// the next session in case the peer resumes
+	write := func(message []byte) bool {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+
+		// Small messages don't shrink enough to be worth the deflate cost.
+		// This is a no-op when compression wasn't negotiated.
+		conn.EnableWriteCompression(len(message) >= p.compressionThreshold)
+
+		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			s.logger.Error("WebSocket write error", zap.Error(err))
+			p.mu.Lock()
+			p.unsent = message
+			p.mu.Unlock()
+			p.requestUnregister(s)
+			return false
+		}
+		p.bytesSent.Add(uint64(len(message)))
+		return true
+	}
+
+	p.mu.Lock()
+	unsent := p.unsent
+	p.unsent = nil
+	p.mu.Unlock()
+	if unsent != nil && !write(unsent) {
+		return
+	}
+
 	for {
 		select {
+		case <-s.stop:
+			// Detached for resume - leave the Send buffer to the next session
+			return
+
 		case message, ok := <-p.Send:
-			p.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if !ok {
-				p.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				p.mu.Lock()
+				frame := p.closeFrame
+				p.mu.Unlock()
+				conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				conn.WriteMessage(websocket.CloseMessage, frame)
 				return
 			}
-
-			if err := p.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
-				p.Logger.Error("WebSocket write error", zap.Error(err))
+			if !write(message) {
 				return
 			}
 
 		case <-ticker.C:
-			p.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := p.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			p.mu.Lock()
+			p.pingSentAt = time.Now()
+			p.mu.Unlock()
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				s.logger.Debug("WebSocket ping failed", zap.Error(err))
+				p.requestUnregister(s)
 				return
 			}
 		}
 	}
 }
 
+// generatePeerID returns a random 128-bit hex ID. IDs must not be
+// guessable: any peer that knows another's ID can address signaling to it.
+// Uniqueness against connected peers is checked by reservePeerID.
 func generatePeerID() string {
-	n := time.Now().UnixNano()
-	const hex = "0123456789abcdef"
-	if n == 0 {
-		return "0"
-	}
-	b := make([]byte, 0, 16)
-	for n > 0 {
-		b = append(b, hex[n&0xf])
-		n >>= 4
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
 	}
-	// Reverse
-	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
-		b[i], b[j] = b[j], b[i]
-	}
-	return string(b)
+	return hex.EncodeToString(b)
 }
 
 // HostStatus represents information about an active host
 type HostStatus struct {
-	PeerID     string `json:"peer_id"`
-	Name       string `json:"name"`
-	Role       string `json:"role"`
-	Room       string `json:"room,omitempty"`
-	ActiveTime int64  `json:"active_time_seconds"`
-	HasClients bool   `json:"has_clients"`
+	PeerID      string `json:"peer_id"`
+	Name        string `json:"name"`
+	Role        string `json:"role"`
+	Room        string `json:"room,omitempty"`
+	ActiveTime  int64  `json:"active_time_seconds"` // Since the host connected
+	IdleSeconds int64  `json:"idle_seconds"`        // Since the host last answered a ping
+	HasClients  bool   `json:"has_clients"`
+	Clients     int    `json:"clients"`
+	// LANIP is the address the host reported in its registration, so
+	// clients can ping hosts and pick the nearest
+	LANIP string `json:"lan_ip,omitempty"`
+	// Server is the signaling URL of the instance the host is connected to.
+	// Empty for hosts on this instance.
+	Server string `json:"server,omitempty"`
+}
+
+// RemoteHostsProvider supplies hosts that are connected to other
+// signaling instances, e.g. through federation
+type RemoteHostsProvider interface {
+	RemoteHosts() []HostStatus
+}
+
+// SetRemoteHosts attaches a provider whose hosts are merged into the
+// HostsHandler response. Routing is unaffected; remote hosts are listed
+// for discovery only.
+func (h *Hub) SetRemoteHosts(provider RemoteHostsProvider) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remoteHosts = provider
 }
 
 // GetActiveHosts returns a list of currently active hosts
@@ -898,24 +2288,31 @@ func (h *Hub) GetActiveHosts() []HostStatus {
 
 	for _, peer := range h.peers {
 		if peer.Role == RoleHost {
-			hasClients := false
+			clients := 0
 
-			// Check if host has any clients
+			// Count the host's clients
 			if peer.Room != "" {
 				if room, ok := h.rooms[peer.Room]; ok {
 					room.mu.RLock()
-					hasClients = len(room.Clients) > 0
+					clients = len(room.Clients)
 					room.mu.RUnlock()
 				}
 			}
 
+			peer.mu.Lock()
+			lastPing := peer.LastPing
+			peer.mu.Unlock()
+
 			hosts = append(hosts, HostStatus{
-				PeerID:     peer.ID,
-				Name:       peer.Name,
-				Role:       string(peer.Role),
-				Room:       peer.Room,
-				ActiveTime: int64(now.Sub(peer.LastPing).Seconds()),
-				HasClients: hasClients,
+				PeerID:      peer.ID,
+				Name:        peer.Name,
+				Role:        string(peer.Role),
+				Room:        peer.Room,
+				ActiveTime:  int64(now.Sub(peer.connectedAt).Seconds()),
+				IdleSeconds: int64(now.Sub(lastPing).Seconds()),
+				HasClients:  clients > 0,
+				Clients:     clients,
+				LANIP:       peer.lanIP,
 			})
 		}
 	}
@@ -923,20 +2320,25 @@ func (h *Hub) GetActiveHosts() []HostStatus {
 	return hosts
 }
 
-// HostsHandler handles HTTP requests for active hosts list
+// HostsHandler handles HTTP requests for active hosts list. CORS is left
+// to the server's origin policy like every other endpoint.
+// ?sort=active_time|clients|name orders the list and ?has_clients=
+// filters it; see queryHosts.
 func (h *Hub) HostsHandler(w http.ResponseWriter, r *http.Request) {
-	// Allow CORS for mobile apps
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	w.Header().Set("Content-Type", "application/json")
+	hosts := h.GetActiveHosts()
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	h.mu.RLock()
+	provider := h.remoteHosts
+	h.mu.RUnlock()
+	if provider != nil {
+		hosts = append(hosts, provider.RemoteHosts()...)
+	}
+	hosts, err := queryHosts(hosts, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	hosts := h.GetActiveHosts()
+	w.Header().Set("Content-Type", "application/json")
 
 	response := struct {
 		Hosts     []HostStatus `json:"hosts"`
@@ -0,0 +1,73 @@
+package signaling
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestHostClientChurn connects and drops hosts and clients of the same
+// rooms concurrently while they signal each other. Run with -race; it
+// checks the hub survives and ends up with no peers.
+func TestHostClientChurn(t *testing.T) {
+	cfg := DefaultHubConfig()
+	cfg.ResumeGrace = 0
+	sec := DefaultSecurityConfig()
+	// Every connection comes from loopback, with a fresh token or device
+	sec.MaxConnAttemptsPerIP = 0
+	sec.TokenChurnThreshold = 0
+	s := newTestServer(t, sec, cfg)
+
+	const workers, rounds = 8, 10
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				room := fmt.Sprintf("r%d", (w+i)%3)
+				host, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("%s/?is_host=true&token=churn-%d-%d", s.url, w, i), nil)
+				if err != nil {
+					t.Errorf("host dial: %v", err)
+					return
+				}
+				client, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("%s/?device_id=churn-%d-%d", s.url, w, i), nil)
+				if err != nil {
+					host.Close()
+					t.Errorf("client dial: %v", err)
+					return
+				}
+				host.WriteJSON(Message{Type: MsgTypeRegister, Role: RoleHost})
+				host.WriteJSON(Message{Type: MsgTypeJoin, Room: room, Role: RoleHost})
+				client.WriteJSON(Message{Type: MsgTypeRegister, Role: RoleClient})
+				client.WriteJSON(Message{Type: MsgTypeJoin, Room: room})
+				client.WriteJSON(Message{Type: MsgTypeOffer, SDP: "v=0"})
+				host.WriteJSON(Message{Type: MsgTypeCandidate, Candidate: "candidate:1"})
+				host.WriteJSON(Message{Type: "app:state", Room: room, Sticky: true})
+
+				// Drop host and client in either order while traffic is in flight
+				first, second := host, client
+				if i%2 == 1 {
+					first, second = client, host
+				}
+				first.Close()
+				second.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+				for {
+					if _, _, err := second.ReadMessage(); err != nil {
+						break
+					}
+				}
+				second.Close()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n := s.hub.metrics.ConnectionsAccepted.Load(); n != 2*workers*rounds {
+		t.Fatalf("%d connections accepted, want %d", n, 2*workers*rounds)
+	}
+	waitFor(t, "all peers removed", func() bool { return peerCount(s.hub) == 0 })
+}
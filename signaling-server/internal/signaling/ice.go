@@ -0,0 +1,179 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// ICE candidate types as reported by WebRTC stacks
+const (
+	CandidateHost  = "host"
+	CandidateSrflx = "srflx"
+	CandidatePrflx = "prflx"
+	CandidateRelay = "relay"
+)
+
+// ConnectedReport is the payload of a MsgTypeConnected message, sent by a
+// peer once its WebRTC connection is established
+type ConnectedReport struct {
+	LocalType  string `json:"local_type"`
+	RemoteType string `json:"remote_type"`
+	Protocol   string `json:"protocol,omitempty"`
+	RTTMillis  int    `json:"rtt_ms,omitempty"`
+
+	// RelayServer is the TURN URL (or host:port) relaying the session, if
+	// relayed
+	RelayServer string `json:"relay_server,omitempty"`
+}
+
+func (r ConnectedReport) relayed() bool {
+	return r.LocalType == CandidateRelay || r.RemoteType == CandidateRelay
+}
+
+// ICEStats aggregates selected candidate pairs across sessions
+type ICEStats struct {
+	Sessions     int            `json:"sessions"`
+	Direct       int            `json:"direct"`
+	Relayed      int            `json:"relayed"`
+	RelayPercent float64        `json:"relay_percent"`
+	PairTypes    map[string]int `json:"pair_types"` // "local/remote" -> count
+
+	// RelayServers counts relayed sessions per configured TURN URL; unused
+	// servers are listed with 0 and unrecognized reports count as "other"
+	RelayServers map[string]int `json:"relay_servers,omitempty"`
+}
+
+// iceTracker accumulates ConnectedReports
+type iceTracker struct {
+	mu           sync.Mutex
+	sessions     int
+	relayed      int
+	pairTypes    map[string]int
+	relayServers map[string]int
+}
+
+// record counts a report. turn is the configured TURN URL it relayed
+// through, "other", or "" if it didn't report one.
+func (t *iceTracker) record(r ConnectedReport, turn string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.pairTypes == nil {
+		t.pairTypes = make(map[string]int)
+	}
+	t.sessions++
+	if r.relayed() {
+		t.relayed++
+	}
+	t.pairTypes[r.LocalType+"/"+r.RemoteType]++
+	if turn != "" {
+		if t.relayServers == nil {
+			t.relayServers = make(map[string]int)
+		}
+		t.relayServers[turn]++
+	}
+}
+
+// snapshot summarizes the reports, listing every URL in turn
+func (t *iceTracker) snapshot(turn []string) ICEStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := ICEStats{
+		Sessions:  t.sessions,
+		Direct:    t.sessions - t.relayed,
+		Relayed:   t.relayed,
+		PairTypes: make(map[string]int, len(t.pairTypes)),
+	}
+	if t.sessions > 0 {
+		stats.RelayPercent = 100 * float64(t.relayed) / float64(t.sessions)
+	}
+	for k, v := range t.pairTypes {
+		stats.PairTypes[k] = v
+	}
+	if len(turn) > 0 || len(t.relayServers) > 0 {
+		stats.RelayServers = make(map[string]int, len(turn)+1)
+		for _, url := range turn {
+			stats.RelayServers[url] = 0
+		}
+		for k, v := range t.relayServers {
+			stats.RelayServers[k] = v
+		}
+	}
+	return stats
+}
+
+// matchTURNServer maps a reported relay server to the configured TURN URL
+// with the same host and port. Anything else is "other", so clients can't
+// grow the stats map without bound.
+func matchTURNServer(reported string, configured []string) string {
+	want := turnHostPort(reported)
+	for _, url := range configured {
+		if turnHostPort(url) == want {
+			return url
+		}
+	}
+	return "other"
+}
+
+// turnHostPort reduces "turn:host:3478?transport=udp", "turns:host" or
+// "host:3478" to a lowercase host:port, filling in the default port
+func turnHostPort(url string) string {
+	url = strings.ToLower(strings.TrimSpace(url))
+	port := "3478"
+	if strings.HasPrefix(url, "turns:") {
+		port = "5349"
+	}
+	url = strings.TrimPrefix(strings.TrimPrefix(url, "turns:"), "turn:")
+	url = strings.TrimPrefix(url, "//")
+	if i := strings.IndexAny(url, "?/"); i >= 0 {
+		url = url[:i]
+	}
+	if host, p, err := net.SplitHostPort(url); err == nil {
+		return net.JoinHostPort(host, p)
+	}
+	return net.JoinHostPort(strings.Trim(url, "[]"), port)
+}
+
+func validCandidateType(t string) bool {
+	switch t {
+	case CandidateHost, CandidateSrflx, CandidatePrflx, CandidateRelay:
+		return true
+	}
+	return false
+}
+
+// handleConnected records the candidate pair a peer reports as selected
+func (h *Hub) handleConnected(msg *Message) {
+	h.mu.RLock()
+	peer, ok := h.peers[msg.From]
+	h.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	var report ConnectedReport
+	if err := json.Unmarshal(msg.Payload, &report); err != nil ||
+		!validCandidateType(report.LocalType) || !validCandidateType(report.RemoteType) {
+		h.sendError(peer, CodeInvalidMessage, "Invalid connected report")
+		return
+	}
+
+	turn := ""
+	if report.relayed() && report.RelayServer != "" {
+		turn = matchTURNServer(report.RelayServer, h.config.ICE.TURN)
+	}
+	h.ice.record(report, turn)
+	h.logger.Info("WebRTC connected",
+		zap.String("peer", peer.ID),
+		zap.String("role", string(peer.Role)),
+		zap.String("local", report.LocalType),
+		zap.String("remote", report.RemoteType),
+		zap.String("protocol", report.Protocol),
+		zap.Bool("relayed", report.relayed()),
+		zap.String("relay-server", turn))
+}
@@ -0,0 +1,72 @@
+package signaling
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// ICEConfig lists the STUN/TURN servers handed to clients on register.
+// With TURNSecret set, TURN credentials are minted per connection in the
+// TURN REST API format instead of using the static TURNUser/TURNCredential.
+type ICEConfig struct {
+	STUN           []string
+	TURN           []string
+	TURNUser       string
+	TURNCredential string
+	TURNSecret     string        // Shared secret with the TURN server
+	TURNTTL        time.Duration // Lifetime of minted TURN credentials
+
+	// Relay lists the URLs of the built-in TURN relay, which always mints
+	// credentials with RelaySecret independently of the TURN settings
+	Relay       []string
+	RelaySecret string
+}
+
+// ICEServer mirrors an RTCIceServer entry
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// registeredPayload is the Payload of MsgTypeRegistered
+type registeredPayload struct {
+	ICEServers []ICEServer `json:"iceServers,omitempty"`
+}
+
+// iceServers returns the ICE servers for peerID, or nil if none are configured
+func (c ICEConfig) iceServers(peerID string) []ICEServer {
+	var servers []ICEServer
+	if len(c.STUN) > 0 {
+		servers = append(servers, ICEServer{URLs: c.STUN})
+	}
+	if len(c.TURN) > 0 {
+		turn := ICEServer{
+			URLs:       c.TURN,
+			Username:   c.TURNUser,
+			Credential: c.TURNCredential,
+		}
+		if c.TURNSecret != "" {
+			turn.Username, turn.Credential = turnRESTCredentials(c.TURNSecret, peerID, c.TURNTTL)
+		}
+		servers = append(servers, turn)
+	}
+	if len(c.Relay) > 0 {
+		relay := ICEServer{URLs: c.Relay}
+		relay.Username, relay.Credential = turnRESTCredentials(c.RelaySecret, peerID, c.TURNTTL)
+		servers = append(servers, relay)
+	}
+	return servers
+}
+
+// turnRESTCredentials mints time-limited TURN credentials:
+// username = "<expiry unix>:<peerID>", credential = base64(hmac-sha1(secret, username))
+func turnRESTCredentials(secret, peerID string, ttl time.Duration) (string, string) {
+	username := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10) + ":" + peerID
+	m := hmac.New(sha1.New, []byte(secret))
+	m.Write([]byte(username))
+	return username, base64.StdEncoding.EncodeToString(m.Sum(nil))
+}
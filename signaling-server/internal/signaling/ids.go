@@ -0,0 +1,57 @@
+package signaling
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"sync"
+)
+
+// IDGenerator creates the peer and connection IDs the hub assigns. The
+// default is crypto-random; tests install a deterministic one with
+// SetIDGenerator so they can assert on IDs. Room IDs are chosen by the
+// host, so the hub never generates them.
+type IDGenerator interface {
+	PeerID() string
+	ConnID() string
+}
+
+type randomIDs struct{}
+
+func (randomIDs) PeerID() string { return generatePeerID() }
+func (randomIDs) ConnID() string { return newConnID() }
+
+// seededIDs produces the same IDs, in the same format as randomIDs, for
+// the same seed
+type seededIDs struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewSeededIDs returns an IDGenerator that yields a fixed sequence for
+// seed. Its IDs are predictable, so it must never be used in production:
+// any peer that knows another's ID can address signaling to it.
+func NewSeededIDs(seed int64) IDGenerator {
+	return &seededIDs{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (g *seededIDs) hex(n int) string {
+	b := make([]byte, n)
+	g.mu.Lock()
+	g.rng.Read(b)
+	g.mu.Unlock()
+	return hex.EncodeToString(b)
+}
+
+func (g *seededIDs) PeerID() string { return g.hex(16) }
+func (g *seededIDs) ConnID() string { return g.hex(8) }
+
+// SetIDGenerator replaces the hub's ID generator; nil restores the
+// crypto-random default. Call it before the hub accepts connections.
+func (h *Hub) SetIDGenerator(g IDGenerator) {
+	if g == nil {
+		g = randomIDs{}
+	}
+	h.mu.Lock()
+	h.ids = g
+	h.mu.Unlock()
+}
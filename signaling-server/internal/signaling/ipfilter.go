@@ -0,0 +1,90 @@
+package signaling
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// IPFilter restricts which client addresses may use the server at all,
+// independent of tokens. Deny wins over Allow; an empty Allow admits
+// every address not denied.
+type IPFilter struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+// ParseCIDRs parses CIDR ranges; bare addresses are taken as a single host
+func ParseCIDRs(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", s)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", s, err)
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets, nil
+}
+
+// Active reports whether the filter restricts anything
+func (f *IPFilter) Active() bool {
+	return f != nil && (len(f.Allow) > 0 || len(f.Deny) > 0)
+}
+
+// Allowed reports whether ip may connect. A nil ip, from a non-IP
+// transport such as the Unix socket, is local and always allowed.
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	if !f.Active() || ip == nil {
+		return true
+	}
+	if containsIP(f.Deny, ip) {
+		return false
+	}
+	return len(f.Allow) == 0 || containsIP(f.Allow, ip)
+}
+
+// FilterIPs refuses requests from addresses SecurityConfig.IPFilter
+// doesn't allow with 403 before they reach next
+func (h *Hub) FilterIPs(next http.Handler) http.Handler {
+	f := h.security.IPFilter
+	if !f.Active() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip, _ := h.ClientIP(r); !f.Allowed(ip) {
+			h.logger.Warn("Request from disallowed address",
+				zap.String("remote", r.RemoteAddr),
+				zap.Stringer("client", ip),
+				zap.String("path", r.URL.Path))
+			h.metrics.Reject(RejectIPDenied)
+			httpError(w, CodeIPDenied, "Address not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
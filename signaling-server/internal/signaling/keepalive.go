@@ -0,0 +1,48 @@
+package signaling
+
+import (
+	"fmt"
+	"time"
+)
+
+// Keepalive defaults: a ping every 30s, and a connection that hasn't
+// answered one for 60s is considered dead
+const (
+	DefaultPingInterval = 30 * time.Second
+	DefaultPongTimeout  = 60 * time.Second
+)
+
+// ValidateKeepalive checks a ping interval and pong timeout pair, and the
+// hub's peer timeout unless it is 0. Both timeouts must be longer than the
+// interval, or a healthy peer would time out before it was ever pinged.
+func ValidateKeepalive(pingInterval, pongTimeout, peerTimeout time.Duration) error {
+	if pingInterval <= 0 || pongTimeout <= 0 {
+		return fmt.Errorf("ping interval and pong timeout must be positive")
+	}
+	if pongTimeout <= pingInterval {
+		return fmt.Errorf("pong timeout %v must be longer than ping interval %v", pongTimeout, pingInterval)
+	}
+	if peerTimeout < 0 {
+		return fmt.Errorf("peer timeout must not be negative")
+	}
+	if peerTimeout > 0 && peerTimeout <= pingInterval {
+		return fmt.Errorf("peer timeout %v must be longer than ping interval %v", peerTimeout, pingInterval)
+	}
+	return nil
+}
+
+// keepaliveOrDefault fills in the defaults for a zero ping interval or pong
+// timeout. A timeout left at its default is stretched to twice the interval
+// if the default would be too short for it.
+func keepaliveOrDefault(pingInterval, pongTimeout time.Duration) (time.Duration, time.Duration) {
+	if pingInterval <= 0 {
+		pingInterval = DefaultPingInterval
+	}
+	if pongTimeout <= 0 {
+		pongTimeout = DefaultPongTimeout
+		if pongTimeout <= pingInterval {
+			pongTimeout = 2 * pingInterval
+		}
+	}
+	return pingInterval, pongTimeout
+}
@@ -0,0 +1,104 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateKeepalive(t *testing.T) {
+	s := time.Second
+	tests := []struct {
+		ping, pong, peer time.Duration
+		ok               bool
+	}{
+		{30 * s, 60 * s, 90 * s, true},
+		{30 * s, 60 * s, 0, true},
+		{30 * s, 30 * s, 0, false},
+		{0, 60 * s, 0, false},
+		{30 * s, 60 * s, 30 * s, false},
+		{30 * s, 60 * s, -s, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateKeepalive(tt.ping, tt.pong, tt.peer); (err == nil) != tt.ok {
+			t.Errorf("ValidateKeepalive(%v, %v, %v) = %v, want ok %v", tt.ping, tt.pong, tt.peer, err, tt.ok)
+		}
+	}
+}
+
+func TestKeepaliveOrDefault(t *testing.T) {
+	s := time.Second
+	tests := []struct {
+		ping, pong         time.Duration
+		wantPing, wantPong time.Duration
+	}{
+		{0, 0, DefaultPingInterval, DefaultPongTimeout},
+		{10 * s, 0, 10 * s, DefaultPongTimeout},
+		{90 * s, 0, 90 * s, 180 * s},
+		{0, 45 * s, DefaultPingInterval, 45 * s},
+	}
+	for _, tt := range tests {
+		ping, pong := keepaliveOrDefault(tt.ping, tt.pong)
+		if ping != tt.wantPing || pong != tt.wantPong {
+			t.Errorf("keepaliveOrDefault(%v, %v) = %v, %v, want %v, %v",
+				tt.ping, tt.pong, ping, pong, tt.wantPing, tt.wantPong)
+		}
+		if err := ValidateKeepalive(ping, pong, 0); err != nil {
+			t.Errorf("keepaliveOrDefault(%v, %v) gave an invalid pair: %v", tt.ping, tt.pong, err)
+		}
+	}
+}
+
+func TestUnresponsivePeerTimesOut(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	s.ws.PingInterval = 20 * time.Millisecond
+	s.ws.PongTimeout = 60 * time.Millisecond
+
+	// Peers attached to a live connection; a dropped one may be held for
+	// resume
+	peers := func() int {
+		s.hub.mu.RLock()
+		defer s.hub.mu.RUnlock()
+		n := 0
+		for _, p := range s.hub.peers {
+			if p.detachedAt.IsZero() {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The default ping handler answers while the connection is read
+	live, _ := s.client("")
+	go func() {
+		for {
+			if _, _, err := live.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	dead, _ := s.client("")
+	dead.SetPingHandler(func(string) error { return nil })
+	done := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := dead.ReadMessage(); err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("peer that never pongs was not disconnected")
+	}
+	waitFor(t, "dead peer to be dropped", func() bool { return peers() == 1 })
+
+	// Well past several timeouts, the responsive peer is still connected
+	time.Sleep(200 * time.Millisecond)
+	if n := peers(); n != 1 {
+		t.Fatalf("%d peers connected, want the responsive one", n)
+	}
+}
@@ -0,0 +1,147 @@
+package signaling
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// keyDelivery tracks one key bundle sent to one client. The bundle itself
+// is opaque to the server; only its ID and delivery state are kept.
+type keyDelivery struct {
+	BundleID    string
+	DeliveredAt time.Time
+	AckedAt     time.Time
+}
+
+// KeyBundleStats summarizes key bundle delivery across rooms
+type KeyBundleStats struct {
+	Delivered int `json:"delivered"`
+	Acked     int `json:"acked"`
+	Pending   int `json:"pending"`
+}
+
+// handleKeyBundle routes a host's encrypted key bundle to the room's
+// clients. With To set the bundle goes to that client only (e.g. wrapped
+// for its public key); otherwise it goes to every client and is kept so
+// that clients joining later receive it too.
+func (h *Hub) handleKeyBundle(msg *Message) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	peer, ok := h.peers[msg.From]
+	if !ok {
+		return
+	}
+	room, ok := h.rooms[peer.Room]
+	if !ok || h.actingRole(peer) != RoleHost {
+		h.logger.Warn("Key bundle from peer that isn't a room host", zap.String("peer", peer.ID))
+		h.sendError(peer, CodeNotHost, "Only the room host can publish key bundles")
+		return
+	}
+	if msg.BundleID == "" || len(msg.Payload) == 0 {
+		h.sendError(peer, CodeInvalidMessage, "Key bundle requires bundleId and payload")
+		return
+	}
+	if max := h.config.MaxKeyBundleSize; max > 0 && len(msg.Payload) > max {
+		h.sendError(peer, CodeTooLarge, "Key bundle too large")
+		return
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if room.Host == nil || room.Host.ID != peer.ID {
+		h.sendError(peer, CodeNotHost, "Only the room host can publish key bundles")
+		return
+	}
+
+	bundle := &Message{
+		Type:     MsgTypeKeyBundle,
+		Room:     room.ID,
+		From:     peer.ID,
+		BundleID: msg.BundleID,
+		Payload:  msg.Payload,
+	}
+
+	if msg.To != "" {
+		client, ok := room.Clients[msg.To]
+		if !ok {
+			h.sendError(peer, CodeNotInRoom, "Key bundle target is not in the room")
+			return
+		}
+		bundle.To = client.ID
+		h.deliverKeyBundle(room, client, bundle)
+		return
+	}
+
+	room.keyBundle = bundle
+	for _, client := range room.Clients {
+		h.deliverKeyBundle(room, client, bundle)
+	}
+}
+
+// deliverKeyBundle must be called with room.mu held for writing
+func (h *Hub) deliverKeyBundle(room *Room, client *Peer, bundle *Message) {
+	room.keyDeliveries[client.ID] = &keyDelivery{
+		BundleID:    bundle.BundleID,
+		DeliveredAt: time.Now(),
+	}
+	h.sendToPeer(client, bundle)
+}
+
+// handleKeyBundleAck records a client's receipt of a key bundle and
+// relays the acknowledgement to the host
+func (h *Hub) handleKeyBundleAck(msg *Message) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	peer, ok := h.peers[msg.From]
+	if !ok {
+		return
+	}
+	room, ok := h.rooms[peer.Room]
+	if !ok {
+		return
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	delivery, ok := room.keyDeliveries[peer.ID]
+	if !ok || delivery.BundleID != msg.BundleID {
+		h.sendError(peer, CodeUnknownBundle, "Unknown key bundle")
+		return
+	}
+	delivery.AckedAt = time.Now()
+
+	if room.Host != nil {
+		h.sendToPeer(room.Host, &Message{
+			Type:     MsgTypeKeyBundleAck,
+			Room:     room.ID,
+			From:     peer.ID,
+			BundleID: msg.BundleID,
+		})
+	}
+}
+
+// KeyBundleStats returns key bundle delivery counts across all rooms
+func (h *Hub) KeyBundleStats() KeyBundleStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var stats KeyBundleStats
+	for _, room := range h.rooms {
+		room.mu.RLock()
+		for _, d := range room.keyDeliveries {
+			stats.Delivered++
+			if d.AckedAt.IsZero() {
+				stats.Pending++
+			} else {
+				stats.Acked++
+			}
+		}
+		room.mu.RUnlock()
+	}
+	return stats
+}
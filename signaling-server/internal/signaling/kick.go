@@ -0,0 +1,47 @@
+package signaling
+
+import "go.uber.org/zap"
+
+// handleKick lets a room's host eject one of its clients. The client is
+// told why and then removed, which closes its connection once the error
+// has been written.
+func (h *Hub) handleKick(msg *Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	host, ok := h.peers[msg.From]
+	if !ok {
+		return
+	}
+	room, ok := h.rooms[host.Room]
+	if !ok || host.Role != RoleHost {
+		h.logger.Warn("Ignoring kick from non-host", zap.String("from", msg.From))
+		return
+	}
+
+	targetID := msg.To
+	if targetID == "" {
+		targetID = msg.PeerID
+	}
+
+	room.mu.RLock()
+	owner := room.isHost(host.ID)
+	target, member := room.Clients[targetID]
+	room.mu.RUnlock()
+
+	if !owner {
+		h.logger.Warn("Ignoring kick from non-host",
+			zap.String("from", msg.From),
+			zap.String("room", room.ID))
+		return
+	}
+	if !member {
+		h.sendError(host, CodeNotInRoom, "Peer is not a client of this room")
+		return
+	}
+
+	h.logger.Info("Client kicked by host",
+		zap.String("room", room.ID),
+		zap.String("peer", target.ID))
+	h.disconnectWithError(target, CodeKicked, "Removed from the room by the host")
+}
@@ -0,0 +1,43 @@
+package signaling
+
+import "testing"
+
+func TestKick(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	host, _ := s.host("token")
+	host.join("r", RoleHost)
+	client, clientID := s.client("")
+	client.join("r", RoleClient)
+	other, _ := s.client("")
+	other.join("r", RoleClient)
+
+	inRoom := func(id string) bool {
+		s.hub.mu.RLock()
+		defer s.hub.mu.RUnlock()
+		room := s.hub.rooms["r"]
+		room.mu.RLock()
+		defer room.mu.RUnlock()
+		_, ok := room.Clients[id]
+		return ok
+	}
+
+	// A client can't kick another
+	other.send(Message{Type: MsgTypeKick, To: clientID})
+	other.send(Message{Type: MsgTypePing})
+	other.expect(MsgTypePong)
+	if !inRoom(clientID) {
+		t.Fatal("client kicked by a non-host")
+	}
+
+	host.send(Message{Type: MsgTypeKick, To: clientID})
+	if code := client.expectError(); code != CodeKicked {
+		t.Fatalf("kicked client got error %s, want %s", code, CodeKicked)
+	}
+	client.expectClose()
+	waitFor(t, "kicked client removal", func() bool { return !inRoom(clientID) })
+
+	host.send(Message{Type: MsgTypeKick, To: "nobody"})
+	if code := host.expectError(); code != CodeNotInRoom {
+		t.Fatalf("kicking a non-member: error %s, want %s", code, CodeNotInRoom)
+	}
+}
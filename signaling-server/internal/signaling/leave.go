@@ -0,0 +1,82 @@
+package signaling
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// handleLeave takes a peer out of its room at its own request. The
+// connection stays open so the peer can join another room. A host leaving
+// gives up its host rights until it joins as a host again.
+func (h *Hub) handleLeave(msg *Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	peer, ok := h.peers[msg.From]
+	if !ok {
+		return
+	}
+	if peer.Room == "" || (msg.Room != "" && msg.Room != peer.Room) {
+		h.sendError(peer, CodeNotInRoom, "Not in this room")
+		return
+	}
+
+	roomID := peer.Room
+	h.leaveRoomLocked(peer)
+	peer.Room = ""
+	peer.Role = RoleClient
+	h.dropCandidateBuffers(peer.ID)
+	h.logger.Info("Peer left room", zap.String("room", roomID), zap.String("peer", peer.ID))
+}
+
+// leaveRoomLocked removes peer from its room and tells the rest of the
+// room. Must be called with h.mu held for writing.
+func (h *Hub) leaveRoomLocked(peer *Peer) {
+	if peer.Room == "" {
+		return
+	}
+	room, ok := h.rooms[peer.Room]
+	if !ok {
+		return
+	}
+
+	room.mu.Lock()
+	hostLeft := false
+	if room.isHost(peer.ID) {
+		wasPrimary := room.Host.ID == peer.ID
+		room.removeHost(peer.ID)
+		hostLeft = room.Host == nil
+		h.emit(EventHostLeft, peer.ID, peer.Room, RoleHost)
+		// Notify clients that host left
+		for _, client := range room.Clients {
+			h.sendToPeer(client, &Message{
+				Type: MsgTypeLeave,
+				From: peer.ID,
+				Room: peer.Room,
+			})
+		}
+		if wasPrimary && !hostLeft {
+			h.announceHostChanged(room)
+		}
+	} else {
+		delete(room.Clients, peer.ID)
+		delete(room.keyDeliveries, peer.ID)
+		// Notify hosts that client left
+		for _, host := range room.hosts() {
+			h.sendToPeer(host, &Message{
+				Type: MsgTypeLeave,
+				From: peer.ID,
+				Room: peer.Room,
+			})
+		}
+	}
+	if room.Host == nil && len(room.Clients) == 0 {
+		room.emptySince = time.Now()
+	}
+	room.mu.Unlock()
+
+	if hostLeft {
+		h.handleHostLeft(room)
+	}
+}
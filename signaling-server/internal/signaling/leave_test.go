@@ -0,0 +1,54 @@
+package signaling
+
+import "testing"
+
+func TestLeaveAndSwitchRooms(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	hostA, _ := s.host("token-a")
+	hostA.join("a", RoleHost)
+	hostB, _ := s.host("token-b")
+	hostB.join("b", RoleHost)
+
+	c, id := s.client("")
+	c.join("a", RoleClient)
+	hostA.expect(MsgTypeJoin)
+
+	c.send(Message{Type: MsgTypeLeave, Room: "a"})
+	if left := hostA.expect(MsgTypeLeave); left.From != id {
+		t.Fatalf("host a told %q left, want %q", left.From, id)
+	}
+
+	if info := c.join("b", RoleClient); info.Room != "b" {
+		t.Fatalf("joined %q, want b", info.Room)
+	}
+	if joined := hostB.expect(MsgTypeJoin); joined.From != id {
+		t.Fatalf("host b told %q joined, want %q", joined.From, id)
+	}
+
+	c.send(Message{Type: MsgTypeLeave, Room: "a"})
+	if code := c.expectError(); code != CodeNotInRoom {
+		t.Fatalf("leaving a room not joined: got %s, want %s", code, CodeNotInRoom)
+	}
+}
+
+func TestHostLeaveDropsHostRights(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	host, id := s.host("token")
+	host.join("a", RoleHost)
+	host.send(Message{Type: MsgTypeLeave})
+
+	waitFor(t, "host to leave", func() bool {
+		s.hub.mu.RLock()
+		defer s.hub.mu.RUnlock()
+		peer := s.hub.peers[id]
+		return peer.Room == "" && s.hub.actingRole(peer) == RoleClient
+	})
+	host.send(Message{Type: MsgTypePairingMode})
+	if code := host.expectError(); code != CodeNotHost {
+		t.Fatalf("pairing-mode after leaving: got %s, want %s", code, CodeNotHost)
+	}
+
+	if info := host.join("a", RoleHost); info.Room != "a" {
+		t.Fatalf("rejoined %q, want a", info.Room)
+	}
+}
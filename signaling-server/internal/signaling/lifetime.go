@@ -0,0 +1,84 @@
+package signaling
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// closeExpiredConnections closes connections older than
+// MaxConnectionLifetime so a leaked token can't keep an established
+// session alive indefinitely. Clients get a "try again later" close frame
+// and reconnect, going through authentication again.
+func (h *Hub) closeExpiredConnections(now time.Time) {
+	if h.security.MaxConnectionLifetime <= 0 {
+		return
+	}
+
+	var expired []*connSession
+	h.mu.RLock()
+	for _, peer := range h.peers {
+		if s := peer.session; s != nil && peer.detachedAt.IsZero() && !s.expiresAt.IsZero() && now.After(s.expiresAt) {
+			expired = append(expired, s)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, s := range expired {
+		h.logger.Info("Closing connection past max lifetime",
+			zap.String("peer", s.peer.ID),
+			zap.Duration("lifetime", h.security.MaxConnectionLifetime))
+		s.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "connection lifetime exceeded, reconnect"),
+			time.Now().Add(time.Second))
+		s.conn.Close()
+	}
+}
+
+// evictDeadPeers removes peers whose last pong is older than PeerTimeout.
+// The read deadline normally catches these, but a half-open TCP connection
+// can linger past it while messages keep being routed to the peer.
+// Detached peers are left to their resume window.
+func (h *Hub) evictDeadPeers(now time.Time) {
+	timeout := h.config.PeerTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	var dead []*Peer
+	h.mu.RLock()
+	for _, peer := range h.peers {
+		if !peer.detachedAt.IsZero() {
+			continue
+		}
+		peer.mu.Lock()
+		stale := now.Sub(peer.LastPing) > timeout
+		peer.mu.Unlock()
+		if stale {
+			dead = append(dead, peer)
+		}
+	}
+	h.mu.RUnlock()
+	if len(dead) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	var conns []*websocket.Conn
+	for _, peer := range dead {
+		if h.peers[peer.ID] != peer || !peer.detachedAt.IsZero() {
+			continue // Removed or detached meanwhile
+		}
+		h.logger.Info("Evicting unresponsive peer",
+			zap.String("peer", peer.ID),
+			zap.Duration("timeout", timeout))
+		conns = append(conns, peer.Conn)
+		h.removePeerLocked(peer)
+	}
+	h.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
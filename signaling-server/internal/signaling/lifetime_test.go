@@ -0,0 +1,56 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMaxConnectionLifetime(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	sec.MaxConnectionLifetime = time.Hour
+	s := newTestServer(t, sec, DefaultHubConfig())
+	c, _ := s.client("")
+
+	s.hub.closeExpiredConnections(time.Now().Add(sec.MaxConnectionLifetime - time.Minute))
+	c.send(Message{Type: MsgTypePing})
+	c.expect(MsgTypePong)
+
+	s.hub.closeExpiredConnections(time.Now().Add(sec.MaxConnectionLifetime + time.Second))
+	if code := c.expectClose(); code != websocket.CloseTryAgainLater {
+		t.Fatalf("close code %d, want %d", code, websocket.CloseTryAgainLater)
+	}
+}
+
+func TestEvictDeadPeers(t *testing.T) {
+	cfg := DefaultHubConfig()
+	cfg.ResumeGrace = 0
+	s := newTestServer(t, DefaultSecurityConfig(), cfg)
+	host, hostID := s.host("token")
+	host.join("r", RoleHost)
+	_, clientID := s.client("")
+
+	// Nobody has missed a pong yet
+	s.hub.evictDeadPeers(time.Now().Add(cfg.PeerTimeout - time.Second))
+	if n := peerCount(s.hub); n != 2 {
+		t.Fatalf("%d peers after an early check, want 2", n)
+	}
+
+	// The host answers pings; the client went quiet an hour ago
+	s.hub.mu.RLock()
+	client := s.hub.peers[clientID]
+	s.hub.mu.RUnlock()
+	client.mu.Lock()
+	client.LastPing = time.Now().Add(-time.Hour)
+	client.mu.Unlock()
+
+	s.hub.evictDeadPeers(time.Now())
+	s.hub.mu.RLock()
+	_, hostLeft := s.hub.peers[hostID]
+	_, clientLeft := s.hub.peers[clientID]
+	s.hub.mu.RUnlock()
+	if !hostLeft || clientLeft {
+		t.Fatalf("after eviction: host present %v, client present %v", hostLeft, clientLeft)
+	}
+}
@@ -0,0 +1,71 @@
+package signaling
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// loadSampleInterval bounds how often runtime memory stats are read;
+// ReadMemStats briefly stops the world so it must not run per request
+const loadSampleInterval = time.Second
+
+// overloadRetryAfter is the Retry-After hint, in seconds, sent with 503s
+const overloadRetryAfter = "5"
+
+// LoadStatus is a point-in-time assessment of server load
+type LoadStatus struct {
+	Goroutines int    `json:"goroutines"`
+	HeapBytes  uint64 `json:"heap_bytes"`
+	Peers      int    `json:"peers"`
+	Overloaded bool   `json:"overloaded"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// loadMonitor caches runtime samples used for load shedding
+type loadMonitor struct {
+	mu         sync.Mutex
+	sampledAt  time.Time
+	goroutines int
+	heapBytes  uint64
+}
+
+func (m *loadMonitor) sample() (int, uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if time.Since(m.sampledAt) >= loadSampleInterval {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		m.goroutines = runtime.NumGoroutine()
+		m.heapBytes = ms.HeapAlloc
+		m.sampledAt = time.Now()
+	}
+	return m.goroutines, m.heapBytes
+}
+
+// Load returns the current load assessment against the configured
+// thresholds. A zero threshold disables that check.
+func (h *Hub) Load() LoadStatus {
+	goroutines, heap := h.load.sample()
+
+	h.mu.RLock()
+	peers := len(h.peers)
+	h.mu.RUnlock()
+
+	status := LoadStatus{
+		Goroutines: goroutines,
+		HeapBytes:  heap,
+		Peers:      peers,
+	}
+
+	switch {
+	case h.security.MaxPeers > 0 && peers >= h.security.MaxPeers:
+		status.Overloaded, status.Reason = true, "peers"
+	case h.security.MaxGoroutines > 0 && goroutines >= h.security.MaxGoroutines:
+		status.Overloaded, status.Reason = true, "goroutines"
+	case h.security.MaxHeapBytes > 0 && heap >= h.security.MaxHeapBytes:
+		status.Overloaded, status.Reason = true, "memory"
+	}
+	return status
+}
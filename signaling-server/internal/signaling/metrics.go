@@ -0,0 +1,148 @@
+package signaling
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Connection rejection reasons reported in metrics
+const (
+	RejectRateLimit  = "rate-limit"
+	RejectBadToken   = "bad-token"
+	RejectBadOrigin  = "bad-origin"
+	RejectNoTLS      = "no-tls"
+	RejectOverloaded = "overloaded"
+	RejectPairing    = "pairing"
+	RejectChurn      = "token-churn"
+	RejectPeerID     = "peer-id"
+	RejectPIN        = "pin"
+	RejectReconnect  = "reconnect-loop"
+	RejectProtocol   = "protocol-version"
+	RejectDraining   = "draining"
+	RejectIPDenied   = "ip-denied"
+	RejectConnLimit  = "connection-limit"
+)
+
+var rejectReasons = []string{
+	RejectRateLimit, RejectBadToken, RejectBadOrigin, RejectNoTLS,
+	RejectOverloaded, RejectPairing, RejectChurn, RejectPeerID, RejectPIN, RejectReconnect, RejectProtocol,
+	RejectDraining, RejectIPDenied, RejectConnLimit,
+}
+
+// Metrics holds monotonically increasing hub counters. Gauges such as
+// active peers and rooms are read from the hub when rendering.
+type Metrics struct {
+	ConnectionsAccepted atomic.Uint64
+	SendBufferDrops     atomic.Uint64
+	HookDrops           atomic.Uint64
+
+	rejected sync.Map // reason -> *atomic.Uint64
+
+	routedMu sync.Mutex
+	routed   map[MessageType]uint64
+}
+
+func newMetrics() *Metrics {
+	m := &Metrics{routed: make(map[MessageType]uint64)}
+	for _, reason := range rejectReasons {
+		m.rejected.Store(reason, new(atomic.Uint64))
+	}
+	return m
+}
+
+// Reject counts a refused connection
+func (m *Metrics) Reject(reason string) {
+	if c, ok := m.rejected.Load(reason); ok {
+		c.(*atomic.Uint64).Add(1)
+	}
+}
+
+// Routed counts a routed message. Unknown types share one "other" series
+// so clients can't grow the label set without bound.
+func (m *Metrics) Routed(t MessageType) {
+	if !knownMessageType(t) {
+		t = "other"
+	}
+	m.routedMu.Lock()
+	m.routed[t]++
+	m.routedMu.Unlock()
+}
+
+// Metrics returns the hub's counters
+func (h *Hub) Metrics() *Metrics {
+	return h.metrics
+}
+
+// MetricsHandler renders hub metrics in the Prometheus text format
+func (h *Hub) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	roles := map[PeerRole]int{RoleHost: 0, RoleClient: 0}
+	for _, peer := range h.peers {
+		roles[peer.Role]++
+	}
+	rooms := len(h.rooms)
+	h.mu.RUnlock()
+
+	var b strings.Builder
+
+	b.WriteString("# HELP signaling_peers Connected peers by role.\n# TYPE signaling_peers gauge\n")
+	for _, role := range sortedKeys(roles) {
+		name := string(role)
+		if name == "" {
+			name = "unregistered"
+		}
+		fmt.Fprintf(&b, "signaling_peers{role=%q} %d\n", name, roles[role])
+	}
+
+	b.WriteString("# HELP signaling_rooms Active rooms.\n# TYPE signaling_rooms gauge\n")
+	fmt.Fprintf(&b, "signaling_rooms %d\n", rooms)
+
+	b.WriteString("# HELP signaling_connections_open Open WebSocket connections, registered or not.\n# TYPE signaling_connections_open gauge\n")
+	fmt.Fprintf(&b, "signaling_connections_open %d\n", h.OpenConnections())
+
+	b.WriteString("# HELP signaling_connections_accepted_total WebSocket connections accepted.\n# TYPE signaling_connections_accepted_total counter\n")
+	fmt.Fprintf(&b, "signaling_connections_accepted_total %d\n", h.metrics.ConnectionsAccepted.Load())
+
+	b.WriteString("# HELP signaling_connections_rejected_total WebSocket connections rejected by reason.\n# TYPE signaling_connections_rejected_total counter\n")
+	for _, reason := range rejectReasons {
+		c, _ := h.metrics.rejected.Load(reason)
+		fmt.Fprintf(&b, "signaling_connections_rejected_total{reason=%q} %d\n", reason, c.(*atomic.Uint64).Load())
+	}
+
+	b.WriteString("# HELP signaling_messages_routed_total Messages routed by type.\n# TYPE signaling_messages_routed_total counter\n")
+	h.metrics.routedMu.Lock()
+	routed := make(map[MessageType]uint64, len(h.metrics.routed))
+	for t, n := range h.metrics.routed {
+		routed[t] = n
+	}
+	h.metrics.routedMu.Unlock()
+	for _, t := range sortedKeys(routed) {
+		fmt.Fprintf(&b, "signaling_messages_routed_total{type=%q} %d\n", string(t), routed[t])
+	}
+
+	b.WriteString("# HELP signaling_send_buffer_drops_total Messages dropped because a peer's send buffer was full.\n# TYPE signaling_send_buffer_drops_total counter\n")
+	fmt.Fprintf(&b, "signaling_send_buffer_drops_total %d\n", h.metrics.SendBufferDrops.Load())
+
+	b.WriteString("# HELP signaling_hook_drops_total OnRegister/OnUnregister calls dropped because the hook queue was full.\n# TYPE signaling_hook_drops_total counter\n")
+	fmt.Fprintf(&b, "signaling_hook_drops_total %d\n", h.metrics.HookDrops.Load())
+
+	h.quality.rtt.writePrometheus(&b, "signaling_rtt_ms", "WebSocket ping round trip in milliseconds.")
+	h.quality.firstOffer.writePrometheus(&b, "signaling_time_to_first_offer_ms", "Time from both peers connected to their first offer in milliseconds.")
+	h.quality.negotiation.writePrometheus(&b, "signaling_negotiation_ms", "Time from first offer to first answer per pairing in milliseconds.")
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,166 @@
+package signaling
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultMintedTokenTTL = 5 * time.Minute
+
+var (
+	errNotHostToken = errors.New("not a host token")
+	errMintRoom     = errors.New("host token is scoped to another room")
+	errRoomRequired = errors.New("room required")
+)
+
+// MintedToken is a client token issued by POST /api/tokens
+type MintedToken struct {
+	Token     string    `json:"token"`
+	Room      string    `json:"room"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type mintTokenRequest struct {
+	Room       string `json:"room"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// MintClientToken issues a client token for room on behalf of the holder
+// of hostToken, so a join link doesn't carry the host's own credential.
+// An empty room means the room hostToken is scoped to. The TTL is capped
+// at TokenExpiry.
+func (h *Hub) MintClientToken(hostToken, room string, ttl time.Duration) (MintedToken, error) {
+	h.tokenMu.RLock()
+	entry, ok := h.validTokens[hostToken]
+	var scope string
+	if ok {
+		ok = !entry.Minted && time.Now().Before(entry.ExpiresAt)
+		scope = entry.Room
+	}
+	h.tokenMu.RUnlock()
+
+	if !ok {
+		return MintedToken{}, errNotHostToken
+	}
+	if room == "" {
+		room = scope
+	}
+	if room == "" {
+		return MintedToken{}, errRoomRequired
+	}
+	if scope != "" && scope != room {
+		return MintedToken{}, errMintRoom
+	}
+	if ttl <= 0 {
+		ttl = defaultMintedTokenTTL
+	}
+	if max := h.security.TokenExpiry; max > 0 && ttl > max {
+		ttl = max
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return MintedToken{}, err
+	}
+	minted := MintedToken{
+		Token:     hex.EncodeToString(b),
+		Room:      room,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	h.tokenMu.Lock()
+	h.validTokens[minted.Token] = &tokenEntry{
+		ExpiresAt: minted.ExpiresAt,
+		Room:      room,
+		Minted:    true,
+	}
+	h.tokensChanged()
+	h.tokenMu.Unlock()
+
+	h.logger.Info("Client token minted",
+		zap.String("by", tokenPrefix(hostToken)),
+		zap.String("token", tokenPrefix(minted.Token)),
+		zap.String("room", room),
+		zap.Duration("ttl", ttl))
+	return minted, nil
+}
+
+// tokenMinted reports whether token was issued by MintClientToken, so it
+// can't be presented on a host connection
+func (h *Hub) tokenMinted(token string) bool {
+	h.tokenMu.RLock()
+	defer h.tokenMu.RUnlock()
+	entry, ok := h.validTokens[token]
+	return ok && entry.Minted
+}
+
+// TokensHandler handles POST {"room":"...","ttl_seconds":300}, minting a
+// client token with the bearer's host token
+func (h *Hub) TokensHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req mintTokenRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httpError(w, CodeInvalidMessage, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	minted, err := h.MintClientToken(extractToken(r), req.Room, time.Duration(req.TTLSeconds)*time.Second)
+	switch {
+	case errors.Is(err, errNotHostToken):
+		httpError(w, CodeNotHost, "A host token is required", http.StatusForbidden)
+		return
+	case errors.Is(err, errRoomRequired):
+		httpError(w, CodeRoomRequired, "Room required", http.StatusBadRequest)
+		return
+	case errors.Is(err, errMintRoom):
+		httpError(w, CodeTokenRoom, "Token not valid for this room", http.StatusForbidden)
+		return
+	case err != nil:
+		h.logger.Error("Failed to mint token", zap.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(minted)
+}
+
+// IssueClientToken issues a client token for room without a host token,
+// for trusted callers such as the QR handler serving the host's own
+// machine. An empty room admits the client to any room.
+func (h *Hub) IssueClientToken(room string, ttl time.Duration) (string, time.Time, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(b)
+	expiresAt := time.Now().Add(ttl)
+
+	h.tokenMu.Lock()
+	h.validTokens[token] = &tokenEntry{
+		ExpiresAt: expiresAt,
+		Room:      room,
+		Minted:    true,
+	}
+	h.tokensChanged()
+	h.tokenMu.Unlock()
+
+	h.logger.Info("Client token issued",
+		zap.String("token", tokenPrefix(token)),
+		zap.String("room", room),
+		zap.Duration("ttl", ttl))
+	return token, expiresAt, nil
+}
@@ -0,0 +1,55 @@
+package signaling
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"go.uber.org/zap"
+)
+
+// maxLoggedMessage is how much of a message LogMessages writes, in bytes
+const maxLoggedMessage = 512
+
+// secretPatterns match values in a marshaled message that may grant
+// access: token, password, PIN and secret fields anywhere in it, whether
+// their value is a string or a number or other scalar, and the ICE
+// passwords inside SDP
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)("[a-z_]*(?:token|password|secret|pin|credential)[a-z_]*"\s*:\s*)(?:"(?:[^"\\]|\\.)*"|-?[0-9][0-9.eE+-]*|true|false)`), `$1"[redacted]"`},
+	{regexp.MustCompile(`(a=ice-pwd:)[^\\\s"]+`), `$1[redacted]`},
+}
+
+// redactMessage returns data with anything that looks like a credential
+// replaced
+func redactMessage(data []byte) []byte {
+	for _, p := range secretPatterns {
+		data = p.re.ReplaceAll(data, []byte(p.repl))
+	}
+	return data
+}
+
+// logMessage writes a routed message to the connection's debug log when
+// LogMessages is set. The body is redacted and then truncated.
+func (p *Peer) logMessage(logger *zap.Logger, msg *Message) {
+	if !p.Hub.config.LogMessages || !logger.Core().Enabled(zap.DebugLevel) {
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	data = redactMessage(data)
+	truncated := len(data) > maxLoggedMessage
+	if truncated {
+		data = data[:maxLoggedMessage]
+	}
+	logger.Debug("Message body",
+		zap.String("type", string(msg.Type)),
+		zap.String("from", msg.From),
+		zap.String("to", msg.To),
+		zap.ByteString("body", data),
+		zap.Bool("truncated", truncated))
+}
@@ -0,0 +1,64 @@
+package signaling
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedactMessage(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{`{"token":"abc123"}`, `{"token":"[redacted]"}`},
+		{`{"resumeToken": "r\"x"}`, `{"resumeToken": "[redacted]"}`},
+		{`{"pin":1234}`, `{"pin":"[redacted]"}`},
+		{`{"payload":{"turn_credential":-5.2e3,"ok":true}}`, `{"payload":{"turn_credential":"[redacted]","ok":true}}`},
+		{`{"password":false}`, `{"password":"[redacted]"}`},
+		{`{"sdp":"a=ice-ufrag:F7gI\r\na=ice-pwd:x9cml/YzD7VC\r\n"}`, `{"sdp":"a=ice-ufrag:F7gI\r\na=ice-pwd:[redacted]\r\n"}`},
+		{`{"type":"offer","name":"TV"}`, `{"type":"offer","name":"TV"}`},
+	}
+	for _, tt := range tests {
+		if got := string(redactMessage([]byte(tt.in))); got != tt.want {
+			t.Errorf("redactMessage(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func observedPeer(logMessages bool) (*Peer, *zap.Logger, *observer.ObservedLogs) {
+	cfg := DefaultHubConfig()
+	cfg.LogMessages = logMessages
+	h := NewHubWithConfig(zap.NewNop(), time.Minute, DefaultSecurityConfig(), cfg)
+	core, logs := observer.New(zapcore.DebugLevel)
+	return &Peer{ID: "p", Hub: h}, zap.New(core), logs
+}
+
+func TestLogMessagesSilentWithoutFlag(t *testing.T) {
+	p, logger, logs := observedPeer(false)
+	p.logMessage(logger, &Message{Type: MsgTypeOffer, From: "p", SDP: "v=0"})
+	if n := logs.Len(); n != 0 {
+		t.Fatalf("%d entries logged without LogMessages", n)
+	}
+}
+
+func TestLogMessages(t *testing.T) {
+	p, logger, logs := observedPeer(true)
+	p.logMessage(logger, &Message{Type: MsgTypeOffer, From: "a", To: "b",
+		SDP: "a=ice-pwd:secretpwd " + strings.Repeat("x", 2*maxLoggedMessage), Payload: json.RawMessage(`{"pin":1234}`)})
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("%d entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	body := fields["body"].(string)
+	if fields["type"] != "offer" || fields["from"] != "a" || fields["to"] != "b" || fields["truncated"] != true {
+		t.Fatalf("fields %v", fields)
+	}
+	if len(body) != maxLoggedMessage || strings.Contains(body, "secretpwd") || strings.Contains(body, "1234") {
+		t.Fatalf("body not redacted and truncated: %s", body)
+	}
+}
@@ -0,0 +1,58 @@
+package signaling
+
+import (
+	"errors"
+	"io"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultMaxMessageSize is the message size limit when none is configured
+const DefaultMaxMessageSize = 64 * 1024
+
+var errMessageTooLarge = errors.New("message exceeds size limit")
+
+// readMessage reads the next message, buffering at most maxMessageSize+1
+// bytes. The limit is enforced here rather than with SetReadLimit, which
+// sends its own close frame and so leaves no way to tell the peer why.
+// With permessage-deflate the reader yields decompressed bytes, so the
+// limit also caps what a small compression bomb can inflate to; reading
+// stops as soon as it is exceeded.
+func (p *Peer) readMessage(conn *websocket.Conn) ([]byte, error) {
+	_, r, err := conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, int64(p.maxMessageSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > p.maxMessageSize {
+		return nil, errMessageTooLarge
+	}
+	return data, nil
+}
+
+// dropSession removes the peer bound to s without holding it for resume,
+// so messages already queued, such as an error explaining why, are
+// flushed before writePump closes the connection. If the peer has already
+// moved to another connection or been removed, nothing is left to flush
+// and s.conn is closed here.
+func (h *Hub) dropSession(s *connSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.peer.session != s || h.peers[s.peer.ID] != s.peer {
+		s.conn.Close()
+		return
+	}
+	h.removePeerLocked(s.peer)
+}
+
+// setCompressionLevel applies a configured flate level to a connection.
+// It has no effect when compression wasn't negotiated.
+func setCompressionLevel(conn *websocket.Conn, level int) {
+	if level != 0 {
+		conn.SetCompressionLevel(level)
+	}
+}
@@ -0,0 +1,43 @@
+package signaling
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+func TestDropSupersededSessionClosesConn(t *testing.T) {
+	h := NewHubWithSecurity(zap.NewNop(), time.Minute, DefaultSecurityConfig())
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	defer srv.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// The peer was never added to the hub, as after a resume elsewhere
+	peer := &Peer{ID: "p", Hub: h}
+	h.dropSession(newConnSession(peer, <-conns, "c", zap.NewNop()))
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = client.ReadMessage()
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("connection left open after dropping a superseded session")
+	}
+}
@@ -0,0 +1,79 @@
+package signaling
+
+import "sort"
+
+// A room's first host is Room.Host. With MaxHostsPerRoom above one, more
+// hosts can join, e.g. one per monitor, and are kept in extraHosts.
+// Clients reach a specific host by addressing it with To; untargeted
+// signaling still goes to Room.Host. Room.Host is nil only when the room
+// has no hosts at all.
+
+// isHost reports whether peerID is one of the room's hosts. Must be
+// called with room.mu held.
+func (r *Room) isHost(peerID string) bool {
+	if r.Host != nil && r.Host.ID == peerID {
+		return true
+	}
+	_, ok := r.extraHosts[peerID]
+	return ok
+}
+
+// hosts returns the room's hosts, Room.Host first and the rest in join
+// order. Must be called with room.mu held.
+func (r *Room) hosts() []*Peer {
+	if r.Host == nil {
+		return nil
+	}
+	hosts := make([]*Peer, 0, len(r.extraHosts)+1)
+	hosts = append(hosts, r.Host)
+	extra := make([]*Peer, 0, len(r.extraHosts))
+	for _, host := range r.extraHosts {
+		extra = append(extra, host)
+	}
+	sort.Slice(extra, func(i, j int) bool {
+		return extra[i].connectedAt.Before(extra[j].connectedAt)
+	})
+	return append(hosts, extra...)
+}
+
+// addHost makes peer a host of the room, reporting false if the room
+// already has max hosts. Must be called with room.mu held for writing.
+func (r *Room) addHost(peer *Peer, max int) bool {
+	switch {
+	case r.Host == nil:
+		r.Host = peer
+	case r.isHost(peer.ID):
+	case 1+len(r.extraHosts) >= max:
+		return false
+	default:
+		if r.extraHosts == nil {
+			r.extraHosts = make(map[string]*Peer)
+		}
+		r.extraHosts[peer.ID] = peer
+	}
+	return true
+}
+
+// removeHost drops peerID from the room's hosts. When Room.Host leaves,
+// the longest-connected other host takes its place. Must be called with
+// room.mu held for writing.
+func (r *Room) removeHost(peerID string) {
+	if _, ok := r.extraHosts[peerID]; ok {
+		delete(r.extraHosts, peerID)
+		return
+	}
+	if r.Host == nil || r.Host.ID != peerID {
+		return
+	}
+	r.Host = nil
+	var next *Peer
+	for _, host := range r.extraHosts {
+		if next == nil || host.connectedAt.Before(next.connectedAt) {
+			next = host
+		}
+	}
+	if next != nil {
+		delete(r.extraHosts, next.ID)
+		r.Host = next
+	}
+}
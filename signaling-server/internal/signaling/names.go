@@ -0,0 +1,33 @@
+package signaling
+
+import (
+	"fmt"
+	"strings"
+)
+
+// uniqueRoomName returns name, or name with the lowest " (n)" suffix not
+// already used by another peer in room. Names are compared
+// case-insensitively. Must be called with room.mu held.
+func uniqueRoomName(room *Room, peer *Peer, name string) string {
+	if name == "" {
+		return name
+	}
+
+	taken := make(map[string]bool, len(room.Clients)+1)
+	for _, host := range room.hosts() {
+		if host.ID != peer.ID {
+			taken[strings.ToLower(host.Name)] = true
+		}
+	}
+	for id, client := range room.Clients {
+		if id != peer.ID {
+			taken[strings.ToLower(client.Name)] = true
+		}
+	}
+
+	candidate := name
+	for n := 2; taken[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s (%d)", name, n)
+	}
+	return candidate
+}
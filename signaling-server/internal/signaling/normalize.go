@@ -0,0 +1,71 @@
+package signaling
+
+import "encoding/json"
+
+// Clients put signaling data either in flat fields or in Payload, and a
+// receiver usually only reads the one it was written against. Offers,
+// answers and single candidates are therefore forwarded in a canonical
+// form with both filled in:
+//
+//	{"type":"offer","sdp":"v=0...",
+//	 "payload":{"type":"offer","sdp":"v=0..."}}
+//
+//	{"type":"ice-candidate","candidate":"candidate:1 ...","sdpMid":"0","sdpMLineIndex":1,
+//	 "payload":{"candidate":"candidate:1 ...","sdpMid":"0","sdpMLineIndex":1}}
+//
+// The end-of-candidates marker is {"candidate":""} in the payload. When a
+// sender fills in both forms and they disagree, the flat fields win, as
+// they do for validation and the media policy. Other payload keys, such
+// as usernameFragment, are passed through. Batches in MsgTypeCandidates
+// only have the payload form.
+
+// normalizeSignal rewrites an offer, answer or candidate into the
+// canonical form and reports whether its flat fields and payload
+// disagreed. A payload that isn't a JSON object is left alone.
+func (m *Message) normalizeSignal() (conflict bool) {
+	switch m.Type {
+	case MsgTypeOffer, MsgTypeAnswer, MsgTypeCandidate, MsgTypeIceCandidate:
+	default:
+		return false
+	}
+
+	fields := map[string]json.RawMessage{}
+	if len(m.Payload) > 0 && json.Unmarshal(m.Payload, &fields) != nil {
+		return false
+	}
+
+	if m.Type == MsgTypeOffer || m.Type == MsgTypeAnswer {
+		var sdp string
+		json.Unmarshal(fields["sdp"], &sdp)
+		if m.SDP == "" {
+			m.SDP = sdp
+		} else if sdp != "" && sdp != m.SDP {
+			conflict = true
+		}
+		fields["type"] = marshalField(m.Type)
+		fields["sdp"] = marshalField(m.SDP)
+	} else {
+		var c candidateEntry
+		json.Unmarshal(m.Payload, &c)
+		if m.Candidate == "" {
+			m.Candidate, m.SDPMid, m.SDPMLineIndex = c.Candidate, c.SDPMid, c.SDPMLineIndex
+		} else if c.Candidate != "" && (c.Candidate != m.Candidate || c.SDPMid != m.SDPMid || c.SDPMLineIndex != m.SDPMLineIndex) {
+			conflict = true
+		}
+		fields["candidate"] = marshalField(m.Candidate)
+		fields["sdpMLineIndex"] = marshalField(m.SDPMLineIndex)
+		if m.SDPMid != "" {
+			fields["sdpMid"] = marshalField(m.SDPMid)
+		} else {
+			delete(fields, "sdpMid")
+		}
+	}
+
+	m.Payload, _ = json.Marshal(fields)
+	return conflict
+}
+
+func marshalField(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
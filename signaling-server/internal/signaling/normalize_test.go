@@ -0,0 +1,108 @@
+package signaling
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func payloadFields(t *testing.T, m *Message) map[string]interface{} {
+	t.Helper()
+	var fields map[string]interface{}
+	if err := json.Unmarshal(m.Payload, &fields); err != nil {
+		t.Fatalf("payload %s: %v", m.Payload, err)
+	}
+	return fields
+}
+
+func TestNormalizeFlatToPayload(t *testing.T) {
+	offer := &Message{Type: MsgTypeOffer, SDP: "v=0 offer"}
+	if offer.normalizeSignal() {
+		t.Fatal("conflict reported")
+	}
+	want := map[string]interface{}{"type": "offer", "sdp": "v=0 offer"}
+	if got := payloadFields(t, offer); !reflect.DeepEqual(got, want) {
+		t.Fatalf("offer payload %v, want %v", got, want)
+	}
+
+	cand := &Message{Type: MsgTypeIceCandidate, Candidate: "candidate:1 1 udp", SDPMid: "0", SDPMLineIndex: 1}
+	cand.normalizeSignal()
+	want = map[string]interface{}{"candidate": "candidate:1 1 udp", "sdpMid": "0", "sdpMLineIndex": float64(1)}
+	if got := payloadFields(t, cand); !reflect.DeepEqual(got, want) {
+		t.Fatalf("candidate payload %v, want %v", got, want)
+	}
+}
+
+func TestNormalizePayloadToFlat(t *testing.T) {
+	answer := &Message{Type: MsgTypeAnswer, Payload: json.RawMessage(`{"type":"answer","sdp":"v=0 answer"}`)}
+	answer.normalizeSignal()
+	if answer.SDP != "v=0 answer" {
+		t.Fatalf("flat sdp %q", answer.SDP)
+	}
+
+	cand := &Message{Type: MsgTypeCandidate, Payload: json.RawMessage(
+		`{"candidate":"candidate:2 1 tcp","sdpMid":"audio","sdpMLineIndex":2,"usernameFragment":"abcd"}`)}
+	cand.normalizeSignal()
+	if cand.Candidate != "candidate:2 1 tcp" || cand.SDPMid != "audio" || cand.SDPMLineIndex != 2 {
+		t.Fatalf("flat candidate %q/%q/%d", cand.Candidate, cand.SDPMid, cand.SDPMLineIndex)
+	}
+	if ufrag := payloadFields(t, cand)["usernameFragment"]; ufrag != "abcd" {
+		t.Fatalf("usernameFragment %v not passed through", ufrag)
+	}
+}
+
+func TestNormalizeConflict(t *testing.T) {
+	offer := &Message{Type: MsgTypeOffer, SDP: "v=0 flat", Payload: json.RawMessage(`{"type":"offer","sdp":"v=0 payload"}`)}
+	if !offer.normalizeSignal() {
+		t.Fatal("sdp conflict not reported")
+	}
+	if sdp := payloadFields(t, offer)["sdp"]; offer.SDP != "v=0 flat" || sdp != "v=0 flat" {
+		t.Fatalf("flat %q, payload %v; the flat sdp should win", offer.SDP, sdp)
+	}
+
+	cand := &Message{Type: MsgTypeIceCandidate, Candidate: "candidate:1", SDPMLineIndex: 0,
+		Payload: json.RawMessage(`{"candidate":"candidate:1","sdpMLineIndex":1}`)}
+	if !cand.normalizeSignal() {
+		t.Fatal("candidate m-line conflict not reported")
+	}
+	if idx := payloadFields(t, cand)["sdpMLineIndex"]; idx != float64(0) {
+		t.Fatalf("payload sdpMLineIndex %v, want the flat 0", idx)
+	}
+
+	same := &Message{Type: MsgTypeAnswer, SDP: "v=0", Payload: json.RawMessage(`{"sdp":"v=0"}`)}
+	if same.normalizeSignal() {
+		t.Fatal("matching forms reported as a conflict")
+	}
+}
+
+func TestNormalizeEndOfCandidates(t *testing.T) {
+	for _, m := range []*Message{
+		{Type: MsgTypeIceCandidate},
+		{Type: MsgTypeIceCandidate, Payload: json.RawMessage(`{"candidate":""}`)},
+	} {
+		if m.normalizeSignal() {
+			t.Fatal("conflict reported")
+		}
+		fields := payloadFields(t, m)
+		if c, ok := fields["candidate"]; !ok || c != "" || m.Candidate != "" {
+			t.Fatalf("end-of-candidates payload %v, flat %q", fields, m.Candidate)
+		}
+		if _, ok := fields["sdpMid"]; ok {
+			t.Fatalf("empty sdpMid kept in %v", fields)
+		}
+	}
+}
+
+func TestNormalizeLeavesOthersAlone(t *testing.T) {
+	raw := json.RawMessage(`"not an object"`)
+	m := &Message{Type: MsgTypeOffer, Payload: raw}
+	if m.normalizeSignal() || string(m.Payload) != string(raw) {
+		t.Fatalf("non-object payload rewritten to %s", m.Payload)
+	}
+
+	batch := json.RawMessage(`[{"candidate":"candidate:1"}]`)
+	m = &Message{Type: MsgTypeCandidates, Payload: batch}
+	if m.normalizeSignal(); string(m.Payload) != string(batch) || m.Candidate != "" {
+		t.Fatalf("candidate batch rewritten to %s", m.Payload)
+	}
+}
@@ -0,0 +1,53 @@
+package signaling
+
+import "testing"
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		host, allowed string
+		want          bool
+	}{
+		{"app.streamlinux.example", "app.streamlinux.example", true},
+		{"APP.streamlinux.example:8443", "app.streamlinux.example", true},
+		{"app.streamlinux.example", "app.streamlinux.example:443", true},
+		{"other.streamlinux.example", "app.streamlinux.example", false},
+
+		{"web.streamlinux.example", "*.streamlinux.example", true},
+		{"a.b.streamlinux.example", "*.streamlinux.example", true},
+		{"web.streamlinux.example:3000", "*.streamlinux.example", true},
+		{"streamlinux.example", "*.streamlinux.example", false},
+		{".streamlinux.example", "*.streamlinux.example", false},
+		{"a..streamlinux.example", "*.streamlinux.example", false},
+		{"evilstreamlinux.example", "*.streamlinux.example", false},
+		{"streamlinux.example.evil.com", "*.streamlinux.example", false},
+		{".example", "*.example", false},
+
+		// A bare "*" or "*example" is not a wildcard
+		{"anything.com", "*", false},
+		{"myexample", "*example", false},
+	}
+	for _, tt := range tests {
+		if got := MatchOrigin(tt.host, tt.allowed); got != tt.want {
+			t.Errorf("MatchOrigin(%q, %q) = %v, want %v", tt.host, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	defer SetAllowedOrigins(allowedOrigins)
+	SetAllowedOrigins([]string{"*.streamlinux.example"})
+
+	for origin, want := range map[string]bool{
+		"":                                true, // Native app
+		"https://web.streamlinux.example": true,
+		"https://streamlinux.example":     false,
+		"https://evil.example":            false,
+		"http://localhost:5173":           true,
+		"http://192.168.1.5":              true,
+		"not a url":                       false,
+	} {
+		if got := originAllowed(origin); got != want {
+			t.Errorf("originAllowed(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
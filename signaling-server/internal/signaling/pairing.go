@@ -0,0 +1,128 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// PairingStatus reports whether new client pairings are being accepted
+type PairingStatus struct {
+	Active    bool  `json:"active"`
+	ExpiresAt int64 `json:"expires_at,omitempty"` // Unix seconds
+}
+
+// pairingRequest is the payload of a MsgTypePairingMode message and of
+// the pairing HTTP endpoint
+type pairingRequest struct {
+	Enabled         bool `json:"enabled"`
+	DurationSeconds int  `json:"duration_seconds,omitempty"`
+}
+
+// EnablePairing opens the pairing window for d, capped at the configured
+// PairingWindow. A non-positive d uses the full PairingWindow.
+func (h *Hub) EnablePairing(d time.Duration) PairingStatus {
+	if d <= 0 || d > h.security.PairingWindow {
+		d = h.security.PairingWindow
+	}
+
+	h.mu.Lock()
+	h.pairingUntil = time.Now().Add(d)
+	h.mu.Unlock()
+
+	h.logger.Info("Pairing mode enabled", zap.Duration("duration", d))
+	return h.PairingStatus()
+}
+
+// DisablePairing closes the pairing window immediately
+func (h *Hub) DisablePairing() PairingStatus {
+	h.mu.Lock()
+	h.pairingUntil = time.Time{}
+	h.mu.Unlock()
+
+	h.logger.Info("Pairing mode disabled")
+	return h.PairingStatus()
+}
+
+// PairingStatus returns the current pairing window state
+func (h *Hub) PairingStatus() PairingStatus {
+	h.mu.RLock()
+	until := h.pairingUntil
+	h.mu.RUnlock()
+
+	if time.Now().After(until) {
+		return PairingStatus{}
+	}
+	return PairingStatus{Active: true, ExpiresAt: until.Unix()}
+}
+
+// acceptsNewClients reports whether a new non-host connection may be
+// admitted under the pairing policy
+func (h *Hub) acceptsNewClients() bool {
+	return !h.security.RequirePairingMode || h.PairingStatus().Active
+}
+
+func (h *Hub) applyPairingRequest(req pairingRequest) PairingStatus {
+	if !req.Enabled {
+		return h.DisablePairing()
+	}
+	return h.EnablePairing(time.Duration(req.DurationSeconds) * time.Second)
+}
+
+// handlePairingMode lets a host open or close the pairing window
+func (h *Hub) handlePairingMode(msg *Message) {
+	h.mu.RLock()
+	peer, ok := h.peers[msg.From]
+	isHost := ok && h.actingRole(peer) == RoleHost
+	h.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	if !isHost {
+		h.logger.Warn("Pairing mode request from non-host ignored", zap.String("peer", peer.ID))
+		h.sendError(peer, CodeNotHost, "Only hosts can change pairing mode")
+		return
+	}
+
+	req := pairingRequest{Enabled: true}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			h.sendError(peer, CodeInvalidMessage, "Invalid pairing mode payload")
+			return
+		}
+	}
+
+	payload, _ := json.Marshal(h.applyPairingRequest(req))
+	h.sendToPeer(peer, &Message{
+		Type:    MsgTypePairingMode,
+		Payload: payload,
+	})
+}
+
+// PairingHandler handles HTTP requests to inspect (GET) or change (POST)
+// the pairing window
+func (h *Hub) PairingHandler(w http.ResponseWriter, r *http.Request) {
+	var status PairingStatus
+	switch r.Method {
+	case http.MethodGet:
+		status = h.PairingStatus()
+	case http.MethodPost:
+		req := pairingRequest{Enabled: true}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		status = h.applyPairingRequest(req)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,113 @@
+package signaling
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PeerIDConflictPolicy decides what happens when a client proposes a
+// peer ID that is already in use
+type PeerIDConflictPolicy string
+
+const (
+	// PeerIDReject refuses the second connection
+	PeerIDReject PeerIDConflictPolicy = "reject"
+	// PeerIDSuffix assigns "<id>-2", "<id>-3", ... and reports the actual
+	// ID back in the registered message
+	PeerIDSuffix PeerIDConflictPolicy = "suffix"
+)
+
+var (
+	// ErrPeerIDTaken is returned when a proposed peer ID is in use and the
+	// conflict policy is PeerIDReject
+	ErrPeerIDTaken = errors.New("peer ID already in use")
+	// ErrInvalidPeerID is returned for proposed IDs with unsupported characters
+	ErrInvalidPeerID = errors.New("invalid peer ID")
+	// ErrPeerIDUnavailable is returned when the ID generator keeps
+	// producing IDs that are already in use
+	ErrPeerIDUnavailable = errors.New("no free peer ID")
+)
+
+const (
+	// maxSuffixAttempts bounds the search for a free disambiguated ID
+	maxSuffixAttempts = 100
+	// maxGenerateAttempts bounds the retries for a generated ID. Random
+	// IDs practically never collide; a generator that keeps colliding,
+	// e.g. a deterministic one reused across hubs, would otherwise spin
+	// under h.mu forever.
+	maxGenerateAttempts = 16
+)
+
+// reservePeerID picks the ID for a new connection and reserves it until
+// the peer is registered, so two concurrent connections can never end up
+// with the same ID. An empty proposal gets a generated ID.
+func (h *Hub) reservePeerID(proposed string) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if proposed == "" {
+		for i := 0; i < maxGenerateAttempts; i++ {
+			id := h.ids.PeerID()
+			if !h.peerIDInUse(id) {
+				h.reservedIDs[id] = struct{}{}
+				return id, nil
+			}
+		}
+		return "", ErrPeerIDUnavailable
+	}
+
+	if !validPeerID(proposed, h.security.FieldLimits.PeerID) {
+		return "", ErrInvalidPeerID
+	}
+
+	id := proposed
+	if h.peerIDInUse(id) {
+		if h.security.PeerIDConflict != PeerIDSuffix {
+			return "", ErrPeerIDTaken
+		}
+		id = ""
+		for n := 2; n < maxSuffixAttempts; n++ {
+			candidate := fmt.Sprintf("%s-%d", proposed, n)
+			if !h.peerIDInUse(candidate) {
+				id = candidate
+				break
+			}
+		}
+		if id == "" {
+			return "", ErrPeerIDTaken
+		}
+	}
+
+	h.reservedIDs[id] = struct{}{}
+	return id, nil
+}
+
+// releasePeerID drops a reservation for a connection that never registered
+func (h *Hub) releasePeerID(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.reservedIDs, id)
+}
+
+// peerIDInUse must be called with h.mu held
+func (h *Hub) peerIDInUse(id string) bool {
+	if _, ok := h.peers[id]; ok {
+		return true
+	}
+	_, ok := h.reservedIDs[id]
+	return ok
+}
+
+func validPeerID(id string, maxLen int) bool {
+	if maxLen > 0 && len(id) > maxLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
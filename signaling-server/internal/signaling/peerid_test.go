@@ -0,0 +1,132 @@
+package signaling
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// collidingIDs hands out the same peer ID every time
+type collidingIDs struct{}
+
+func (collidingIDs) PeerID() string { return "peer-dup" }
+func (collidingIDs) ConnID() string { return "conn-dup" }
+
+func TestGeneratedPeerIDCollision(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	s.hub.SetIDGenerator(collidingIDs{})
+
+	if _, id := s.host("t1"); id != "peer-dup" {
+		t.Fatalf("first peer ID %q, want peer-dup", id)
+	}
+	// The generator never yields a free ID; the hub gives up instead of
+	// spinning under its lock
+	_, resp, err := s.dial("is_host=true&token=t2")
+	if err == nil {
+		t.Fatal("second connection accepted with a colliding ID")
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("second connection: %v, want status %d", err, http.StatusServiceUnavailable)
+	}
+}
+
+func TestSeededIDsDeterministic(t *testing.T) {
+	a, b := NewSeededIDs(42), NewSeededIDs(42)
+	for i := 0; i < 3; i++ {
+		pa, pb := a.PeerID(), b.PeerID()
+		if pa != pb {
+			t.Fatalf("peer ID %d: %q != %q", i, pa, pb)
+		}
+		if len(pa) != len(generatePeerID()) {
+			t.Fatalf("seeded peer ID %q not in the random format", pa)
+		}
+	}
+	if a.ConnID() != b.ConnID() {
+		t.Fatal("conn IDs differ for the same seed")
+	}
+	if NewSeededIDs(1).PeerID() == NewSeededIDs(2).PeerID() {
+		t.Fatal("different seeds yield the same peer ID")
+	}
+}
+
+func TestProposedPeerIDRace(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	sec.AllowClientPeerIDs = true
+	s := newTestServer(t, sec, DefaultHubConfig())
+
+	const racers = 8
+	var wg sync.WaitGroup
+	statuses := make(chan int, racers)
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, resp, err := s.dial("peer_id=sticky")
+			switch {
+			case err == nil:
+				statuses <- http.StatusSwitchingProtocols
+			case resp != nil:
+				statuses <- resp.StatusCode
+			default:
+				statuses <- 0
+			}
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	won := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusSwitchingProtocols:
+			won++
+		case http.StatusConflict:
+		default:
+			t.Errorf("racer got status %d", status)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("%d racers got the ID, want exactly 1", won)
+	}
+}
+
+func TestProposedPeerIDSuffix(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	sec.AllowClientPeerIDs = true
+	sec.PeerIDConflict = PeerIDSuffix
+	s := newTestServer(t, sec, DefaultHubConfig())
+
+	if _, id := s.client("peer_id=sticky"); id != "sticky" {
+		t.Fatalf("first client got %q, want sticky", id)
+	}
+	_, id := s.client("peer_id=sticky")
+	if id == "sticky" || !strings.HasPrefix(id, "sticky-") {
+		t.Fatalf("second client got %q, want a disambiguated sticky-N", id)
+	}
+}
+
+func TestGeneratePeerIDUnique(t *testing.T) {
+	const n = 100000
+	seen := make(map[string]struct{}, n)
+	ascending := 0
+	prev := ""
+	for i := 0; i < n; i++ {
+		id := generatePeerID()
+		if len(id) != 32 {
+			t.Fatalf("ID %q is not 128 bits of hex", id)
+		}
+		if _, dup := seen[id]; dup {
+			t.Fatalf("duplicate ID %q after %d", id, i)
+		}
+		seen[id] = struct{}{}
+		if id > prev {
+			ascending++
+		}
+		prev = id
+	}
+	// Random IDs go up about half the time; sequential ones always do
+	if ascending > n*6/10 || ascending < n*4/10 {
+		t.Fatalf("%d of %d IDs sorted after their predecessor", ascending, n)
+	}
+}
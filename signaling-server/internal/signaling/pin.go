@@ -0,0 +1,220 @@
+package signaling
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxPINAttempts is how many wrong PINs a host may enter before the
+	// waiting connection is rejected
+	maxPINAttempts = 3
+	// maxHeldMessages bounds what a connection may queue before approval
+	maxHeldMessages = 32
+)
+
+// SetPendingAuth puts a connection on hold until its PIN is verified.
+// A PIN is generated when auth.PIN is empty, and CreatedAt/ExpiresAt
+// default to now and now+PINExpiry.
+func (h *Hub) SetPendingAuth(auth *PendingAuth) error {
+	if auth.PIN == "" {
+		pin, err := generatePIN()
+		if err != nil {
+			return err
+		}
+		auth.PIN = pin
+	}
+	if auth.CreatedAt.IsZero() {
+		auth.CreatedAt = time.Now()
+	}
+	if auth.ExpiresAt.IsZero() {
+		auth.ExpiresAt = auth.CreatedAt.Add(h.security.PINExpiry)
+	}
+	return h.addPendingAuth(auth)
+}
+
+// VerifyPIN checks a PIN entered on the host for a waiting connection.
+// On success the connection is approved and its held messages are routed;
+// the third wrong PIN, or any attempt after expiry, rejects it.
+func (h *Hub) VerifyPIN(connID, pin string) bool {
+	h.tokenMu.Lock()
+	auth, ok := h.pendingAuth[connID]
+	if !ok {
+		h.tokenMu.Unlock()
+		return false
+	}
+
+	if time.Now().After(auth.ExpiresAt) {
+		delete(h.pendingAuth, connID)
+		h.tokenMu.Unlock()
+		h.rejectPendingAuth(auth, CodePINExpired, "PIN expired")
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(auth.PIN), []byte(pin)) != 1 {
+		auth.Attempts++
+		exhausted := auth.Attempts >= maxPINAttempts
+		if exhausted {
+			delete(h.pendingAuth, connID)
+		}
+		h.tokenMu.Unlock()
+
+		h.logger.Warn("Wrong PIN entered",
+			zap.String("connection", connID),
+			zap.Int("attempts", auth.Attempts))
+		if exhausted {
+			h.rejectPendingAuth(auth, CodePINAttempts, "Too many wrong PIN attempts")
+		}
+		return false
+	}
+
+	delete(h.pendingAuth, connID)
+	held := auth.held
+	auth.held = nil
+	h.tokenMu.Unlock()
+
+	h.logger.Info("PIN verified", zap.String("connection", connID))
+
+	if auth.Peer != nil {
+		h.mu.Lock()
+		if h.peers[connID] == auth.Peer {
+			auth.Peer.awaitingPIN = false
+			h.sendToPeer(auth.Peer, &Message{
+				Type:   MsgTypePinAccepted,
+				PeerID: connID,
+			})
+		}
+		h.mu.Unlock()
+	}
+
+	for _, msg := range held {
+		h.routeMessage(msg)
+	}
+	return true
+}
+
+// holdIfAwaitingPIN queues a message from a connection whose PIN hasn't
+// been verified yet and reports whether it did so. Must be called with
+// h.mu held.
+func (h *Hub) holdIfAwaitingPIN(msg *Message) bool {
+	peer, ok := h.peers[msg.From]
+	if !ok || !peer.awaitingPIN {
+		return false
+	}
+
+	h.tokenMu.Lock()
+	defer h.tokenMu.Unlock()
+
+	if auth, ok := h.pendingAuth[peer.ID]; ok && len(auth.held) < maxHeldMessages {
+		auth.held = append(auth.held, msg)
+	} else {
+		h.logger.Warn("Dropping message from connection awaiting PIN",
+			zap.String("peer", peer.ID),
+			zap.String("type", string(msg.Type)))
+	}
+	return true
+}
+
+// announcePINRequired tells a newly registered connection its PIN and the
+// hosts that issued its token (or every host, if none is connected) that
+// a PIN is awaited. It returns false when the connection has no pending
+// entry, e.g. because it already expired. Must be called with h.mu held.
+func (h *Hub) announcePINRequired(peer *Peer) bool {
+	h.tokenMu.RLock()
+	auth, ok := h.pendingAuth[peer.ID]
+	var hostPeers []string
+	if entry, exists := h.validTokens[peer.token]; exists {
+		hostPeers = entry.hostList()
+	}
+	h.tokenMu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	h.sendToPeer(peer, &Message{
+		Type:   MsgTypePinRequired,
+		PeerID: peer.ID,
+		PIN:    auth.PIN,
+	})
+
+	notice := &Message{
+		Type:   MsgTypePinRequired,
+		PeerID: peer.ID,
+		Name:   auth.DeviceName,
+	}
+	notified := false
+	for _, id := range hostPeers {
+		if host, ok := h.peers[id]; ok {
+			h.sendToPeer(host, notice)
+			notified = true
+		}
+	}
+	if notified {
+		return true
+	}
+	for _, other := range h.peers {
+		if h.actingRole(other) == RoleHost {
+			h.sendToPeer(other, notice)
+		}
+	}
+	return true
+}
+
+// handlePinVerify lets a host approve a waiting connection by the PIN
+// its user entered
+func (h *Hub) handlePinVerify(msg *Message) {
+	h.mu.RLock()
+	peer, ok := h.peers[msg.From]
+	role := RoleClient
+	if ok {
+		role = h.actingRole(peer)
+	}
+	h.mu.RUnlock()
+	if !ok {
+		return
+	}
+	if role != RoleHost {
+		h.sendError(peer, CodeNotHost, "Only hosts can verify PINs")
+		return
+	}
+
+	if !h.VerifyPIN(msg.PeerID, msg.PIN) {
+		h.sendError(peer, CodePINFailed, "PIN verification failed")
+		return
+	}
+	h.sendToPeer(peer, &Message{
+		Type:   MsgTypePinAccepted,
+		PeerID: msg.PeerID,
+	})
+}
+
+// rejectPendingAuth disconnects a connection whose PIN flow failed
+func (h *Hub) rejectPendingAuth(auth *PendingAuth, code ErrorCode, reason string) {
+	h.logger.Warn("Pending auth rejected",
+		zap.String("connection", auth.ConnectionID),
+		zap.String("reason", reason))
+
+	if auth.Peer == nil {
+		return
+	}
+	h.mu.RLock()
+	if h.peers[auth.ConnectionID] == auth.Peer {
+		h.sendError(auth.Peer, code, reason)
+	}
+	h.mu.RUnlock()
+	h.unregisterPeer(auth.Peer)
+}
+
+// generatePIN returns a random 6-digit PIN
+func generatePIN() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,57 @@
+package signaling
+
+import "testing"
+
+func TestPINHandshake(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	host, _ := s.host("host-token")
+
+	s.remote = "192.0.2.30:40000"
+	c := s.mustDial("token=host-token")
+	s.remote = ""
+	pin := c.expect(MsgTypePinRequired).PIN
+	if len(pin) == 0 {
+		t.Fatal("no PIN shown to the client")
+	}
+	c.send(Message{Type: MsgTypeRegister, Role: RoleClient}) // Held until approved
+
+	notice := host.expect(MsgTypePinRequired)
+	if notice.PIN != "" {
+		t.Fatal("PIN revealed to the host")
+	}
+
+	host.send(Message{Type: MsgTypePinVerify, PeerID: notice.PeerID, PIN: pin + "0"})
+	if code := host.expectError(); code != CodePINFailed {
+		t.Fatalf("wrong PIN: got %s, want %s", code, CodePINFailed)
+	}
+
+	host.send(Message{Type: MsgTypePinVerify, PeerID: notice.PeerID, PIN: pin})
+	if ok := host.expect(MsgTypePinAccepted); ok.PeerID != notice.PeerID {
+		t.Fatalf("accepted %q, want %q", ok.PeerID, notice.PeerID)
+	}
+	c.expect(MsgTypePinAccepted)
+	if id := c.expect(MsgTypeRegistered).PeerID; id != notice.PeerID {
+		t.Fatalf("held registration gave %q, want %q", id, notice.PeerID)
+	}
+}
+
+func TestPINVerifyNeedsHostConnection(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	host, _ := s.host("host-token")
+
+	s.remote = "192.0.2.31:40000"
+	waiting := s.mustDial("token=host-token")
+	s.remote = ""
+	pin := waiting.expect(MsgTypePinRequired).PIN
+	id := host.expect(MsgTypePinRequired).PeerID
+
+	// A local client can't approve it, whatever role it claims
+	c, _ := s.client("")
+	c.send(Message{Type: MsgTypePinVerify, Role: RoleHost, PeerID: id, PIN: pin})
+	if code := c.expectError(); code != CodeNotHost {
+		t.Fatalf("client verifying a PIN: got %s, want %s", code, CodeNotHost)
+	}
+	if !s.hub.VerifyPIN(id, pin) {
+		t.Fatal("PIN no longer valid after the refused attempt")
+	}
+}
@@ -0,0 +1,45 @@
+package signaling
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Handshake registration lets a client register in the upgrade request
+// itself, so the peer is registered before any of its messages are read:
+//
+//	?role=client&name=Living+Room+TV&tags=tv,4k
+//
+// or the X-Peer-Role, X-Peer-Name and X-Peer-Tags headers. Clients that
+// don't send it register with a register message as before.
+
+// handshakeRegistration builds the register message carried by the
+// upgrade request, if any
+func handshakeRegistration(r *http.Request) (*Message, bool) {
+	query := r.URL.Query()
+	field := func(param, header string) string {
+		if v := query.Get(param); v != "" {
+			return v
+		}
+		return r.Header.Get(header)
+	}
+
+	role := field("role", "X-Peer-Role")
+	name := field("name", "X-Peer-Name")
+	tags := field("tags", "X-Peer-Tags")
+	if role == "" && name == "" && tags == "" {
+		return nil, false
+	}
+
+	msg := &Message{
+		Type: MsgTypeRegister,
+		Role: PeerRole(strings.ToLower(role)),
+		Name: name,
+	}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			msg.Tags = append(msg.Tags, tag)
+		}
+	}
+	return msg, true
+}
@@ -0,0 +1,51 @@
+package signaling
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Wire protocol versions, negotiated as WebSocket subprotocols
+// "streamlinux.v1", "streamlinux.v2", ... Clients that offer no
+// subprotocol are treated as v1.
+const (
+	ProtocolV1 = 1
+	ProtocolV2 = 2 // registered carries protocolVersion
+
+	subprotocolPrefix = "streamlinux.v"
+)
+
+// supportedSubprotocols lists the protocols the server speaks, most
+// preferred first; the upgrader picks the first one the client offers
+var supportedSubprotocols = []string{"streamlinux.v2", "streamlinux.v1"}
+
+// protocolVersion parses a negotiated subprotocol, defaulting to v1
+func protocolVersion(subprotocol string) int {
+	if v, err := strconv.Atoi(strings.TrimPrefix(subprotocol, subprotocolPrefix)); err == nil && strings.HasPrefix(subprotocol, subprotocolPrefix) {
+		return v
+	}
+	return ProtocolV1
+}
+
+// offersSupportedProtocol reports whether a client that offered
+// subprotocols offered at least one the server supports
+func offersSupportedProtocol(offered []string) bool {
+	if len(offered) == 0 {
+		return true // Legacy client, speaks v1
+	}
+	for _, p := range offered {
+		for _, s := range supportedSubprotocols {
+			if p == s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// negotiatedVersion returns the protocol version of an upgraded connection
+func negotiatedVersion(conn *websocket.Conn) int {
+	return protocolVersion(conn.Subprotocol())
+}
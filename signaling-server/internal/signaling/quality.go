@@ -0,0 +1,211 @@
+package signaling
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBucketsMs are histogram upper bounds in milliseconds, from LAN
+// round trips up to negotiations that only complete after TURN fallback
+var latencyBucketsMs = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+// histogram is a fixed-bucket histogram; percentiles are interpolated
+// within the bucket they fall in
+type histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64 // len(bounds)+1, the last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := 0
+	for i < len(h.bounds) && v > h.bounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// HistogramSnapshot summarizes a latency distribution in milliseconds
+type HistogramSnapshot struct {
+	Count uint64  `json:"count"`
+	P50   float64 `json:"p50_ms"`
+	P95   float64 `json:"p95_ms"`
+	P99   float64 `json:"p99_ms"`
+	Mean  float64 `json:"mean_ms"`
+}
+
+func (h *histogram) snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := HistogramSnapshot{Count: h.count}
+	if h.count == 0 {
+		return s
+	}
+	s.P50 = h.quantile(0.50)
+	s.P95 = h.quantile(0.95)
+	s.P99 = h.quantile(0.99)
+	s.Mean = math.Round(h.sum/float64(h.count)*10) / 10
+	return s
+}
+
+// quantile must be called with h.mu held and h.count > 0
+func (h *histogram) quantile(q float64) float64 {
+	rank := q * float64(h.count)
+	var cumulative float64
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		if cumulative+float64(c) >= rank {
+			lower := 0.0
+			if i > 0 {
+				lower = h.bounds[i-1]
+			}
+			if i == len(h.bounds) {
+				return lower // Beyond the last bound; report the bound
+			}
+			upper := h.bounds[i]
+			v := lower + (upper-lower)*(rank-cumulative)/float64(c)
+			return math.Round(v*10) / 10
+		}
+		cumulative += float64(c)
+	}
+	return h.bounds[len(h.bounds)-1]
+}
+
+// writePrometheus renders the histogram in the Prometheus text format
+func (h *histogram) writePrometheus(b *strings.Builder, name, help string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(b, "%s_bucket{le=\"%g\"} %d\n", name, bound, cumulative)
+	}
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(b, "%s_sum %g\n", name, h.sum)
+	fmt.Fprintf(b, "%s_count %d\n", name, h.count)
+}
+
+// QualityStats are connection-quality distributions
+type QualityStats struct {
+	SignalingRTT     HistogramSnapshot `json:"signaling_rtt"`
+	TimeToFirstOffer HistogramSnapshot `json:"time_to_first_offer"`
+	Negotiation      HistogramSnapshot `json:"negotiation"`
+}
+
+// pairing tracks the offer/answer exchange between two peers
+type pairing struct {
+	a, b     string
+	offerAt  time.Time
+	answered bool
+}
+
+// qualityTracker accumulates signaling latency distributions
+type qualityTracker struct {
+	rtt         *histogram // WebSocket ping to pong
+	firstOffer  *histogram // Both peers connected to first offer between them
+	negotiation *histogram // First offer to first answer per pairing
+
+	mu       sync.Mutex
+	pairings map[string]*pairing
+}
+
+func newQualityTracker() *qualityTracker {
+	return &qualityTracker{
+		rtt:         newHistogram(latencyBucketsMs),
+		firstOffer:  newHistogram(latencyBucketsMs),
+		negotiation: newHistogram(latencyBucketsMs),
+		pairings:    make(map[string]*pairing),
+	}
+}
+
+func pairingKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "|" + b
+}
+
+// observeSignal records an offer or answer from one peer to another.
+// connected is when the later of the two peers connected.
+func (q *qualityTracker) observeSignal(msgType MessageType, from, to string, connected time.Time) {
+	if msgType != MsgTypeOffer && msgType != MsgTypeAnswer {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	key := pairingKey(from, to)
+	p, ok := q.pairings[key]
+	now := time.Now()
+
+	switch msgType {
+	case MsgTypeOffer:
+		if !ok {
+			q.pairings[key] = &pairing{a: from, b: to, offerAt: now}
+			q.firstOffer.observe(msSince(connected, now))
+		}
+	case MsgTypeAnswer:
+		if ok && !p.answered {
+			p.answered = true
+			q.negotiation.observe(msSince(p.offerAt, now))
+		}
+	}
+}
+
+// forget drops pairings involving a departed peer
+func (q *qualityTracker) forget(peerID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for key, p := range q.pairings {
+		if p.a == peerID || p.b == peerID {
+			delete(q.pairings, key)
+		}
+	}
+}
+
+func (q *qualityTracker) snapshot() QualityStats {
+	return QualityStats{
+		SignalingRTT:     q.rtt.snapshot(),
+		TimeToFirstOffer: q.firstOffer.snapshot(),
+		Negotiation:      q.negotiation.snapshot(),
+	}
+}
+
+func msSince(start, end time.Time) float64 {
+	return float64(end.Sub(start).Microseconds()) / 1000
+}
+
+// trackNegotiation feeds an offer/answer delivered to target into the
+// quality histograms. Must be called with h.mu held.
+func (h *Hub) trackNegotiation(msg *Message, target *Peer) {
+	from, ok := h.peers[msg.From]
+	if !ok {
+		return
+	}
+	connected := from.connectedAt
+	if target.connectedAt.After(connected) {
+		connected = target.connectedAt
+	}
+	h.quality.observeSignal(msg.Type, from.ID, target.ID, connected)
+}
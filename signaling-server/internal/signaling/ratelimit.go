@@ -0,0 +1,63 @@
+package signaling
+
+import (
+	"net"
+)
+
+// remoteIP strips the port from a RemoteAddr, so a client can't get a
+// fresh rate limit bucket by connecting from another source port
+func remoteIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
+
+// rateLimitKey picks the connection-attempt bucket: the device ID with a
+// token hash, else the token hash, else the address. The bucket is
+// charged before the token is checked, so the device ID alone would let
+// anyone who knows it lock that device out.
+func (h *Hub) rateLimitKey(ip, deviceID, token string) string {
+	if h.security.RateLimitByDevice {
+		var digest string
+		if token != "" {
+			digest = tokenDigest(token)[:16]
+		}
+		if deviceID != "" {
+			return "device:" + deviceID + ":" + digest
+		}
+		if digest != "" {
+			return "token:" + digest
+		}
+	}
+	return "ip:" + ip
+}
+
+// allowConnAttempt charges a connection attempt to key and, when key isn't
+// the address itself, to the per-address ceiling
+func (h *Hub) allowConnAttempt(ip, key string) bool {
+	window := h.security.RateLimitWindow
+	if key != "ip:"+ip && h.security.MaxConnAttemptsPerIP > 0 &&
+		h.rateLimiter.Remaining("ip:"+ip, h.security.MaxConnAttemptsPerIP, window) == 0 {
+		return false
+	}
+	if !h.rateLimiter.Allow(key, h.security.MaxConnAttempts, window) {
+		return false
+	}
+	if key != "ip:"+ip && h.security.MaxConnAttemptsPerIP > 0 {
+		h.rateLimiter.Allow("ip:"+ip, h.security.MaxConnAttemptsPerIP, window)
+	}
+	return true
+}
+
+// authFailuresExhausted reports whether ip used up its failed-token budget
+func (h *Hub) authFailuresExhausted(ip string) bool {
+	max := h.security.MaxAuthFailures
+	return max > 0 && h.authLimiter.Remaining(ip, max, h.security.RateLimitWindow) == 0
+}
+
+func (h *Hub) recordAuthFailure(ip string) {
+	if max := h.security.MaxAuthFailures; max > 0 {
+		h.authLimiter.Allow(ip, max, h.security.RateLimitWindow)
+	}
+}
@@ -0,0 +1,90 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRateLimiterAllowN(t *testing.T) {
+	r := NewRateLimiter()
+	if !r.AllowN("k", 3, 4, time.Minute) {
+		t.Fatal("AllowN(3) of 4 refused")
+	}
+	if r.AllowN("k", 2, 4, time.Minute) {
+		t.Fatal("AllowN(2) past the limit allowed")
+	}
+	if got := r.Remaining("k", 4, time.Minute); got != 1 {
+		t.Fatalf("Remaining = %d, want 1", got)
+	}
+	r.Reset("k")
+	if got := r.Remaining("k", 4, time.Minute); got != 4 {
+		t.Fatalf("Remaining after Reset = %d, want 4", got)
+	}
+}
+
+func TestRateLimitDevicesBehindOneIP(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	sec.MaxConnAttempts = 2
+	h := NewHubWithSecurity(zap.NewNop(), 0, sec)
+
+	phone := h.rateLimitKey("198.51.100.7", "phone", "")
+	tablet := h.rateLimitKey("198.51.100.7", "tablet", "")
+	for i := 0; i < sec.MaxConnAttempts; i++ {
+		if !h.allowConnAttempt("198.51.100.7", phone) {
+			t.Fatalf("phone attempt %d refused", i+1)
+		}
+	}
+	if h.allowConnAttempt("198.51.100.7", phone) {
+		t.Fatal("phone allowed past its limit")
+	}
+	if !h.allowConnAttempt("198.51.100.7", tablet) {
+		t.Fatal("tablet throttled by the phone's bucket")
+	}
+}
+
+func TestRateLimitDeviceKeyedWithToken(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	sec.MaxConnAttempts = 2
+	h := NewHubWithSecurity(zap.NewNop(), 0, sec)
+
+	// Someone reusing the phone's device ID with another token, or none,
+	// doesn't use up the phone's attempts
+	phone := h.rateLimitKey("198.51.100.7", "phone", "phone-token")
+	for _, token := range []string{"guess", ""} {
+		spoof := h.rateLimitKey("198.51.100.8", "phone", token)
+		for i := 0; i < sec.MaxConnAttempts+1; i++ {
+			h.allowConnAttempt("198.51.100.8", spoof)
+		}
+	}
+	if !h.allowConnAttempt("198.51.100.7", phone) {
+		t.Fatal("phone throttled by attempts with its device ID and another token")
+	}
+}
+
+func TestAuthFailuresSurviveValidToken(t *testing.T) {
+	sec := tokenOnlySecurity()
+	sec.MaxAuthFailures = 3
+	s := newTestServer(t, sec, DefaultHubConfig())
+	s.hub.RegisterToken("good-token", time.Minute)
+	s.remote = "198.51.100.7:40000"
+
+	guess := func(token string) int {
+		t.Helper()
+		return s.mustDial("token=" + token).expectClose()
+	}
+	for i := 0; i < sec.MaxAuthFailures-1; i++ {
+		if code := guess("guess"); code != CloseAuthFailed {
+			t.Fatalf("guess %d: close code %d, want %d", i+1, code, CloseAuthFailed)
+		}
+	}
+	// A legitimate client behind the same NAT doesn't wipe the budget
+	s.client("token=good-token")
+	if code := guess("guess"); code != CloseAuthFailed {
+		t.Fatalf("last guess: close code %d, want %d", code, CloseAuthFailed)
+	}
+	if code := guess("guess"); code != CloseRateLimited {
+		t.Fatalf("after budget spent: close code %d, want %d", code, CloseRateLimited)
+	}
+}
@@ -0,0 +1,43 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ReadyStatus is the response of /readyz
+type ReadyStatus struct {
+	Ready  bool   `json:"ready"`
+	Reason string `json:"reason,omitempty"` // Why new clients shouldn't be sent here
+}
+
+// Ready reports whether the hub should be sent new clients: its Run loop
+// is going, it isn't draining and it has room for another connection.
+// Liveness is separate; a hub that isn't ready is still serving the peers
+// it has.
+func (h *Hub) Ready() ReadyStatus {
+	switch {
+	case !h.running.Load():
+		return ReadyStatus{Reason: "not-running"}
+	case h.Draining():
+		return ReadyStatus{Reason: "draining"}
+	case h.conns.full():
+		return ReadyStatus{Reason: "connection-limit"}
+	}
+	if load := h.Load(); load.Overloaded {
+		return ReadyStatus{Reason: "overloaded-" + load.Reason}
+	}
+	return ReadyStatus{Ready: true}
+}
+
+// ReadyHandler serves /readyz, answering 503 while the hub isn't ready so
+// a load balancer stops routing new clients to it
+func (h *Hub) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	status := h.Ready()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !status.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,144 @@
+package signaling
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// reconnectBaseBackoff is the first lockout for a device in a reconnect
+	// loop; each further offense doubles it up to reconnectMaxBackoff
+	reconnectBaseBackoff = 5 * time.Second
+	reconnectMaxBackoff  = 5 * time.Minute
+)
+
+// ReconnectOffender is a device currently or recently backed off for
+// reconnecting too often
+type ReconnectOffender struct {
+	DeviceID     string    `json:"device_id"`
+	Strikes      int       `json:"strikes"`
+	BlockedUntil time.Time `json:"blocked_until"`
+}
+
+// ReconnectStats summarizes reconnect-loop tracking
+type ReconnectStats struct {
+	TrackedDevices int                 `json:"tracked_devices"`
+	Offenders      []ReconnectOffender `json:"offenders"`
+}
+
+type reconnectState struct {
+	attempts     []time.Time
+	strikes      int
+	blockedUntil time.Time
+}
+
+// reconnectKey identifies a device by its device_id together with the
+// token it presents. device_id alone is chosen by the client, so keying
+// on it would let anyone back off a device whose ID they know.
+type reconnectKey struct {
+	deviceID string
+	token    string // tokenDigest of the token, empty without one
+}
+
+func newReconnectKey(deviceID, token string) reconnectKey {
+	key := reconnectKey{deviceID: deviceID}
+	if token != "" {
+		key.token = tokenDigest(token)
+	}
+	return key
+}
+
+// reconnectTracker counts connection attempts per device and token to
+// catch clients stuck in a connect/fail/disconnect loop
+type reconnectTracker struct {
+	mu      sync.Mutex
+	devices map[reconnectKey]*reconnectState
+}
+
+// admit records a connection attempt and returns how long the device must
+// wait before it may connect again, or zero if it is admitted. flagged is
+// true when this attempt started a new backoff.
+func (t *reconnectTracker) admit(key reconnectKey, limit int, window time.Duration) (wait time.Duration, flagged bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.devices == nil {
+		t.devices = make(map[reconnectKey]*reconnectState)
+	}
+	st, ok := t.devices[key]
+	if !ok {
+		st = &reconnectState{}
+		t.devices[key] = st
+	}
+
+	now := time.Now()
+	if now.Before(st.blockedUntil) {
+		return st.blockedUntil.Sub(now), false
+	}
+
+	recent := st.attempts[:0]
+	for _, at := range st.attempts {
+		if now.Sub(at) <= window {
+			recent = append(recent, at)
+		}
+	}
+	st.attempts = append(recent, now)
+
+	if len(st.attempts) <= limit {
+		return 0, false
+	}
+
+	st.strikes++
+	backoff := reconnectBaseBackoff << (st.strikes - 1)
+	if backoff > reconnectMaxBackoff || backoff <= 0 {
+		backoff = reconnectMaxBackoff
+	}
+	st.blockedUntil = now.Add(backoff)
+	st.attempts = nil
+	return backoff, true
+}
+
+// reset forgets a device after it held a stable session
+func (t *reconnectTracker) reset(key reconnectKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.devices, key)
+}
+
+// prune forgets devices with no attempts within window and no active backoff
+func (t *reconnectTracker) prune(window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for device, st := range t.devices {
+		if now.After(st.blockedUntil) &&
+			(len(st.attempts) == 0 || now.Sub(st.attempts[len(st.attempts)-1]) > window) {
+			delete(t.devices, device)
+		}
+	}
+}
+
+func (t *reconnectTracker) snapshot() ReconnectStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := ReconnectStats{
+		TrackedDevices: len(t.devices),
+		Offenders:      make([]ReconnectOffender, 0),
+	}
+	for key, st := range t.devices {
+		if st.strikes > 0 {
+			stats.Offenders = append(stats.Offenders, ReconnectOffender{
+				DeviceID:     key.deviceID,
+				Strikes:      st.strikes,
+				BlockedUntil: st.blockedUntil,
+			})
+		}
+	}
+	sort.Slice(stats.Offenders, func(i, j int) bool {
+		return stats.Offenders[i].Strikes > stats.Offenders[j].Strikes
+	})
+	return stats
+}
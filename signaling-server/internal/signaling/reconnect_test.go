@@ -0,0 +1,38 @@
+package signaling
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestReconnectLimitOptIn(t *testing.T) {
+	if DefaultSecurityConfig().ReconnectLimit != 0 {
+		t.Fatal("reconnect backoff on by default")
+	}
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	for i := 0; i < 12; i++ {
+		s.mustDial("device_id=phone").Close()
+	}
+}
+
+func TestReconnectLimitPerToken(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	sec.ReconnectLimit = 2
+	sec.MaxConnAttempts = 100
+	sec.TokenChurnThreshold = 0
+	s := newTestServer(t, sec, DefaultHubConfig())
+
+	for i := 0; i < sec.ReconnectLimit; i++ {
+		s.mustDial("is_host=true&token=looping&device_id=phone").Close()
+	}
+	_, resp, err := s.dial("is_host=true&token=looping&device_id=phone")
+	if err == nil || resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("looping device: %v, want status %d", err, http.StatusTooManyRequests)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Fatal("backoff has no Retry-After")
+	}
+
+	// Someone else claiming the same device_id isn't locked out with it
+	s.mustDial("is_host=true&token=other&device_id=phone")
+}
@@ -0,0 +1,163 @@
+package signaling
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// connSession is one WebSocket connection bound to a Peer. A peer that
+// resumes after a dropped connection gets a new session but keeps its ID,
+// room membership and Send buffer.
+type connSession struct {
+	peer *Peer
+	conn *websocket.Conn
+
+	stop       chan struct{} // Closed when the hub detaches the peer from this connection
+	writerDone chan struct{} // Closed when writePump has exited
+	expiresAt  time.Time     // Forced close after MaxConnectionLifetime; zero if unlimited
+
+	connID string
+	logger *zap.Logger // Tagged with connID
+
+	unregisterOnce sync.Once
+}
+
+func newConnSession(peer *Peer, conn *websocket.Conn, connID string, logger *zap.Logger) *connSession {
+	s := &connSession{
+		peer:       peer,
+		conn:       conn,
+		stop:       make(chan struct{}),
+		writerDone: make(chan struct{}),
+		connID:     connID,
+		logger:     logger,
+	}
+	if lifetime := peer.Hub.security.MaxConnectionLifetime; lifetime > 0 {
+		s.expiresAt = time.Now().Add(lifetime)
+	}
+	return s
+}
+
+// generateResumeToken returns a random token for resuming a peer session
+func generateResumeToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// detachForResume keeps a disconnected peer around for ResumeGrace instead
+// of removing it, so a client reconnecting after a network switch gets the
+// same identity back without peer-left/peer-joined churn. Messages routed
+// to the peer meanwhile stay queued in its Send buffer. It reports whether
+// the peer was detached. Must be called with h.mu held for writing.
+func (h *Hub) detachForResume(peer *Peer) bool {
+	if h.config.ResumeGrace <= 0 || peer.resumeToken == "" || !peer.detachedAt.IsZero() {
+		return false
+	}
+
+	peer.detachedAt = time.Now()
+	close(peer.session.stop)
+	peer.resumeTimer = time.AfterFunc(h.config.ResumeGrace, func() {
+		select {
+		case h.resumeExpired <- peer:
+		case <-h.done:
+		}
+	})
+
+	peer.session.logger.Info("Peer disconnected, holding for resume",
+		zap.String("id", peer.ID),
+		zap.Duration("grace", h.config.ResumeGrace))
+	return true
+}
+
+// expireResume removes a detached peer whose grace window ran out
+func (h *Hub) expireResume(peer *Peer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if peer.detachedAt.IsZero() || time.Since(peer.detachedAt) < h.config.ResumeGrace {
+		return // Resumed in the meantime
+	}
+	h.logger.Info("Resume window expired", zap.String("id", peer.ID))
+	h.removePeerLocked(peer)
+}
+
+// canResume reports whether token belongs to a peer that can be resumed
+func (h *Hub) canResume(token string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.resumable[token]
+	return ok
+}
+
+// resumePeer rebinds the peer holding token to conn. A peer whose old
+// connection the server still considers alive (the client noticed the
+// drop first) is taken over. The old writer is allowed to finish before
+// the new pumps start so no queued message is lost or sent twice.
+func (h *Hub) resumePeer(token string, conn *websocket.Conn, connID string, logger *zap.Logger) (*Peer, bool) {
+	h.mu.Lock()
+	peer, ok := h.resumable[token]
+	if !ok {
+		h.mu.Unlock()
+		return nil, false
+	}
+
+	old := peer.session
+	if peer.detachedAt.IsZero() {
+		close(old.stop)
+	}
+	if peer.resumeTimer != nil {
+		peer.resumeTimer.Stop()
+		peer.resumeTimer = nil
+	}
+	peer.detachedAt = time.Time{}
+	peer.session = newConnSession(peer, conn, connID, logger)
+	peer.connID.Store(connID)
+	peer.Conn = conn
+	peer.ProtocolVersion = negotiatedVersion(conn)
+	sess := peer.session
+	h.mu.Unlock()
+
+	// Stale requests from the old connection's pumps are ignored by
+	// endSession, since the peer no longer points at that session
+	old.conn.Close()
+	<-old.writerDone
+
+	peer.mu.Lock()
+	peer.LastPing = time.Now()
+	peer.mu.Unlock()
+
+	logger.Info("Peer resumed", zap.String("id", peer.ID))
+	h.sendToPeer(peer, &Message{
+		Type:         MsgTypeRegistered,
+		PeerID:       peer.ID,
+		ResumeToken:  token,
+		Capabilities: serverCapabilities(),
+	})
+
+	go peer.writePump(sess)
+	go peer.readPump(sess)
+	return peer, true
+}
+
+// endSession handles a pump reporting its connection as failed, holding
+// the peer for resume when possible. Reports from a session the peer has
+// since moved away from are ignored.
+func (h *Hub) endSession(s *connSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.peer.session != s || h.peers[s.peer.ID] != s.peer {
+		return // Superseded by a resumed session, or already removed
+	}
+	if h.detachForResume(s.peer) {
+		return
+	}
+	h.removePeerLocked(s.peer)
+}
@@ -0,0 +1,141 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// roomSummary is one room as listed by /rooms
+type roomSummary struct {
+	ID         string    `json:"id"`
+	HasHost    bool      `json:"has_host"`
+	NumClients int       `json:"num_clients"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastActive time.Time `json:"last_active"`
+
+	// Peers is only filled in for the admin listing
+	Peers []peerSummary `json:"peers,omitempty"`
+}
+
+// peerSummary is one member of a room as listed by /admin/rooms
+type peerSummary struct {
+	ID             string    `json:"id"`
+	Role           PeerRole  `json:"role"`
+	Name           string    `json:"name,omitempty"`
+	ConnectedSince time.Time `json:"connected_since"`
+	LastPingAge    float64   `json:"last_ping_age_seconds"`
+	BytesSent      uint64    `json:"bytes_sent"`
+	BytesReceived  uint64    `json:"bytes_received"`
+}
+
+// summarizePeer must be called with h.mu held; it takes peer.mu
+func summarizePeer(peer *Peer, now time.Time) peerSummary {
+	peer.mu.Lock()
+	lastPing := peer.LastPing
+	peer.mu.Unlock()
+
+	return peerSummary{
+		ID:             peer.ID,
+		Role:           peer.Role,
+		Name:           peer.Name,
+		ConnectedSince: peer.connectedAt,
+		LastPingAge:    now.Sub(lastPing).Seconds(),
+		BytesSent:      peer.bytesSent.Load(),
+		BytesReceived:  peer.bytesReceived.Load(),
+	}
+}
+
+// roomInfoSnapshot holds the encoded /rooms response and the admin
+// listing with members. It is never modified after being published.
+type roomInfoSnapshot struct {
+	body      []byte
+	adminBody []byte
+	builtAt   time.Time
+}
+
+// buildRoomInfo encodes the current rooms under the hub read lock
+func (h *Hub) buildRoomInfo() *roomInfoSnapshot {
+	now := time.Now()
+	h.mu.RLock()
+	rooms := make([]roomSummary, 0, len(h.rooms))
+	for _, room := range h.rooms {
+		room.mu.RLock()
+		summary := roomSummary{
+			ID:         room.ID,
+			HasHost:    room.Host != nil,
+			NumClients: len(room.Clients),
+			CreatedAt:  room.CreatedAt,
+			LastActive: room.LastActive,
+			Peers:      make([]peerSummary, 0, len(room.Clients)+1+len(room.extraHosts)),
+		}
+		for _, host := range room.hosts() {
+			summary.Peers = append(summary.Peers, summarizePeer(host, now))
+		}
+		for _, client := range room.Clients {
+			summary.Peers = append(summary.Peers, summarizePeer(client, now))
+		}
+		room.mu.RUnlock()
+		rooms = append(rooms, summary)
+	}
+	h.mu.RUnlock()
+
+	adminBody, _ := json.Marshal(rooms)
+	for i := range rooms {
+		rooms[i].Peers = nil
+	}
+	body, _ := json.Marshal(rooms)
+	return &roomInfoSnapshot{
+		body:      append(body, '\n'),
+		adminBody: append(adminBody, '\n'),
+		builtAt:   time.Now(),
+	}
+}
+
+// refreshRoomInfo rebuilds the /rooms snapshot every RoomInfoRefresh, so
+// the hub locks are taken once per interval however often clients poll
+func (h *Hub) refreshRoomInfo() {
+	ticker := time.NewTicker(h.config.RoomInfoRefresh)
+	defer ticker.Stop()
+
+	h.roomInfo.Store(h.buildRoomInfo())
+	for {
+		select {
+		case <-ticker.C:
+			h.roomInfo.Store(h.buildRoomInfo())
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// HandleRoomInfo lists the active rooms with their member counts. With
+// RoomInfoRefresh set the response comes from the latest snapshot and may
+// be that much out of date.
+func (h *Hub) HandleRoomInfo(w http.ResponseWriter, r *http.Request) {
+	snap := h.currentRoomInfo()
+	writeRoomInfo(w, snap, snap.body)
+}
+
+// AdminRoomInfoHandler lists the active rooms like HandleRoomInfo, plus
+// each member's ID, name and traffic. Mount it behind the admin
+// credential: any signaling token holder could otherwise see who is in
+// every room.
+func (h *Hub) AdminRoomInfoHandler(w http.ResponseWriter, r *http.Request) {
+	snap := h.currentRoomInfo()
+	writeRoomInfo(w, snap, snap.adminBody)
+}
+
+func (h *Hub) currentRoomInfo() *roomInfoSnapshot {
+	snap := h.roomInfo.Load()
+	if snap == nil || h.config.RoomInfoRefresh <= 0 {
+		snap = h.buildRoomInfo()
+	}
+	return snap
+}
+
+func writeRoomInfo(w http.ResponseWriter, snap *roomInfoSnapshot, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Last-Modified", snap.builtAt.UTC().Format(http.TimeFormat))
+	w.Write(body)
+}
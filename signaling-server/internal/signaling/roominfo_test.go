@@ -0,0 +1,113 @@
+package signaling
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// benchHub returns a hub with rooms rooms of clients clients each, not
+// running, for driving handlers directly
+func benchHub(cfg HubConfig, rooms, clients int) *Hub {
+	h := NewHubWithConfig(zap.NewNop(), time.Minute, DefaultSecurityConfig(), cfg)
+	now := time.Now()
+	for r := 0; r < rooms; r++ {
+		room := &Room{ID: fmt.Sprintf("room-%d", r), Clients: map[string]*Peer{}, CreatedAt: now, LastActive: now}
+		room.Host = &Peer{ID: fmt.Sprintf("host-%d", r), Role: RoleHost, Room: room.ID, connectedAt: now, LastPing: now}
+		for c := 0; c < clients; c++ {
+			p := &Peer{ID: fmt.Sprintf("client-%d-%d", r, c), Role: RoleClient, Room: room.ID, connectedAt: now, LastPing: now}
+			room.Clients[p.ID] = p
+		}
+		h.rooms[room.ID] = room
+	}
+	return h
+}
+
+// BenchmarkHandleRoomInfo polls /rooms from every CPU while a writer
+// stands in for the hub loop, taking h.mu as registrations do. The
+// hub-writes/ms metric is how much hub work got through meanwhile.
+func BenchmarkHandleRoomInfo(b *testing.B) {
+	for _, mode := range []struct {
+		name    string
+		refresh time.Duration
+	}{
+		{"live", 0},
+		{"snapshot", time.Second},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			cfg := DefaultHubConfig()
+			cfg.RoomInfoRefresh = mode.refresh
+			h := benchHub(cfg, 100, 8)
+			if mode.refresh > 0 {
+				h.roomInfo.Store(h.buildRoomInfo())
+			}
+
+			var writes atomic.Int64
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					h.mu.Lock()
+					h.mu.Unlock()
+					writes.Add(1)
+				}
+			}()
+
+			req := httptest.NewRequest("GET", "/rooms", nil)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					h.HandleRoomInfo(httptest.NewRecorder(), req)
+				}
+			})
+			b.StopTimer()
+			close(stop)
+			<-done
+			b.ReportMetric(float64(writes.Load())/float64(b.Elapsed().Milliseconds()+1), "hub-writes/ms")
+		})
+	}
+}
+
+func TestRoomInfoSnapshot(t *testing.T) {
+	cfg := DefaultHubConfig()
+	cfg.RoomInfoRefresh = time.Hour
+	h := benchHub(cfg, 1, 2)
+	h.roomInfo.Store(h.buildRoomInfo())
+	delete(h.rooms, "room-0")
+
+	w := httptest.NewRecorder()
+	h.HandleRoomInfo(w, httptest.NewRequest("GET", "/rooms", nil))
+	if body := w.Body.String(); !strings.Contains(body, `"id":"room-0"`) || !strings.Contains(body, `"num_clients":2`) {
+		t.Fatalf("snapshot body %s", body)
+	}
+	if w.Header().Get("Last-Modified") == "" {
+		t.Fatal("no Last-Modified")
+	}
+}
+
+func TestRoomInfoHidesMembers(t *testing.T) {
+	h := benchHub(DefaultHubConfig(), 1, 1)
+
+	w := httptest.NewRecorder()
+	h.HandleRoomInfo(w, httptest.NewRequest("GET", "/rooms", nil))
+	if body := w.Body.String(); strings.Contains(body, "client-0-0") || strings.Contains(body, `"peers"`) {
+		t.Fatalf("/rooms lists members: %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	h.AdminRoomInfoHandler(w, httptest.NewRequest("GET", "/admin/rooms", nil))
+	if body := w.Body.String(); !strings.Contains(body, `"id":"client-0-0"`) || !strings.Contains(body, `"id":"host-0"`) {
+		t.Fatalf("admin listing missing members: %s", body)
+	}
+}
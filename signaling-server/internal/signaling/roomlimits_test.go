@@ -0,0 +1,83 @@
+package signaling
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// joinDirect adds a client peer to h and drives handleJoin for it,
+// returning the error code sent back, if any
+func joinDirect(t *testing.T, h *Hub, peerID, roomID string) (ErrorCode, int) {
+	t.Helper()
+	peer := &Peer{ID: peerID, Hub: h, Role: RoleClient, Send: make(chan []byte, 16)}
+	h.mu.Lock()
+	h.peers[peerID] = peer
+	h.handleJoin(&Message{Type: MsgTypeJoin, From: peerID, Room: roomID}, passwordCheck{})
+	h.mu.Unlock()
+
+	for {
+		select {
+		case data := <-peer.Send:
+			var msg Message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.Type != MsgTypeError {
+				continue
+			}
+			var e errorPayload
+			if err := json.Unmarshal(msg.Payload, &e); err != nil {
+				t.Fatal(err)
+			}
+			return e.Code, e.Max
+		default:
+			return "", 0
+		}
+	}
+}
+
+func TestMaxClientsPerRoom(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	sec.MaxClientsPerRoom = 2
+	h := NewHubWithSecurity(zap.NewNop(), 0, sec)
+
+	for _, id := range []string{"a", "b"} {
+		if code, _ := joinDirect(t, h, id, "r"); code != "" {
+			t.Fatalf("client %s: error %s", id, code)
+		}
+	}
+	code, max := joinDirect(t, h, "c", "r")
+	if code != CodeRoomFull || max != 2 {
+		t.Fatalf("third client: error %q max %d, want %s max 2", code, max, CodeRoomFull)
+	}
+	if _, in := h.rooms["r"].Clients["c"]; in {
+		t.Fatal("rejected client added to the room")
+	}
+	// No room is full for a member rejoining it
+	if code, _ := joinDirect(t, h, "a", "r"); code != "" {
+		t.Fatalf("rejoin: error %s", code)
+	}
+}
+
+func TestMaxRooms(t *testing.T) {
+	sec := DefaultSecurityConfig()
+	sec.MaxRooms = 1
+	h := NewHubWithSecurity(zap.NewNop(), 0, sec)
+
+	if code, _ := joinDirect(t, h, "a", "r1"); code != "" {
+		t.Fatalf("first room: error %s", code)
+	}
+	code, max := joinDirect(t, h, "b", "r2")
+	if code != CodeTooManyRooms || max != 1 {
+		t.Fatalf("second room: error %q max %d, want %s max 1", code, max, CodeTooManyRooms)
+	}
+	if _, ok := h.rooms["r2"]; ok {
+		t.Fatal("room created past the limit")
+	}
+	// Existing rooms still take members
+	if code, _ := joinDirect(t, h, "c", "r1"); code != "" {
+		t.Fatalf("join existing room: error %s", code)
+	}
+}
@@ -0,0 +1,166 @@
+package signaling
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+
+	"go.uber.org/zap"
+)
+
+// roomPasswordIterations is the PBKDF2 work factor, as OWASP recommends
+// for PBKDF2-HMAC-SHA256. A hash takes around a tenth of a second, so it
+// never runs under h.mu: verifyJoinPassword hashes on the joining peer's
+// reader goroutine, and MaxAuthFailures bounds the guesses a connection
+// gets.
+const roomPasswordIterations = 600000
+
+// roomPassword is a salted PBKDF2-HMAC-SHA256 hash of a room password
+type roomPassword struct {
+	salt []byte
+	hash []byte
+}
+
+func newRoomPassword(password string) (*roomPassword, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return &roomPassword{
+		salt: salt,
+		hash: pbkdf2SHA256([]byte(password), salt, roomPasswordIterations),
+	}, nil
+}
+
+func (p *roomPassword) matches(password string) bool {
+	got := pbkdf2SHA256([]byte(password), p.salt, roomPasswordIterations)
+	return subtle.ConstantTimeCompare(got, p.hash) == 1
+}
+
+// pbkdf2SHA256 derives one SHA-256-sized key as in RFC 8018
+func pbkdf2SHA256(password, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	mac.Write(binary.BigEndian.AppendUint32(nil, 1))
+	u := mac.Sum(nil)
+
+	key := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// setRoomPassword protects room with the password from a host's join,
+// hashed by verifyJoinPassword. Must be called with room.mu held for
+// writing.
+func (h *Hub) setRoomPassword(room *Room, host *Peer, pw *roomPassword) {
+	if pw == nil {
+		return // Hashing failed and was logged
+	}
+	room.password = pw
+	h.logger.Info("Room password set", zap.String("room", room.ID), zap.String("host", host.ID))
+}
+
+// passwordCheck is a join's password verified against the room's hash,
+// or hashed for a host to set, before the hub lock is taken
+type passwordCheck struct {
+	against *roomPassword // nil if nothing was checked
+	ok      bool
+	set     *roomPassword // A host's new room password
+}
+
+// verifyJoinPassword checks a client's join password against the room's,
+// if it has one, or hashes the password a host's join sets. Must be
+// called without h.mu held.
+func (h *Hub) verifyJoinPassword(msg *Message) passwordCheck {
+	if msg.Password == "" {
+		return passwordCheck{}
+	}
+	if msg.Role == RoleHost {
+		return h.hashHostPassword(msg)
+	}
+
+	h.mu.RLock()
+	var pw *roomPassword
+	if room, ok := h.rooms[msg.Room]; ok {
+		room.mu.RLock()
+		pw = room.password
+		room.mu.RUnlock()
+	}
+	h.mu.RUnlock()
+
+	if pw == nil {
+		return passwordCheck{}
+	}
+	return passwordCheck{against: pw, ok: pw.matches(msg.Password)}
+}
+
+// hashHostPassword hashes the password of a join claiming the host role,
+// if it comes from a host connection, so anyone else can't make the hub
+// hash on their behalf. Must be called without h.mu held.
+func (h *Hub) hashHostPassword(msg *Message) passwordCheck {
+	h.mu.RLock()
+	peer, ok := h.peers[msg.From]
+	ok = ok && peer.hostConn
+	h.mu.RUnlock()
+	if !ok {
+		return passwordCheck{}
+	}
+
+	pw, err := newRoomPassword(msg.Password)
+	if err != nil {
+		h.logger.Error("Failed to hash room password", zap.Error(err))
+		return passwordCheck{}
+	}
+	return passwordCheck{set: pw}
+}
+
+// checkRoomPassword reports whether a client may join room with password,
+// given the check made before locking, telling it why not otherwise. A
+// client that keeps guessing is disconnected after MaxAuthFailures wrong
+// passwords. Must be called with h.mu held for writing and room.mu not
+// held.
+func (h *Hub) checkRoomPassword(room *Room, peer *Peer, password string, check passwordCheck) bool {
+	room.mu.RLock()
+	pw := room.password
+	room.mu.RUnlock()
+
+	if pw == nil {
+		return true
+	}
+	if password == "" {
+		h.sendError(peer, CodeRoomPassword, "Room password required")
+		return false
+	}
+	if check.against != pw {
+		// The room's password was set or replaced after the check; hashing
+		// here would hold the lock, so have the client retry
+		h.sendError(peer, CodeRoomPassword, "Room password changed, try again")
+		return false
+	}
+	if check.ok {
+		peer.passwordFailures = 0
+		return true
+	}
+
+	peer.passwordFailures++
+	h.logger.Warn("Wrong room password",
+		zap.String("room", room.ID),
+		zap.String("peer", peer.ID),
+		zap.Int("failures", peer.passwordFailures))
+	if max := h.security.MaxAuthFailures; max > 0 && peer.passwordFailures >= max {
+		h.logger.Warn("Too many wrong room passwords, disconnecting", zap.String("peer", peer.ID))
+		h.disconnectWithError(peer, CodeRoomPassword, "Wrong room password")
+		return false
+	}
+	h.sendError(peer, CodeRoomPassword, "Wrong room password")
+	return false
+}
@@ -0,0 +1,70 @@
+package signaling
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914 section 11, first 32 bytes
+	got := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1))
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"
+	if got != want {
+		t.Fatalf("pbkdf2SHA256 = %s, want %s", got, want)
+	}
+}
+
+func TestRoomPassword(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	host, _ := s.host("host-token")
+	host.send(Message{Type: MsgTypeJoin, Room: "r", Role: RoleHost, Password: "hunter2"})
+	host.expect(MsgTypeRoomInfo)
+
+	t.Run("missing", func(t *testing.T) {
+		c, _ := s.client("")
+		c.send(Message{Type: MsgTypeJoin, Room: "r"})
+		if code := c.expectError(); code != CodeRoomPassword {
+			t.Fatalf("got %s, want %s", code, CodeRoomPassword)
+		}
+	})
+
+	t.Run("wrong", func(t *testing.T) {
+		c, _ := s.client("")
+		c.send(Message{Type: MsgTypeJoin, Room: "r", Password: "hunter3"})
+		if code := c.expectError(); code != CodeRoomPassword {
+			t.Fatalf("got %s, want %s", code, CodeRoomPassword)
+		}
+	})
+
+	t.Run("correct", func(t *testing.T) {
+		c, id := s.client("")
+		c.send(Message{Type: MsgTypeJoin, Room: "r", Password: "hunter2"})
+		info := c.expect(MsgTypeRoomInfo)
+		if info.Room != "r" {
+			t.Fatalf("joined %q", info.Room)
+		}
+		if joined := host.expect(MsgTypeJoin); joined.From != id {
+			t.Fatalf("host told about %q, want %q", joined.From, id)
+		}
+	})
+
+	t.Run("claimed host role", func(t *testing.T) {
+		c, _ := s.client("")
+		c.send(Message{Type: MsgTypeJoin, Room: "r", Role: RoleHost})
+		if code := c.expectError(); code != CodeNotHost {
+			t.Fatalf("got %s, want %s", code, CodeNotHost)
+		}
+	})
+
+	t.Run("too many wrong", func(t *testing.T) {
+		c, _ := s.client("")
+		for i := 1; i < s.hub.security.MaxAuthFailures; i++ {
+			c.send(Message{Type: MsgTypeJoin, Room: "r", Password: "guess"})
+			c.expectError()
+		}
+		c.send(Message{Type: MsgTypeJoin, Room: "r", Password: "guess"})
+		if code := c.expectClose(); code != CloseAuthFailed {
+			t.Fatalf("close code %d, want %d", code, CloseAuthFailed)
+		}
+	})
+}
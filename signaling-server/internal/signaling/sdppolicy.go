@@ -0,0 +1,130 @@
+package signaling
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// MediaPolicy restricts the media sections ("m=" lines) an offer may
+// contain. It is only enforced when Allowed or Required is set.
+type MediaPolicy struct {
+	Allowed    []string // Media types an offer may use, e.g. "video", "audio", "application"
+	Required   []string // Media types every offer must contain
+	FailClosed bool     // Reject offers whose SDP can't be parsed instead of passing them through
+}
+
+func (p MediaPolicy) enabled() bool {
+	return len(p.Allowed) > 0 || len(p.Required) > 0
+}
+
+// errUnparsableSDP marks an SDP the policy couldn't inspect
+var errUnparsableSDP = errors.New("unparsable SDP")
+
+// check returns an error describing why sdp violates the policy
+func (p MediaPolicy) check(sdp string) error {
+	media, err := sdpMediaTypes(sdp)
+	if err != nil {
+		if p.FailClosed {
+			return err
+		}
+		return nil
+	}
+
+	present := make(map[string]bool, len(media))
+	for _, m := range media {
+		if len(p.Allowed) > 0 && !containsFold(p.Allowed, m) {
+			return fmt.Errorf("media type %q not allowed", m)
+		}
+		present[m] = true
+	}
+	for _, req := range p.Required {
+		if !present[strings.ToLower(req)] {
+			return fmt.Errorf("media type %q required", req)
+		}
+	}
+	return nil
+}
+
+// sdpMediaTypes returns the lowercased media type of every active m= line.
+// Sections with port 0 have been rejected/disabled and are skipped.
+func sdpMediaTypes(sdp string) ([]string, error) {
+	sdp = strings.TrimSpace(sdp)
+	if !strings.HasPrefix(sdp, "v=") {
+		return nil, errUnparsableSDP
+	}
+
+	var media []string
+	for _, line := range strings.Split(sdp, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasPrefix(line, "m=") {
+			continue
+		}
+		// m=<media> <port>[/<count>] <proto> <fmt> ...
+		fields := strings.Fields(line[2:])
+		if len(fields) < 4 {
+			return nil, errUnparsableSDP
+		}
+		if fields[1] == "0" {
+			continue
+		}
+		media = append(media, strings.ToLower(fields[0]))
+	}
+	return media, nil
+}
+
+// offerSDP extracts the SDP of an offer, from the flat field or from a
+// {"type":"offer","sdp":"..."} payload
+func offerSDP(msg *Message) (string, bool) {
+	if msg.SDP != "" {
+		return msg.SDP, true
+	}
+	var desc struct {
+		SDP string `json:"sdp"`
+	}
+	if len(msg.Payload) > 0 && json.Unmarshal(msg.Payload, &desc) == nil && desc.SDP != "" {
+		return desc.SDP, true
+	}
+	return "", false
+}
+
+// checkMediaPolicy enforces the configured media policy on an offer and
+// reports whether it may be routed
+func (h *Hub) checkMediaPolicy(msg *Message) bool {
+	if msg.Type != MsgTypeOffer || !h.config.MediaPolicy.enabled() {
+		return true
+	}
+
+	sdp, ok := offerSDP(msg)
+	var err error
+	if !ok {
+		if h.config.MediaPolicy.FailClosed {
+			err = errUnparsableSDP
+		}
+	} else {
+		err = h.config.MediaPolicy.check(sdp)
+	}
+	if err == nil {
+		return true
+	}
+
+	h.logger.Warn("Offer rejected by media policy",
+		zap.String("from", msg.From),
+		zap.Error(err))
+	if peer, ok := h.peers[msg.From]; ok {
+		h.sendError(peer, CodeMediaPolicy, "Offer rejected: "+err.Error())
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
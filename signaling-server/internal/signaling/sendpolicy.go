@@ -0,0 +1,80 @@
+package signaling
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// SendPolicy selects what happens to a message for a peer whose send
+// buffer is full
+type SendPolicy string
+
+const (
+	// SendDropNewest drops the message being sent
+	SendDropNewest SendPolicy = "drop-newest"
+	// SendDropOldest discards the oldest queued message to make room, so
+	// the peer sees the latest state, e.g. the end of a candidate burst
+	SendDropOldest SendPolicy = "drop-oldest"
+	// SendDisconnect drops the message and disconnects the peer, which can
+	// reconnect and start over instead of continuing with gaps
+	SendDisconnect SendPolicy = "disconnect"
+)
+
+// sendBufferFull applies the send policy to data, which didn't fit in
+// peer's buffer. Must be called with peer.mu held.
+func (h *Hub) sendBufferFull(peer *Peer, data []byte) {
+	h.metrics.SendBufferDrops.Add(1)
+
+	switch h.config.SendPolicy {
+	case SendDropOldest:
+		select {
+		case <-peer.Send:
+		default:
+		}
+		select {
+		case peer.Send <- data:
+		default:
+		}
+		h.logger.Debug("Peer send buffer full, dropped oldest message", zap.String("peer", peer.ID))
+
+	case SendDisconnect:
+		if peer.slow {
+			return
+		}
+		// sendToPeer is called with h.mu held, so the removal is left to Run
+		select {
+		case h.slowPeers <- peer:
+			peer.slow = true
+			h.logger.Warn("Peer send buffer full, disconnecting", zap.String("peer", peer.ID))
+		default:
+		}
+
+	default:
+		h.logger.Warn("Peer send buffer full", zap.String("peer", peer.ID))
+	}
+}
+
+// disconnectSlowPeer removes a peer that couldn't keep up with its
+// messages. It isn't held for resume, since resuming would hand it the
+// same backlog.
+func (h *Hub) disconnectSlowPeer(peer *Peer) {
+	h.mu.Lock()
+	if h.peers[peer.ID] != peer {
+		h.mu.Unlock()
+		return
+	}
+	s := peer.session
+	h.removePeerLocked(peer)
+	h.mu.Unlock()
+	if s == nil {
+		return
+	}
+
+	conn := s.conn
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(CloseSlowConsumer, "send buffer full, reconnect"),
+		time.Now().Add(time.Second))
+	conn.Close()
+}
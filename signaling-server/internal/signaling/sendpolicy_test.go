@@ -0,0 +1,94 @@
+package signaling
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fullPeer returns a peer of h whose two-slot send buffer holds "1" and "2"
+func fullPeer(h *Hub) *Peer {
+	peer := &Peer{ID: "slow", Hub: h, Send: make(chan []byte, 2)}
+	peer.Send <- []byte("1")
+	peer.Send <- []byte("2")
+	h.peers[peer.ID] = peer
+	return peer
+}
+
+// queued drains peer's buffer, naming raw entries by their content and
+// messages by their ID
+func queued(t *testing.T, peer *Peer) []string {
+	t.Helper()
+	var out []string
+	for {
+		select {
+		case data := <-peer.Send:
+			var msg Message
+			if json.Unmarshal(data, &msg) == nil {
+				out = append(out, msg.MessageID)
+			} else {
+				out = append(out, string(data))
+			}
+		default:
+			return out
+		}
+	}
+}
+
+func TestSendPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy SendPolicy
+		want   []string
+	}{
+		{SendDropNewest, []string{"1", "2"}},
+		{SendDropOldest, []string{"2", "3"}},
+		{SendDisconnect, []string{"1", "2"}},
+	} {
+		cfg := DefaultHubConfig()
+		cfg.SendPolicy = tc.policy
+		h := NewHubWithConfig(zap.NewNop(), time.Minute, DefaultSecurityConfig(), cfg)
+		peer := fullPeer(h)
+
+		h.sendToPeer(peer, &Message{Type: MsgTypePing, MessageID: "3"})
+		if got := queued(t, peer); len(got) != len(tc.want) || got[0] != tc.want[0] || got[1] != tc.want[1] {
+			t.Errorf("%s: buffer %v, want %v", tc.policy, got, tc.want)
+		}
+		if n := h.metrics.SendBufferDrops.Load(); n != 1 {
+			t.Errorf("%s: %d drops counted, want 1", tc.policy, n)
+		}
+
+		select {
+		case slow := <-h.slowPeers:
+			if tc.policy != SendDisconnect {
+				t.Errorf("%s: peer queued for disconnect", tc.policy)
+			} else if slow != peer {
+				t.Errorf("%s: wrong peer queued for disconnect", tc.policy)
+			}
+		default:
+			if tc.policy == SendDisconnect {
+				t.Errorf("%s: peer not queued for disconnect", tc.policy)
+			}
+		}
+	}
+}
+
+func TestDisconnectSlowPeer(t *testing.T) {
+	cfg := DefaultHubConfig()
+	cfg.SendPolicy = SendDisconnect
+	h := NewHubWithConfig(zap.NewNop(), time.Minute, DefaultSecurityConfig(), cfg)
+	peer := fullPeer(h)
+
+	// Further overflow while the removal is pending queues it only once
+	h.sendToPeer(peer, &Message{Type: MsgTypePing})
+	h.sendToPeer(peer, &Message{Type: MsgTypePing})
+	if n := len(h.slowPeers); n != 1 {
+		t.Fatalf("peer queued %d times, want 1", n)
+	}
+
+	h.disconnectSlowPeer(<-h.slowPeers)
+	if _, ok := h.peers[peer.ID]; ok {
+		t.Fatal("slow peer still registered")
+	}
+}
@@ -0,0 +1,57 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Stats is an operational summary of the hub
+type Stats struct {
+	Peers       int             `json:"peers"`
+	Rooms       int             `json:"rooms"`
+	LargestRoom int             `json:"largest_room"` // Members of the biggest room, host included
+	PendingAuth int             `json:"pending_auth"`
+	Load        LoadStatus      `json:"load"`
+	ICE         ICEStats        `json:"ice"`
+	TokenChurn  TokenChurnStats `json:"token_churn"`
+	KeyBundles  KeyBundleStats  `json:"key_bundles"`
+	Reconnects  ReconnectStats  `json:"reconnects"`
+	Quality     QualityStats    `json:"quality"`
+	Timestamp   int64           `json:"timestamp"`
+}
+
+// Stats returns the current hub statistics
+func (h *Hub) Stats() Stats {
+	h.mu.RLock()
+	rooms := len(h.rooms)
+	largest := 0
+	for _, room := range h.rooms {
+		room.mu.RLock()
+		size := len(room.Clients) + len(room.hosts())
+		room.mu.RUnlock()
+		largest = max(largest, size)
+	}
+	h.mu.RUnlock()
+
+	load := h.Load()
+	return Stats{
+		Peers:       load.Peers,
+		Rooms:       rooms,
+		LargestRoom: largest,
+		PendingAuth: h.PendingAuthCount(),
+		Load:        load,
+		ICE:         h.ice.snapshot(h.config.ICE.TURN),
+		TokenChurn:  h.churn.snapshot(h.security.TokenChurnThreshold),
+		KeyBundles:  h.KeyBundleStats(),
+		Reconnects:  h.reconnects.snapshot(),
+		Quality:     h.quality.snapshot(),
+		Timestamp:   time.Now().Unix(),
+	}
+}
+
+// StatsHandler handles HTTP requests for hub statistics
+func (h *Hub) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Stats())
+}
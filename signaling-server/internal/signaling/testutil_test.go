@@ -0,0 +1,187 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// testServer runs a hub behind an httptest server. Connections come from
+// loopback, which the hub trusts; set remote to appear as a LAN client.
+type testServer struct {
+	t      testing.TB
+	hub    *Hub
+	srv    *httptest.Server
+	url    string
+	ws     WebSocketSecurity
+	remote string
+	dialer *websocket.Dialer // websocket.DefaultDialer if nil
+}
+
+func newTestServer(t testing.TB, sec SecurityConfig, cfg HubConfig) *testServer {
+	t.Helper()
+	s := &testServer{
+		t:   t,
+		hub: NewHubWithConfig(zap.NewNop(), time.Minute, sec, cfg),
+		ws:  WebSocketSecurity{DefaultTokenTTL: time.Minute},
+	}
+	go s.hub.Run()
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.remote != "" {
+			r.RemoteAddr = s.remote
+		}
+		HandleWebSocket(s.hub, w, r, zap.NewNop(), s.ws)
+	}))
+	s.url = "ws" + strings.TrimPrefix(s.srv.URL, "http")
+	t.Cleanup(func() {
+		s.hub.Shutdown()
+		s.srv.Close()
+	})
+	return s
+}
+
+// tokenOnlySecurity is the default security config with remote clients
+// admitted on their token alone, without the PIN handshake
+func tokenOnlySecurity() SecurityConfig {
+	sec := DefaultSecurityConfig()
+	sec.RequirePIN = false
+	return sec
+}
+
+// dial opens a WebSocket with the given query string, e.g.
+// "is_host=true&token=t"
+func (s *testServer) dial(query string) (*testConn, *http.Response, error) {
+	url := s.url
+	if query != "" {
+		url += "/?" + query
+	}
+	dialer := s.dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	conn, resp, err := dialer.Dial(url, nil)
+	if err != nil {
+		return nil, resp, err
+	}
+	c := &testConn{t: s.t, Conn: conn}
+	s.t.Cleanup(func() { conn.Close() })
+	return c, resp, nil
+}
+
+func (s *testServer) mustDial(query string) *testConn {
+	s.t.Helper()
+	c, resp, err := s.dial(query)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		s.t.Fatalf("dial %q: %v (status %d)", query, err, status)
+	}
+	return c
+}
+
+// host connects as a host with token and registers, returning the
+// connection and its peer ID
+func (s *testServer) host(token string) (*testConn, string) {
+	s.t.Helper()
+	c := s.mustDial("is_host=true&token=" + token)
+	return c, c.register(RoleHost)
+}
+
+// client connects and registers as a client
+func (s *testServer) client(query string) (*testConn, string) {
+	s.t.Helper()
+	c := s.mustDial(query)
+	return c, c.register(RoleClient)
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type testConn struct {
+	t testing.TB
+	*websocket.Conn
+}
+
+func (c *testConn) send(msg Message) {
+	c.t.Helper()
+	if err := c.WriteJSON(msg); err != nil {
+		c.t.Fatalf("send %s: %v", msg.Type, err)
+	}
+}
+
+func (c *testConn) read() Message {
+	c.t.Helper()
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg Message
+	if err := c.ReadJSON(&msg); err != nil {
+		c.t.Fatalf("read: %v", err)
+	}
+	return msg
+}
+
+// expect reads until a message of type typ, skipping others
+func (c *testConn) expect(typ MessageType) Message {
+	c.t.Helper()
+	for {
+		if msg := c.read(); msg.Type == typ {
+			return msg
+		}
+	}
+}
+
+// expectError reads until an error message and returns its code
+func (c *testConn) expectError() ErrorCode {
+	c.t.Helper()
+	var e errorPayload
+	if err := json.Unmarshal(c.expect(MsgTypeError).Payload, &e); err != nil {
+		c.t.Fatalf("error payload: %v", err)
+	}
+	return e.Code
+}
+
+// expectClose reads until the connection closes and returns the close code
+func (c *testConn) expectClose() int {
+	c.t.Helper()
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	// Don't echo the close frame; the server may already have hung up
+	c.SetCloseHandler(func(int, string) error { return nil })
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			if ce, ok := err.(*websocket.CloseError); ok {
+				return ce.Code
+			}
+			c.t.Fatalf("expected close frame, got %v", err)
+		}
+	}
+}
+
+// register sends a registration and returns the assigned peer ID
+func (c *testConn) register(role PeerRole) string {
+	c.t.Helper()
+	c.send(Message{Type: MsgTypeRegister, Role: role})
+	return c.expect(MsgTypeRegistered).PeerID
+}
+
+// join joins room and waits for the room info
+func (c *testConn) join(room string, role PeerRole) Message {
+	c.t.Helper()
+	c.send(Message{Type: MsgTypeJoin, Room: room, Role: role})
+	return c.expect(MsgTypeRoomInfo)
+}
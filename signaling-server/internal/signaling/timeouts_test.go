@@ -0,0 +1,42 @@
+package signaling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// A WebSocket outlives the server's HTTP timeouts, which only bound
+// ordinary requests
+func TestWebSocketOutlivesServerTimeouts(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	hub := NewHubWithSecurity(zap.NewNop(), time.Minute, DefaultSecurityConfig())
+	go hub.Run()
+	defer hub.Shutdown()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		HandleWebSocket(hub, w, r, zap.NewNop(), WebSocketSecurity{DefaultTokenTTL: time.Minute})
+	}))
+	srv.Config.ReadTimeout = timeout
+	srv.Config.WriteTimeout = timeout
+	srv.Start()
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &testConn{t: t, Conn: conn}
+
+	time.Sleep(3 * timeout)
+	c.register(RoleClient)
+	time.Sleep(3 * timeout)
+	c.send(Message{Type: MsgTypePing})
+	c.expect(MsgTypePong)
+}
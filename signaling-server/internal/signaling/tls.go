@@ -1,6 +1,11 @@
 package signaling
 
-import "crypto/tls"
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
 
 // TLSConfig returns a hardened TLS configuration for the signaling server.
 func TLSConfig() *tls.Config {
@@ -17,3 +22,29 @@ func TLSConfig() *tls.Config {
 		},
 	}
 }
+
+// LoadCertificate loads the server key pair and returns it with the
+// SHA-256 fingerprint of its leaf certificate, formatted like an SDP
+// a=fingerprint value ("AB:CD:..."). Serving this exact certificate from
+// the TLS config keeps the advertised fingerprint honest.
+func LoadCertificate(certFile, keyFile string) (tls.Certificate, string, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	return cert, CertFingerprint(cert), nil
+}
+
+// CertFingerprint returns the uppercase, colon-separated SHA-256 digest of
+// the certificate's leaf
+func CertFingerprint(cert tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(cert.Certificate[0])
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,67 @@
+package signaling
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RegisterTokenForRoom registers a session token that only admits clients
+// to room. Tokens from RegisterToken stay valid for any room.
+func (h *Hub) RegisterTokenForRoom(token, room string, expiry time.Duration) {
+	h.tokenMu.Lock()
+	defer h.tokenMu.Unlock()
+	if h.tokenRevokedLocked(token) {
+		h.logger.Warn("Refusing to register revoked token", zap.String("token_id", tokenID(token)))
+		return
+	}
+	h.validTokens[token] = &tokenEntry{
+		ExpiresAt: time.Now().Add(expiry),
+		Room:      room,
+	}
+	h.tokensChanged()
+	h.logger.Info("Token registered",
+		zap.String("token", tokenPrefix(token)),
+		zap.String("room", room))
+}
+
+// ValidateTokenForRoom checks that token is valid and was issued for
+// roomID, or for no room in particular, and hasn't been revoked
+func (h *Hub) ValidateTokenForRoom(token, roomID string) bool {
+	if h.tokenRevoked(token) {
+		return false
+	}
+	claims, ok := h.tokenValidator().Validate(token)
+	return ok && (claims.Room == "" || claims.Room == roomID)
+}
+
+// scopeHostTokens binds the tokens a host connected with to the room it
+// just joined as host, so they don't admit clients anywhere else
+func (h *Hub) scopeHostTokens(hostPeer, room string) {
+	h.tokenMu.Lock()
+	defer h.tokenMu.Unlock()
+	for _, entry := range h.validTokens {
+		if entry.ownedBy(hostPeer) && entry.Room != room {
+			entry.Room = room
+			h.tokensChanged()
+		}
+	}
+}
+
+// tokenAdmits reports whether a peer's token lets it join roomID as a
+// client. Host connections are held to it too: their token was
+// registered at connect, and one issued for a room doesn't admit them as
+// a client anywhere else.
+// Client connections whose token wasn't checked at connect aren't
+// scoped; localhost and token-less deployments are unaffected. Must be
+// called with h.mu held.
+func (h *Hub) tokenAdmits(peer *Peer, roomID string) bool {
+	if !(peer.tokenChecked || peer.hostConn) || h.ValidateTokenForRoom(peer.token, roomID) {
+		return true
+	}
+	h.logger.Warn("Token not valid for room",
+		zap.String("peer", peer.ID),
+		zap.String("room", roomID),
+		zap.String("token", tokenPrefix(peer.token)))
+	return false
+}
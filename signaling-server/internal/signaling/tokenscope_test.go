@@ -0,0 +1,71 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenScopedToRoom(t *testing.T) {
+	s := newTestServer(t, tokenOnlySecurity(), DefaultHubConfig())
+	s.hub.RegisterTokenForRoom("room-a-token", "a", time.Minute)
+	s.hub.RegisterToken("any-room-token", time.Minute)
+	s.remote = "192.0.2.10:40000"
+
+	c, _ := s.client("token=room-a-token")
+	for _, role := range []PeerRole{RoleClient, ""} {
+		c.send(Message{Type: MsgTypeJoin, Room: "b", Role: role})
+		if code := c.expectError(); code != CodeTokenRoom {
+			t.Fatalf("join other room with role %q: got %s, want %s", role, code, CodeTokenRoom)
+		}
+	}
+	c.send(Message{Type: MsgTypeJoin, Room: "b", Role: RoleHost})
+	if code := c.expectError(); code != CodeNotHost {
+		t.Fatalf("join other room claiming host: got %s, want %s", code, CodeNotHost)
+	}
+	if info := c.join("a", RoleClient); info.Room != "a" {
+		t.Fatalf("joined %q, want a", info.Room)
+	}
+
+	other, _ := s.client("token=any-room-token")
+	if info := other.join("b", RoleClient); info.Room != "b" {
+		t.Fatalf("unscoped token joined %q, want b", info.Room)
+	}
+}
+
+func TestHostTokenScopedOnJoin(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	host, _ := s.host("host-token")
+	host.join("living-room", RoleHost)
+
+	waitFor(t, "host token scoped", func() bool {
+		return s.hub.ValidateTokenForRoom("host-token", "living-room") &&
+			!s.hub.ValidateTokenForRoom("host-token", "kitchen")
+	})
+}
+
+func TestHostConnectionScopedOnClientJoin(t *testing.T) {
+	s := newTestServer(t, tokenOnlySecurity(), DefaultHubConfig())
+	s.hub.RegisterTokenForRoom("room-a-token", "a", time.Minute)
+	s.remote = "192.0.2.10:40000"
+
+	// A host connection doesn't let a room-scoped token join elsewhere
+	// as a client
+	c, _ := s.host("room-a-token")
+	for _, role := range []PeerRole{RoleClient, ""} {
+		c.send(Message{Type: MsgTypeJoin, Room: "b", Role: role})
+		if code := c.expectError(); code != CodeTokenRoom {
+			t.Fatalf("client join of other room on a host connection with role %q: got %s, want %s", role, code, CodeTokenRoom)
+		}
+	}
+	if info := c.join("a", RoleClient); info.Room != "a" {
+		t.Fatalf("joined %q, want a", info.Room)
+	}
+
+	// Nor does a host token once it's scoped to the room it hosts
+	host, _ := s.host("host-token")
+	host.join("living-room", RoleHost)
+	host.send(Message{Type: MsgTypeJoin, Room: "kitchen", Role: RoleClient})
+	if code := host.expectError(); code != CodeTokenRoom {
+		t.Fatalf("host joining another room as a client: got %s, want %s", code, CodeTokenRoom)
+	}
+}
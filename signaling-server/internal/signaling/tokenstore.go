@@ -0,0 +1,145 @@
+package signaling
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TokenStore persists registered tokens so they survive a restart
+type TokenStore interface {
+	Load() ([]StoredToken, error)
+	Save(tokens []StoredToken) error
+}
+
+// StoredToken is a persisted token. The owning host isn't kept: after a
+// restart a host reclaims its token by reconnecting with it.
+type StoredToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Room      string    `json:"room,omitempty"`
+	Minted    bool      `json:"minted,omitempty"`
+}
+
+// FileTokenStore keeps tokens in a JSON file, readable by the owner only
+type FileTokenStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileTokenStore creates a store backed by the file at path
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+// Load reads the stored tokens; a missing file is an empty store
+func (s *FileTokenStore) Load() ([]StoredToken, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tokens []StoredToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Save replaces the stored tokens. The file is written next to the old one
+// and renamed over it, so a crash mid-write leaves the previous state.
+func (s *FileTokenStore) Save(tokens []StoredToken) error {
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// restoreTokens loads persisted tokens into the hub, dropping expired ones
+func (h *Hub) restoreTokens() {
+	tokens, err := h.config.TokenStore.Load()
+	if err != nil {
+		h.logger.Warn("Failed to load token store", zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	restored := 0
+	h.tokenMu.Lock()
+	for _, t := range tokens {
+		if t.Token == "" || now.After(t.ExpiresAt) {
+			continue
+		}
+		h.validTokens[t.Token] = &tokenEntry{
+			ExpiresAt: t.ExpiresAt,
+			Room:      t.Room,
+			Minted:    t.Minted,
+		}
+		restored++
+	}
+	h.tokenMu.Unlock()
+
+	h.logger.Info("Tokens restored",
+		zap.Int("restored", restored),
+		zap.Int("expired", len(tokens)-restored))
+	if restored != len(tokens) {
+		h.tokensChanged()
+	}
+}
+
+// tokensChanged schedules a save of the tokens. It doesn't block, so it
+// can be called with tokenMu held.
+func (h *Hub) tokensChanged() {
+	if h.config.TokenStore == nil {
+		return
+	}
+	select {
+	case h.tokenSave <- struct{}{}:
+	default: // A save is already pending
+	}
+}
+
+// persistTokens saves the tokens whenever they change, coalescing bursts
+func (h *Hub) persistTokens() {
+	for {
+		select {
+		case <-h.tokenSave:
+			h.saveTokens()
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// saveTokens writes the current tokens to the store
+func (h *Hub) saveTokens() {
+	h.tokenMu.RLock()
+	tokens := make([]StoredToken, 0, len(h.validTokens))
+	for token, entry := range h.validTokens {
+		tokens = append(tokens, StoredToken{
+			Token:     token,
+			ExpiresAt: entry.ExpiresAt,
+			Room:      entry.Room,
+			Minted:    entry.Minted,
+		})
+	}
+	h.tokenMu.RUnlock()
+
+	if err := h.config.TokenStore.Save(tokens); err != nil {
+		h.logger.Warn("Failed to save token store", zap.Error(err))
+	}
+}
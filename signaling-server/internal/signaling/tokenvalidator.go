@@ -0,0 +1,82 @@
+package signaling
+
+import "time"
+
+// TokenClaims is what a TokenValidator learned from a valid token
+type TokenClaims struct {
+	Room      string    // Room the token admits clients to; empty for any room
+	Subject   string    // Who the token was issued to, if known
+	ExpiresAt time.Time // Zero if unknown
+}
+
+// TokenValidator decides whether a client token is valid. Validate must
+// be safe for concurrent use.
+type TokenValidator interface {
+	Validate(token string) (TokenClaims, bool)
+}
+
+// hubTokens validates tokens registered with the hub, including ones it
+// restored from its TokenStore, and self-contained pairing tokens
+type hubTokens struct {
+	h *Hub
+}
+
+func (t hubTokens) Validate(token string) (TokenClaims, bool) {
+	h := t.h
+	h.tokenMu.RLock()
+	entry, ok := h.validTokens[token]
+	var claims TokenClaims
+	if ok {
+		claims = TokenClaims{Room: entry.Room, ExpiresAt: entry.ExpiresAt}
+	}
+	h.tokenMu.RUnlock()
+
+	if !ok {
+		// Self-contained pairing tokens aren't stored; they verify by MAC
+		room, valid := h.verifyPairingToken(token)
+		return TokenClaims{Room: room}, valid
+	}
+	return claims, !time.Now().After(claims.ExpiresAt)
+}
+
+// tokenValidators accepts a token as soon as one of its validators does
+type tokenValidators []TokenValidator
+
+// TokenValidators combines validators, trying each in order and using
+// the claims of the first that accepts the token
+func TokenValidators(validators ...TokenValidator) TokenValidator {
+	return tokenValidators(validators)
+}
+
+func (vs tokenValidators) Validate(token string) (TokenClaims, bool) {
+	for _, v := range vs {
+		if claims, ok := v.Validate(token); ok {
+			return claims, true
+		}
+	}
+	return TokenClaims{}, false
+}
+
+// DefaultTokenValidator returns the validator the hub starts with, which
+// only knows the tokens registered with the hub. Combine it with others
+// through TokenValidators to keep host, minted and pairing tokens working.
+func (h *Hub) DefaultTokenValidator() TokenValidator {
+	return hubTokens{h}
+}
+
+// SetTokenValidator replaces how client tokens are checked, both at
+// connect and for REST endpoints; nil restores the default
+func (h *Hub) SetTokenValidator(v TokenValidator) {
+	if v == nil {
+		v = h.DefaultTokenValidator()
+	}
+	h.tokenMu.Lock()
+	defer h.tokenMu.Unlock()
+	h.validator = v
+}
+
+func (h *Hub) tokenValidator() TokenValidator {
+	h.tokenMu.RLock()
+	defer h.tokenMu.RUnlock()
+	return h.validator
+}
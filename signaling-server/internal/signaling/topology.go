@@ -0,0 +1,93 @@
+package signaling
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// TopologyNode is a vertex in the hub topology graph. Peers and rooms are
+// both nodes so that a visualizer can cluster peers around their room.
+type TopologyNode struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"` // "peer" or "room"
+	Role string `json:"role,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// TopologyEdge links a peer to the room it has joined
+type TopologyEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Role   string `json:"role"`
+}
+
+// Topology is a node/edge snapshot of the current hub state
+type Topology struct {
+	Nodes     []TopologyNode `json:"nodes"`
+	Edges     []TopologyEdge `json:"edges"`
+	Timestamp int64          `json:"timestamp"`
+}
+
+func roomNodeID(roomID string) string {
+	return "room:" + roomID
+}
+
+// Topology returns a snapshot of peers, rooms and room membership
+func (h *Hub) Topology() Topology {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	topo := Topology{
+		Nodes:     make([]TopologyNode, 0, len(h.peers)+len(h.rooms)),
+		Edges:     make([]TopologyEdge, 0, len(h.peers)),
+		Timestamp: time.Now().Unix(),
+	}
+
+	for _, peer := range h.peers {
+		topo.Nodes = append(topo.Nodes, TopologyNode{
+			ID:   peer.ID,
+			Kind: "peer",
+			Role: string(peer.Role),
+			Name: peer.Name,
+		})
+	}
+
+	for _, room := range h.rooms {
+		topo.Nodes = append(topo.Nodes, TopologyNode{
+			ID:   roomNodeID(room.ID),
+			Kind: "room",
+			Name: room.ID,
+		})
+
+		room.mu.RLock()
+		for _, host := range room.hosts() {
+			topo.Edges = append(topo.Edges, TopologyEdge{
+				Source: host.ID,
+				Target: roomNodeID(room.ID),
+				Role:   string(RoleHost),
+			})
+		}
+		for id := range room.Clients {
+			topo.Edges = append(topo.Edges, TopologyEdge{
+				Source: id,
+				Target: roomNodeID(room.ID),
+				Role:   string(RoleClient),
+			})
+		}
+		room.mu.RUnlock()
+	}
+
+	return topo
+}
+
+// TopologyHandler handles HTTP requests for the hub topology graph
+func (h *Hub) TopologyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Topology())
+}
@@ -0,0 +1,71 @@
+package signaling
+
+import "testing"
+
+// nextRoomMessage reads past the hub's own notices, such as peer-joined,
+// to the next message a peer sent to the room
+func nextRoomMessage(c *testConn) Message {
+	c.t.Helper()
+	for {
+		msg := c.read()
+		if !knownMessageType(msg.Type) {
+			return msg
+		}
+	}
+}
+
+func TestUnknownTypeRejected(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	host, _ := s.host("token")
+	host.join("r", RoleHost)
+	c, id := s.client("")
+	c.join("r", RoleClient)
+	host.expect(MsgTypeJoin)
+
+	c.send(Message{Type: "bogus", Room: "r"})
+	if code := c.expectError(); code != CodeUnknownType {
+		t.Fatalf("bogus type: got %s, want %s", code, CodeUnknownType)
+	}
+
+	// The next room message the host sees is the app one, not the bogus one
+	c.send(Message{Type: "app:cursor", Room: "r"})
+	if msg := nextRoomMessage(host); msg.Type != "app:cursor" || msg.From != id {
+		t.Fatalf("host got %s from %q, want app:cursor from %q", msg.Type, msg.From, id)
+	}
+}
+
+func TestAppMessageNeedsMembership(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	host, _ := s.host("token")
+	host.join("r", RoleHost)
+	member, id := s.client("")
+	member.join("r", RoleClient)
+	host.expect(MsgTypeJoin)
+
+	outsider, _ := s.client("")
+	outsider.send(Message{Type: "app:cursor", Room: "r"})
+	if code := outsider.expectError(); code != CodeNotInRoom {
+		t.Fatalf("outsider broadcast: got %s, want %s", code, CodeNotInRoom)
+	}
+
+	member.send(Message{Type: "app:quality", Room: "r"})
+	if msg := nextRoomMessage(host); msg.Type != "app:quality" || msg.From != id {
+		t.Fatalf("host got %s from %q, want app:quality from %q", msg.Type, msg.From, id)
+	}
+}
+
+func TestBroadcastUnknownTypesLegacy(t *testing.T) {
+	cfg := DefaultHubConfig()
+	cfg.BroadcastUnknownTypes = true
+	s := newTestServer(t, DefaultSecurityConfig(), cfg)
+	host, _ := s.host("token")
+	host.join("r", RoleHost)
+	c, id := s.client("")
+	c.join("r", RoleClient)
+	host.expect(MsgTypeJoin)
+
+	c.send(Message{Type: "bogus", Room: "r"})
+	if msg := nextRoomMessage(host); msg.Type != "bogus" || msg.From != id {
+		t.Fatalf("host got %s from %q, want bogus from %q", msg.Type, msg.From, id)
+	}
+}
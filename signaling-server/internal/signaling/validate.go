@@ -0,0 +1,124 @@
+package signaling
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// FieldLimits bounds the length of individual Message string fields so a
+// single oversized value can't be fanned out to every peer in a room.
+// A zero limit disables the check for that field.
+type FieldLimits struct {
+	Name      int
+	Room      int
+	Candidate int
+	SDPMid    int
+	PeerID    int // Applies to PeerID and To
+	MessageID int
+	Password  int
+}
+
+// DefaultFieldLimits returns the default field length limits
+func DefaultFieldLimits() FieldLimits {
+	return FieldLimits{
+		Name:      64,
+		Room:      128,
+		Candidate: 1024,
+		SDPMid:    64,
+		PeerID:    128,
+		MessageID: 64,
+		Password:  128,
+	}
+}
+
+// validate checks a message received from a peer before it is routed
+func (m *Message) validate(limits FieldLimits) error {
+	fields := []struct {
+		name  string
+		value string
+		limit int
+	}{
+		{"name", m.Name, limits.Name},
+		{"room", m.Room, limits.Room},
+		{"candidate", m.Candidate, limits.Candidate},
+		{"sdpMid", m.SDPMid, limits.SDPMid},
+		{"peerId", m.PeerID, limits.PeerID},
+		{"to", m.To, limits.PeerID},
+		{"messageId", m.MessageID, limits.MessageID},
+		{"password", m.Password, limits.Password},
+	}
+	for _, f := range fields {
+		if f.limit > 0 && len(f.value) > f.limit {
+			return fmt.Errorf("field %q exceeds %d bytes", f.name, f.limit)
+		}
+	}
+	if m.LANIP != "" && net.ParseIP(m.LANIP) == nil {
+		return errBadLANIP
+	}
+	return m.validateRequired()
+}
+
+var (
+	errSDPRequired      = errors.New("sdp required")
+	errSDPMidRequired   = errors.New("candidate requires sdpMid")
+	errBadSDPMLineIndex = errors.New("sdpMLineIndex must not be negative")
+	errCandidatePayload = errors.New("malformed candidate payload")
+	errEmptyCandidate   = errors.New("batched candidates must not be empty")
+	errBadLANIP         = errors.New("lanIp must be an IP address")
+)
+
+// validateRequired enforces the fields each message type needs, so the
+// receiving peer never gets an offer without SDP or a half candidate.
+// Fields may sit in the payload, as the routing code accepts both forms.
+func (m *Message) validateRequired() error {
+	switch m.Type {
+	case MsgTypeOffer, MsgTypeAnswer:
+		if _, ok := offerSDP(m); !ok {
+			return errSDPRequired
+		}
+
+	case MsgTypeCandidate, MsgTypeIceCandidate:
+		c := candidateEntry{
+			Candidate:     m.Candidate,
+			SDPMid:        m.SDPMid,
+			SDPMLineIndex: m.SDPMLineIndex,
+		}
+		if m.Candidate == "" && len(m.Payload) > 0 {
+			if err := json.Unmarshal(m.Payload, &c); err != nil {
+				return errCandidatePayload
+			}
+		}
+		if c.SDPMLineIndex < 0 {
+			return errBadSDPMLineIndex
+		}
+		// An empty candidate is the end-of-candidates marker
+		if c.Candidate != "" && c.SDPMid == "" {
+			return errSDPMidRequired
+		}
+
+	case MsgTypeCandidates:
+		var entries []candidateEntry
+		if err := json.Unmarshal(m.Payload, &entries); err != nil || len(entries) == 0 {
+			return errCandidatePayload
+		}
+		for _, c := range entries {
+			if c.SDPMLineIndex < 0 {
+				return errBadSDPMLineIndex
+			}
+			if c.Candidate == "" {
+				return errEmptyCandidate
+			}
+			if c.SDPMid == "" {
+				return errSDPMidRequired
+			}
+		}
+
+	case MsgTypeJoin:
+		if m.Room == "" {
+			return errRoomRequired
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package signaling
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFieldLimits(t *testing.T) {
+	limits := DefaultFieldLimits()
+	for _, tc := range []struct {
+		field string
+		limit int
+		set   func(m *Message, v string)
+	}{
+		{"name", limits.Name, func(m *Message, v string) { m.Name = v }},
+		{"room", limits.Room, func(m *Message, v string) { m.Room = v }},
+		{"candidate", limits.Candidate, func(m *Message, v string) { m.Candidate = v }},
+		{"sdpMid", limits.SDPMid, func(m *Message, v string) { m.SDPMid = v }},
+		{"peerId", limits.PeerID, func(m *Message, v string) { m.PeerID = v }},
+		{"to", limits.PeerID, func(m *Message, v string) { m.To = v }},
+		{"messageId", limits.MessageID, func(m *Message, v string) { m.MessageID = v }},
+		{"password", limits.Password, func(m *Message, v string) { m.Password = v }},
+	} {
+		msg := Message{Type: MsgTypePing}
+		tc.set(&msg, strings.Repeat("x", tc.limit))
+		if err := msg.validate(limits); err != nil {
+			t.Errorf("%s at its limit of %d: %v", tc.field, tc.limit, err)
+		}
+
+		tc.set(&msg, strings.Repeat("x", tc.limit+1))
+		err := msg.validate(limits)
+		if err == nil || !strings.Contains(err.Error(), `"`+tc.field+`"`) {
+			t.Errorf("%s over its limit: got %v, want an error naming the field", tc.field, err)
+		}
+		if err := msg.validate(FieldLimits{}); err != nil {
+			t.Errorf("%s with limits disabled: %v", tc.field, err)
+		}
+	}
+}
+
+func TestOversizedFieldRejected(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	c := s.mustDial("")
+	c.send(Message{Type: MsgTypeRegister, Role: RoleClient, Name: strings.Repeat("x", DefaultFieldLimits().Name+1)})
+	if code := c.expectError(); code != CodeInvalidMessage {
+		t.Fatalf("oversized name: error %s, want %s", code, CodeInvalidMessage)
+	}
+}
+
+func TestValidateRequired(t *testing.T) {
+	raw := func(s string) json.RawMessage { return json.RawMessage(s) }
+	for _, tc := range []struct {
+		name string
+		msg  Message
+		want error
+	}{
+		{"offer", Message{Type: MsgTypeOffer, SDP: "v=0"}, nil},
+		{"offer in payload", Message{Type: MsgTypeOffer, Payload: raw(`{"type":"offer","sdp":"v=0"}`)}, nil},
+		{"offer without sdp", Message{Type: MsgTypeOffer}, errSDPRequired},
+		{"offer with empty payload sdp", Message{Type: MsgTypeOffer, Payload: raw(`{"sdp":""}`)}, errSDPRequired},
+		{"answer", Message{Type: MsgTypeAnswer, SDP: "v=0"}, nil},
+		{"answer without sdp", Message{Type: MsgTypeAnswer}, errSDPRequired},
+
+		{"candidate", Message{Type: MsgTypeCandidate, Candidate: "candidate:1", SDPMid: "0"}, nil},
+		{"ice-candidate in payload", Message{Type: MsgTypeIceCandidate, Payload: raw(`{"candidate":"candidate:1","sdpMid":"0","sdpMLineIndex":1}`)}, nil},
+		{"end-of-candidates", Message{Type: MsgTypeCandidate}, nil},
+		{"candidate without sdpMid", Message{Type: MsgTypeCandidate, Candidate: "candidate:1"}, errSDPMidRequired},
+		{"candidate with negative index", Message{Type: MsgTypeCandidate, Candidate: "candidate:1", SDPMid: "0", SDPMLineIndex: -1}, errBadSDPMLineIndex},
+		{"candidate with malformed payload", Message{Type: MsgTypeIceCandidate, Payload: raw(`[1]`)}, errCandidatePayload},
+
+		{"candidates", Message{Type: MsgTypeCandidates, Payload: raw(`[{"candidate":"candidate:1","sdpMid":"0"}]`)}, nil},
+		{"candidates empty", Message{Type: MsgTypeCandidates, Payload: raw(`[]`)}, errCandidatePayload},
+		{"candidates with end marker", Message{Type: MsgTypeCandidates, Payload: raw(`[{"sdpMid":"0"}]`)}, errEmptyCandidate},
+		{"candidates without sdpMid", Message{Type: MsgTypeCandidates, Payload: raw(`[{"candidate":"candidate:1"}]`)}, errSDPMidRequired},
+		{"candidates with negative index", Message{Type: MsgTypeCandidates, Payload: raw(`[{"candidate":"candidate:1","sdpMid":"0","sdpMLineIndex":-2}]`)}, errBadSDPMLineIndex},
+
+		{"join", Message{Type: MsgTypeJoin, Room: "r"}, nil},
+		{"join without room", Message{Type: MsgTypeJoin}, errRoomRequired},
+
+		{"ping", Message{Type: MsgTypePing}, nil},
+		{"bad lanIp", Message{Type: MsgTypeRegister, LANIP: "not-an-ip"}, errBadLANIP},
+	} {
+		if err := tc.msg.validate(DefaultFieldLimits()); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestInvalidMessageNotRouted(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	host, hostID := s.host("token")
+	host.join("r", RoleHost)
+	client, _ := s.client("")
+	client.join("r", RoleClient)
+
+	client.send(Message{Type: MsgTypeOffer, To: hostID})
+	if code := client.expectError(); code != CodeInvalidMessage {
+		t.Fatalf("offer without sdp: error %s, want %s", code, CodeInvalidMessage)
+	}
+	client.send(Message{Type: MsgTypeOffer, To: hostID, SDP: "v=0", MessageID: "good"})
+	if got := host.expect(MsgTypeOffer).MessageID; got != "good" {
+		t.Fatalf("host received offer %q first, want only the valid one", got)
+	}
+}
@@ -0,0 +1,21 @@
+package signaling
+
+// handleWhoAmI tells a peer its own ID, role, name and room, so a client
+// that missed registered or lost track after a resume can recover its
+// state without reconnecting
+func (h *Hub) handleWhoAmI(msg *Message) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	peer, ok := h.peers[msg.From]
+	if !ok {
+		return
+	}
+	h.sendToPeer(peer, &Message{
+		Type:   MsgTypeWhoAmI,
+		PeerID: peer.ID,
+		Role:   peer.Role,
+		Name:   peer.Name,
+		Room:   peer.Room,
+	})
+}
@@ -0,0 +1,24 @@
+package signaling
+
+import "testing"
+
+func TestWhoAmI(t *testing.T) {
+	s := newTestServer(t, DefaultSecurityConfig(), DefaultHubConfig())
+	host, _ := s.host("token")
+	host.join("r", RoleHost)
+
+	c := s.mustDial("")
+	c.send(Message{Type: MsgTypeRegister, Role: RoleClient, Name: "phone"})
+	id := c.expect(MsgTypeRegistered).PeerID
+
+	c.send(Message{Type: MsgTypeWhoAmI})
+	if me := c.expect(MsgTypeWhoAmI); me.PeerID != id || me.Role != RoleClient || me.Name != "phone" || me.Room != "" {
+		t.Fatalf("before joining: %+v", me)
+	}
+
+	c.join("r", RoleClient)
+	c.send(Message{Type: MsgTypeWhoAmI})
+	if me := c.expect(MsgTypeWhoAmI); me.PeerID != id || me.Room != "r" {
+		t.Fatalf("after joining: peer %q room %q, want %q in r", me.PeerID, me.Room, id)
+	}
+}
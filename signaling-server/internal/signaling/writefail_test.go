@@ -0,0 +1,89 @@
+package signaling
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// breakableListener hands out connections whose writes can be made to fail
+type breakableListener struct {
+	net.Listener
+	mu    sync.Mutex
+	conns []*breakableConn
+}
+
+func (l *breakableListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	bc := &breakableConn{Conn: c}
+	l.mu.Lock()
+	l.conns = append(l.conns, bc)
+	l.mu.Unlock()
+	return bc, nil
+}
+
+func (l *breakableListener) conn(i int) *breakableConn {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.conns[i]
+}
+
+type breakableConn struct {
+	net.Conn
+	broken atomic.Bool
+}
+
+func (c *breakableConn) Write(b []byte) (int, error) {
+	if c.broken.Load() {
+		return 0, errors.New("simulated write failure")
+	}
+	return c.Conn.Write(b)
+}
+
+func TestWriteFailureUnregistersPromptly(t *testing.T) {
+	cfg := DefaultHubConfig()
+	cfg.ResumeGrace = 0
+	s := newTestServer(t, DefaultSecurityConfig(), cfg)
+
+	// Swap in a server whose connections can fail writes; reads keep
+	// working, so only the write side notices
+	hub := s.hub
+	ln := &breakableListener{}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		HandleWebSocket(hub, w, r, zap.NewNop(), s.ws)
+	}))
+	ln.Listener = srv.Listener
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+	s.url = "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	host, _ := s.host("token")
+	host.join("r", RoleHost)
+	client, clientID := s.client("")
+	client.join("r", RoleClient)
+	host.expect(MsgTypePeerJoined)
+
+	ln.conn(1).broken.Store(true)
+	start := time.Now()
+	host.send(Message{Type: MsgTypeOffer, To: clientID, SDP: "v=0"})
+
+	left := host.expect(MsgTypePeerLeft)
+	if left.PeerID != clientID {
+		t.Fatalf("peer-left for %q, want %q", left.PeerID, clientID)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("peer removed after %v, want well before the pong timeout", elapsed)
+	}
+}
@@ -0,0 +1,609 @@
+/**
+ * Built-in TURN relay
+ *
+ * A minimal TURN server (RFC 5766) over UDP for when peer-to-peer ICE
+ * fails, typically a phone on a symmetric-NAT cellular network. Only the
+ * UDP allocate/send/data path is implemented: Allocate, Refresh,
+ * CreatePermission and Send/Data indications. ChannelBind is refused,
+ * which makes clients keep using indications. Credentials are the TURN
+ * REST API ones the signaling server already hands out.
+ *
+ * Like coturn's no-loopback-peers and denied-peer-ip, permissions are
+ * refused for addresses that would let a client reach services listening
+ * only on this machine, see peerAllowed.
+ */
+package turn
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLifetime   = 10 * time.Minute
+	maxLifetime       = time.Hour
+	permissionTimeout = 5 * time.Minute
+	nonceValidity     = 10 * time.Minute
+	maxPacketSize     = 1600
+)
+
+// Config holds relay settings
+type Config struct {
+	ListenAddr     string // UDP address clients send TURN requests to, e.g. ":3478"
+	RelayIP        net.IP // Address relayed ports are advertised on
+	PortMin        int    // First port of the relay range
+	PortMax        int    // Last port of the relay range
+	Realm          string
+	Secret         string // TURN REST shared secret, as for an external server
+	MaxAllocations int    // Concurrent allocations (0 = one per relay port)
+
+	// DeniedPeers are further ranges clients may not relay to, e.g. a
+	// management network
+	DeniedPeers []*net.IPNet
+
+	// AllowLoopbackPeers permits loopback peers and the server's own
+	// addresses; only for testing on one machine
+	AllowLoopbackPeers bool
+}
+
+// allocation is one client's relayed transport address
+type allocation struct {
+	client   *net.UDPAddr
+	relay    *net.UDPConn
+	username string
+	txID     [12]byte // Of the Allocate, so retransmits get the same answer
+
+	mu          sync.Mutex
+	expires     time.Time
+	permissions map[string]time.Time // Peer IP -> expiry
+}
+
+func (a *allocation) permitted(ip net.IP, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	exp, ok := a.permissions[ip.String()]
+	return ok && now.Before(exp)
+}
+
+// Relay is a TURN-over-UDP server
+type Relay struct {
+	config Config
+	logger *zap.Logger
+	conn   *net.UDPConn
+
+	nonceKey []byte
+	ownIPs   []net.IP // This machine's interface addresses, denied as peers
+
+	mu          sync.Mutex
+	allocations map[string]*allocation // Client address -> allocation
+	ports       map[int]bool           // Relay ports in use
+	nextPort    int
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewRelay validates config and binds the TURN listener
+func NewRelay(config Config, logger *zap.Logger) (*Relay, error) {
+	if config.Secret == "" {
+		return nil, errors.New("relay requires a shared secret")
+	}
+	if config.RelayIP == nil {
+		return nil, errors.New("relay requires an address to advertise")
+	}
+	if config.PortMin <= 0 || config.PortMax > 65535 || config.PortMin > config.PortMax {
+		return nil, fmt.Errorf("invalid relay port range %d-%d", config.PortMin, config.PortMax)
+	}
+	if config.Realm == "" {
+		config.Realm = "streamlinux"
+	}
+	if config.MaxAllocations <= 0 {
+		config.MaxAllocations = config.PortMax - config.PortMin + 1
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", config.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceKey := make([]byte, 16)
+	if _, err := rand.Read(nonceKey); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	var ownIPs []net.IP
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				ownIPs = append(ownIPs, ipnet.IP)
+			}
+		}
+	}
+
+	return &Relay{
+		config:      config,
+		logger:      logger,
+		conn:        conn,
+		nonceKey:    nonceKey,
+		ownIPs:      ownIPs,
+		allocations: make(map[string]*allocation),
+		ports:       make(map[int]bool),
+		nextPort:    config.PortMin,
+		done:        make(chan struct{}),
+	}, nil
+}
+
+// Port returns the UDP port the relay listens on
+func (r *Relay) Port() int {
+	return r.conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// Start begins serving TURN requests
+func (r *Relay) Start() {
+	r.wg.Add(2)
+	go r.serve()
+	go r.expireLoop()
+
+	r.logger.Info("TURN relay started",
+		zap.Stringer("listen", r.conn.LocalAddr()),
+		zap.Stringer("relay-ip", r.config.RelayIP),
+		zap.String("ports", fmt.Sprintf("%d-%d", r.config.PortMin, r.config.PortMax)))
+}
+
+// Stop closes the listener and every allocation
+func (r *Relay) Stop() {
+	close(r.done)
+	r.conn.Close()
+
+	r.mu.Lock()
+	for key, a := range r.allocations {
+		r.removeLocked(key, a)
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	r.logger.Info("TURN relay stopped")
+}
+
+func (r *Relay) serve() {
+	defer r.wg.Done()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-r.done:
+				return
+			default:
+			}
+			r.logger.Warn("TURN read failed", zap.Error(err))
+			continue
+		}
+		m, err := parseMessage(buf[:n])
+		if err != nil {
+			continue // ChannelData or junk; channels are never bound
+		}
+		r.handle(m, from)
+	}
+}
+
+func (r *Relay) expireLoop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			r.expire(now)
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *Relay) expire(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, a := range r.allocations {
+		a.mu.Lock()
+		expired := now.After(a.expires)
+		for ip, exp := range a.permissions {
+			if now.After(exp) {
+				delete(a.permissions, ip)
+			}
+		}
+		a.mu.Unlock()
+		if expired {
+			r.logger.Debug("TURN allocation expired", zap.Stringer("client", a.client))
+			r.removeLocked(key, a)
+		}
+	}
+}
+
+// removeLocked drops an allocation. Must be called with r.mu held.
+func (r *Relay) removeLocked(key string, a *allocation) {
+	delete(r.allocations, key)
+	delete(r.ports, a.relay.LocalAddr().(*net.UDPAddr).Port)
+	a.relay.Close()
+}
+
+func (r *Relay) handle(m *message, from *net.UDPAddr) {
+	switch {
+	case m.class == classIndication && m.method == methodSend:
+		r.handleSend(m, from)
+		return
+	case m.class != classRequest:
+		return
+	case m.method == methodBinding:
+		resp := newMessage(methodBinding, classSuccess, m.txID)
+		resp.addAddress(attrXORMappedAddress, from)
+		r.write(resp.encode(nil), from)
+		return
+	}
+
+	username, key, code := r.authenticate(m)
+	if code != 0 {
+		resp := newMessage(m.method, classError, m.txID)
+		resp.addError(code)
+		if code == codeUnauthorized || code == codeStaleNonce {
+			resp.addString(attrRealm, r.config.Realm)
+			resp.addString(attrNonce, r.newNonce())
+		}
+		r.write(resp.encode(nil), from)
+		return
+	}
+
+	var resp *message
+	switch m.method {
+	case methodAllocate:
+		resp = r.handleAllocate(m, from, username)
+	case methodRefresh:
+		resp = r.handleRefresh(m, from, username)
+	case methodCreatePermission:
+		resp = r.handleCreatePermission(m, from, username)
+	case methodChannelBind:
+		resp = errorResponse(m, codeBadRequest) // Not supported, see package comment
+	default:
+		resp = newMessage(m.method, classError, m.txID)
+		resp.addError(codeBadRequest)
+	}
+	r.write(resp.encode(key), from)
+}
+
+// authenticate checks the long-term credentials of a request. Usernames
+// are "<expiry unix>:<id>" and passwords base64(hmac-sha1(secret,
+// username)), the TURN REST API scheme.
+func (r *Relay) authenticate(m *message) (string, []byte, int) {
+	if _, ok := m.get(attrMessageIntegrity); !ok {
+		return "", nil, codeUnauthorized
+	}
+	username := m.getString(attrUsername)
+	if username == "" || m.getString(attrRealm) != r.config.Realm {
+		return "", nil, codeBadRequest
+	}
+
+	expiry, _, _ := strings.Cut(username, ":")
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return "", nil, codeUnauthorized
+	}
+
+	mac := hmac.New(sha1.New, []byte(r.config.Secret))
+	mac.Write([]byte(username))
+	password := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	sum := md5.Sum([]byte(username + ":" + r.config.Realm + ":" + password))
+	key := sum[:]
+	if !m.checkIntegrity(key) {
+		return "", nil, codeUnauthorized
+	}
+	if !r.validNonce(m.getString(attrNonce)) {
+		return "", nil, codeStaleNonce
+	}
+	return username, key, 0
+}
+
+// newNonce returns a stateless nonce, "<issued unix>-<mac>"
+func (r *Relay) newNonce() string {
+	issued := strconv.FormatInt(time.Now().Unix(), 10)
+	return issued + "-" + r.nonceMAC(issued)
+}
+
+func (r *Relay) validNonce(nonce string) bool {
+	issued, sig, ok := strings.Cut(nonce, "-")
+	if !ok || !hmac.Equal([]byte(sig), []byte(r.nonceMAC(issued))) {
+		return false
+	}
+	unix, err := strconv.ParseInt(issued, 10, 64)
+	return err == nil && time.Since(time.Unix(unix, 0)) < nonceValidity
+}
+
+func (r *Relay) nonceMAC(issued string) string {
+	mac := hmac.New(sha1.New, r.nonceKey)
+	mac.Write([]byte(issued))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+func requestedLifetime(m *message) time.Duration {
+	v, ok := m.get(attrLifetime)
+	if !ok || len(v) != 4 {
+		return defaultLifetime
+	}
+	return min(time.Duration(binary.BigEndian.Uint32(v))*time.Second, maxLifetime)
+}
+
+func errorResponse(m *message, code int) *message {
+	resp := newMessage(m.method, classError, m.txID)
+	resp.addError(code)
+	return resp
+}
+
+func (r *Relay) allocationFor(from *net.UDPAddr, username string) (*allocation, int) {
+	r.mu.Lock()
+	a, ok := r.allocations[from.String()]
+	r.mu.Unlock()
+	if !ok {
+		return nil, codeAllocationMismatch
+	}
+	if a.username != username {
+		return nil, codeWrongCredentials
+	}
+	return a, 0
+}
+
+func (r *Relay) handleAllocate(m *message, from *net.UDPAddr, username string) *message {
+	transport, ok := m.get(attrRequestedTransport)
+	if !ok || len(transport) != 4 {
+		return errorResponse(m, codeBadRequest)
+	}
+	if transport[0] != protocolUDP {
+		return errorResponse(m, codeUnsupportedProtocol)
+	}
+
+	r.mu.Lock()
+	a, exists := r.allocations[from.String()]
+	if exists {
+		r.mu.Unlock()
+		if a.txID != m.txID {
+			return errorResponse(m, codeAllocationMismatch)
+		}
+		return r.allocateSuccess(m, a, from) // Retransmitted Allocate
+	}
+	if len(r.allocations) >= r.config.MaxAllocations {
+		r.mu.Unlock()
+		return errorResponse(m, codeQuotaReached)
+	}
+	relay := r.bindRelayLocked()
+	if relay == nil {
+		r.mu.Unlock()
+		r.logger.Warn("TURN relay ports exhausted")
+		return errorResponse(m, codeInsufficientCap)
+	}
+	a = &allocation{
+		client:      from,
+		relay:       relay,
+		username:    username,
+		txID:        m.txID,
+		expires:     time.Now().Add(requestedLifetime(m)),
+		permissions: make(map[string]time.Time),
+	}
+	r.allocations[from.String()] = a
+	r.mu.Unlock()
+
+	r.wg.Add(1)
+	go r.relayLoop(a)
+
+	r.logger.Info("TURN allocation created",
+		zap.Stringer("client", from),
+		zap.Stringer("relay", relay.LocalAddr()),
+		zap.String("user", username))
+	return r.allocateSuccess(m, a, from)
+}
+
+func (r *Relay) allocateSuccess(m *message, a *allocation, from *net.UDPAddr) *message {
+	a.mu.Lock()
+	lifetime := time.Until(a.expires)
+	a.mu.Unlock()
+
+	resp := newMessage(methodAllocate, classSuccess, m.txID)
+	resp.addAddress(attrXORRelayedAddress, &net.UDPAddr{
+		IP:   r.config.RelayIP,
+		Port: a.relay.LocalAddr().(*net.UDPAddr).Port,
+	})
+	resp.addUint32(attrLifetime, uint32(lifetime.Seconds()))
+	resp.addAddress(attrXORMappedAddress, from)
+	return resp
+}
+
+// bindRelayLocked binds the next free port of the range. Must be called
+// with r.mu held.
+func (r *Relay) bindRelayLocked() *net.UDPConn {
+	span := r.config.PortMax - r.config.PortMin + 1
+	for i := 0; i < span; i++ {
+		port := r.nextPort
+		r.nextPort++
+		if r.nextPort > r.config.PortMax {
+			r.nextPort = r.config.PortMin
+		}
+		if r.ports[port] {
+			continue
+		}
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+		if err != nil {
+			continue // Taken by another process
+		}
+		r.ports[port] = true
+		return conn
+	}
+	return nil
+}
+
+func (r *Relay) handleRefresh(m *message, from *net.UDPAddr, username string) *message {
+	a, code := r.allocationFor(from, username)
+	if code != 0 {
+		return errorResponse(m, code)
+	}
+
+	lifetime := requestedLifetime(m)
+	if lifetime == 0 {
+		r.mu.Lock()
+		if r.allocations[from.String()] == a {
+			r.removeLocked(from.String(), a)
+		}
+		r.mu.Unlock()
+		r.logger.Info("TURN allocation released", zap.Stringer("client", from))
+	} else {
+		a.mu.Lock()
+		a.expires = time.Now().Add(lifetime)
+		a.mu.Unlock()
+	}
+
+	resp := newMessage(methodRefresh, classSuccess, m.txID)
+	resp.addUint32(attrLifetime, uint32(lifetime.Seconds()))
+	return resp
+}
+
+func (r *Relay) handleCreatePermission(m *message, from *net.UDPAddr, username string) *message {
+	a, code := r.allocationFor(from, username)
+	if code != 0 {
+		return errorResponse(m, code)
+	}
+
+	var peers []net.IP
+	for _, attr := range m.attrs {
+		if attr.typ != attrXORPeerAddress {
+			continue
+		}
+		peer, ok := parseXORAddress(attr.value, m.txID)
+		if !ok {
+			return errorResponse(m, codeBadRequest)
+		}
+		peers = append(peers, peer.IP)
+	}
+	if len(peers) == 0 {
+		return errorResponse(m, codeBadRequest)
+	}
+	for _, ip := range peers {
+		if !r.peerAllowed(ip) {
+			r.logger.Warn("TURN permission to denied peer refused",
+				zap.String("client", from.String()),
+				zap.String("peer", ip.String()))
+			return errorResponse(m, codeForbidden)
+		}
+	}
+
+	expires := time.Now().Add(permissionTimeout)
+	a.mu.Lock()
+	for _, ip := range peers {
+		a.permissions[ip.String()] = expires
+	}
+	a.mu.Unlock()
+
+	return newMessage(methodCreatePermission, classSuccess, m.txID)
+}
+
+// peerAllowed reports whether clients may relay to ip. Loopback,
+// unspecified, multicast and broadcast addresses are refused, and so are
+// this machine's own addresses apart from RelayIP: a host running next to
+// the server sends from that address, and whatever listens on it is
+// reachable from the LAN anyway. Config.DeniedPeers are refused as well.
+func (r *Relay) peerAllowed(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	if ip.IsUnspecified() || ip.IsMulticast() || ip.Equal(net.IPv4bcast) {
+		return false
+	}
+	for _, denied := range r.config.DeniedPeers {
+		if denied.Contains(ip) {
+			return false
+		}
+	}
+	if r.config.AllowLoopbackPeers {
+		return true
+	}
+	if ip.IsLoopback() {
+		return false
+	}
+	if ip.Equal(r.config.RelayIP) {
+		return true
+	}
+	for _, own := range r.ownIPs {
+		if ip.Equal(own) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleSend relays the DATA of a Send indication to its peer. Indications
+// aren't authenticated; the sender is identified by its allocation.
+func (r *Relay) handleSend(m *message, from *net.UDPAddr) {
+	r.mu.Lock()
+	a, ok := r.allocations[from.String()]
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	v, ok := m.get(attrXORPeerAddress)
+	if !ok {
+		return
+	}
+	peer, ok := parseXORAddress(v, m.txID)
+	data, hasData := m.get(attrData)
+	if !ok || !hasData || !a.permitted(peer.IP, time.Now()) {
+		return
+	}
+	a.relay.WriteToUDP(data, peer)
+}
+
+// relayLoop forwards datagrams arriving on an allocation's relay port to
+// its client as Data indications, for peers with a permission
+func (r *Relay) relayLoop(a *allocation) {
+	defer r.wg.Done()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, peer, err := a.relay.ReadFromUDP(buf)
+		if err != nil {
+			return // Closed on expiry, release or Stop
+		}
+		if !a.permitted(peer.IP, time.Now()) {
+			continue
+		}
+
+		var txID [12]byte
+		rand.Read(txID[:])
+		ind := newMessage(methodData, classIndication, txID)
+		ind.addAddress(attrXORPeerAddress, peer)
+		ind.add(attrData, buf[:n])
+		r.write(ind.encode(nil), a.client)
+	}
+}
+
+func (r *Relay) write(b []byte, to *net.UDPAddr) {
+	if _, err := r.conn.WriteToUDP(b, to); err != nil {
+		r.logger.Debug("TURN write failed", zap.Stringer("to", to), zap.Error(err))
+	}
+}
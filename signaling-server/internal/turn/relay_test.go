@@ -0,0 +1,206 @@
+package turn
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestPeerAllowed(t *testing.T) {
+	_, mgmt, _ := net.ParseCIDR("10.99.0.0/16")
+	r := &Relay{
+		config: Config{RelayIP: net.ParseIP("192.168.1.10"), DeniedPeers: []*net.IPNet{mgmt}},
+		ownIPs: []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("192.168.1.10"), net.ParseIP("172.17.0.1")},
+	}
+	for ip, want := range map[string]bool{
+		"192.168.1.20":     true, // LAN host
+		"203.0.113.5":      true,
+		"192.168.1.10":     true, // RelayIP, where a host on this machine sends from
+		"127.0.0.1":        false,
+		"127.0.0.53":       false,
+		"::1":              false,
+		"::ffff:127.0.0.1": false,
+		"0.0.0.0":          false,
+		"::":               false,
+		"224.0.0.251":      false,
+		"ff02::1":          false,
+		"255.255.255.255":  false,
+		"172.17.0.1":       false, // Another of this machine's addresses
+		"10.99.3.4":        false, // DeniedPeers
+	} {
+		if got := r.peerAllowed(net.ParseIP(ip)); got != want {
+			t.Errorf("peerAllowed(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
+
+// turnClient speaks TURN to a relay for tests
+type turnClient struct {
+	t     *testing.T
+	conn  *net.UDPConn
+	user  string
+	key   []byte
+	nonce string
+}
+
+func newTurnClient(t *testing.T, r *Relay, secret string) *turnClient {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: r.Port()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	user := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10) + ":peer"
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(user))
+	password := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	key := md5.Sum([]byte(user + ":" + r.config.Realm + ":" + password))
+	return &turnClient{t: t, conn: conn, user: user, key: key[:]}
+}
+
+func (c *turnClient) request(method uint16, build func(*message)) *message {
+	c.t.Helper()
+	var txID [12]byte
+	rand.Read(txID[:])
+	m := newMessage(method, classRequest, txID)
+	build(m)
+	var key []byte
+	if c.nonce != "" {
+		m.addString(attrUsername, c.user)
+		m.addString(attrRealm, "streamlinux")
+		m.addString(attrNonce, c.nonce)
+		key = c.key
+	}
+	c.conn.Write(m.encode(key))
+	return c.receive()
+}
+
+func (c *turnClient) receive() *message {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, maxPacketSize)
+	n, err := c.conn.Read(buf)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	m, err := parseMessage(buf[:n])
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return m
+}
+
+func errorCode(m *message) int {
+	v, _ := m.get(attrErrorCode)
+	if len(v) < 4 {
+		return 0
+	}
+	return int(v[2])*100 + int(v[3])
+}
+
+func startRelay(t *testing.T, config Config) *Relay {
+	config.ListenAddr = "127.0.0.1:0"
+	config.RelayIP = net.IPv4(127, 0, 0, 1)
+	config.Secret = "secret"
+	config.PortMin, config.PortMax = 50100, 50120
+	r, err := NewRelay(config, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	t.Cleanup(r.Stop)
+	return r
+}
+
+// allocate runs the unauthenticated-then-authenticated Allocate exchange
+// and returns the relayed address
+func (c *turnClient) allocate() *net.UDPAddr {
+	c.t.Helper()
+	transport := func(m *message) { m.add(attrRequestedTransport, []byte{protocolUDP, 0, 0, 0}) }
+	resp := c.request(methodAllocate, transport)
+	if resp.class != classError || errorCode(resp) != codeUnauthorized {
+		c.t.Fatalf("unauthenticated allocate: class %d code %d", resp.class, errorCode(resp))
+	}
+	c.nonce = resp.getString(attrNonce)
+
+	resp = c.request(methodAllocate, transport)
+	if resp.class != classSuccess || !resp.checkIntegrity(c.key) {
+		c.t.Fatalf("allocate: class %d code %d", resp.class, errorCode(resp))
+	}
+	v, _ := resp.get(attrXORRelayedAddress)
+	relayed, ok := parseXORAddress(v, resp.txID)
+	if !ok {
+		c.t.Fatal("no relayed address")
+	}
+	return relayed
+}
+
+func (c *turnClient) permit(peer *net.UDPAddr) *message {
+	return c.request(methodCreatePermission, func(m *message) { m.addAddress(attrXORPeerAddress, peer) })
+}
+
+func TestRelayRefusesLoopbackPeers(t *testing.T) {
+	r := startRelay(t, Config{})
+	c := newTurnClient(t, r, "secret")
+	c.allocate()
+
+	resp := c.permit(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22})
+	if resp.class != classError || errorCode(resp) != codeForbidden {
+		t.Fatalf("permission to loopback: class %d code %d, want %d", resp.class, errorCode(resp), codeForbidden)
+	}
+}
+
+func TestRelayDataPath(t *testing.T) {
+	r := startRelay(t, Config{AllowLoopbackPeers: true})
+	c := newTurnClient(t, r, "secret")
+	relayed := c.allocate()
+
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	peerAddr := peer.LocalAddr().(*net.UDPAddr)
+
+	if resp := c.permit(peerAddr); resp.class != classSuccess {
+		t.Fatalf("create permission: class %d code %d", resp.class, errorCode(resp))
+	}
+
+	// Peer to client arrives as a Data indication
+	peer.WriteToUDP([]byte("hello client"), relayed)
+	ind := c.receive()
+	if data, _ := ind.get(attrData); ind.method != methodData || string(data) != "hello client" {
+		t.Fatalf("data indication: method %#x data %q", ind.method, data)
+	}
+
+	// Client to peer goes out a Send indication, from the relayed address
+	var txID [12]byte
+	send := newMessage(methodSend, classIndication, txID)
+	send.addAddress(attrXORPeerAddress, peerAddr)
+	send.add(attrData, []byte("hello peer"))
+	c.conn.Write(send.encode(nil))
+
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, maxPacketSize)
+	n, from, err := peer.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "hello peer" || from.Port != relayed.Port {
+		t.Fatalf("peer got %q from %s, want from %s", buf[:n], from, relayed)
+	}
+
+	// A bad key is refused
+	c.key = []byte("wrong")
+	if resp := c.permit(peerAddr); errorCode(resp) != codeUnauthorized {
+		t.Fatalf("bad key: code %d, want %d", errorCode(resp), codeUnauthorized)
+	}
+}
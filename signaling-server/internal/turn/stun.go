@@ -0,0 +1,266 @@
+package turn
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"net"
+)
+
+const (
+	magicCookie    = 0x2112A442
+	headerSize     = 20
+	fingerprintXOR = 0x5354554e
+)
+
+// STUN/TURN methods (RFC 5389, RFC 5766)
+const (
+	methodBinding          = 0x001
+	methodAllocate         = 0x003
+	methodRefresh          = 0x004
+	methodSend             = 0x006
+	methodData             = 0x007
+	methodCreatePermission = 0x008
+	methodChannelBind      = 0x009
+)
+
+// Message classes
+const (
+	classRequest    = 0
+	classIndication = 1
+	classSuccess    = 2
+	classError      = 3
+)
+
+// Attributes
+const (
+	attrUsername           = 0x0006
+	attrMessageIntegrity   = 0x0008
+	attrErrorCode          = 0x0009
+	attrLifetime           = 0x000D
+	attrXORPeerAddress     = 0x0012
+	attrData               = 0x0013
+	attrRealm              = 0x0014
+	attrNonce              = 0x0015
+	attrXORRelayedAddress  = 0x0016
+	attrRequestedTransport = 0x0019
+	attrXORMappedAddress   = 0x0020
+	attrFingerprint        = 0x8028
+)
+
+// Error codes used in responses
+const (
+	codeBadRequest          = 400
+	codeUnauthorized        = 401
+	codeForbidden           = 403
+	codeAllocationMismatch  = 437
+	codeStaleNonce          = 438
+	codeWrongCredentials    = 441
+	codeUnsupportedProtocol = 442
+	codeQuotaReached        = 486
+	codeInsufficientCap     = 508
+)
+
+var errorReasons = map[int]string{
+	codeBadRequest:          "Bad Request",
+	codeUnauthorized:        "Unauthorized",
+	codeForbidden:           "Forbidden",
+	codeAllocationMismatch:  "Allocation Mismatch",
+	codeStaleNonce:          "Stale Nonce",
+	codeWrongCredentials:    "Wrong Credentials",
+	codeUnsupportedProtocol: "Unsupported Transport Protocol",
+	codeQuotaReached:        "Allocation Quota Reached",
+	codeInsufficientCap:     "Insufficient Capacity",
+}
+
+const protocolUDP = 17
+
+var errNotSTUN = errors.New("not a STUN message")
+
+type attribute struct {
+	typ    uint16
+	value  []byte
+	offset int // Start of the attribute header in the raw message
+}
+
+// message is a decoded STUN message
+type message struct {
+	method uint16
+	class  uint16
+	txID   [12]byte
+	attrs  []attribute
+	raw    []byte
+}
+
+// parseMessage decodes a STUN message, rejecting anything without the
+// RFC 5389 magic cookie
+func parseMessage(b []byte) (*message, error) {
+	if len(b) < headerSize || b[0]&0xC0 != 0 {
+		return nil, errNotSTUN
+	}
+	typ := binary.BigEndian.Uint16(b[0:2])
+	length := int(binary.BigEndian.Uint16(b[2:4]))
+	if binary.BigEndian.Uint32(b[4:8]) != magicCookie || length%4 != 0 || headerSize+length > len(b) {
+		return nil, errNotSTUN
+	}
+
+	m := &message{
+		method: typ&0x000F | (typ&0x00E0)>>1 | (typ&0x3E00)>>2,
+		class:  (typ&0x0010)>>4 | (typ&0x0100)>>7,
+		raw:    b[:headerSize+length],
+	}
+	copy(m.txID[:], b[8:20])
+
+	for off := headerSize; off+4 <= len(m.raw); {
+		at := binary.BigEndian.Uint16(m.raw[off : off+2])
+		alen := int(binary.BigEndian.Uint16(m.raw[off+2 : off+4]))
+		if off+4+alen > len(m.raw) {
+			return nil, errNotSTUN
+		}
+		m.attrs = append(m.attrs, attribute{typ: at, value: m.raw[off+4 : off+4+alen], offset: off})
+		off += 4 + (alen+3)&^3
+	}
+	return m, nil
+}
+
+func newMessage(method, class uint16, txID [12]byte) *message {
+	return &message{method: method, class: class, txID: txID}
+}
+
+func (m *message) get(typ uint16) ([]byte, bool) {
+	for _, a := range m.attrs {
+		if a.typ == typ {
+			return a.value, true
+		}
+	}
+	return nil, false
+}
+
+func (m *message) getString(typ uint16) string {
+	v, _ := m.get(typ)
+	return string(v)
+}
+
+func (m *message) add(typ uint16, value []byte) {
+	m.attrs = append(m.attrs, attribute{typ: typ, value: value})
+}
+
+func (m *message) addString(typ uint16, s string) {
+	m.add(typ, []byte(s))
+}
+
+func (m *message) addUint32(typ uint16, v uint32) {
+	m.add(typ, binary.BigEndian.AppendUint32(nil, v))
+}
+
+func (m *message) addError(code int) {
+	reason := errorReasons[code]
+	v := []byte{0, 0, byte(code / 100), byte(code % 100)}
+	m.add(attrErrorCode, append(v, reason...))
+}
+
+func (m *message) addAddress(typ uint16, addr *net.UDPAddr) {
+	m.add(typ, xorAddress(addr, m.txID))
+}
+
+// encode serializes m, appending MESSAGE-INTEGRITY when key is set and
+// always a FINGERPRINT
+func (m *message) encode(key []byte) []byte {
+	typ := m.method&0x000F | (m.method&0x0070)<<1 | (m.method&0x0F80)<<2 |
+		(m.class&1)<<4 | (m.class&2)<<7
+
+	b := make([]byte, headerSize, 128)
+	binary.BigEndian.PutUint16(b[0:2], typ)
+	binary.BigEndian.PutUint32(b[4:8], magicCookie)
+	copy(b[8:20], m.txID[:])
+	for _, a := range m.attrs {
+		b = appendAttr(b, a.typ, a.value)
+	}
+
+	if key != nil {
+		binary.BigEndian.PutUint16(b[2:4], uint16(len(b)-headerSize+24))
+		mac := hmac.New(sha1.New, key)
+		mac.Write(b)
+		b = appendAttr(b, attrMessageIntegrity, mac.Sum(nil))
+	}
+
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)-headerSize+8))
+	crc := crc32.ChecksumIEEE(b) ^ fingerprintXOR
+	return appendAttr(b, attrFingerprint, binary.BigEndian.AppendUint32(nil, crc))
+}
+
+func appendAttr(b []byte, typ uint16, value []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// checkIntegrity verifies MESSAGE-INTEGRITY against key. The HMAC covers
+// the message up to the attribute, with the length field rewritten as if
+// it were the last one.
+func (m *message) checkIntegrity(key []byte) bool {
+	for _, a := range m.attrs {
+		if a.typ != attrMessageIntegrity {
+			continue
+		}
+		if len(a.value) != sha1.Size {
+			return false
+		}
+		covered := make([]byte, a.offset)
+		copy(covered, m.raw[:a.offset])
+		binary.BigEndian.PutUint16(covered[2:4], uint16(a.offset-headerSize+24))
+		mac := hmac.New(sha1.New, key)
+		mac.Write(covered)
+		return hmac.Equal(mac.Sum(nil), a.value)
+	}
+	return false
+}
+
+// xorAddress encodes an XOR-*-ADDRESS attribute value
+func xorAddress(addr *net.UDPAddr, txID [12]byte) []byte {
+	ip := addr.IP.To4()
+	family := byte(0x01)
+	if ip == nil {
+		ip, family = addr.IP.To16(), 0x02
+	}
+	v := []byte{0, family, 0, 0}
+	binary.BigEndian.PutUint16(v[2:4], uint16(addr.Port)^uint16(magicCookie>>16))
+
+	mask := binary.BigEndian.AppendUint32(nil, magicCookie)
+	mask = append(mask, txID[:]...)
+	for i, b := range ip {
+		v = append(v, b^mask[i])
+	}
+	return v
+}
+
+// parseXORAddress decodes an XOR-*-ADDRESS attribute value
+func parseXORAddress(v []byte, txID [12]byte) (*net.UDPAddr, bool) {
+	if len(v) < 8 {
+		return nil, false
+	}
+	n := 4
+	if v[1] == 0x02 {
+		n = 16
+	} else if v[1] != 0x01 {
+		return nil, false
+	}
+	if len(v) < 4+n {
+		return nil, false
+	}
+
+	mask := binary.BigEndian.AppendUint32(nil, magicCookie)
+	mask = append(mask, txID[:]...)
+	ip := make(net.IP, n)
+	for i := range ip {
+		ip[i] = v[4+i] ^ mask[i]
+	}
+	port := binary.BigEndian.Uint16(v[2:4]) ^ uint16(magicCookie>>16)
+	return &net.UDPAddr{IP: ip, Port: int(port)}, true
+}
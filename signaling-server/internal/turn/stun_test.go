@@ -0,0 +1,160 @@
+package turn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"net"
+	"testing"
+)
+
+// RFC 5769 section 2.1 sample request
+const (
+	sampleRequest = "000100582112a442b7e7a701bc34d686fa87dfae" +
+		"802200105354554e207465737420636c69656e74" +
+		"002400046e0001ff" +
+		"80290008932ff9b151263b36" +
+		"000600096576746a3a68367659202020" +
+		"000800149aeaa70cbfd8cb56781ef2b5b2d3f249c1b571a2" +
+		"80280004e57a3bcf"
+	samplePassword = "VOkJxbRl1RmTxUk/WvJxBt"
+)
+
+func TestParseSampleRequest(t *testing.T) {
+	raw, _ := hex.DecodeString(sampleRequest)
+	m, err := parseMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.method != methodBinding || m.class != classRequest {
+		t.Fatalf("method %#x class %d, want binding request", m.method, m.class)
+	}
+	if got := m.getString(attrUsername); got != "evtj:h6vY" {
+		t.Fatalf("username %q", got)
+	}
+	if !m.checkIntegrity([]byte(samplePassword)) {
+		t.Fatal("sample MESSAGE-INTEGRITY doesn't verify")
+	}
+	if m.checkIntegrity([]byte("wrong")) {
+		t.Fatal("MESSAGE-INTEGRITY verifies with the wrong key")
+	}
+	fp := crc32.ChecksumIEEE(raw[:len(raw)-8]) ^ fingerprintXOR
+	if fp != binary.BigEndian.Uint32(raw[len(raw)-4:]) {
+		t.Fatal("sample FINGERPRINT doesn't match")
+	}
+}
+
+func TestEncodeSample(t *testing.T) {
+	raw, _ := hex.DecodeString(sampleRequest)
+	m, _ := parseMessage(raw)
+
+	// Re-encoding the attributes before MESSAGE-INTEGRITY gives the same
+	// message up to the padding, which the sample fills with spaces
+	again := newMessage(m.method, m.class, m.txID)
+	for _, a := range m.attrs[:4] {
+		again.add(a.typ, a.value)
+	}
+	got := again.encode([]byte(samplePassword))
+	if len(got) != len(raw) || !bytes.Equal(got[:m.attrs[3].offset+4+9], raw[:m.attrs[3].offset+4+9]) {
+		t.Fatalf("encode =\n%x\nwant\n%x", got, raw)
+	}
+	parsed, err := parseMessage(got)
+	if err != nil || !parsed.checkIntegrity([]byte(samplePassword)) {
+		t.Fatalf("re-encoded sample: err %v, integrity doesn't verify", err)
+	}
+	fp := crc32.ChecksumIEEE(got[:len(got)-8]) ^ fingerprintXOR
+	if fp != binary.BigEndian.Uint32(got[len(got)-4:]) {
+		t.Fatal("re-encoded FINGERPRINT doesn't match")
+	}
+}
+
+func TestEncodeParseRoundTrip(t *testing.T) {
+	var txID [12]byte
+	copy(txID[:], "roundtrip-tx")
+	key := []byte("key")
+
+	m := newMessage(methodAllocate, classError, txID)
+	m.addError(codeStaleNonce)
+	m.addString(attrRealm, "streamlinux")
+	m.addUint32(attrLifetime, 600)
+	m.addString(attrNonce, "odd") // Needs padding
+	raw := m.encode(key)
+
+	got, err := parseMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.method != methodAllocate || got.class != classError || got.txID != txID {
+		t.Fatalf("header: method %#x class %d tx %q", got.method, got.class, got.txID)
+	}
+	if v, _ := got.get(attrErrorCode); len(v) < 4 || int(v[2])*100+int(v[3]) != codeStaleNonce || string(v[4:]) != "Stale Nonce" {
+		t.Fatalf("error code attribute %q", v)
+	}
+	if got.getString(attrNonce) != "odd" || got.getString(attrRealm) != "streamlinux" {
+		t.Fatalf("attributes %q %q", got.getString(attrNonce), got.getString(attrRealm))
+	}
+	if v, _ := got.get(attrLifetime); binary.BigEndian.Uint32(v) != 600 {
+		t.Fatalf("lifetime %x", v)
+	}
+	if !got.checkIntegrity(key) {
+		t.Fatal("round-tripped MESSAGE-INTEGRITY doesn't verify")
+	}
+
+	// Any change to the covered bytes breaks integrity
+	tampered := append([]byte(nil), raw...)
+	tampered[headerSize+8] ^= 1
+	if m, err := parseMessage(tampered); err != nil || m.checkIntegrity(key) {
+		t.Fatalf("tampered message: err %v, integrity still valid", err)
+	}
+
+	// Without a key there is no MESSAGE-INTEGRITY to check
+	plain, _ := parseMessage(m.encode(nil))
+	if plain.checkIntegrity(key) {
+		t.Fatal("message without MESSAGE-INTEGRITY passes the check")
+	}
+}
+
+func TestXORAddressRoundTrip(t *testing.T) {
+	var txID [12]byte
+	copy(txID[:], "0123456789ab")
+	for _, addr := range []*net.UDPAddr{
+		{IP: net.ParseIP("192.0.2.1"), Port: 32853},
+		{IP: net.ParseIP("2001:db8:1234:5678:11:2233:4455:6677"), Port: 32853},
+	} {
+		got, ok := parseXORAddress(xorAddress(addr, txID), txID)
+		if !ok || !got.IP.Equal(addr.IP) || got.Port != addr.Port {
+			t.Errorf("round trip of %s gave %v (ok %v)", addr, got, ok)
+		}
+	}
+
+	// RFC 5769 section 2.2: 192.0.2.1:32853 with the sample transaction
+	raw, _ := hex.DecodeString(sampleRequest)
+	copy(txID[:], raw[8:20])
+	if got := hex.EncodeToString(xorAddress(&net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 32853}, txID)); got != "0001a147e112a643" {
+		t.Fatalf("XOR-MAPPED-ADDRESS = %s", got)
+	}
+}
+
+func TestParseMessageRejects(t *testing.T) {
+	raw, _ := hex.DecodeString(sampleRequest)
+	badCookie := append([]byte(nil), raw...)
+	badCookie[4] ^= 0xff
+	badLength := append([]byte(nil), raw...)
+	binary.BigEndian.PutUint16(badLength[2:4], 0x0fff)
+	badAttr := append([]byte(nil), raw[:headerSize]...)
+	binary.BigEndian.PutUint16(badAttr[2:4], 4)
+	badAttr = append(badAttr, 0x00, 0x06, 0x00, 0x40) // Claims 64 bytes, has none
+
+	for name, b := range map[string][]byte{
+		"short":          raw[:10],
+		"rtp":            append([]byte{0x80}, raw[1:]...),
+		"bad cookie":     badCookie,
+		"bad length":     badLength,
+		"truncated attr": badAttr,
+	} {
+		if _, err := parseMessage(b); err != errNotSTUN {
+			t.Errorf("%s: err %v, want %v", name, err, errNotSTUN)
+		}
+	}
+}